@@ -123,7 +123,12 @@ func runSpec(t *testing.T, filename string, spec *testSpec, bidder adapters.Bidd
 	diffErrorLists(t, fmt.Sprintf("%s: MakeBids", filename), bidsErrs, spec.MakeBidsErrors)
 
 	for i := 0; i < len(spec.BidResponses); i++ {
-		diffBidLists(t, filename, bidResponses[i].Bids, spec.BidResponses[i].Bids)
+		var bids []*adapters.TypedBid
+		// A Bidder returns a nil BidderResponse when there are no bids, for example after a 204.
+		if bidResponses[i] != nil {
+			bids = bidResponses[i].Bids
+		}
+		diffBidLists(t, filename, bids, spec.BidResponses[i].Bids)
 	}
 }
 
@@ -0,0 +1,393 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/golang/glog"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/macros"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// GenericAdapter is the Bidder of every bidder declared in static/bidder-info.
+type GenericAdapter struct {
+	name             openrtb_ext.BidderName
+	endpointTemplate template.Template
+	headerTemplates  map[string]*template.Template
+	params           map[string]Param
+	paramNames       []string
+	bidTypes         []string
+	platformID       string
+	appSecret        string
+}
+
+// headerParams are the values which the header templates may use.
+type headerParams struct {
+	macros.EndpointTemplateParams
+	PlatformID string
+	AppSecret  string
+}
+
+// requestParams are the values which decide how imps are split into requests.
+// Imps are only sent together if their params resolve to the same endpoint and publisher.
+type requestParams struct {
+	endpoint    macros.EndpointTemplateParams
+	publisherID string
+}
+
+type impGroup struct {
+	params requestParams
+	imps   []openrtb.Imp
+}
+
+// NewGenericBidder builds the Bidder of a declared bidder. The templates were already checked by RegisterBidders.
+func NewGenericBidder(name openrtb_ext.BidderName, declaration Declaration, cfg config.Adapter) *GenericAdapter {
+	endpointTemplate, err := template.New(string(name) + "_endpoint").Parse(cfg.Endpoint)
+	if err != nil {
+		glog.Fatalf("Unable to parse the endpoint url template of bidder %s: %v", name, err)
+	}
+
+	headerTemplates := make(map[string]*template.Template, len(declaration.Headers))
+	for header, value := range declaration.Headers {
+		headerTemplate, err := template.New(header).Parse(value)
+		if err != nil {
+			glog.Fatalf("Unable to parse the template of header %s of bidder %s: %v", header, name, err)
+		}
+		headerTemplates[header] = headerTemplate
+	}
+
+	bidTypes := declaration.BidTypes
+	if len(bidTypes) == 0 {
+		bidTypes = defaultBidTypes
+	}
+
+	return &GenericAdapter{
+		name:             name,
+		endpointTemplate: *endpointTemplate,
+		headerTemplates:  headerTemplates,
+		params:           declaration.Params,
+		paramNames:       sortedParams(declaration.Params),
+		bidTypes:         bidTypes,
+		platformID:       cfg.PlatformID,
+		appSecret:        cfg.AppSecret,
+	}
+}
+
+// MakeRequests copies the params of each imp into the fields named by the declaration,
+// and sends one request for each endpoint and publisher which the imps resolve to.
+func (a *GenericAdapter) MakeRequests(request *openrtb.BidRequest, reqInfo *adapters.ExtraRequestInfo) ([]*adapters.RequestData, []error) {
+	var errs []error
+	var groups []*impGroup
+
+	for _, imp := range request.Imp {
+		params, err := a.applyParams(&imp)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		group := findGroup(groups, params)
+		if group == nil {
+			group = &impGroup{params: params}
+			groups = append(groups, group)
+		}
+		group.imps = append(group.imps, imp)
+	}
+
+	requests := make([]*adapters.RequestData, 0, len(groups))
+	for _, group := range groups {
+		requestData, err := a.makeRequest(*request, group)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		requests = append(requests, requestData)
+	}
+	return requests, errs
+}
+
+func findGroup(groups []*impGroup, params requestParams) *impGroup {
+	for _, group := range groups {
+		if group.params == params {
+			return group
+		}
+	}
+	return nil
+}
+
+// applyParams copies the params of the imp into the imp's fields, and returns the ones which apply to the whole request.
+// The imp.ext is replaced by the params mapped into it, since the bidder doesn't know about the Prebid imp.ext.
+func (a *GenericAdapter) applyParams(imp *openrtb.Imp) (requestParams, error) {
+	var params requestParams
+
+	var bidderExt adapters.ExtImpBidder
+	if err := json.Unmarshal(imp.Ext, &bidderExt); err != nil {
+		return params, &errortypes.BadInput{
+			Message: fmt.Sprintf("ext.bidder not provided in imp %s: %v", imp.ID, err),
+		}
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(bidderExt.Bidder, &values); err != nil {
+		return params, &errortypes.BadInput{
+			Message: fmt.Sprintf("The params of imp %s are not an object: %v", imp.ID, err),
+		}
+	}
+
+	impExt := make(map[string]json.RawMessage)
+	for _, name := range a.paramNames {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		field := a.params[name].Field
+		switch {
+		case field == FieldImpBidFloor:
+			if err := json.Unmarshal(value, &imp.BidFloor); err != nil {
+				return params, &errortypes.BadInput{
+					Message: fmt.Sprintf("Param %s of imp %s must be a number: %v", name, imp.ID, err),
+				}
+			}
+		case field == FieldImpTagID:
+			imp.TagID = stringValue(value)
+		case field == FieldImpBidFloorCur:
+			imp.BidFloorCur = stringValue(value)
+		case field == FieldPublisherID:
+			params.publisherID = stringValue(value)
+		case strings.HasPrefix(field, FieldEndpointPrefix):
+			setEndpointMacro(&params.endpoint, strings.TrimPrefix(field, FieldEndpointPrefix), stringValue(value))
+		case strings.HasPrefix(field, FieldImpExtPrefix):
+			impExt[strings.TrimPrefix(field, FieldImpExtPrefix)] = value
+		}
+	}
+
+	imp.Ext = nil
+	if len(impExt) > 0 {
+		impExtJSON, err := json.Marshal(impExt)
+		if err != nil {
+			return params, err
+		}
+		imp.Ext = impExtJSON
+	}
+	return params, nil
+}
+
+// stringValue returns a string param as-is, and the JSON of any other value, so that integer IDs can be copied into string fields.
+func stringValue(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+	return string(bytes.TrimSpace(value))
+}
+
+func setEndpointMacro(endpoint *macros.EndpointTemplateParams, macro string, value string) {
+	switch macro {
+	case "Host":
+		endpoint.Host = value
+	case "PublisherID":
+		endpoint.PublisherID = value
+	case "ZoneID":
+		endpoint.ZoneID = value
+	case "SourceId":
+		endpoint.SourceId = value
+	case "AccountID":
+		endpoint.AccountID = value
+	}
+}
+
+func (a *GenericAdapter) makeRequest(request openrtb.BidRequest, group *impGroup) (*adapters.RequestData, error) {
+	request.Imp = group.imps
+	if group.params.publisherID != "" {
+		setPublisherID(&request, group.params.publisherID)
+	}
+
+	uri, err := macros.ResolveMacros(a.endpointTemplate, group.params.endpoint)
+	if err != nil {
+		return nil, &errortypes.BadInput{
+			Message: fmt.Sprintf("Failed to resolve the endpoint of imp %s: %v", group.imps[0].ID, err),
+		}
+	}
+
+	headers := http.Header{}
+	headers.Add("Content-Type", "application/json;charset=utf-8")
+	headers.Add("Accept", "application/json")
+	headers.Add("X-Openrtb-Version", "2.5")
+	values := headerParams{
+		EndpointTemplateParams: group.params.endpoint,
+		PlatformID:             a.platformID,
+		AppSecret:              a.appSecret,
+	}
+	for header, headerTemplate := range a.headerTemplates {
+		value, err := macros.ResolveMacros(*headerTemplate, values)
+		if err != nil {
+			return nil, &errortypes.BadInput{
+				Message: fmt.Sprintf("Failed to resolve header %s: %v", header, err),
+			}
+		}
+		headers.Set(header, value)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return &adapters.RequestData{
+		Method:  "POST",
+		Uri:     uri,
+		Body:    body,
+		Headers: headers,
+	}, nil
+}
+
+// setPublisherID copies the site or app and its publisher before setting the ID, since they're shared with other bidders.
+func setPublisherID(request *openrtb.BidRequest, publisherID string) {
+	var publisher openrtb.Publisher
+	if request.Site != nil {
+		site := *request.Site
+		if site.Publisher != nil {
+			publisher = *site.Publisher
+		}
+		publisher.ID = publisherID
+		site.Publisher = &publisher
+		request.Site = &site
+	} else if request.App != nil {
+		app := *request.App
+		if app.Publisher != nil {
+			publisher = *app.Publisher
+		}
+		publisher.ID = publisherID
+		app.Publisher = &publisher
+		request.App = &app
+	}
+}
+
+// MakeBids reads a plain OpenRTB 2.5 response, finding out the media type of each bid as declared.
+func (a *GenericAdapter) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	if response.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	if response.StatusCode == http.StatusBadRequest {
+		return nil, []error{&errortypes.BadInput{
+			Message: fmt.Sprintf("Unexpected status code: %d. Run with request.debug = 1 for more info", response.StatusCode),
+		}}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, []error{&errortypes.BadServerResponse{
+			Message: fmt.Sprintf("Unexpected status code: %d. Run with request.debug = 1 for more info", response.StatusCode),
+		}}
+	}
+
+	var bidResp openrtb.BidResponse
+	if err := json.Unmarshal(response.Body, &bidResp); err != nil {
+		return nil, []error{&errortypes.BadServerResponse{
+			Message: fmt.Sprintf("Bad server response: %v", err),
+		}}
+	}
+
+	var errs []error
+	bidResponse := adapters.NewBidderResponseWithBidsCapacity(len(internalRequest.Imp))
+	if bidResp.Cur != "" {
+		bidResponse.Currency = bidResp.Cur
+	}
+	for _, seatBid := range bidResp.SeatBid {
+		for i := range seatBid.Bid {
+			bidType, err := a.findBidType(&seatBid.Bid[i], internalRequest.Imp)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			bidResponse.Bids = append(bidResponse.Bids, &adapters.TypedBid{
+				Bid:     &seatBid.Bid[i],
+				BidType: bidType,
+			})
+		}
+	}
+	return bidResponse, errs
+}
+
+// findBidType tries each of the declared ways to find the media type of the bid, in order.
+func (a *GenericAdapter) findBidType(bid *openrtb.Bid, imps []openrtb.Imp) (openrtb_ext.BidType, error) {
+	for _, source := range a.bidTypes {
+		var bidType openrtb_ext.BidType
+		switch source {
+		case BidTypeFromExt:
+			bidType = bidTypeFromExt(bid)
+		case BidTypeFromImp:
+			bidType = bidTypeFromImp(bid.ImpID, imps)
+		case BidTypeFromMarkup:
+			bidType = bidTypeFromMarkup(bid.AdM)
+		}
+		if bidType != "" {
+			return bidType, nil
+		}
+	}
+	return "", &errortypes.BadServerResponse{
+		Message: fmt.Sprintf("Failed to find the media type of bid %s for imp %s", bid.ID, bid.ImpID),
+	}
+}
+
+func bidTypeFromExt(bid *openrtb.Bid) openrtb_ext.BidType {
+	var bidExt openrtb_ext.ExtBid
+	if err := json.Unmarshal(bid.Ext, &bidExt); err != nil || bidExt.Prebid == nil {
+		return ""
+	}
+	switch bidExt.Prebid.Type {
+	case openrtb_ext.BidTypeBanner, openrtb_ext.BidTypeVideo, openrtb_ext.BidTypeAudio, openrtb_ext.BidTypeNative:
+		return bidExt.Prebid.Type
+	}
+	return ""
+}
+
+// bidTypeFromImp returns the media type of the imp, if it only has one.
+func bidTypeFromImp(impID string, imps []openrtb.Imp) openrtb_ext.BidType {
+	for i := range imps {
+		if imps[i].ID != impID {
+			continue
+		}
+		var bidType openrtb_ext.BidType
+		count := 0
+		if imps[i].Banner != nil {
+			bidType = openrtb_ext.BidTypeBanner
+			count++
+		}
+		if imps[i].Video != nil {
+			bidType = openrtb_ext.BidTypeVideo
+			count++
+		}
+		if imps[i].Audio != nil {
+			bidType = openrtb_ext.BidTypeAudio
+			count++
+		}
+		if imps[i].Native != nil {
+			bidType = openrtb_ext.BidTypeNative
+			count++
+		}
+		if count == 1 {
+			return bidType
+		}
+		return ""
+	}
+	return ""
+}
+
+func bidTypeFromMarkup(adm string) openrtb_ext.BidType {
+	adm = strings.TrimSpace(adm)
+	switch {
+	case adm == "":
+		return ""
+	case strings.HasPrefix(adm, "<VAST"), strings.HasPrefix(adm, "<?xml") && strings.Contains(adm, "<VAST"):
+		return openrtb_ext.BidTypeVideo
+	case strings.HasPrefix(adm, "{"):
+		return openrtb_ext.BidTypeNative
+	}
+	return openrtb_ext.BidTypeBanner
+}
@@ -0,0 +1,32 @@
+package generic
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/adapters/adapterstest"
+	"github.com/prebid/prebid-server/config"
+)
+
+func TestJsonSamples(t *testing.T) {
+	bidder := NewGenericBidder("declared", testDeclaration(), config.Adapter{
+		Endpoint:  "https://{{.Host}}.declared.com/openrtb2",
+		AppSecret: "secret",
+	})
+	adapterstest.RunJSONBidderTest(t, "generictest", bidder)
+}
+
+func testDeclaration() Declaration {
+	return Declaration{
+		Headers: map[string]string{
+			"X-Api-Key": "{{.AppSecret}}",
+		},
+		Params: map[string]Param{
+			"placementId": {Type: ParamString, Required: true, Field: FieldImpTagID},
+			"publisherId": {Type: ParamInteger, Required: true, Field: FieldPublisherID},
+			"region":      {Type: ParamString, Required: true, Field: FieldEndpointPrefix + "Host"},
+			"floor":       {Type: ParamNumber, Field: FieldImpBidFloor},
+			"keywords":    {Type: ParamString, Field: FieldImpExtPrefix + "keywords"},
+		},
+		BidTypes: []string{BidTypeFromExt, BidTypeFromImp, BidTypeFromMarkup},
+	}
+}
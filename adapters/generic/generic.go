@@ -0,0 +1,224 @@
+package generic
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/usersync"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Declaration is the openrtb section of a static/bidder-info/{bidder}.yaml file.
+// It describes how to talk to a bidder which speaks plain OpenRTB 2.5, so that no Go code is needed for it.
+type Declaration struct {
+	// Endpoint is the default of adapters.{bidder}.endpoint. It may use the macros.EndpointTemplateParams.
+	Endpoint string `yaml:"endpoint"`
+	// Headers are added to every request. Their values may use the same macros as the Endpoint,
+	// as well as {{.PlatformID}} and {{.AppSecret}} from the host config.
+	Headers map[string]string `yaml:"headers"`
+	// Params describe request.imp[i].ext.{bidder}, and where each of them is copied in the outgoing request.
+	Params map[string]Param `yaml:"params"`
+	// BidTypes are the ways to find out the media type of a bid, in the order they should be tried.
+	BidTypes []string `yaml:"bidTypes"`
+	// UserSync enables user syncs with the URL from adapters.{bidder}.usersync_url.
+	UserSync *UserSync `yaml:"userSync"`
+}
+
+// Param describes one of the params which publishers send to a declared bidder.
+type Param struct {
+	Type        string `yaml:"type"`
+	Required    bool   `yaml:"required"`
+	Field       string `yaml:"field"`
+	Description string `yaml:"description"`
+}
+
+// UserSync describes how a declared bidder syncs its user IDs.
+type UserSync struct {
+	Type        adapters.SyncType `yaml:"type"`
+	GVLVendorID uint16            `yaml:"gvlVendorId"`
+}
+
+// Types of params
+const (
+	ParamString  = "string"
+	ParamInteger = "integer"
+	ParamNumber  = "number"
+	ParamBoolean = "boolean"
+)
+
+// Fields which params can be copied into
+const (
+	FieldImpTagID       = "imp.tagid"
+	FieldImpBidFloor    = "imp.bidfloor"
+	FieldImpBidFloorCur = "imp.bidfloorcur"
+	FieldImpExtPrefix   = "imp.ext."
+	FieldPublisherID    = "publisher.id"
+	FieldEndpointPrefix = "endpoint."
+)
+
+// Ways to find out the media type of a bid
+const (
+	// BidTypeFromExt reads bid.ext.prebid.type
+	BidTypeFromExt = "ext"
+	// BidTypeFromImp uses the media type of the bid's imp, if it only has one
+	BidTypeFromImp = "imp"
+	// BidTypeFromMarkup looks at the adm: VAST is video, JSON is native and anything else is banner
+	BidTypeFromMarkup = "markup"
+)
+
+var defaultBidTypes = []string{BidTypeFromExt, BidTypeFromImp}
+
+// endpointMacros are the fields of macros.EndpointTemplateParams which params can be copied into.
+var endpointMacros = map[string]bool{
+	"Host":        true,
+	"PublisherID": true,
+	"ZoneID":      true,
+	"SourceId":    true,
+	"AccountID":   true,
+}
+
+// declarations holds every bidder added by RegisterBidders. It must not be mutated afterwards.
+var declarations = make(map[openrtb_ext.BidderName]Declaration)
+
+// declaredInfo holds the parts of a static/bidder-info file which matter to a declared bidder.
+type declaredInfo struct {
+	Capabilities *adapters.CapabilitiesInfo `yaml:"capabilities"`
+	OpenRTB      *Declaration               `yaml:"openrtb"`
+}
+
+// RegisterBidders reads the static/bidder-info files, and registers a bidder for every file with an openrtb section.
+//
+// This must be called on startup before the config is loaded, because the config, the metrics and the
+// endpoints all read the list of bidders from openrtb_ext.BidderMap.
+func RegisterBidders(infoDir string) error {
+	files, err := ioutil.ReadDir(infoDir)
+	if err != nil {
+		return fmt.Errorf("Failed to read the bidder infos from directory %s: %v", infoDir, err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".yaml" {
+			continue
+		}
+		path := filepath.Join(infoDir, file.Name())
+		fileData, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading from file %s: %v", path, err)
+		}
+		var info declaredInfo
+		if err := yaml.Unmarshal(fileData, &info); err != nil {
+			return fmt.Errorf("error parsing yaml in file %s: %v", path, err)
+		}
+		if info.OpenRTB == nil {
+			continue
+		}
+
+		name := strings.TrimSuffix(file.Name(), ".yaml")
+		if err := validateDeclaration(info); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := openrtb_ext.RegisterBidder(name, paramsSchema(name, *info.OpenRTB)); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		declarations[openrtb_ext.BidderName(name)] = *info.OpenRTB
+	}
+	return nil
+}
+
+// Declarations returns the bidders added by RegisterBidders.
+func Declarations() map[openrtb_ext.BidderName]Declaration {
+	return declarations
+}
+
+// SetDefaults sets the default adapters.{bidder}.endpoint of the declared bidders.
+// It must be called after config.SetupViper, so that it replaces the blank default set there.
+func SetDefaults(v *viper.Viper) {
+	for name, declaration := range declarations {
+		v.SetDefault("adapters."+string(name)+".endpoint", declaration.Endpoint)
+	}
+}
+
+func validateDeclaration(info declaredInfo) error {
+	if info.Capabilities == nil || (info.Capabilities.App == nil && info.Capabilities.Site == nil) {
+		return fmt.Errorf("capabilities must support app or site traffic")
+	}
+
+	declaration := info.OpenRTB
+	if _, err := template.New("endpoint").Parse(declaration.Endpoint); err != nil {
+		return fmt.Errorf("openrtb.endpoint is not a valid template: %v", err)
+	}
+	for header, value := range declaration.Headers {
+		if _, err := template.New(header).Parse(value); err != nil {
+			return fmt.Errorf("openrtb.headers.%s is not a valid template: %v", header, err)
+		}
+	}
+	for _, name := range sortedParams(declaration.Params) {
+		if err := validateParam(declaration.Params[name]); err != nil {
+			return fmt.Errorf("openrtb.params.%s %v", name, err)
+		}
+	}
+	for _, bidType := range declaration.BidTypes {
+		if bidType != BidTypeFromExt && bidType != BidTypeFromImp && bidType != BidTypeFromMarkup {
+			return fmt.Errorf("openrtb.bidTypes has %q, which should be one of: %s, %s, %s", bidType, BidTypeFromExt, BidTypeFromImp, BidTypeFromMarkup)
+		}
+	}
+	if declaration.UserSync != nil && declaration.UserSync.Type != adapters.SyncTypeRedirect && declaration.UserSync.Type != adapters.SyncTypeIframe {
+		return fmt.Errorf("openrtb.userSync.type must be %s or %s", adapters.SyncTypeRedirect, adapters.SyncTypeIframe)
+	}
+	return nil
+}
+
+func validateParam(param Param) error {
+	switch param.Type {
+	case ParamString, ParamInteger, ParamNumber, ParamBoolean:
+	default:
+		return fmt.Errorf("has type %q, which should be one of: %s, %s, %s, %s", param.Type, ParamString, ParamInteger, ParamNumber, ParamBoolean)
+	}
+
+	switch {
+	case param.Field == FieldImpBidFloor:
+		if param.Type != ParamNumber && param.Type != ParamInteger {
+			return fmt.Errorf("must be a number or an integer to be copied into %s", param.Field)
+		}
+	case param.Field == FieldImpTagID, param.Field == FieldImpBidFloorCur, param.Field == FieldPublisherID:
+		if param.Type != ParamString && param.Type != ParamInteger {
+			return fmt.Errorf("must be a string or an integer to be copied into %s", param.Field)
+		}
+	case strings.HasPrefix(param.Field, FieldEndpointPrefix):
+		if !endpointMacros[strings.TrimPrefix(param.Field, FieldEndpointPrefix)] {
+			return fmt.Errorf("is copied into %s, but endpoints only have the macros Host, PublisherID, ZoneID, SourceId and AccountID", param.Field)
+		}
+		if param.Type != ParamString && param.Type != ParamInteger {
+			return fmt.Errorf("must be a string or an integer to be copied into %s", param.Field)
+		}
+	case strings.HasPrefix(param.Field, FieldImpExtPrefix):
+		if param.Field == FieldImpExtPrefix {
+			return fmt.Errorf("must name a key of imp.ext")
+		}
+	default:
+		return fmt.Errorf("has field %q, which should be one of: %s, %s, %s, %s, %s{key} or %s{macro}", param.Field, FieldImpTagID, FieldImpBidFloor, FieldImpBidFloorCur, FieldPublisherID, FieldImpExtPrefix, FieldEndpointPrefix)
+	}
+	return nil
+}
+
+// sortedParams returns the names of the params in order, so that the requests and errors don't depend on map iteration.
+func sortedParams(params map[string]Param) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewGenericSyncer returns the syncer of a declared bidder.
+func NewGenericSyncer(name openrtb_ext.BidderName, userSync UserSync, temp *template.Template) usersync.Usersyncer {
+	return adapters.NewSyncer(string(name), userSync.GVLVendorID, temp, userSync.Type)
+}
@@ -0,0 +1,143 @@
+package generic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterBidders(t *testing.T) {
+	defer restoreRegistries()()
+	if !assert.NoError(t, RegisterBidders("testdata/bidder-info")) {
+		return
+	}
+
+	assert.Equal(t, openrtb_ext.BidderName("declaredexample"), openrtb_ext.BidderMap["declaredexample"])
+	declaration, ok := Declarations()["declaredexample"]
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, Param{Type: ParamString, Required: true, Field: FieldImpTagID, Description: "The placement which the imp is sold as"}, declaration.Params["placementId"])
+	assert.Equal(t, &UserSync{Type: adapters.SyncTypeRedirect, GVLVendorID: 42}, declaration.UserSync)
+
+	v := viper.New()
+	v.SetDefault("adapters.declaredexample.endpoint", "")
+	SetDefaults(v)
+	assert.Equal(t, "https://{{.Host}}.declared-example.com/openrtb2", v.GetString("adapters.declaredexample.endpoint"))
+}
+
+func TestParamsSchema(t *testing.T) {
+	schema := paramsSchema("declared", Declaration{
+		Params: map[string]Param{
+			"placementId": {Type: ParamString, Required: true, Field: FieldImpTagID, Description: "The placement"},
+			"floor":       {Type: ParamNumber, Field: FieldImpBidFloor},
+		},
+	})
+
+	assert.JSONEq(t, `{
+		"$schema": "http://json-schema.org/draft-04/schema#",
+		"title": "declared Adapter Params",
+		"description": "A schema which validates params accepted by the declared adapter",
+		"type": "object",
+		"properties": {
+			"floor": {"type": "number"},
+			"placementId": {"type": "string", "description": "The placement"}
+		},
+		"required": ["placementId"]
+	}`, schema)
+}
+
+func TestRegisterBiddersErrors(t *testing.T) {
+	testCases := []struct {
+		description string
+		bidder      string
+		info        string
+		message     string
+	}{
+		{
+			description: "Built-in bidder",
+			bidder:      "appnexus",
+			info:        "capabilities:\n  site:\n    mediaTypes: [banner]\nopenrtb:\n  endpoint: https://example.com\n",
+			message:     "Bidder appnexus is already registered.",
+		},
+		{
+			description: "No capabilities",
+			bidder:      "nocapabilities",
+			info:        "openrtb:\n  endpoint: https://example.com\n",
+			message:     "capabilities must support app or site traffic",
+		},
+		{
+			description: "Unknown param type",
+			bidder:      "badtype",
+			info:        "capabilities:\n  site:\n    mediaTypes: [banner]\nopenrtb:\n  params:\n    placementId:\n      type: array\n      field: imp.tagid\n",
+			message:     `openrtb.params.placementId has type "array", which should be one of: string, integer, number, boolean`,
+		},
+		{
+			description: "Unknown field",
+			bidder:      "badfield",
+			info:        "capabilities:\n  site:\n    mediaTypes: [banner]\nopenrtb:\n  params:\n    placementId:\n      type: string\n      field: imp.banner.id\n",
+			message:     `openrtb.params.placementId has field "imp.banner.id", which should be one of: imp.tagid, imp.bidfloor, imp.bidfloorcur, publisher.id, imp.ext.{key} or endpoint.{macro}`,
+		},
+		{
+			description: "Field of the wrong type",
+			bidder:      "badfloor",
+			info:        "capabilities:\n  site:\n    mediaTypes: [banner]\nopenrtb:\n  params:\n    floor:\n      type: string\n      field: imp.bidfloor\n",
+			message:     "openrtb.params.floor must be a number or an integer to be copied into imp.bidfloor",
+		},
+		{
+			description: "Unknown endpoint macro",
+			bidder:      "badmacro",
+			info:        "capabilities:\n  site:\n    mediaTypes: [banner]\nopenrtb:\n  params:\n    region:\n      type: string\n      field: endpoint.Region\n",
+			message:     "openrtb.params.region is copied into endpoint.Region, but endpoints only have the macros Host, PublisherID, ZoneID, SourceId and AccountID",
+		},
+		{
+			description: "Unknown bid type source",
+			bidder:      "badbidtype",
+			info:        "capabilities:\n  site:\n    mediaTypes: [banner]\nopenrtb:\n  bidTypes: [mtype]\n",
+			message:     `openrtb.bidTypes has "mtype", which should be one of: ext, imp, markup`,
+		},
+		{
+			description: "Unknown sync type",
+			bidder:      "badsync",
+			info:        "capabilities:\n  site:\n    mediaTypes: [banner]\nopenrtb:\n  userSync:\n    type: pixel\n",
+			message:     "openrtb.userSync.type must be redirect or iframe",
+		},
+	}
+
+	defer restoreRegistries()()
+	for _, test := range testCases {
+		dir, err := ioutil.TempDir("", "bidder-info")
+		if !assert.NoError(t, err, test.description) {
+			continue
+		}
+		path := filepath.Join(dir, test.bidder+".yaml")
+		ioutil.WriteFile(path, []byte(test.info), 0644)
+
+		assert.EqualError(t, RegisterBidders(dir), path+": "+test.message, test.description)
+		assert.NotContains(t, Declarations(), openrtb_ext.BidderName(test.bidder), test.description)
+		os.RemoveAll(dir)
+	}
+}
+
+// restoreRegistries saves the bidders registered so far, and returns a func which puts them back.
+// Tests which call RegisterBidders should defer it, so that their bidders don't leak into other tests.
+func restoreRegistries() func() {
+	savedBidderMap := make(map[string]openrtb_ext.BidderName, len(openrtb_ext.BidderMap))
+	for name, bidder := range openrtb_ext.BidderMap {
+		savedBidderMap[name] = bidder
+	}
+	savedDeclarations := make(map[openrtb_ext.BidderName]Declaration, len(declarations))
+	for name, declaration := range declarations {
+		savedDeclarations[name] = declaration
+	}
+	return func() {
+		openrtb_ext.BidderMap = savedBidderMap
+		declarations = savedDeclarations
+	}
+}
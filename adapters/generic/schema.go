@@ -0,0 +1,46 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type jsonSchema struct {
+	Schema      string                        `json:"$schema"`
+	Title       string                        `json:"title"`
+	Description string                        `json:"description"`
+	Type        string                        `json:"type"`
+	Properties  map[string]jsonSchemaProperty `json:"properties"`
+	Required    []string                      `json:"required,omitempty"`
+}
+
+type jsonSchemaProperty struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// paramsSchema builds the JSON schema of request.imp[i].ext.{bidder}, in the same shape as the
+// files in static/bidder-params.
+func paramsSchema(name string, declaration Declaration) string {
+	schema := jsonSchema{
+		Schema:      "http://json-schema.org/draft-04/schema#",
+		Title:       fmt.Sprintf("%s Adapter Params", name),
+		Description: fmt.Sprintf("A schema which validates params accepted by the %s adapter", name),
+		Type:        "object",
+		Properties:  make(map[string]jsonSchemaProperty, len(declaration.Params)),
+	}
+	for _, paramName := range sortedParams(declaration.Params) {
+		param := declaration.Params[paramName]
+		schema.Properties[paramName] = jsonSchemaProperty{
+			Type:        param.Type,
+			Description: param.Description,
+		}
+		if param.Required {
+			schema.Required = append(schema.Required, paramName)
+		}
+	}
+
+	// This can't fail, since the schema only holds strings.
+	schemaJSON, _ := json.MarshalIndent(schema, "", "  ")
+	return string(schemaJSON)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	yaml "gopkg.in/yaml.v2"
@@ -73,19 +74,19 @@ func (i *InfoAwareBidder) pruneImps(imps []openrtb.Imp, allowedTypes parsedSuppo
 	for i := 0; i < len(imps); i++ {
 		if !allowedTypes.banner && imps[i].Banner != nil {
 			imps[i].Banner = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses banner, but this bidder doesn't support it", i)))
+			errs = append(errs, unsupportedMediaType(fmt.Sprintf("request.imp[%d] uses banner, but this bidder doesn't support it", i)))
 		}
 		if !allowedTypes.video && imps[i].Video != nil {
 			imps[i].Video = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses video, but this bidder doesn't support it", i)))
+			errs = append(errs, unsupportedMediaType(fmt.Sprintf("request.imp[%d] uses video, but this bidder doesn't support it", i)))
 		}
 		if !allowedTypes.audio && imps[i].Audio != nil {
 			imps[i].Audio = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses audio, but this bidder doesn't support it", i)))
+			errs = append(errs, unsupportedMediaType(fmt.Sprintf("request.imp[%d] uses audio, but this bidder doesn't support it", i)))
 		}
 		if !allowedTypes.native && imps[i].Native != nil {
 			imps[i].Native = nil
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] uses native, but this bidder doesn't support it", i)))
+			errs = append(errs, unsupportedMediaType(fmt.Sprintf("request.imp[%d] uses native, but this bidder doesn't support it", i)))
 		}
 		if !hasAnyTypes(&imps[i]) {
 			numToFilter = numToFilter + 1
@@ -121,30 +122,47 @@ func (i *InfoAwareBidder) filterImps(imps []openrtb.Imp, numToFilter int) ([]ope
 		if hasAnyTypes(&imps[i]) {
 			newImps = append(newImps, imps[i])
 		} else {
-			errs = append(errs, BadInput(fmt.Sprintf("request.imp[%d] has no supported MediaTypes. It will be ignored", i)))
+			errs = append(errs, unsupportedMediaType(fmt.Sprintf("request.imp[%d] has no supported MediaTypes. It will be ignored", i)))
 		}
 	}
 	return newImps, errs
 }
 
+// unsupportedMediaType is a warning rather than an error, since the other media types of the request are still sent.
+func unsupportedMediaType(msg string) *errortypes.UnsupportedMediaType {
+	return &errortypes.UnsupportedMediaType{
+		Message: msg,
+	}
+}
+
 type BidderInfos map[string]BidderInfo
 
 // ParseBidderInfos reads all the static/bidder-info/{bidder}.yaml files from the filesystem.
 // The map it returns will have a key for every element of the bidders array.
+// Host aliases get the info of their core bidder, with the AliasOf set.
 // If a {bidder}.yaml file does not exist for some bidder, it will panic.
 func ParseBidderInfos(cfg map[string]config.Adapter, infoDir string, bidders []openrtb_ext.BidderName) BidderInfos {
 	bidderInfos := make(map[string]BidderInfo, len(bidders))
 	for _, bidderName := range bidders {
 		bidderString := string(bidderName)
-		fileData, err := ioutil.ReadFile(infoDir + "/" + bidderString + ".yaml")
+		aliasOf := cfg[strings.ToLower(bidderString)].AliasOf
+		infoFile := bidderString
+		if aliasOf != "" {
+			infoFile = aliasOf
+		}
+		fileData, err := ioutil.ReadFile(infoDir + "/" + infoFile + ".yaml")
 		if err != nil {
-			glog.Fatalf("error reading from file %s: %v", infoDir+"/"+bidderString+".yaml", err)
+			glog.Fatalf("error reading from file %s: %v", infoDir+"/"+infoFile+".yaml", err)
 		}
 
 		var parsedInfo BidderInfo
 		if err := yaml.Unmarshal(fileData, &parsedInfo); err != nil {
-			glog.Fatalf("error parsing yaml in file %s: %v", infoDir+"/"+bidderString+".yaml", err)
+			glog.Fatalf("error parsing yaml in file %s: %v", infoDir+"/"+infoFile+".yaml", err)
 		}
+		if parsedInfo.OpenRTB != nil && !isSupportedOpenRTBVersion(parsedInfo.OpenRTB.Version) {
+			glog.Fatalf("error in file %s: openrtb.version must be %q or %q. Got %q", infoDir+"/"+infoFile+".yaml", openrtb_ext.OpenRTBVersion25, openrtb_ext.OpenRTBVersion26, parsedInfo.OpenRTB.Version)
+		}
+		parsedInfo.AliasOf = aliasOf
 
 		if isEnabledBidder(cfg, bidderString) {
 			parsedInfo.Status = StatusActive
@@ -177,6 +195,22 @@ func (infos BidderInfos) SupportsWebMediaType(bidder openrtb_ext.BidderName, med
 	return containsMediaType(infos[string(bidder)].Capabilities.Site.MediaTypes, mediaType)
 }
 
+// OpenRTBVersion returns the OpenRTB version which the bidder declared support for.
+// Bidders which don't declare one support OpenRTB 2.5. Host aliases use the version of their core bidder.
+func (infos BidderInfos) OpenRTBVersion(bidder openrtb_ext.BidderName) string {
+	if aliasOf := infos[string(bidder)].AliasOf; aliasOf != "" {
+		bidder = openrtb_ext.BidderName(aliasOf)
+	}
+	if openrtbInfo := infos[string(bidder)].OpenRTB; openrtbInfo != nil && openrtbInfo.Version != "" {
+		return openrtbInfo.Version
+	}
+	return openrtb_ext.OpenRTBVersion25
+}
+
+func isSupportedOpenRTBVersion(version string) bool {
+	return version == "" || version == openrtb_ext.OpenRTBVersion25 || version == openrtb_ext.OpenRTBVersion26
+}
+
 // isEnabledBidder Checks that a bidder config exists and is not disabled
 func isEnabledBidder(cfg map[string]config.Adapter, bidder string) bool {
 	a, ok := cfg[strings.ToLower(bidder)]
@@ -196,9 +230,16 @@ type BidderInfo struct {
 	Status       BidderStatus      `yaml:"status" json:"status"`
 	Maintainer   *MaintainerInfo   `yaml:"maintainer" json:"maintainer"`
 	Capabilities *CapabilitiesInfo `yaml:"capabilities" json:"capabilities"`
+	OpenRTB      *OpenRTBInfo      `yaml:"openrtb" json:"openrtb,omitempty"`
 	AliasOf      string            `json:"aliasOf,omitempty"`
 }
 
+// OpenRTBInfo declares the OpenRTB version of the requests which the bidder supports.
+// Bidders which only support OpenRTB 2.5 get the OpenRTB 2.6 fields which have no 2.5 location removed.
+type OpenRTBInfo struct {
+	Version string `yaml:"version" json:"version"`
+}
+
 type MaintainerInfo struct {
 	Email string `yaml:"email" json:"email"`
 }
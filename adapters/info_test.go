@@ -98,11 +98,11 @@ func TestImpFiltering(t *testing.T) {
 	assert.EqualError(t, errs[3], "request.imp[1] has no supported MediaTypes. It will be ignored")
 	assert.EqualError(t, errs[4], "request.imp[3] has no supported MediaTypes. It will be ignored")
 	assert.EqualError(t, errs[5], "mock MakeRequests error")
-	assert.IsType(t, &errortypes.BadInput{}, errs[0])
-	assert.IsType(t, &errortypes.BadInput{}, errs[1])
-	assert.IsType(t, &errortypes.BadInput{}, errs[2])
-	assert.IsType(t, &errortypes.BadInput{}, errs[3])
-	assert.IsType(t, &errortypes.BadInput{}, errs[4])
+	assert.IsType(t, &errortypes.UnsupportedMediaType{}, errs[0])
+	assert.IsType(t, &errortypes.UnsupportedMediaType{}, errs[1])
+	assert.IsType(t, &errortypes.UnsupportedMediaType{}, errs[2])
+	assert.IsType(t, &errortypes.UnsupportedMediaType{}, errs[3])
+	assert.IsType(t, &errortypes.UnsupportedMediaType{}, errs[4])
 
 	req := bidder.gotRequest
 	if !assert.Len(t, req.Imp, 2) {
@@ -154,4 +154,24 @@ func TestParsing(t *testing.T) {
 	assert.Equal(t, true, infos.SupportsWebMediaType(mockBidderName, openrtb_ext.BidTypeVideo))
 	assert.Equal(t, false, infos.SupportsWebMediaType(mockBidderName, openrtb_ext.BidTypeAudio))
 	assert.Equal(t, true, infos.SupportsWebMediaType(mockBidderName, openrtb_ext.BidTypeNative))
+
+	assert.Equal(t, openrtb_ext.OpenRTBVersion26, infos.OpenRTBVersion(mockBidderName))
+	assert.Equal(t, openrtb_ext.OpenRTBVersion25, infos.OpenRTBVersion("undeclaredBidder"), "Bidders should default to OpenRTB 2.5")
+
+	infos["somealias"] = adapters.BidderInfo{AliasOf: string(mockBidderName)}
+	assert.Equal(t, openrtb_ext.OpenRTBVersion26, infos.OpenRTBVersion("somealias"), "Host aliases should use the version of their core bidder")
+}
+
+func TestParsingHostAlias(t *testing.T) {
+	cfg := map[string]config.Adapter{
+		"somebidder": {Disabled: true},
+		"somealias":  {AliasOf: "someBidder"},
+	}
+	infos := adapters.ParseBidderInfos(cfg, "./adapterstest/bidder-info", []openrtb_ext.BidderName{"someBidder", "somealias"})
+
+	assert.Equal(t, "someBidder", infos["somealias"].AliasOf)
+	assert.Equal(t, "some-email@domain.com", infos["somealias"].Maintainer.Email, "An alias should have the info of its core bidder")
+	assert.True(t, infos.IsActive("somealias"), "An alias should be enabled on its own")
+	assert.False(t, infos.IsActive("someBidder"))
+	assert.Empty(t, infos["someBidder"].AliasOf)
 }
@@ -0,0 +1,33 @@
+package adapters
+
+import (
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// UpconvertOpenRTB26 wraps a Bidder which supports OpenRTB 2.6, so that the requests it makes have the OpenRTB 2.6
+// fields in their OpenRTB 2.6 locations.
+//
+// Prebid Server models requests with the OpenRTB 2.5 objects, so the Bidder gets fields like regs.gdpr in regs.ext.gdpr.
+// The bodies of the requests it makes are moved back with openrtb_ext.UpconvertOpenRTB26Request, and their
+// X-Openrtb-Version header, if they have one, is set to 2.6.
+func UpconvertOpenRTB26(bidder Bidder) Bidder {
+	return &openRTB26Bidder{
+		Bidder: bidder,
+	}
+}
+
+type openRTB26Bidder struct {
+	Bidder
+}
+
+func (b *openRTB26Bidder) MakeRequests(request *openrtb.BidRequest, reqInfo *ExtraRequestInfo) ([]*RequestData, []error) {
+	reqData, errs := b.Bidder.MakeRequests(request, reqInfo)
+	for _, data := range reqData {
+		data.Body = openrtb_ext.UpconvertOpenRTB26Request(data.Body)
+		if data.Headers.Get("X-Openrtb-Version") != "" {
+			data.Headers.Set("X-Openrtb-Version", openrtb_ext.OpenRTBVersion26)
+		}
+	}
+	return reqData, errs
+}
@@ -0,0 +1,45 @@
+package adapters
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpconvertOpenRTB26(t *testing.T) {
+	bidder := UpconvertOpenRTB26(&requestBodyBidder{headers: http.Header{"X-Openrtb-Version": []string{"2.5"}}})
+	request := &openrtb.BidRequest{
+		ID:     "req",
+		Imp:    []openrtb.Imp{{ID: "imp", Video: &openrtb.Video{Ext: json.RawMessage(`{"podid":"pod-1"}`)}, Ext: json.RawMessage(`{"prebid":{"is_rewarded_inventory":1}}`)}},
+		Regs:   &openrtb.Regs{Ext: json.RawMessage(`{"gdpr":1}`)},
+		User:   &openrtb.User{Ext: json.RawMessage(`{"consent":"BOEF","eids":[{"source":"adserver.org"}]}`)},
+		Source: &openrtb.Source{Ext: json.RawMessage(`{"schain":{"ver":"1.0"}}`)},
+	}
+
+	reqData, errs := bidder.MakeRequests(request, &ExtraRequestInfo{})
+	assert.Empty(t, errs)
+	if assert.Len(t, reqData, 1) {
+		assert.JSONEq(t, `{"id":"req","imp":[{"id":"imp","rwdd":1,"video":{"mimes":null,"podid":"pod-1"}}],"regs":{"gdpr":1},"user":{"consent":"BOEF","eids":[{"source":"adserver.org"}]},"source":{"schain":{"ver":"1.0"}}}`, string(reqData[0].Body))
+		assert.Equal(t, "2.6", reqData[0].Headers.Get("X-Openrtb-Version"))
+	}
+}
+
+// requestBodyBidder sends the request as it is, like an OpenRTB bidder.
+type requestBodyBidder struct {
+	headers http.Header
+}
+
+func (b *requestBodyBidder) MakeRequests(request *openrtb.BidRequest, reqInfo *ExtraRequestInfo) ([]*RequestData, []error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, []error{err}
+	}
+	return []*RequestData{{Method: "POST", Uri: "https://bidder.com", Body: body, Headers: b.headers}}, nil
+}
+
+func (b *requestBodyBidder) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *RequestData, response *ResponseData) (*BidderResponse, []error) {
+	return nil, nil
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strings"
 	"text/template"
 	"time"
@@ -71,6 +73,24 @@ type Configuration struct {
 	Debug Debug `mapstructure:"debug"`
 	// RequestValidation specifies the request validation options.
 	RequestValidation RequestValidation `mapstructure:"request_validation"`
+	// Tracing configures the export of spans which trace each auction through Prebid Server.
+	Tracing Tracing `mapstructure:"tracing"`
+	// AdQuality configures how bids which break the request's blocking rules are handled.
+	AdQuality AdQuality `mapstructure:"ad_quality"`
+	// SecureMarkup configures the checks on the size, security and scripts of the bids' markup.
+	SecureMarkup SecureMarkup `mapstructure:"secure_markup"`
+	// LegacyAuction configures the legacy /auction endpoint.
+	LegacyAuction LegacyAuction `mapstructure:"legacy_auction"`
+	// Throttling keeps some of the traffic away from the bidders.
+	Throttling Throttling `mapstructure:"throttling"`
+	// Targeting configures the targeting keys which are added to the bids.
+	Targeting Targeting `mapstructure:"targeting"`
+	// VAST configures the checks and the trackers of the video and audio bids' VAST.
+	VAST VAST `mapstructure:"vast"`
+	// DeviceDetection fills the device fields which the requests left empty, using their User-Agent.
+	DeviceDetection DeviceDetection `mapstructure:"device_detection"`
+	// Native configures the checks and the trackers of the native bids.
+	Native Native `mapstructure:"native"`
 }
 
 const MIN_COOKIE_SIZE_BYTES = 500
@@ -111,6 +131,15 @@ func (cfg *Configuration) validate() configErrors {
 	errs = cfg.CurrencyConverter.validate(errs)
 	errs = validateAdapters(cfg.Adapters, errs)
 	errs = cfg.Debug.validate(errs)
+	errs = cfg.Tracing.validate(errs)
+	errs = cfg.AdQuality.validate(errs)
+	errs = cfg.SecureMarkup.validate(errs)
+	errs = cfg.LegacyAuction.validate(cfg.Adapters, errs)
+	errs = cfg.Throttling.validate(cfg.Adapters, errs)
+	errs = cfg.Targeting.validate(errs)
+	errs = cfg.VAST.validate(errs)
+	errs = cfg.DeviceDetection.validate(errs)
+	errs = cfg.Native.validate(errs)
 	return errs
 }
 
@@ -303,6 +332,90 @@ type Adapter struct {
 	// needed for Facebook
 	PlatformID string `mapstructure:"platform_id"`
 	AppSecret  string `mapstructure:"app_secret"`
+
+	// AliasOf makes this adapter a host alias of the named core bidder. A host alias is a bidder of its own:
+	// it has its own name in responses, usersyncs and metrics, and takes the same params as its core bidder.
+	// Its endpoint and credentials default to the core bidder's ones, but its usersync_url doesn't, since
+	// it syncs its own user IDs.
+	AliasOf string `mapstructure:"aliasOf"`
+	// GVLVendorID is the GDPR vendor ID of a host alias. It defaults to the core bidder's one.
+	GVLVendorID uint16 `mapstructure:"gvl_vendor_id"`
+
+	// HTTPClient overrides the http_client settings for the requests to this bidder.
+	HTTPClient AdapterHTTPClient `mapstructure:"http_client"`
+}
+
+// AdapterHTTPClient holds the connection settings of a single bidder.
+//
+// A bidder without any of them set shares the connection pool of the http_client config with the other bidders.
+// Otherwise, it gets a pool of its own, so that a high-volume bidder can't starve the others. The pool sizes
+// which aren't set are copied from http_client.
+type AdapterHTTPClient struct {
+	MaxConnsPerHost     *int `mapstructure:"max_connections_per_host"`
+	MaxIdleConns        *int `mapstructure:"max_idle_connections"`
+	MaxIdleConnsPerHost *int `mapstructure:"max_idle_connections_per_host"`
+	IdleConnTimeout     *int `mapstructure:"idle_connection_timeout_seconds"`
+	// TLSMinVersion is the lowest TLS version accepted from the bidder: "1.0", "1.1", "1.2" or "1.3".
+	TLSMinVersion string `mapstructure:"tls_min_version"`
+	// TLSInsecureSkipVerify accepts any certificate from the bidder. It should only be used for testing.
+	TLSInsecureSkipVerify bool `mapstructure:"tls_insecure_skip_verify"`
+	// HTTP2 attempts HTTP/2 on https:// endpoints.
+	HTTP2 bool `mapstructure:"http2"`
+	// GzipRequests compresses the request bodies, and sends them with "Content-Encoding: gzip".
+	GzipRequests bool `mapstructure:"gzip_requests"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// OwnsConnections returns true if the bidder needs a connection pool of its own.
+func (cfg *AdapterHTTPClient) OwnsConnections() bool {
+	return cfg.MaxConnsPerHost != nil || cfg.MaxIdleConns != nil || cfg.MaxIdleConnsPerHost != nil || cfg.IdleConnTimeout != nil ||
+		cfg.TLSMinVersion != "" || cfg.TLSInsecureSkipVerify || cfg.HTTP2
+}
+
+// TLSVersion returns the crypto/tls constant of TLSMinVersion, or 0 if it isn't set.
+func (cfg *AdapterHTTPClient) TLSVersion() uint16 {
+	return tlsVersions[cfg.TLSMinVersion]
+}
+
+func (cfg *AdapterHTTPClient) validate(adapterName string, errs configErrors) configErrors {
+	limits := []struct {
+		key   string
+		value *int
+	}{
+		{"max_connections_per_host", cfg.MaxConnsPerHost},
+		{"max_idle_connections", cfg.MaxIdleConns},
+		{"max_idle_connections_per_host", cfg.MaxIdleConnsPerHost},
+		{"idle_connection_timeout_seconds", cfg.IdleConnTimeout},
+	}
+	for _, limit := range limits {
+		if limit.value != nil && *limit.value < 0 {
+			errs = append(errs, fmt.Errorf("adapters.%s.http_client.%s must be >= 0. Got %d", adapterName, limit.key, *limit.value))
+		}
+	}
+	if _, ok := tlsVersions[cfg.TLSMinVersion]; cfg.TLSMinVersion != "" && !ok {
+		errs = append(errs, fmt.Errorf("adapters.%s.http_client.tls_min_version must be one of: 1.0, 1.1, 1.2, 1.3. Got %s", adapterName, cfg.TLSMinVersion))
+	}
+	return errs
+}
+
+// validateAdapterAlias makes sure that a host alias refers to a core bidder, and doesn't replace one
+func validateAdapterAlias(adapterMap map[string]Adapter, adapterName string, aliasOf string, errs configErrors) configErrors {
+	if _, isBidder := openrtb_ext.BidderMap[adapterName]; isBidder {
+		return append(errs, fmt.Errorf("adapters.%s.aliasOf can't be set, since %s is a bidder of its own", adapterName, adapterName))
+	}
+	if adapterMap[strings.ToLower(aliasOf)].AliasOf != "" {
+		return append(errs, fmt.Errorf("adapters.%s.aliasOf refers to %s, which is an alias itself", adapterName, aliasOf))
+	}
+	if _, isBidder := openrtb_ext.BidderMap[aliasOf]; !isBidder {
+		return append(errs, fmt.Errorf("adapters.%s.aliasOf refers to the unknown bidder %s", adapterName, aliasOf))
+	}
+	return errs
 }
 
 // validateAdapterEndpoint makes sure that an adapter has a valid endpoint
@@ -373,12 +486,17 @@ func validateAdapterUserSyncURL(userSyncURL string, adapterName string, errs con
 // validateAdapters validates adapter's endpoint and user sync URL
 func validateAdapters(adapterMap map[string]Adapter, errs configErrors) configErrors {
 	for adapterName, adapter := range adapterMap {
+		if adapter.AliasOf != "" {
+			errs = validateAdapterAlias(adapterMap, adapterName, adapter.AliasOf, errs)
+		}
 		if !adapter.Disabled {
 			// Verify that every adapter has a valid endpoint associated with it
 			errs = validateAdapterEndpoint(adapter.Endpoint, adapterName, errs)
 
 			// Verify that valid user_sync URLs are specified in the config
 			errs = validateAdapterUserSyncURL(adapter.UserSyncURL, adapterName, errs)
+
+			errs = adapter.HTTPClient.validate(adapterName, errs)
 		}
 	}
 	return errs
@@ -387,6 +505,7 @@ func validateAdapters(adapterMap map[string]Adapter, errs configErrors) configEr
 type Metrics struct {
 	Influxdb   InfluxMetrics     `mapstructure:"influxdb"`
 	Prometheus PrometheusMetrics `mapstructure:"prometheus"`
+	StatsD     StatsDMetrics     `mapstructure:"statsd"`
 	Disabled   DisabledMetrics   `mapstructure:"disabled_metrics"`
 }
 
@@ -396,7 +515,8 @@ type DisabledMetrics struct {
 }
 
 func (cfg *Metrics) validate(errs configErrors) configErrors {
-	return cfg.Prometheus.validate(errs)
+	errs = cfg.Prometheus.validate(errs)
+	return cfg.StatsD.validate(errs)
 }
 
 type InfluxMetrics struct {
@@ -425,6 +545,47 @@ func (m *PrometheusMetrics) Timeout() time.Duration {
 	return time.Duration(m.TimeoutMillisRaw) * time.Millisecond
 }
 
+type StatsDMetrics struct {
+	// Host is the StatsD server, or agent, which metrics are sent to. Metrics are only sent if this is defined.
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	// Prefix is prepended to the name of every metric.
+	Prefix string `mapstructure:"prefix"`
+	// Flavor is either "statsd", which adds the labels to the metric names, or "dogstatsd", which sends them as tags.
+	Flavor string `mapstructure:"flavor"`
+	// MaxPacketSize is the maximum number of bytes sent in a single UDP packet.
+	MaxPacketSize int `mapstructure:"max_packet_size"`
+	// FlushInterval is the maximum number of milliseconds which a metric waits before it's sent.
+	FlushInterval int `mapstructure:"flush_interval_ms"`
+	// SampleRates maps metric names (without the prefix) to the fraction of their values which are sent.
+	// Metrics which aren't listed are always sent.
+	SampleRates map[string]float64 `mapstructure:"sample_rates"`
+}
+
+func (cfg *StatsDMetrics) validate(errs configErrors) configErrors {
+	if cfg.Host == "" {
+		return errs
+	}
+	if cfg.Port <= 0 {
+		errs = append(errs, fmt.Errorf("metrics.statsd.port must be positive if metrics.statsd.host is defined. Got %d", cfg.Port))
+	}
+	if cfg.Flavor != "statsd" && cfg.Flavor != "dogstatsd" {
+		errs = append(errs, fmt.Errorf("metrics.statsd.flavor must be \"statsd\" or \"dogstatsd\". Got \"%s\"", cfg.Flavor))
+	}
+	if cfg.MaxPacketSize <= 0 {
+		errs = append(errs, fmt.Errorf("metrics.statsd.max_packet_size must be positive. Got %d", cfg.MaxPacketSize))
+	}
+	if cfg.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("metrics.statsd.flush_interval_ms must be positive. Got %d", cfg.FlushInterval))
+	}
+	for name, rate := range cfg.SampleRates {
+		if rate <= 0 || rate > 1 {
+			errs = append(errs, fmt.Errorf("metrics.statsd.sample_rates.%s must be in the range (0, 1]. Got %f", name, rate))
+		}
+	}
+	return errs
+}
+
 type DataCache struct {
 	Type       string `mapstructure:"type"`
 	Filename   string `mapstructure:"filename"`
@@ -485,10 +646,417 @@ type DefReqFiles struct {
 
 type Debug struct {
 	TimeoutNotification TimeoutNotification `mapstructure:"timeout_notification"`
+	FixtureCapture      FixtureCapture      `mapstructure:"fixture_capture"`
+	Trace               Trace               `mapstructure:"trace"`
 }
 
 func (cfg *Debug) validate(errs configErrors) configErrors {
-	return cfg.TimeoutNotification.validate(errs)
+	errs = cfg.TimeoutNotification.validate(errs)
+	errs = cfg.FixtureCapture.validate(errs)
+	return cfg.Trace.validate(errs)
+}
+
+type Tracing struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ServiceName identifies Prebid Server in the exported spans.
+	ServiceName string `mapstructure:"service_name"`
+	// SampleRate is the fraction of auctions which are traced, unless the caller sent a traceparent header.
+	SampleRate float64 `mapstructure:"sample_rate"`
+	// Exporter is where spans are sent. One of "stdout", "file" or "otlp".
+	Exporter string `mapstructure:"exporter"`
+	// FilePath is the file which spans are appended to, if the Exporter is "file".
+	FilePath string `mapstructure:"file_path"`
+	// OTLPEndpoint is the URL of the collector's OTLP/HTTP traces endpoint, if the Exporter is "otlp".
+	OTLPEndpoint string `mapstructure:"otlp_endpoint"`
+	// BatchSize is the maximum number of spans which are exported together.
+	BatchSize int `mapstructure:"batch_size"`
+	// FlushInterval is the maximum number of milliseconds which a finished span waits before it's exported.
+	FlushInterval int `mapstructure:"flush_interval_ms"`
+	// Timeout is the maximum number of milliseconds which a single export can take.
+	Timeout int `mapstructure:"timeout_ms"`
+}
+
+func (cfg *Tracing) validate(errs configErrors) configErrors {
+	if !cfg.Enabled {
+		return errs
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_rate must be between 0 and 1. Got %f", cfg.SampleRate))
+	}
+	switch cfg.Exporter {
+	case "stdout":
+	case "file":
+		if cfg.FilePath == "" {
+			errs = append(errs, fmt.Errorf("tracing.file_path must be defined if tracing.exporter is \"file\""))
+		}
+	case "otlp":
+		if cfg.OTLPEndpoint == "" {
+			errs = append(errs, fmt.Errorf("tracing.otlp_endpoint must be defined if tracing.exporter is \"otlp\""))
+		}
+		if cfg.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("tracing.timeout_ms must be positive if tracing.exporter is \"otlp\". Got %d", cfg.Timeout))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be one of \"stdout\", \"file\" or \"otlp\". Got \"%s\"", cfg.Exporter))
+	}
+	if cfg.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("tracing.batch_size must be positive. Got %d", cfg.BatchSize))
+	}
+	if cfg.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("tracing.flush_interval_ms must be positive. Got %d", cfg.FlushInterval))
+	}
+	return errs
+}
+
+// The actions which can be taken on a bid which breaks one of the request's ad quality rules.
+const (
+	AdQualityReject = "reject"
+	AdQualityWarn   = "warn"
+	AdQualityOff    = "off"
+)
+
+// AdQuality decides what happens to bids which break the blocking rules of the request.
+// Each rule can "reject" the bid, let it through with a warning ("warn"), or not be checked at all ("off").
+// An empty value is treated as "warn", so that hosts opt in to rejecting bids.
+type AdQuality struct {
+	// BlockedAdvertisers checks the bid's adomain against request.badv.
+	BlockedAdvertisers string `mapstructure:"badv"`
+	// BlockedCategories checks the bid's cat against request.bcat.
+	BlockedCategories string `mapstructure:"bcat"`
+	// BlockedAttributes checks the bid's attr against the battr of the imp's banner, video, audio or native object.
+	BlockedAttributes string `mapstructure:"battr"`
+	// BlockedApps checks the bid's bundle against request.bapp.
+	BlockedApps string `mapstructure:"bapp"`
+}
+
+// Enabled returns true if at least one of the rules is checked.
+func (cfg *AdQuality) Enabled() bool {
+	return cfg.BlockedAdvertisers != AdQualityOff ||
+		cfg.BlockedCategories != AdQualityOff ||
+		cfg.BlockedAttributes != AdQualityOff ||
+		cfg.BlockedApps != AdQualityOff
+}
+
+func (cfg *AdQuality) validate(errs configErrors) configErrors {
+	errs = validateAdQualityAction("ad_quality.badv", cfg.BlockedAdvertisers, errs)
+	errs = validateAdQualityAction("ad_quality.bcat", cfg.BlockedCategories, errs)
+	errs = validateAdQualityAction("ad_quality.battr", cfg.BlockedAttributes, errs)
+	errs = validateAdQualityAction("ad_quality.bapp", cfg.BlockedApps, errs)
+	return errs
+}
+
+func validateAdQualityAction(key string, action string, errs configErrors) configErrors {
+	switch action {
+	case "", AdQualityReject, AdQualityWarn, AdQualityOff:
+	default:
+		errs = append(errs, fmt.Errorf("%s must be one of \"reject\", \"warn\" or \"off\". Got \"%s\"", key, action))
+	}
+	return errs
+}
+
+// The modes of the markup checks.
+const (
+	SecureMarkupEnforce = "enforce"
+	SecureMarkupWarn    = "warn"
+	SecureMarkupOff     = "off"
+)
+
+// SecureMarkup decides how the bids' adm and nurl are checked. Bids whose markup is too big, matches one of
+// the DisallowedScripts, or loads http:// resources for an imp with secure=1 are removed from the response ("enforce"),
+// or kept with a warning ("warn"). The checks can also be skipped ("off").
+type SecureMarkup struct {
+	// Mode is used for the accounts which aren't listed in EnforceAccounts, WarnAccounts or OffAccounts.
+	// An empty value is treated as "warn".
+	Mode string `mapstructure:"mode"`
+	// MaxMarkupBytes is the largest adm which a bid can have. Use 0 for no limit.
+	MaxMarkupBytes int `mapstructure:"max_markup_bytes"`
+	// DisallowedScripts are regular expressions which a bid's adm must not match.
+	DisallowedScripts []string `mapstructure:"disallowed_script_patterns,flow"`
+	// EnforceAccounts, WarnAccounts and OffAccounts override the Mode for some accounts.
+	// They're used to create the hash table AccountModes so the mode of an account can be instantly accessed.
+	EnforceAccounts []string `mapstructure:"enforce_accounts,flow"`
+	WarnAccounts    []string `mapstructure:"warn_accounts,flow"`
+	OffAccounts     []string `mapstructure:"off_accounts,flow"`
+	AccountModes    map[string]string
+}
+
+// ModeForAccount returns the mode of the checks for the account.
+func (cfg *SecureMarkup) ModeForAccount(account string) string {
+	if mode, ok := cfg.AccountModes[account]; ok {
+		return mode
+	}
+	if cfg.Mode == "" {
+		return SecureMarkupWarn
+	}
+	return cfg.Mode
+}
+
+func (cfg *SecureMarkup) validate(errs configErrors) configErrors {
+	switch cfg.Mode {
+	case "", SecureMarkupEnforce, SecureMarkupWarn, SecureMarkupOff:
+	default:
+		errs = append(errs, fmt.Errorf("secure_markup.mode must be one of \"enforce\", \"warn\" or \"off\". Got \"%s\"", cfg.Mode))
+	}
+	if cfg.MaxMarkupBytes < 0 {
+		errs = append(errs, fmt.Errorf("secure_markup.max_markup_bytes must be >= 0. Got %d", cfg.MaxMarkupBytes))
+	}
+	for _, pattern := range cfg.DisallowedScripts {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("secure_markup.disallowed_script_patterns contains an invalid pattern \"%s\": %v", pattern, err))
+		}
+	}
+	lists := make(map[string]string)
+	for _, accounts := range []struct {
+		list     string
+		accounts []string
+	}{
+		{"enforce_accounts", cfg.EnforceAccounts},
+		{"warn_accounts", cfg.WarnAccounts},
+		{"off_accounts", cfg.OffAccounts},
+	} {
+		for _, account := range accounts.accounts {
+			if list, ok := lists[account]; ok && list != accounts.list {
+				errs = append(errs, fmt.Errorf("secure_markup: account \"%s\" can't be in both %s and %s", account, list, accounts.list))
+				continue
+			}
+			lists[account] = accounts.list
+		}
+	}
+	return errs
+}
+
+// LegacyAuction configures the legacy /auction endpoint.
+type LegacyAuction struct {
+	// OpenRTBBidders get their /auction requests through the OpenRTB exchange, rather than through their legacy adapters.
+	// Their legacy params must be valid OpenRTB params.
+	OpenRTBBidders []string `mapstructure:"openrtb_bidders"`
+}
+
+// LegacyBidderAliases are the /auction bidder codes which were served by the legacy adapter of another bidder.
+var LegacyBidderAliases = map[string]openrtb_ext.BidderName{
+	"districtm": openrtb_ext.BidderAppnexus,
+}
+
+func (cfg *LegacyAuction) validate(adapters map[string]Adapter, errs configErrors) configErrors {
+	for _, bidder := range cfg.OpenRTBBidders {
+		_, isBidder := openrtb_ext.BidderMap[bidder]
+		_, isLegacyAlias := LegacyBidderAliases[bidder]
+		isHostAlias := adapters[strings.ToLower(bidder)].AliasOf != ""
+		if !isBidder && !isLegacyAlias && !isHostAlias {
+			errs = append(errs, fmt.Errorf("legacy_auction.openrtb_bidders contains the unknown bidder %s", bidder))
+		}
+	}
+	return errs
+}
+
+// UsesOpenRTB returns true if the bidder's /auction requests go through the OpenRTB exchange.
+func (cfg *LegacyAuction) UsesOpenRTB(bidder string) bool {
+	for _, openrtbBidder := range cfg.OpenRTBBidders {
+		if openrtbBidder == bidder {
+			return true
+		}
+	}
+	return false
+}
+
+// Throttling holds the rules which decide how much of the traffic reaches each bidder.
+type Throttling struct {
+	Rules []ThrottlingRule `mapstructure:"rules"`
+}
+
+// ThrottlingRule keeps a share of the matching imps away from a bidder.
+// The empty filters match everything.
+type ThrottlingRule struct {
+	// Bidder is the bidder, or the alias, which the rule applies to.
+	Bidder string `mapstructure:"bidder"`
+	// Accounts are the publisher IDs of the requests which the rule applies to.
+	Accounts []string `mapstructure:"accounts"`
+	// MediaTypes are the types of the imps which the rule applies to: banner, video, audio or native.
+	MediaTypes []string `mapstructure:"media_types"`
+	// DeviceTypes are the OpenRTB device.devicetype values of the requests which the rule applies to.
+	DeviceTypes []int `mapstructure:"device_types"`
+	// Countries are the ISO-3166-1 alpha-3 device.geo.country codes of the requests which the rule applies to.
+	Countries []string `mapstructure:"countries"`
+	// Percent of the matching requests which still reach the bidder, from 0 to 100.
+	Percent *float64 `mapstructure:"percent"`
+	// MaxQPS caps the matching requests which reach the bidder each second, on every host instance. Use 0 for no cap.
+	MaxQPS int `mapstructure:"max_qps"`
+}
+
+func (cfg *Throttling) validate(adapters map[string]Adapter, errs configErrors) configErrors {
+	for i, rule := range cfg.Rules {
+		_, isBidder := openrtb_ext.BidderMap[rule.Bidder]
+		isHostAlias := adapters[strings.ToLower(rule.Bidder)].AliasOf != ""
+		if !isBidder && !isHostAlias {
+			errs = append(errs, fmt.Errorf("throttling.rules[%d].bidder must be a known bidder. Got \"%s\"", i, rule.Bidder))
+		}
+		for _, mediaType := range rule.MediaTypes {
+			if _, err := openrtb_ext.ParseBidType(mediaType); err != nil {
+				errs = append(errs, fmt.Errorf("throttling.rules[%d].media_types must be banner, video, audio or native. Got \"%s\"", i, mediaType))
+			}
+		}
+		if rule.Percent == nil && rule.MaxQPS == 0 {
+			errs = append(errs, fmt.Errorf("throttling.rules[%d] must set percent or max_qps", i))
+		}
+		if rule.Percent != nil && (*rule.Percent < 0 || *rule.Percent > 100) {
+			errs = append(errs, fmt.Errorf("throttling.rules[%d].percent must be between 0 and 100. Got %f", i, *rule.Percent))
+		}
+		if rule.MaxQPS < 0 {
+			errs = append(errs, fmt.Errorf("throttling.rules[%d].max_qps must be >= 0. Got %d", i, rule.MaxQPS))
+		}
+	}
+	return errs
+}
+
+// Targeting configures the targeting keys which are added to the bids.
+type Targeting struct {
+	// MaxKeyLength truncates the bidder-specific keys, like hb_pb_appnexus, since some ad servers don't accept long keys.
+	// A zero value is treated as 20.
+	MaxKeyLength int `mapstructure:"max_key_length"`
+	// AccountOverrides set another MaxKeyLength for some accounts.
+	// They're used to create the hash table AccountMaxKeyLengths so the limit of an account can be instantly accessed.
+	AccountOverrides     []TargetingAccountOverride `mapstructure:"account_overrides"`
+	AccountMaxKeyLengths map[string]int
+}
+
+// TargetingAccountOverride sets the MaxKeyLength of the targeting keys for some accounts.
+type TargetingAccountOverride struct {
+	Accounts     []string `mapstructure:"accounts"`
+	MaxKeyLength int      `mapstructure:"max_key_length"`
+}
+
+// MaxKeyLengthForAccount returns the length at which the account's bidder-specific keys are truncated.
+func (cfg *Targeting) MaxKeyLengthForAccount(account string) int {
+	if length, ok := cfg.AccountMaxKeyLengths[account]; ok {
+		return length
+	}
+	if cfg.MaxKeyLength == 0 {
+		return defaultTargetingKeyLength
+	}
+	return cfg.MaxKeyLength
+}
+
+// MaxPrefixLengthForAccount returns the longest targeting prefix which the account's requests can use.
+// Longer prefixes would leave too few characters once the keys are truncated, and different keys would collide.
+func (cfg *Targeting) MaxPrefixLengthForAccount(account string) int {
+	return cfg.MaxKeyLengthForAccount(account) - minTargetingKeyLength + len(defaultTargetingPrefix)
+}
+
+func (cfg *Targeting) validate(errs configErrors) configErrors {
+	if cfg.MaxKeyLength != 0 && cfg.MaxKeyLength < minTargetingKeyLength {
+		errs = append(errs, fmt.Errorf("targeting.max_key_length must be >= %d. Got %d", minTargetingKeyLength, cfg.MaxKeyLength))
+	}
+	for i, override := range cfg.AccountOverrides {
+		if override.MaxKeyLength < minTargetingKeyLength {
+			errs = append(errs, fmt.Errorf("targeting.account_overrides[%d].max_key_length must be >= %d. Got %d", i, minTargetingKeyLength, override.MaxKeyLength))
+		}
+	}
+	return errs
+}
+
+const (
+	defaultTargetingKeyLength = 20
+	defaultTargetingPrefix    = "hb"
+	// minTargetingKeyLength leaves room for the longest standard key, hb_cache_path, and a few characters of the bidder.
+	// Prefixes which are longer than hb need as many more characters, which MaxPrefixLengthForAccount takes away from them.
+	minTargetingKeyLength = 16
+)
+
+// VAST configures the checks and the trackers of the video and audio bids' VAST.
+type VAST struct {
+	// ValidateXML removes the video bids whose adm isn't well-formed VAST XML.
+	ValidateXML bool `mapstructure:"validate_xml"`
+	// Wrap adds the trackers and the verifications to the VAST of the video bids before they're cached.
+	// Bids with an adm get them in each of their ads, and bids with only a nurl get a wrapper of the given Version around it.
+	// An empty Version is treated as "3.0".
+	Wrap    bool   `mapstructure:"wrap"`
+	Version string `mapstructure:"version"`
+	// ImpressionTrackers and ErrorTrackers are URL templates. They can use the macros {{.Bidder}}, {{.AccountID}},
+	// {{.AuctionID}} and {{.BidID}}.
+	ImpressionTrackers []string `mapstructure:"impression_trackers"`
+	ErrorTrackers      []string `mapstructure:"error_trackers"`
+	// Verifications are the measurement scripts which are added to each ad.
+	Verifications []VASTVerification `mapstructure:"verifications"`
+}
+
+// VASTVerification is an Open Measurement script which verifies the ads.
+type VASTVerification struct {
+	Vendor        string `mapstructure:"vendor"`
+	JavaScriptURL string `mapstructure:"javascript_url"`
+	Parameters    string `mapstructure:"parameters"`
+}
+
+func (cfg *VAST) validate(errs configErrors) configErrors {
+	switch cfg.Version {
+	case "", "2.0", "3.0", "4.0":
+	default:
+		errs = append(errs, fmt.Errorf("vast.version must be one of \"2.0\", \"3.0\" or \"4.0\". Got \"%s\"", cfg.Version))
+	}
+	errs = validateTrackers("vast.impression_trackers", cfg.ImpressionTrackers, errs)
+	errs = validateTrackers("vast.error_trackers", cfg.ErrorTrackers, errs)
+	for i, verification := range cfg.Verifications {
+		if _, err := url.ParseRequestURI(verification.JavaScriptURL); err != nil {
+			errs = append(errs, fmt.Errorf("vast.verifications[%d].javascript_url must be a URL. Got \"%s\"", i, verification.JavaScriptURL))
+		}
+	}
+	return errs
+}
+
+func validateTrackers(field string, trackers []string, errs configErrors) configErrors {
+	for i, tracker := range trackers {
+		trackerTemplate, err := template.New("vastTracker").Parse(tracker)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d] is an invalid template: %v", field, i, err))
+			continue
+		}
+		resolved, err := macros.ResolveMacros(*trackerTemplate, macros.TrackerTemplateParams{Bidder: "bidder", AccountID: dummyAccountID, AuctionID: "auction", BidID: "bid"})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d] uses an unknown macro: %v", field, i, err))
+			continue
+		}
+		if _, err := url.ParseRequestURI(resolved); err != nil {
+			errs = append(errs, fmt.Errorf("%s[%d] must be a URL. Got \"%s\"", field, i, tracker))
+		}
+	}
+	return errs
+}
+
+// Native configures the checks and the trackers of the native bids.
+type Native struct {
+	// Validate checks the native bids against the native request of their imp. Bids which can be repaired
+	// are kept without the assets and trackers which the request didn't allow, and the others are removed.
+	Validate bool `mapstructure:"validate"`
+	// ImpressionTrackers are URL templates which are added to the markup of each native bid. They can use the same
+	// macros as vast.impression_trackers.
+	ImpressionTrackers []string `mapstructure:"impression_trackers"`
+}
+
+func (cfg *Native) validate(errs configErrors) configErrors {
+	return validateTrackers("native.impression_trackers", cfg.ImpressionTrackers, errs)
+}
+
+// DeviceDetection configures the detection of the requests' devices from their User-Agent.
+type DeviceDetection struct {
+	Enabled bool `mapstructure:"enabled"`
+	// RulesFile is the JSON file with the detection rules. Prebid Server comes with one in static/device-detection.
+	RulesFile string `mapstructure:"rules_file"`
+	// ReloadIntervalSeconds is how often the RulesFile is checked for changes. 0 never reloads it.
+	ReloadIntervalSeconds int `mapstructure:"reload_interval_seconds"`
+}
+
+// ReloadInterval returns how often the rules file should be checked for changes.
+func (cfg *DeviceDetection) ReloadInterval() time.Duration {
+	return time.Duration(cfg.ReloadIntervalSeconds) * time.Second
+}
+
+func (cfg *DeviceDetection) validate(errs configErrors) configErrors {
+	if cfg.Enabled && cfg.RulesFile == "" {
+		errs = append(errs, fmt.Errorf("device_detection.rules_file must be set when device_detection.enabled is true"))
+	}
+	if cfg.ReloadIntervalSeconds < 0 {
+		errs = append(errs, fmt.Errorf("device_detection.reload_interval_seconds must be >= 0. Got %d", cfg.ReloadIntervalSeconds))
+	}
+	return errs
 }
 
 type TimeoutNotification struct {
@@ -507,6 +1075,98 @@ func (cfg *TimeoutNotification) validate(errs configErrors) configErrors {
 	return errs
 }
 
+// FixtureCapture saves a sample of the auctions of some bidders as adapterstest JSON files,
+// which can be replayed against later versions of their adapters.
+type FixtureCapture struct {
+	// Bidders whose auctions are captured. Nothing is captured if it's empty.
+	Bidders []string `mapstructure:"bidders"`
+	// Fraction of the auctions of these bidders which are captured
+	SamplingRate float32 `mapstructure:"sampling_rate"`
+	// Directory in which the files are written
+	Directory string `mapstructure:"directory"`
+	// Maximum number of files written for each bidder since startup. Use 0 for no limit.
+	MaxFiles int `mapstructure:"max_files"`
+}
+
+// Captures returns true if the auctions of the bidder are sampled.
+func (cfg *FixtureCapture) Captures(bidder string) bool {
+	if cfg.SamplingRate <= 0 {
+		return false
+	}
+	for _, captured := range cfg.Bidders {
+		if strings.EqualFold(captured, bidder) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *FixtureCapture) validate(errs configErrors) configErrors {
+	if cfg.SamplingRate < 0.0 || cfg.SamplingRate > 1.0 {
+		errs = append(errs, fmt.Errorf("debug.fixture_capture.sampling_rate must be positive and not greater than 1.0. Got %f", cfg.SamplingRate))
+	}
+	if cfg.MaxFiles < 0 {
+		errs = append(errs, fmt.Errorf("debug.fixture_capture.max_files must be >= 0. Got %d", cfg.MaxFiles))
+	}
+	if len(cfg.Bidders) > 0 && cfg.Directory == "" {
+		errs = append(errs, fmt.Errorf("debug.fixture_capture.directory must be set to capture the auctions of debug.fixture_capture.bidders"))
+	}
+	return errs
+}
+
+// TraceLevelNone is the level of the auction traces for the accounts which can't get any.
+const TraceLevelNone = "none"
+
+// Trace decides which accounts can get a trace of their auctions with request.ext.prebid.trace.
+// Accounts which ask for a more detailed trace than they're allowed get the most detailed one they're allowed.
+type Trace struct {
+	// MaxLevel is the most detailed trace which the accounts that aren't listed in VerboseAccounts or BasicAccounts
+	// can get. One of "verbose", "basic" or "none". An empty value is treated as "none".
+	MaxLevel string `mapstructure:"max_level"`
+	// VerboseAccounts and BasicAccounts override the MaxLevel for some accounts.
+	// They're used to create the hash table AccountLevels so the level of an account can be instantly accessed.
+	VerboseAccounts []string `mapstructure:"verbose_accounts,flow"`
+	BasicAccounts   []string `mapstructure:"basic_accounts,flow"`
+	AccountLevels   map[string]string
+}
+
+// Enabled returns true if any account can get a trace.
+func (cfg *Trace) Enabled() bool {
+	return (cfg.MaxLevel != "" && cfg.MaxLevel != TraceLevelNone) || len(cfg.VerboseAccounts) > 0 || len(cfg.BasicAccounts) > 0
+}
+
+// LevelForAccount returns the level of the trace which the account gets if it asks for the requested one.
+func (cfg *Trace) LevelForAccount(account string, requested openrtb_ext.TraceLevel) openrtb_ext.TraceLevel {
+	maxLevel, ok := cfg.AccountLevels[account]
+	if !ok {
+		maxLevel = cfg.MaxLevel
+	}
+	allowed := openrtb_ext.TraceLevel(maxLevel)
+	if allowed.Includes(requested) {
+		return requested
+	}
+	if allowed.Includes(openrtb_ext.TraceBasic) && requested.Includes(openrtb_ext.TraceBasic) {
+		return openrtb_ext.TraceBasic
+	}
+	return openrtb_ext.TraceNone
+}
+
+func (cfg *Trace) validate(errs configErrors) configErrors {
+	switch cfg.MaxLevel {
+	case "", TraceLevelNone, string(openrtb_ext.TraceBasic), string(openrtb_ext.TraceVerbose):
+	default:
+		errs = append(errs, fmt.Errorf("debug.trace.max_level must be one of \"verbose\", \"basic\" or \"none\". Got \"%s\"", cfg.MaxLevel))
+	}
+	for _, account := range cfg.BasicAccounts {
+		for _, verbose := range cfg.VerboseAccounts {
+			if account == verbose {
+				errs = append(errs, fmt.Errorf("debug.trace: account \"%s\" can't be in both verbose_accounts and basic_accounts", account))
+			}
+		}
+	}
+	return errs
+}
+
 // New uses viper to get our server configurations.
 func New(v *viper.Viper) (*Configuration, error) {
 	var c Configuration
@@ -545,6 +1205,38 @@ func New(v *viper.Viper) (*Configuration, error) {
 		c.BlacklistedAcctMap[c.BlacklistedAccts[i]] = true
 	}
 
+	// To look for an account's markup checks in O(1) time, we fill this hash table located in the
+	// the SecureMarkup field of the Configuration struct defined in this file
+	c.SecureMarkup.AccountModes = make(map[string]string)
+	for _, account := range c.SecureMarkup.EnforceAccounts {
+		c.SecureMarkup.AccountModes[account] = SecureMarkupEnforce
+	}
+	for _, account := range c.SecureMarkup.WarnAccounts {
+		c.SecureMarkup.AccountModes[account] = SecureMarkupWarn
+	}
+	for _, account := range c.SecureMarkup.OffAccounts {
+		c.SecureMarkup.AccountModes[account] = SecureMarkupOff
+	}
+
+	// To look for the trace level of an account in O(1) time, we fill this hash table located in the
+	// the Debug field of the Configuration struct defined in this file
+	c.Debug.Trace.AccountLevels = make(map[string]string)
+	for _, account := range c.Debug.Trace.VerboseAccounts {
+		c.Debug.Trace.AccountLevels[account] = string(openrtb_ext.TraceVerbose)
+	}
+	for _, account := range c.Debug.Trace.BasicAccounts {
+		c.Debug.Trace.AccountLevels[account] = string(openrtb_ext.TraceBasic)
+	}
+
+	// To look for an account's targeting key length in O(1) time, we fill this hash table located in the
+	// the Targeting field of the Configuration struct defined in this file
+	c.Targeting.AccountMaxKeyLengths = make(map[string]int)
+	for _, override := range c.Targeting.AccountOverrides {
+		for _, account := range override.Accounts {
+			c.Targeting.AccountMaxKeyLengths[account] = override.MaxKeyLength
+		}
+	}
+
 	glog.Info("Logging the resolved configuration:")
 	logGeneral(reflect.ValueOf(c), "  \t")
 	if errs := c.validate(); len(errs) > 0 {
@@ -642,6 +1334,7 @@ func (cfg *Configuration) setDerivedDefaults() {
 	setDefaultUsersync(cfg.Adapters, openrtb_ext.BidderYieldmo, "https://ads.yieldmo.com/pbsync?gdpr={{.GDPR}}&gdpr_consent={{.GDPRConsent}}&us_privacy={{.USPrivacy}}&redirectUri="+url.QueryEscape(externalURL)+"%2Fsetuid%3Fbidder%3Dyieldmo%26gdpr%3D{{.GDPR}}%26gdpr_consent%3D{{.GDPRConsent}}%26uid%3D%24UID")
 	setDefaultUsersync(cfg.Adapters, openrtb_ext.BidderYieldone, "https://y.one.impact-ad.jp/hbs_cs?gdpr={{.GDPR}}&gdpr_consent={{.GDPRConsent}}&us_privacy={{.USPrivacy}}&redirectUri="+url.QueryEscape(externalURL)+"%2Fsetuid%3Fbidder%3Dyieldone%26gdpr%3D{{.GDPR}}%26gdpr_consent%3D{{.GDPRConsent}}%26uid%3D%24UID")
 	setDefaultUsersync(cfg.Adapters, openrtb_ext.BidderZeroClickFraud, "https://s.0cf.io/sync?gdpr={{.GDPR}}&gdpr_consent={{.GDPRConsent}}&us_privacy={{.USPrivacy}}&r="+url.QueryEscape(externalURL)+"%2Fsetuid%3Fbidder%3Dzeroclickfraud%26gdpr%3D{{.GDPR}}%26gdpr_consent%3D{{.GDPRConsent}}%26uid%3D%24%7Buid%7D")
+	setAliasDefaults(cfg.Adapters)
 }
 
 func setDefaultUsersync(m map[string]Adapter, bidder openrtb_ext.BidderName, defaultValue string) {
@@ -654,6 +1347,32 @@ func setDefaultUsersync(m map[string]Adapter, bidder openrtb_ext.BidderName, def
 	}
 }
 
+// setAliasDefaults copies the endpoint and credentials of each core bidder into its host aliases, unless the alias has its own.
+func setAliasDefaults(m map[string]Adapter) {
+	for name, alias := range m {
+		if alias.AliasOf == "" {
+			continue
+		}
+		core := m[strings.ToLower(alias.AliasOf)]
+		if alias.Endpoint == "" {
+			alias.Endpoint = core.Endpoint
+		}
+		if alias.ExtraAdapterInfo == "" {
+			alias.ExtraAdapterInfo = core.ExtraAdapterInfo
+		}
+		if alias.PlatformID == "" {
+			alias.PlatformID = core.PlatformID
+		}
+		if alias.AppSecret == "" {
+			alias.AppSecret = core.AppSecret
+		}
+		if alias.XAPI.Username == "" && alias.XAPI.Password == "" {
+			alias.XAPI = core.XAPI
+		}
+		m[name] = alias
+	}
+}
+
 // Set the default config values for the viper object we are using.
 func SetupViper(v *viper.Viper, filename string) {
 	if filename != "" {
@@ -710,6 +1429,12 @@ func SetupViper(v *viper.Viper, filename string) {
 	v.SetDefault("metrics.prometheus.namespace", "")
 	v.SetDefault("metrics.prometheus.subsystem", "")
 	v.SetDefault("metrics.prometheus.timeout_ms", 10000)
+	v.SetDefault("metrics.statsd.host", "")
+	v.SetDefault("metrics.statsd.port", 8125)
+	v.SetDefault("metrics.statsd.prefix", "prebidserver.")
+	v.SetDefault("metrics.statsd.flavor", "statsd")
+	v.SetDefault("metrics.statsd.max_packet_size", 1432)
+	v.SetDefault("metrics.statsd.flush_interval_ms", 100)
 	v.SetDefault("datacache.type", "dummy")
 	v.SetDefault("datacache.filename", "")
 	v.SetDefault("datacache.cache_size", 0)
@@ -744,6 +1469,15 @@ func SetupViper(v *viper.Viper, filename string) {
 	v.SetDefault("stored_requests.http_events.amp_endpoint", "")
 	v.SetDefault("stored_requests.http_events.refresh_rate_seconds", 0)
 	v.SetDefault("stored_requests.http_events.timeout_ms", 0)
+	v.SetDefault("stored_requests.redis_cache.address", "")
+	v.SetDefault("stored_requests.redis_cache.password", "")
+	v.SetDefault("stored_requests.redis_cache.db", 0)
+	v.SetDefault("stored_requests.redis_cache.key_prefix", "pbs:stored:")
+	v.SetDefault("stored_requests.redis_cache.ttl_seconds", 0)
+	v.SetDefault("stored_requests.redis_cache.timeout_ms", 50)
+	v.SetDefault("stored_requests.redis_cache.pool_size", 10)
+	v.SetDefault("stored_requests.redis_cache.pubsub_channel", "")
+	v.SetDefault("stored_requests.validate_on_startup", false)
 	// stored_video is short for stored_video_requests.
 	// PBS is not in the business of storing video content beyond the normal prebid cache system.
 	v.SetDefault("stored_video_req.filesystem.enabled", false)
@@ -764,6 +1498,14 @@ func SetupViper(v *viper.Viper, filename string) {
 	v.SetDefault("stored_video_req.in_memory_cache.ttl_seconds", 0)
 	v.SetDefault("stored_video_req.in_memory_cache.request_cache_size_bytes", 0)
 	v.SetDefault("stored_video_req.in_memory_cache.imp_cache_size_bytes", 0)
+	v.SetDefault("stored_video_req.redis_cache.address", "")
+	v.SetDefault("stored_video_req.redis_cache.password", "")
+	v.SetDefault("stored_video_req.redis_cache.db", 0)
+	v.SetDefault("stored_video_req.redis_cache.key_prefix", "pbs:stored:video:")
+	v.SetDefault("stored_video_req.redis_cache.ttl_seconds", 0)
+	v.SetDefault("stored_video_req.redis_cache.timeout_ms", 50)
+	v.SetDefault("stored_video_req.redis_cache.pool_size", 10)
+	v.SetDefault("stored_video_req.redis_cache.pubsub_channel", "")
 	v.SetDefault("stored_video_req.cache_events.enabled", false)
 	v.SetDefault("stored_video_req.cache_events.endpoint", "")
 	v.SetDefault("stored_video_req.http_events.endpoint", "")
@@ -902,6 +1644,57 @@ func SetupViper(v *viper.Viper, filename string) {
 	v.SetDefault("debug.timeout_notification.log", false)
 	v.SetDefault("debug.timeout_notification.sampling_rate", 0.0)
 	v.SetDefault("debug.timeout_notification.fail_only", false)
+	v.SetDefault("debug.trace.max_level", TraceLevelNone)
+	v.SetDefault("debug.trace.verbose_accounts", []string{})
+	v.SetDefault("debug.trace.basic_accounts", []string{})
+	v.SetDefault("debug.fixture_capture.bidders", []string{})
+	v.SetDefault("debug.fixture_capture.sampling_rate", 0.0)
+	v.SetDefault("debug.fixture_capture.directory", "")
+	v.SetDefault("debug.fixture_capture.max_files", 100)
+
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.service_name", "prebid-server")
+	v.SetDefault("tracing.sample_rate", 1.0)
+	v.SetDefault("tracing.exporter", "stdout")
+	v.SetDefault("tracing.file_path", "")
+	v.SetDefault("tracing.otlp_endpoint", "http://localhost:4318/v1/traces")
+	v.SetDefault("tracing.batch_size", 512)
+	v.SetDefault("tracing.flush_interval_ms", 5000)
+	v.SetDefault("tracing.timeout_ms", 1000)
+
+	v.SetDefault("ad_quality.badv", AdQualityWarn)
+	v.SetDefault("ad_quality.bcat", AdQualityWarn)
+	v.SetDefault("ad_quality.battr", AdQualityWarn)
+	v.SetDefault("ad_quality.bapp", AdQualityWarn)
+
+	v.SetDefault("secure_markup.mode", SecureMarkupWarn)
+	v.SetDefault("secure_markup.max_markup_bytes", 512000)
+	// Ads which take over the whole page by redirecting it.
+	v.SetDefault("secure_markup.disallowed_script_patterns", []string{`(?i)\btop\.location(\.href)?\s*=[^=]`})
+	v.SetDefault("secure_markup.enforce_accounts", []string{})
+	v.SetDefault("secure_markup.warn_accounts", []string{})
+	v.SetDefault("secure_markup.off_accounts", []string{})
+
+	v.SetDefault("legacy_auction.openrtb_bidders", []string{})
+
+	v.SetDefault("throttling.rules", []ThrottlingRule{})
+
+	v.SetDefault("targeting.max_key_length", defaultTargetingKeyLength)
+	v.SetDefault("targeting.account_overrides", []TargetingAccountOverride{})
+
+	v.SetDefault("vast.validate_xml", true)
+	v.SetDefault("vast.wrap", false)
+	v.SetDefault("vast.version", "3.0")
+	v.SetDefault("vast.impression_trackers", []string{})
+	v.SetDefault("vast.error_trackers", []string{})
+	v.SetDefault("vast.verifications", []VASTVerification{})
+
+	v.SetDefault("native.validate", false)
+	v.SetDefault("native.impression_trackers", []string{})
+
+	v.SetDefault("device_detection.enabled", false)
+	v.SetDefault("device_detection.rules_file", "./static/device-detection/rules.json")
+	v.SetDefault("device_detection.reload_interval_seconds", 60)
 
 	/* IPv4
 	/*  Site Local: 10.0.0.0/8, 172.16.0.0/12, 192.168.0.0/16
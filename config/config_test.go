@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"crypto/tls"
 	"net"
 	"strings"
 	"testing"
@@ -34,6 +35,7 @@ func TestDefaults(t *testing.T) {
 	cmpInts(t, "metrics.influxdb.collection_rate_seconds", cfg.Metrics.Influxdb.MetricSendInterval, 20)
 	cmpBools(t, "account_adapter_details", cfg.Metrics.Disabled.AccountAdapterDetails, false)
 	cmpStrings(t, "certificates_file", cfg.PemCertsFile, "")
+	cmpBools(t, "vast.validate_xml", cfg.VAST.ValidateXML, true)
 }
 
 var fullConfig = []byte(`
@@ -87,6 +89,11 @@ metrics:
     username: admin
     password: admin1324
     metric_send_interval: 30
+  statsd:
+    host: statsd-agent
+    flavor: dogstatsd
+    sample_rates:
+      adapter_prices: 0.1
   disabled_metrics:
     account_adapter_details: true
 datacache:
@@ -122,6 +129,10 @@ certificates_file: /etc/ssl/cert.pem
 request_validation:
     ipv4_private_networks: ["1.1.1.0/24"]
     ipv6_private_networks: ["1111::/16", "2222::/16"]
+secure_markup:
+  mode: warn
+  enforce_accounts: ["strict-pub"]
+  off_accounts: ["trusted-pub"]
 `)
 
 var adapterExtraInfoConfig = []byte(`
@@ -268,6 +279,15 @@ func TestFullConfig(t *testing.T) {
 	cmpStrings(t, "metrics.influxdb.username", cfg.Metrics.Influxdb.Username, "admin")
 	cmpStrings(t, "metrics.influxdb.password", cfg.Metrics.Influxdb.Password, "admin1324")
 	cmpInts(t, "metrics.influxdb.metric_send_interval", cfg.Metrics.Influxdb.MetricSendInterval, 30)
+	cmpStrings(t, "metrics.statsd.host", cfg.Metrics.StatsD.Host, "statsd-agent")
+	cmpInts(t, "metrics.statsd.port", cfg.Metrics.StatsD.Port, 8125)
+	cmpStrings(t, "metrics.statsd.flavor", cfg.Metrics.StatsD.Flavor, "dogstatsd")
+	assert.Equal(t, map[string]float64{"adapter_prices": 0.1}, cfg.Metrics.StatsD.SampleRates, "metrics.statsd.sample_rates")
+	cmpStrings(t, "secure_markup.mode", cfg.SecureMarkup.Mode, "warn")
+	cmpInts(t, "secure_markup.max_markup_bytes", cfg.SecureMarkup.MaxMarkupBytes, 512000)
+	cmpStrings(t, "secure_markup mode for strict-pub", cfg.SecureMarkup.ModeForAccount("strict-pub"), "enforce")
+	cmpStrings(t, "secure_markup mode for trusted-pub", cfg.SecureMarkup.ModeForAccount("trusted-pub"), "off")
+	cmpStrings(t, "secure_markup mode for other-pub", cfg.SecureMarkup.ModeForAccount("other-pub"), "warn")
 	cmpStrings(t, "datacache.type", cfg.DataCache.Type, "postgres")
 	cmpStrings(t, "datacache.filename", cfg.DataCache.Filename, "/usr/db/db.db")
 	cmpInts(t, "datacache.cache_size", cfg.DataCache.CacheSize, 10000000)
@@ -383,6 +403,20 @@ func TestNegativePrometheusTimeout(t *testing.T) {
 	assertOneError(t, cfg.validate(), "metrics.prometheus.timeout_ms must be positive if metrics.prometheus.port is defined. Got timeout=0 and port=8001")
 }
 
+func TestStatsDValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Metrics.StatsD.Host = "localhost"
+	assert.Empty(t, cfg.validate(), "The default StatsD config should be valid once a host is defined")
+
+	cfg.Metrics.StatsD.Flavor = "graphite"
+	assertOneError(t, cfg.validate(), "metrics.statsd.flavor must be \"statsd\" or \"dogstatsd\". Got \"graphite\"")
+
+	cfg = newDefaultConfig(t)
+	cfg.Metrics.StatsD.Host = "localhost"
+	cfg.Metrics.StatsD.SampleRates = map[string]float64{"adapter_prices": 0}
+	assertOneError(t, cfg.validate(), "metrics.statsd.sample_rates.adapter_prices must be in the range (0, 1]. Got 0.000000")
+}
+
 func TestOverflowedVendorID(t *testing.T) {
 	cfg := newDefaultConfig(t)
 	cfg.GDPR.HostVendorID = (0xffff) + 1
@@ -487,6 +521,403 @@ func TestValidateDebug(t *testing.T) {
 	assert.NotNil(t, err, "cfg.debug.timeout_notification.sampling_rate should not be allowed to be greater than 1.0, but it was allowed")
 }
 
+func TestValidateFixtureCapture(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	assert.False(t, cfg.Debug.FixtureCapture.Captures("appnexus"), "Nothing should be captured by default")
+
+	cfg.Debug.FixtureCapture.Bidders = []string{"appnexus"}
+	cfg.Debug.FixtureCapture.SamplingRate = 0.5
+	assertOneError(t, cfg.validate(), "debug.fixture_capture.directory must be set to capture the auctions of debug.fixture_capture.bidders")
+
+	cfg.Debug.FixtureCapture.Directory = "/var/fixtures"
+	assert.Empty(t, cfg.validate())
+	assert.True(t, cfg.Debug.FixtureCapture.Captures("appnexus"))
+	assert.False(t, cfg.Debug.FixtureCapture.Captures("rubicon"))
+
+	cfg.Debug.FixtureCapture.MaxFiles = -1
+	assertOneError(t, cfg.validate(), "debug.fixture_capture.max_files must be >= 0. Got -1")
+}
+
+func TestValidateTrace(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	assert.False(t, cfg.Debug.Trace.Enabled(), "Nobody should get a trace by default")
+	assert.Equal(t, openrtb_ext.TraceNone, cfg.Debug.Trace.LevelForAccount("any-account", openrtb_ext.TraceVerbose))
+
+	cfg.Debug.Trace.MaxLevel = "full"
+	assertOneError(t, cfg.validate(), "debug.trace.max_level must be one of \"verbose\", \"basic\" or \"none\". Got \"full\"")
+
+	cfg = newDefaultConfig(t)
+	cfg.Debug.Trace.VerboseAccounts = []string{"pub-1", "pub-2"}
+	cfg.Debug.Trace.BasicAccounts = []string{"pub-2"}
+	assertOneError(t, cfg.validate(), "debug.trace: account \"pub-2\" can't be in both verbose_accounts and basic_accounts")
+}
+
+func TestTraceLevelForAccount(t *testing.T) {
+	cfg := Trace{
+		MaxLevel:      "basic",
+		AccountLevels: map[string]string{"verbose-pub": "verbose", "hidden-pub": "none"},
+	}
+	assert.True(t, cfg.Enabled())
+	assert.Equal(t, openrtb_ext.TraceVerbose, cfg.LevelForAccount("verbose-pub", openrtb_ext.TraceVerbose))
+	assert.Equal(t, openrtb_ext.TraceBasic, cfg.LevelForAccount("verbose-pub", openrtb_ext.TraceBasic))
+	assert.Equal(t, openrtb_ext.TraceBasic, cfg.LevelForAccount("other-pub", openrtb_ext.TraceVerbose), "Accounts should get the most detailed trace they're allowed")
+	assert.Equal(t, openrtb_ext.TraceNone, cfg.LevelForAccount("other-pub", openrtb_ext.TraceNone))
+	assert.Equal(t, openrtb_ext.TraceNone, cfg.LevelForAccount("hidden-pub", openrtb_ext.TraceBasic))
+}
+
+func TestValidateTracing(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Tracing.Enabled = true
+	assert.Empty(t, cfg.validate(), "The default tracing config should be valid once enabled")
+
+	cfg.Tracing.SampleRate = 1.5
+	assertOneError(t, cfg.validate(), "tracing.sample_rate must be between 0 and 1. Got 1.500000")
+
+	cfg = newDefaultConfig(t)
+	cfg.Tracing.Enabled = true
+	cfg.Tracing.Exporter = "file"
+	assertOneError(t, cfg.validate(), "tracing.file_path must be defined if tracing.exporter is \"file\"")
+
+	cfg = newDefaultConfig(t)
+	cfg.Tracing.Enabled = true
+	cfg.Tracing.Exporter = "zipkin"
+	assertOneError(t, cfg.validate(), "tracing.exporter must be one of \"stdout\", \"file\" or \"otlp\". Got \"zipkin\"")
+
+	cfg.Tracing.Enabled = false
+	assert.Empty(t, cfg.validate(), "The tracing config shouldn't be validated if it's disabled")
+}
+
+func TestValidateAdQuality(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	assert.Equal(t, AdQuality{
+		BlockedAdvertisers: AdQualityWarn,
+		BlockedCategories:  AdQualityWarn,
+		BlockedAttributes:  AdQualityWarn,
+		BlockedApps:        AdQualityWarn,
+	}, cfg.AdQuality, "Every ad quality rule should only warn by default")
+	assert.True(t, cfg.AdQuality.Enabled())
+
+	cfg.AdQuality.BlockedCategories = AdQualityReject
+	cfg.AdQuality.BlockedApps = AdQualityOff
+	assert.Empty(t, cfg.validate())
+
+	cfg.AdQuality.BlockedAttributes = "drop"
+	assertOneError(t, cfg.validate(), "ad_quality.battr must be one of \"reject\", \"warn\" or \"off\". Got \"drop\"")
+
+	cfg.AdQuality = AdQuality{
+		BlockedAdvertisers: AdQualityOff,
+		BlockedCategories:  AdQualityOff,
+		BlockedAttributes:  AdQualityOff,
+		BlockedApps:        AdQualityOff,
+	}
+	assert.False(t, cfg.AdQuality.Enabled(), "Ad quality shouldn't be enabled if every rule is off")
+}
+
+func TestValidateSecureMarkup(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	assert.Equal(t, SecureMarkupWarn, cfg.SecureMarkup.ModeForAccount("any-account"), "Markup should only be warned about by default")
+
+	cfg.SecureMarkup.Mode = "block"
+	assertOneError(t, cfg.validate(), "secure_markup.mode must be one of \"enforce\", \"warn\" or \"off\". Got \"block\"")
+
+	cfg = newDefaultConfig(t)
+	cfg.SecureMarkup.MaxMarkupBytes = -1
+	assertOneError(t, cfg.validate(), "secure_markup.max_markup_bytes must be >= 0. Got -1")
+
+	cfg = newDefaultConfig(t)
+	cfg.SecureMarkup.DisallowedScripts = []string{"eval("}
+	assertOneError(t, cfg.validate(), "secure_markup.disallowed_script_patterns contains an invalid pattern \"eval(\": error parsing regexp: missing closing ): `eval(`")
+
+	cfg = newDefaultConfig(t)
+	cfg.SecureMarkup.EnforceAccounts = []string{"pub-1", "pub-2"}
+	cfg.SecureMarkup.WarnAccounts = []string{"pub-2"}
+	assertOneError(t, cfg.validate(), "secure_markup: account \"pub-2\" can't be in both enforce_accounts and warn_accounts")
+
+	cfg = newDefaultConfig(t)
+	cfg.SecureMarkup.WarnAccounts = []string{"pub-1"}
+	cfg.SecureMarkup.OffAccounts = []string{"pub-1", "pub-2"}
+	assertOneError(t, cfg.validate(), "secure_markup: account \"pub-1\" can't be in both warn_accounts and off_accounts")
+}
+
+func TestValidateLegacyAuction(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.LegacyAuction.OpenRTBBidders = []string{"appnexus", "districtm"}
+	assert.Empty(t, cfg.validate())
+	assert.True(t, cfg.LegacyAuction.UsesOpenRTB("districtm"))
+	assert.False(t, cfg.LegacyAuction.UsesOpenRTB("rubicon"))
+
+	cfg.LegacyAuction.OpenRTBBidders = []string{"indexExchange"}
+	assertOneError(t, cfg.validate(), "legacy_auction.openrtb_bidders contains the unknown bidder indexExchange")
+}
+
+func TestThrottling(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer([]byte(`
+throttling:
+  rules:
+    - bidder: appnexus
+      accounts: ["acct-1"]
+      media_types: ["video"]
+      device_types: [3, 7]
+      countries: ["FRA"]
+      percent: 25
+    - bidder: rubicon
+      max_qps: 100
+`)))
+	cfg, err := New(v)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.Len(t, cfg.Throttling.Rules, 2) {
+		return
+	}
+	percent := 25.0
+	assert.Equal(t, ThrottlingRule{
+		Bidder:      "appnexus",
+		Accounts:    []string{"acct-1"},
+		MediaTypes:  []string{"video"},
+		DeviceTypes: []int{3, 7},
+		Countries:   []string{"FRA"},
+		Percent:     &percent,
+	}, cfg.Throttling.Rules[0])
+	assert.Equal(t, ThrottlingRule{Bidder: "rubicon", MaxQPS: 100}, cfg.Throttling.Rules[1])
+}
+
+func TestValidateThrottling(t *testing.T) {
+	none := 0.0
+	tooMuch := 101.0
+	testCases := []struct {
+		rule     ThrottlingRule
+		expected string
+	}{
+		{ThrottlingRule{Bidder: "unknown", Percent: &none}, "throttling.rules[0].bidder must be a known bidder. Got \"unknown\""},
+		{ThrottlingRule{Bidder: "appnexus", MediaTypes: []string{"display"}, Percent: &none}, "throttling.rules[0].media_types must be banner, video, audio or native. Got \"display\""},
+		{ThrottlingRule{Bidder: "appnexus"}, "throttling.rules[0] must set percent or max_qps"},
+		{ThrottlingRule{Bidder: "appnexus", Percent: &tooMuch}, "throttling.rules[0].percent must be between 0 and 100. Got 101.000000"},
+		{ThrottlingRule{Bidder: "appnexus", Percent: &none, MaxQPS: -1}, "throttling.rules[0].max_qps must be >= 0. Got -1"},
+	}
+	for _, test := range testCases {
+		cfg := newDefaultConfig(t)
+		cfg.Throttling.Rules = []ThrottlingRule{test.rule}
+		assertOneError(t, cfg.validate(), test.expected)
+	}
+
+	cfg := newDefaultConfig(t)
+	cfg.Throttling.Rules = []ThrottlingRule{{Bidder: "appnexus", MediaTypes: []string{"banner"}, Percent: &none}, {Bidder: "rubicon", MaxQPS: 10}}
+	assert.Empty(t, cfg.validate())
+}
+
+func TestTargeting(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer([]byte(`
+targeting:
+  max_key_length: 24
+  account_overrides:
+    - accounts: ["acct-1", "acct-2"]
+      max_key_length: 40
+`)))
+	cfg, err := New(v)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 40, cfg.Targeting.MaxKeyLengthForAccount("acct-1"))
+	assert.Equal(t, 40, cfg.Targeting.MaxKeyLengthForAccount("acct-2"))
+	assert.Equal(t, 24, cfg.Targeting.MaxKeyLengthForAccount("acct-3"))
+	assert.Equal(t, 20, newDefaultConfig(t).Targeting.MaxKeyLengthForAccount("acct-1"), "The default limit should be 20")
+	assert.Equal(t, 26, cfg.Targeting.MaxPrefixLengthForAccount("acct-1"))
+	assert.Equal(t, 10, cfg.Targeting.MaxPrefixLengthForAccount("acct-3"))
+	assert.Equal(t, 6, newDefaultConfig(t).Targeting.MaxPrefixLengthForAccount("acct-1"))
+}
+
+func TestValidateTargeting(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Targeting.MaxKeyLength = 10
+	assertOneError(t, cfg.validate(), "targeting.max_key_length must be >= 16. Got 10")
+
+	cfg = newDefaultConfig(t)
+	cfg.Targeting.AccountOverrides = []TargetingAccountOverride{{Accounts: []string{"acct-1"}, MaxKeyLength: 0}}
+	assertOneError(t, cfg.validate(), "targeting.account_overrides[0].max_key_length must be >= 16. Got 0")
+}
+
+func TestValidateVAST(t *testing.T) {
+	testCases := []struct {
+		vast     VAST
+		expected string
+	}{
+		{VAST{Version: "5.0"}, `vast.version must be one of "2.0", "3.0" or "4.0". Got "5.0"`},
+		{VAST{ImpressionTrackers: []string{"https://t.example.com/imp?bid={{.BidID"}}, `vast.impression_trackers[0] is an invalid template: template: vastTracker:1: unclosed action`},
+		{VAST{ErrorTrackers: []string{"https://t.example.com/err?user={{.UserID}}"}}, `vast.error_trackers[0] uses an unknown macro: template: vastTracker:1:33: executing "vastTracker" at <.UserID>: can't evaluate field UserID in type macros.TrackerTemplateParams`},
+		{VAST{ImpressionTrackers: []string{"not a url"}}, `vast.impression_trackers[0] must be a URL. Got "not a url"`},
+		{VAST{Verifications: []VASTVerification{{Vendor: "vendor"}}}, `vast.verifications[0].javascript_url must be a URL. Got ""`},
+	}
+	for _, test := range testCases {
+		cfg := newDefaultConfig(t)
+		cfg.VAST = test.vast
+		assertOneError(t, cfg.validate(), test.expected)
+	}
+
+	cfg := newDefaultConfig(t)
+	cfg.VAST = VAST{
+		Wrap:               true,
+		Version:            "4.0",
+		ImpressionTrackers: []string{"https://t.example.com/imp?bidder={{.Bidder}}&account={{.AccountID}}&auction={{.AuctionID}}&bid={{.BidID}}"},
+		Verifications:      []VASTVerification{{Vendor: "vendor", JavaScriptURL: "https://vendor.com/omid.js"}},
+	}
+	assert.Empty(t, cfg.validate())
+}
+
+func TestValidateNative(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Native.ImpressionTrackers = []string{"https://t.example.com/imp?bid={{.BidID}}", "not a url"}
+	assertOneError(t, cfg.validate(), `native.impression_trackers[1] must be a URL. Got "not a url"`)
+
+	cfg = newDefaultConfig(t)
+	cfg.Native = Native{
+		Validate:           true,
+		ImpressionTrackers: []string{"https://t.example.com/imp?bidder={{.Bidder}}&account={{.AccountID}}&auction={{.AuctionID}}&bid={{.BidID}}"},
+	}
+	assert.Empty(t, cfg.validate())
+}
+
+func TestValidateDeviceDetection(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.DeviceDetection = DeviceDetection{Enabled: true}
+	assertOneError(t, cfg.validate(), "device_detection.rules_file must be set when device_detection.enabled is true")
+
+	cfg = newDefaultConfig(t)
+	cfg.DeviceDetection.ReloadIntervalSeconds = -1
+	assertOneError(t, cfg.validate(), "device_detection.reload_interval_seconds must be >= 0. Got -1")
+
+	cfg = newDefaultConfig(t)
+	cfg.DeviceDetection = DeviceDetection{Enabled: true, RulesFile: "rules.json"}
+	assert.Empty(t, cfg.validate())
+}
+
+func TestHostAliases(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer([]byte(`
+adapters:
+  appnexus:
+    platform_id: "core-platform"
+  appnexus_video:
+    aliasOf: appnexus
+    usersync_url: "https://sync.appnexus-video.com"
+    gvl_vendor_id: 99
+  appnexus_eu:
+    aliasOf: appnexus
+    endpoint: "https://eu.adnxs.com/openrtb2"
+    platform_id: "eu-platform"
+`)))
+	cfg, err := New(v)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	video := cfg.Adapters["appnexus_video"]
+	assert.Equal(t, "appnexus", video.AliasOf)
+	assert.Equal(t, cfg.Adapters["appnexus"].Endpoint, video.Endpoint, "An alias should default to the endpoint of its core bidder")
+	assert.Equal(t, "core-platform", video.PlatformID, "An alias should default to the credentials of its core bidder")
+	assert.Equal(t, "https://sync.appnexus-video.com", video.UserSyncURL)
+	assert.Equal(t, uint16(99), video.GVLVendorID)
+
+	eu := cfg.Adapters["appnexus_eu"]
+	assert.Equal(t, "https://eu.adnxs.com/openrtb2", eu.Endpoint)
+	assert.Equal(t, "eu-platform", eu.PlatformID)
+	assert.Empty(t, eu.UserSyncURL, "An alias shouldn't sync with the usersync_url of its core bidder")
+}
+
+func TestValidateHostAliases(t *testing.T) {
+	testCases := []struct {
+		description string
+		adapters    map[string]Adapter
+		message     string
+	}{
+		{
+			description: "Unknown core bidder",
+			adapters:    map[string]Adapter{"myalias": {AliasOf: "unknown", Endpoint: "http://example.com"}},
+			message:     "adapters.myalias.aliasOf refers to the unknown bidder unknown",
+		},
+		{
+			description: "Alias of an alias",
+			adapters: map[string]Adapter{
+				"appnexus": {Endpoint: "http://example.com"},
+				"myalias":  {AliasOf: "appnexus", Endpoint: "http://example.com"},
+				"other":    {AliasOf: "myalias", Endpoint: "http://example.com"},
+			},
+			message: "adapters.other.aliasOf refers to myalias, which is an alias itself",
+		},
+		{
+			description: "Core bidder as an alias",
+			adapters: map[string]Adapter{
+				"appnexus": {Endpoint: "http://example.com"},
+				"rubicon":  {AliasOf: "appnexus", Endpoint: "http://example.com"},
+			},
+			message: "adapters.rubicon.aliasOf can't be set, since rubicon is a bidder of its own",
+		},
+	}
+
+	for _, test := range testCases {
+		assertOneError(t, validateAdapters(test.adapters, nil), test.message)
+	}
+}
+
+func TestAdapterHTTPClient(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer([]byte(`
+adapters:
+  appnexus:
+    http_client:
+      max_connections_per_host: 0
+      max_idle_connections_per_host: 50
+      tls_min_version: "1.2"
+      http2: true
+      gzip_requests: true
+`)))
+	cfg, err := New(v)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	client := cfg.Adapters["appnexus"].HTTPClient
+	if assert.NotNil(t, client.MaxConnsPerHost, "A zero limit should be kept, since it means unlimited") {
+		assert.Equal(t, 0, *client.MaxConnsPerHost)
+	}
+	if assert.NotNil(t, client.MaxIdleConnsPerHost) {
+		assert.Equal(t, 50, *client.MaxIdleConnsPerHost)
+	}
+	assert.Nil(t, client.MaxIdleConns, "Unset limits should be inherited from http_client")
+	assert.Equal(t, uint16(tls.VersionTLS12), client.TLSVersion())
+	assert.True(t, client.HTTP2)
+	assert.True(t, client.GzipRequests)
+	assert.True(t, client.OwnsConnections())
+
+	rubicon := cfg.Adapters["rubicon"].HTTPClient
+	assert.False(t, rubicon.OwnsConnections(), "Bidders without overrides should share the global pool")
+}
+
+func TestValidateAdapterHTTPClient(t *testing.T) {
+	negative := -1
+	cfg := newDefaultConfig(t)
+	appnexus := cfg.Adapters["appnexus"]
+	appnexus.HTTPClient.MaxIdleConns = &negative
+	cfg.Adapters["appnexus"] = appnexus
+	assertOneError(t, cfg.validate(), "adapters.appnexus.http_client.max_idle_connections must be >= 0. Got -1")
+
+	cfg = newDefaultConfig(t)
+	appnexus = cfg.Adapters["appnexus"]
+	appnexus.HTTPClient.TLSMinVersion = "1.4"
+	cfg.Adapters["appnexus"] = appnexus
+	assertOneError(t, cfg.validate(), "adapters.appnexus.http_client.tls_min_version must be one of: 1.0, 1.1, 1.2, 1.3. Got 1.4")
+}
+
 func newDefaultConfig(t *testing.T) *Configuration {
 	v := viper.New()
 	SetupViper(v, "")
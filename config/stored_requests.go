@@ -35,6 +35,12 @@ type StoredRequests struct {
 	// HTTPEvents configures an instance of stored_requests/events/http/http.go.
 	// If non-nil, the server will use those endpoints to populate and update the cache.
 	HTTPEvents HTTPEventsConfig `mapstructure:"http_events"`
+	// RedisCache configures an instance of stored_requests/caches/redis/cache.go.
+	// If the address is set, Stored Requests will be saved in a cache which is shared by every Prebid Server instance.
+	RedisCache RedisCache `mapstructure:"redis_cache"`
+	// ValidateOnStartup makes the server validate every Stored Request and Stored Imp it can list when it starts,
+	// and log the IDs of the invalid ones. Only backends which can list their IDs (e.g. the filesystem) are supported.
+	ValidateOnStartup bool `mapstructure:"validate_on_startup"`
 }
 
 // StoredRequestsSlim struct defines options for stored requests from a single endpoint
@@ -58,6 +64,9 @@ type StoredRequestsSlim struct {
 	// HTTPEvents configures an instance of stored_requests/events/http/http.go.
 	// If non-nil, the server will use those endpoints to populate and update the cache.
 	HTTPEvents HTTPEventsConfigSlim `mapstructure:"http_events"`
+	// RedisCache configures an instance of stored_requests/caches/redis/cache.go.
+	// If the address is set, Stored Requests will be saved in a cache which is shared by every Prebid Server instance.
+	RedisCache RedisCache `mapstructure:"redis_cache"`
 }
 
 // HTTPEventsConfigSlim configures stored_requests/events/http/http.go
@@ -119,7 +128,7 @@ type HTTPFetcherConfig struct {
 }
 
 func (cfg *StoredRequests) validate(errs configErrors) configErrors {
-	if cfg.InMemoryCache.Type == "none" {
+	if cfg.InMemoryCache.Type == "none" && cfg.RedisCache.Address == "" {
 		if cfg.CacheEventsAPI {
 			errs = append(errs, errors.New("stored_requests.cache_events_api must be false if stored_requests.in_memory_cache=none"))
 		}
@@ -136,6 +145,7 @@ func (cfg *StoredRequests) validate(errs configErrors) configErrors {
 		}
 	}
 	errs = cfg.InMemoryCache.validate(errs)
+	errs = cfg.RedisCache.validate(errs)
 	errs = cfg.Postgres.validate(errs)
 	return errs
 }
@@ -499,3 +509,42 @@ func (cfg *InMemoryCache) validate(errs configErrors) configErrors {
 	}
 	return errs
 }
+
+// RedisCache configures a Stored Request cache on a server which speaks the Redis protocol.
+// It is meant to be composed with the InMemoryCache, so that every instance shares one cache.
+type RedisCache struct {
+	// Address is the host:port of the server. The cache is disabled if this is empty.
+	Address  string `mapstructure:"address"`
+	Password string `mapstructure:"password"`
+	// DB is the database number which the cache should use.
+	DB int `mapstructure:"db"`
+	// KeyPrefix is prepended to every key, so that the server can be shared with other applications.
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// TTL is the number of seconds after which values expire. TTL <= 0 can be used for "no ttl".
+	TTL int `mapstructure:"ttl_seconds"`
+	// Timeout is the maximum number of milliseconds which a single round trip to the server can take.
+	Timeout int `mapstructure:"timeout_ms"`
+	// PoolSize is the maximum number of idle connections which are kept open.
+	PoolSize int `mapstructure:"pool_size"`
+	// PubSubChannel is the channel used to propagate saves and invalidations from the cache events API
+	// to every other instance. Propagation is disabled if this is empty.
+	PubSubChannel string `mapstructure:"pubsub_channel"`
+}
+
+func (cfg *RedisCache) validate(errs configErrors) configErrors {
+	if cfg.Address == "" {
+		return errs
+	}
+	if cfg.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("stored_requests.redis_cache.timeout_ms must be > 0. Got %d", cfg.Timeout))
+	}
+	if cfg.PoolSize < 0 {
+		errs = append(errs, fmt.Errorf("stored_requests.redis_cache.pool_size must be >= 0. Got %d", cfg.PoolSize))
+	}
+	return errs
+}
+
+// TimeoutDuration returns the Timeout as a time.Duration
+func (cfg *RedisCache) TimeoutDuration() time.Duration {
+	return time.Duration(cfg.Timeout) * time.Millisecond
+}
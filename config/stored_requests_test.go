@@ -114,6 +114,24 @@ func TestInMemoryCacheValidation(t *testing.T) {
 	}).validate(nil))
 }
 
+func TestRedisCacheValidation(t *testing.T) {
+	assertNoErrs(t, (&RedisCache{}).validate(nil))
+	assertNoErrs(t, (&RedisCache{
+		Address:  "localhost:6379",
+		Timeout:  50,
+		PoolSize: 10,
+	}).validate(nil))
+	assertErrsExist(t, (&RedisCache{
+		Address: "localhost:6379",
+		Timeout: 0,
+	}).validate(nil))
+	assertErrsExist(t, (&RedisCache{
+		Address:  "localhost:6379",
+		Timeout:  50,
+		PoolSize: -1,
+	}).validate(nil))
+}
+
 func assertErrsExist(t *testing.T, err configErrors) {
 	t.Helper()
 	if len(err) == 0 {
@@ -0,0 +1,69 @@
+package devicedetection
+
+import (
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+)
+
+// Detector finds the device which sent a User-Agent.
+type Detector interface {
+	// Detect returns the device of the User-Agent, and false if the device isn't known.
+	Detect(userAgent string) (Device, bool)
+}
+
+// Device holds the OpenRTB device fields which a Detector can find. Empty fields weren't detected.
+type Device struct {
+	DeviceType openrtb.DeviceType
+	OS         string
+	OSV        string
+	Make       string
+	Model      string
+}
+
+// NewDetector returns the Detector which the host configured.
+// If device detection is disabled, it returns a NilDetector.
+func NewDetector(cfg config.DeviceDetection) (Detector, error) {
+	if !cfg.Enabled {
+		return NilDetector{}, nil
+	}
+	return NewFileDetector(cfg.RulesFile, cfg.ReloadInterval())
+}
+
+// NilDetector never detects a device.
+type NilDetector struct{}
+
+func (NilDetector) Detect(userAgent string) (Device, bool) {
+	return Device{}, false
+}
+
+// Fill sets the fields of the device which the caller left empty, using the device of its User-Agent.
+// Fields which the caller sent are never overwritten.
+//
+// It returns the detected device, and false if the device of the User-Agent isn't known.
+func Fill(detector Detector, device *openrtb.Device) (Device, bool) {
+	if device == nil || device.UA == "" {
+		return Device{}, false
+	}
+	detected, ok := detector.Detect(device.UA)
+	if !ok {
+		return Device{}, false
+	}
+
+	if device.DeviceType == 0 {
+		device.DeviceType = detected.DeviceType
+	}
+	if device.OS == "" {
+		device.OS = detected.OS
+	}
+	// A version only makes sense for the OS which it belongs to.
+	if device.OSV == "" && device.OS == detected.OS {
+		device.OSV = detected.OSV
+	}
+	if device.Make == "" {
+		device.Make = detected.Make
+	}
+	if device.Model == "" && device.Make == detected.Make {
+		device.Model = detected.Model
+	}
+	return detected, true
+}
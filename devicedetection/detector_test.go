@@ -0,0 +1,86 @@
+package devicedetection
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDetectorDisabled(t *testing.T) {
+	detector, err := NewDetector(config.DeviceDetection{Enabled: false, RulesFile: "missing.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, NilDetector{}, detector)
+}
+
+func TestNewDetectorMissingFile(t *testing.T) {
+	_, err := NewDetector(config.DeviceDetection{Enabled: true, RulesFile: "missing.json"})
+	assert.Error(t, err)
+}
+
+func TestFill(t *testing.T) {
+	detector := rules{{
+		pattern: regexpOrFail(t, "iPhone"),
+		device: Device{
+			DeviceType: openrtb.DeviceTypePhone,
+			OS:         "iOS",
+			OSV:        "13.3",
+			Make:       "Apple",
+			Model:      "iPhone",
+		},
+	}}
+
+	testCases := []struct {
+		description    string
+		device         *openrtb.Device
+		expectedDevice *openrtb.Device
+		expectedOK     bool
+	}{
+		{
+			description:    "Empty fields are filled",
+			device:         &openrtb.Device{UA: "iPhone"},
+			expectedDevice: &openrtb.Device{UA: "iPhone", DeviceType: openrtb.DeviceTypePhone, OS: "iOS", OSV: "13.3", Make: "Apple", Model: "iPhone"},
+			expectedOK:     true,
+		},
+		{
+			description:    "Caller values are kept",
+			device:         &openrtb.Device{UA: "iPhone", DeviceType: openrtb.DeviceTypeMobileTablet, OSV: "14.0", Model: "iPhone12,1"},
+			expectedDevice: &openrtb.Device{UA: "iPhone", DeviceType: openrtb.DeviceTypeMobileTablet, OS: "iOS", OSV: "14.0", Make: "Apple", Model: "iPhone12,1"},
+			expectedOK:     true,
+		},
+		{
+			description:    "The version of another OS isn't filled",
+			device:         &openrtb.Device{UA: "iPhone", OS: "Android"},
+			expectedDevice: &openrtb.Device{UA: "iPhone", DeviceType: openrtb.DeviceTypePhone, OS: "Android", Make: "Apple", Model: "iPhone"},
+			expectedOK:     true,
+		},
+		{
+			description:    "The model of another make isn't filled",
+			device:         &openrtb.Device{UA: "iPhone", Make: "Samsung"},
+			expectedDevice: &openrtb.Device{UA: "iPhone", DeviceType: openrtb.DeviceTypePhone, OS: "iOS", OSV: "13.3", Make: "Samsung"},
+			expectedOK:     true,
+		},
+		{
+			description:    "Unknown devices aren't changed",
+			device:         &openrtb.Device{UA: "Unknown"},
+			expectedDevice: &openrtb.Device{UA: "Unknown"},
+			expectedOK:     false,
+		},
+		{
+			description:    "Devices without a User-Agent aren't changed",
+			device:         &openrtb.Device{},
+			expectedDevice: &openrtb.Device{},
+			expectedOK:     false,
+		},
+	}
+
+	for _, test := range testCases {
+		_, ok := Fill(detector, test.device)
+		assert.Equal(t, test.expectedOK, ok, test.description)
+		assert.Equal(t, test.expectedDevice, test.device, test.description)
+	}
+
+	_, ok := Fill(detector, nil)
+	assert.False(t, ok, "A nil device shouldn't be detected")
+}
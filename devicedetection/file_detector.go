@@ -0,0 +1,94 @@
+package devicedetection
+
+import (
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// FileDetector detects devices with the rules of a local file.
+//
+// If it has a reload interval, it checks the file's modification time at that interval, and loads the file again
+// when it changes. If the new rules can't be loaded, the old ones are kept.
+type FileDetector struct {
+	path           string
+	reloadInterval time.Duration
+	rules          atomic.Value // Should only hold rules
+	modTime        time.Time
+	done           chan bool
+}
+
+// NewFileDetector loads the rules of the file, and returns an error if they can't be loaded.
+func NewFileDetector(path string, reloadInterval time.Duration) (*FileDetector, error) {
+	detector := &FileDetector{
+		path:           path,
+		reloadInterval: reloadInterval,
+		done:           make(chan bool),
+	}
+	if err := detector.load(); err != nil {
+		return nil, err
+	}
+	if reloadInterval > 0 {
+		go detector.startPeriodicReloading()
+	}
+	return detector, nil
+}
+
+// Detect implements the Detector interface.
+func (d *FileDetector) Detect(userAgent string) (Device, bool) {
+	return d.rules.Load().(rules).Detect(userAgent)
+}
+
+// Reload loads the file again if it changed since it was last loaded.
+func (d *FileDetector) Reload() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(d.modTime) {
+		return nil
+	}
+	return d.load()
+}
+
+// StopPeriodicReloading stops checking the file for changes, while keeping the latest rules.
+func (d *FileDetector) StopPeriodicReloading() {
+	close(d.done)
+}
+
+func (d *FileDetector) load() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+	parsed, err := parseRules(data)
+	if err != nil {
+		return err
+	}
+	d.rules.Store(parsed)
+	d.modTime = info.ModTime()
+	return nil
+}
+
+func (d *FileDetector) startPeriodicReloading() {
+	ticker := time.NewTicker(d.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.Reload(); err != nil {
+				glog.Errorf("Error reloading the device detection rules from %s: %v", d.path, err)
+			}
+		case <-d.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package devicedetection
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileDetectorReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devicedetection")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules.json")
+
+	writeRules(t, path, `{"rules": [{"pattern": "Roku", "devicetype": 7}]}`, time.Unix(1000, 0))
+	detector, err := NewFileDetector(path, time.Duration(0))
+	if !assert.NoError(t, err) {
+		return
+	}
+	device, _ := detector.Detect("Roku/DVP-9.10")
+	assert.Equal(t, openrtb.DeviceTypeSetTopBox, device.DeviceType)
+
+	writeRules(t, path, `{"rules": [{"pattern": "Roku", "devicetype": 3}]}`, time.Unix(1000, 0))
+	assert.NoError(t, detector.Reload())
+	device, _ = detector.Detect("Roku/DVP-9.10")
+	assert.Equal(t, openrtb.DeviceTypeSetTopBox, device.DeviceType, "An unchanged file shouldn't be loaded again")
+
+	writeRules(t, path, `{"rules": [{"pattern": "Roku", "devicetype": 3}]}`, time.Unix(2000, 0))
+	assert.NoError(t, detector.Reload())
+	device, _ = detector.Detect("Roku/DVP-9.10")
+	assert.Equal(t, openrtb.DeviceTypeConnectedTV, device.DeviceType, "A changed file should be loaded again")
+
+	writeRules(t, path, `{"rules": [`, time.Unix(3000, 0))
+	assert.Error(t, detector.Reload())
+	device, _ = detector.Detect("Roku/DVP-9.10")
+	assert.Equal(t, openrtb.DeviceTypeConnectedTV, device.DeviceType, "The old rules should be kept if the new ones are invalid")
+
+	// Stopping a detector which never reloads shouldn't block.
+	detector.StopPeriodicReloading()
+}
+
+func TestFileDetectorPeriodicReloading(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devicedetection")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules.json")
+
+	writeRules(t, path, `{"rules": [{"pattern": "Roku", "devicetype": 7}]}`, time.Unix(1000, 0))
+	detector, err := NewFileDetector(path, time.Millisecond)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer detector.StopPeriodicReloading()
+
+	writeRules(t, path, `{"rules": []}`, time.Unix(2000, 0))
+	assert.Eventually(t, func() bool {
+		_, ok := detector.Detect("Roku/DVP-9.10")
+		return !ok
+	}, time.Second, time.Millisecond)
+}
+
+func writeRules(t *testing.T, path string, data string, modTime time.Time) {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set the modification time of %s: %v", path, err)
+	}
+}
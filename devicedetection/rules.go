@@ -0,0 +1,95 @@
+package devicedetection
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+)
+
+// RulesFile is the format of the device detection rules database.
+type RulesFile struct {
+	Rules []RuleData `json:"rules"`
+}
+
+// RuleData describes the device of the User-Agents which match its pattern.
+//
+// The pattern may have named groups called "os", "osv", "make" or "model", whose matches replace
+// the rule's own values. Underscores in the "osv" group are replaced by dots, since
+// User-Agents like "CPU iPhone OS 13_3" use them as version separators.
+type RuleData struct {
+	Pattern    string             `json:"pattern"`
+	DeviceType openrtb.DeviceType `json:"devicetype,omitempty"`
+	OS         string             `json:"os,omitempty"`
+	OSV        string             `json:"osv,omitempty"`
+	Make       string             `json:"make,omitempty"`
+	Model      string             `json:"model,omitempty"`
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	device  Device
+}
+
+// rules checks the User-Agents against each rule in order, so the first matching rule wins.
+type rules []rule
+
+// parseRules compiles the rules of a rules database.
+func parseRules(data []byte) (rules, error) {
+	var file RulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	parsed := make(rules, 0, len(file.Rules))
+	for i, ruleData := range file.Rules {
+		pattern, err := regexp.Compile(ruleData.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d].pattern is invalid: %v", i, err)
+		}
+		if ruleData.DeviceType < 0 || ruleData.DeviceType > openrtb.DeviceTypeSetTopBox {
+			return nil, fmt.Errorf("rules[%d].devicetype must be an OpenRTB device type from 1 to 7. Got %d", i, ruleData.DeviceType)
+		}
+		parsed = append(parsed, rule{
+			pattern: pattern,
+			device: Device{
+				DeviceType: ruleData.DeviceType,
+				OS:         ruleData.OS,
+				OSV:        ruleData.OSV,
+				Make:       ruleData.Make,
+				Model:      ruleData.Model,
+			},
+		})
+	}
+	return parsed, nil
+}
+
+func (r rules) Detect(userAgent string) (Device, bool) {
+	for _, rule := range r {
+		match := rule.pattern.FindStringSubmatch(userAgent)
+		if match == nil {
+			continue
+		}
+		device := rule.device
+		for i, group := range rule.pattern.SubexpNames() {
+			value := strings.TrimSpace(match[i])
+			if value == "" {
+				continue
+			}
+			switch group {
+			case "os":
+				device.OS = value
+			case "osv":
+				device.OSV = strings.Replace(value, "_", ".", -1)
+			case "make":
+				device.Make = value
+			case "model":
+				device.Model = value
+			}
+		}
+		return device, true
+	}
+	return Device{}, false
+}
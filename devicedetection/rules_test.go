@@ -0,0 +1,139 @@
+package devicedetection
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRulesErrors(t *testing.T) {
+	testCases := []struct {
+		description string
+		data        string
+		expected    string
+	}{
+		{"Malformed JSON", `{"rules": [`, "unexpected end of JSON input"},
+		{"Invalid pattern", `{"rules": [{"pattern": "("}]}`, "rules[0].pattern is invalid: error parsing regexp: missing closing ): `(`"},
+		{"Invalid device type", `{"rules": [{"pattern": "Roku"}, {"pattern": "TV", "devicetype": 8}]}`, "rules[1].devicetype must be an OpenRTB device type from 1 to 7. Got 8"},
+	}
+	for _, test := range testCases {
+		_, err := parseRules([]byte(test.data))
+		assert.EqualError(t, err, test.expected, test.description)
+	}
+}
+
+func TestRulesDetect(t *testing.T) {
+	parsed, err := parseRules([]byte(`{"rules": [
+		{"pattern": "Roku", "devicetype": 7, "os": "Roku OS", "make": "Roku"},
+		{"pattern": "(?P<os>Android) (?P<osv>[0-9_]+); (?P<make>\\w+) (?P<model>\\w+)", "devicetype": 4, "os": "Other", "model": "Other"},
+		{"pattern": "Android", "devicetype": 5}
+	]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	device, ok := parsed.Detect("Mozilla/5.0 Roku/DVP-9.10 Android 10; Samsung Galaxy")
+	assert.True(t, ok)
+	assert.Equal(t, Device{DeviceType: openrtb.DeviceTypeSetTopBox, OS: "Roku OS", Make: "Roku"}, device, "The first matching rule should win")
+
+	device, ok = parsed.Detect("Mozilla/5.0 Android 10_1; Samsung Galaxy")
+	assert.True(t, ok)
+	assert.Equal(t, Device{DeviceType: openrtb.DeviceTypePhone, OS: "Android", OSV: "10.1", Make: "Samsung", Model: "Galaxy"}, device, "Named groups should replace the rule's values")
+
+	_, ok = parsed.Detect("Mozilla/5.0 (X11; Linux x86_64)")
+	assert.False(t, ok)
+}
+
+func TestBundledRules(t *testing.T) {
+	detector, err := NewFileDetector("../static/device-detection/rules.json", time.Duration(0))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	testCases := []struct {
+		userAgent string
+		expected  Device
+	}{
+		{
+			"Roku/DVP-9.10 (519.10E04111A)",
+			Device{DeviceType: openrtb.DeviceTypeSetTopBox, OS: "Roku OS", OSV: "9.10", Make: "Roku"},
+		},
+		{
+			"AppleCoreMedia/1.0.0.17K449 (Apple TV; U; CPU OS 13_3 like Mac OS X; en_us)",
+			Device{DeviceType: openrtb.DeviceTypeSetTopBox, OS: "tvOS", OSV: "13.3", Make: "Apple", Model: "Apple TV"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 9; AFTMM Build/PS7233; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/70.0.3538.110 Mobile Safari/537.36",
+			Device{DeviceType: openrtb.DeviceTypeSetTopBox, OS: "Fire OS", Make: "Amazon", Model: "AFTMM"},
+		},
+		{
+			"Mozilla/5.0 (SMART-TV; Linux; Tizen 5.0) AppleWebKit/538.1 (KHTML, like Gecko) Version/5.0 NativeTVAds Safari/538.1",
+			Device{DeviceType: openrtb.DeviceTypeConnectedTV, OS: "Tizen", OSV: "5.0", Make: "Samsung"},
+		},
+		{
+			"Mozilla/5.0 (Web0S; Linux/SmartTV) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/53.0.2785.34 Safari/537.36 WebAppManager",
+			Device{DeviceType: openrtb.DeviceTypeConnectedTV, OS: "webOS", Make: "LG"},
+		},
+		{
+			"Mozilla/5.0 (PlayStation 4 7.02) AppleWebKit/605.1.15 (KHTML, like Gecko)",
+			Device{DeviceType: openrtb.DeviceTypeConnectedDevice, OS: "PlayStation", OSV: "7.02", Make: "Sony", Model: "PlayStation 4"},
+		},
+		{
+			"Mozilla/5.0 (iPad; CPU OS 13_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.4 Mobile/15E148 Safari/604.1",
+			Device{DeviceType: openrtb.DeviceTypeTablet, OS: "iOS", OSV: "13.3", Make: "Apple", Model: "iPad"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 13_3_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.5 Mobile/15E148 Safari/604.1",
+			Device{DeviceType: openrtb.DeviceTypePhone, OS: "iOS", OSV: "13.3.1", Make: "Apple", Model: "iPhone"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 10; SM-G973F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.106 Mobile Safari/537.36",
+			Device{DeviceType: openrtb.DeviceTypePhone, OS: "Android", OSV: "10", Model: "SM-G973F"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 9; en-us; Pixel 3 Build/PQ3A.190801.002) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/76.0.3809.89 Mobile Safari/537.36",
+			Device{DeviceType: openrtb.DeviceTypePhone, OS: "Android", OSV: "9", Model: "Pixel 3"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 9; SM-T510) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.106 Safari/537.36",
+			Device{DeviceType: openrtb.DeviceTypeTablet, OS: "Android", OSV: "9", Model: "SM-T510"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.116 Safari/537.36",
+			Device{DeviceType: openrtb.DeviceTypePersonalComputer, OS: "Windows", OSV: "10.0"},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1.1 Safari/605.1.15",
+			Device{DeviceType: openrtb.DeviceTypePersonalComputer, OS: "macOS", OSV: "10.15.5", Make: "Apple"},
+		},
+		{
+			"Mozilla/5.0 (X11; CrOS x86_64 13020.87.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.119 Safari/537.36",
+			Device{DeviceType: openrtb.DeviceTypePersonalComputer, OS: "Chrome OS", OSV: "13020.87.0"},
+		},
+		{
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:78.0) Gecko/20100101 Firefox/78.0",
+			Device{DeviceType: openrtb.DeviceTypePersonalComputer, OS: "Linux"},
+		},
+	}
+
+	for _, test := range testCases {
+		device, ok := detector.Detect(test.userAgent)
+		assert.True(t, ok, test.userAgent)
+		assert.Equal(t, test.expected, device, test.userAgent)
+	}
+
+	_, ok := detector.Detect("curl/7.64.1")
+	assert.False(t, ok, "Unknown User-Agents shouldn't be detected")
+}
+
+func regexpOrFail(t *testing.T, pattern string) *regexp.Regexp {
+	t.Helper()
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Fatalf("Failed to compile %s: %v", pattern, err)
+	}
+	return compiled
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/julienschmidt/httprouter"
 	"github.com/mssola/user_agent"
+	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/cache"
 	"github.com/prebid/prebid-server/config"
@@ -64,16 +65,19 @@ type auction struct {
 	metricsEngine pbsmetrics.MetricsEngine
 	dataCache     cache.Cache
 	exchanges     map[string]adapters.Adapter
+	// openrtbExchange runs the auctions of the bidders in legacy_auction.openrtb_bidders.
+	openrtbExchange exchange.Exchange
 }
 
-func Auction(cfg *config.Configuration, syncers map[openrtb_ext.BidderName]usersync.Usersyncer, gdprPerms gdpr.Permissions, metricsEngine pbsmetrics.MetricsEngine, dataCache cache.Cache, exchanges map[string]adapters.Adapter) httprouter.Handle {
+func Auction(cfg *config.Configuration, syncers map[openrtb_ext.BidderName]usersync.Usersyncer, gdprPerms gdpr.Permissions, metricsEngine pbsmetrics.MetricsEngine, dataCache cache.Cache, exchanges map[string]adapters.Adapter, openrtbExchange exchange.Exchange) httprouter.Handle {
 	a := &auction{
-		cfg:           cfg,
-		syncers:       syncers,
-		gdprPerms:     gdprPerms,
-		metricsEngine: metricsEngine,
-		dataCache:     dataCache,
-		exchanges:     exchanges,
+		cfg:             cfg,
+		syncers:         syncers,
+		gdprPerms:       gdprPerms,
+		metricsEngine:   metricsEngine,
+		dataCache:       dataCache,
+		exchanges:       exchanges,
+		openrtbExchange: openrtbExchange,
 	}
 	return a.auction
 }
@@ -114,8 +118,12 @@ func (a *auction) auction(w http.ResponseWriter, r *http.Request, _ httprouter.P
 	}
 	ch := make(chan bidResult)
 	sentBids := 0
+	var openrtbBidders []*pbs.PBSBidder
 	for _, bidder := range req.Bidders {
-		if ex, ok := a.exchanges[bidder.BidderCode]; ok {
+		if a.cfg.LegacyAuction.UsesOpenRTB(bidder.BidderCode) {
+			a.processUserSync(req, bidder, pbsmetrics.AdapterLabels{}, nil, &ctx)
+			openrtbBidders = append(openrtbBidders, bidder)
+		} else if ex, ok := a.exchanges[bidder.BidderCode]; ok {
 			// Make sure we have an independent label struct for each bidder. We don't want to run into issues with the goroutine below.
 			blabels := pbsmetrics.AdapterLabels{
 				Source:      labels.Source,
@@ -152,6 +160,9 @@ func (a *auction) auction(w http.ResponseWriter, r *http.Request, _ httprouter.P
 			bidder.Error = "Unsupported bidder"
 		}
 	}
+	if len(openrtbBidders) > 0 {
+		resp.Bids = append(resp.Bids, a.openrtbAuction(ctx, req, openrtbBidders, labels)...)
+	}
 	for i := 0; i < sentBids; i++ {
 		result := <-ch
 		for _, bid := range result.bidList {
@@ -174,6 +185,48 @@ func (a *auction) auction(w http.ResponseWriter, r *http.Request, _ httprouter.P
 	enc.Encode(resp)
 }
 
+// openrtbAuction gets the bids of the bidders which the host moved to the OpenRTB exchange.
+// The exchange takes care of their privacy policies and metrics, so only the cache and targeting keys are left to the legacy code.
+func (a *auction) openrtbAuction(ctx context.Context, req *pbs.PBSRequest, bidders []*pbs.PBSBidder, labels pbsmetrics.Labels) pbs.PBSBidSlice {
+	bidRequest, err := pbs.ToOpenRTBRequest(req, bidders)
+	if err == nil {
+		usersyncs := req.Cookie
+		if usersyncs == nil {
+			usersyncs = usersync.NewPBSCookie()
+		}
+		var bidResponse *openrtb.BidResponse
+		if bidResponse, err = a.openrtbExchange.HoldAuction(ctx, bidRequest, usersyncs, labels, nil, nil); err == nil {
+			var bids pbs.PBSBidSlice
+			if bids, err = pbs.FromOpenRTBResponse(bidResponse, bidders); err == nil {
+				return checkOpenRTBBidSizes(bids, bidders)
+			}
+		}
+	}
+
+	glog.Errorf("Legacy auction failed to get the OpenRTB bids: %v", err)
+	for _, bidder := range bidders {
+		bidder.Error = err.Error()
+	}
+	return nil
+}
+
+// checkOpenRTBBidSizes applies checkForValidBidSize to the bids of every bidder.
+func checkOpenRTBBidSizes(bids pbs.PBSBidSlice, bidders []*pbs.PBSBidder) pbs.PBSBidSlice {
+	validBids := make(pbs.PBSBidSlice, 0, len(bids))
+	for _, bidder := range bidders {
+		var bidderBids pbs.PBSBidSlice
+		for _, bid := range bids {
+			if bid.BidderCode == bidder.BidderCode {
+				bidderBids = append(bidderBids, bid)
+			}
+		}
+		bidderBids = checkForValidBidSize(bidderBids, bidder)
+		bidder.NumBids = len(bidderBids)
+		validBids = append(validBids, bidderBids...)
+	}
+	return validBids
+}
+
 func (a *auction) recoverSafely(inner func(*pbs.PBSBidder, pbsmetrics.AdapterLabels)) func(*pbs.PBSBidder, pbsmetrics.AdapterLabels) {
 	return func(bidder *pbs.PBSBidder, labels pbsmetrics.AdapterLabels) {
 		defer func() {
@@ -507,6 +560,10 @@ func (a *auction) processUserSync(req *pbs.PBSRequest, bidder *pbs.PBSBidder, bl
 		syncerCode = "appnexus"
 	}
 	syncer := a.syncers[openrtb_ext.BidderName(syncerCode)]
+	if syncer == nil {
+		// Some of the legacy_auction.openrtb_bidders and host aliases have no syncer, so they have no cookie to look for.
+		return skip
+	}
 	uid, _, _ := req.Cookie.GetUID(syncer.FamilyName())
 	if uid == "" {
 		bidder.NoCookie = true
@@ -525,7 +582,7 @@ func (a *auction) processUserSync(req *pbs.PBSRequest, bidder *pbs.PBSBidder, bl
 			}
 		}
 		blabels.CookieFlag = pbsmetrics.CookieFlagNo
-		if ex.SkipNoCookies() {
+		if ex != nil && ex.SkipNoCookies() {
 			skip = true
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/cache/dummycache"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbs"
@@ -20,8 +22,11 @@ import (
 	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
 	"github.com/prebid/prebid-server/prebid_cache_client"
 	gdprPolicy "github.com/prebid/prebid-server/privacy/gdpr"
+	"github.com/prebid/prebid-server/stored_requests"
+	"github.com/prebid/prebid-server/usersync"
 	"github.com/prebid/prebid-server/usersync/usersyncers"
 	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSortBidsAndAddKeywordsForMobile(t *testing.T) {
@@ -619,3 +624,78 @@ func TestPanicRecovery(t *testing.T) {
 	recovered := dummy.recoverSafely(panicker)
 	recovered(nil, pbsmetrics.AdapterLabels{})
 }
+
+func TestOpenRTBAuction(t *testing.T) {
+	ex := &legacyMockExchange{response: &openrtb.BidResponse{
+		SeatBid: []openrtb.SeatBid{{
+			Seat: "appnexus",
+			Bid: []openrtb.Bid{
+				{ID: "bid", ImpID: "unit", Price: 1, Ext: json.RawMessage(`{"prebid":{"type":"banner"}}`)},
+			},
+		}},
+		Ext: json.RawMessage(`{"responsetimemillis":{"appnexus":20}}`),
+	}}
+	a := auction{openrtbExchange: ex}
+	bidder := &pbs.PBSBidder{BidderCode: "appnexus", AdUnits: []pbs.PBSAdUnit{{
+		Code:       "unit",
+		BidID:      "bid-id",
+		Sizes:      []openrtb.Format{{W: 300, H: 250}},
+		MediaTypes: []pbs.MediaType{pbs.MEDIA_TYPE_BANNER},
+		Params:     json.RawMessage(`{"placementId":1}`),
+	}}}
+	req := &pbs.PBSRequest{AccountID: "account", Tid: "tid", Bidders: []*pbs.PBSBidder{bidder}}
+
+	bids := a.openrtbAuction(context.Background(), req, []*pbs.PBSBidder{bidder}, pbsmetrics.Labels{})
+
+	if assert.NotNil(t, ex.request) {
+		assert.JSONEq(t, `{"appnexus":{"placementId":1}}`, string(ex.request.Imp[0].Ext))
+	}
+	if assert.Len(t, bids, 1) {
+		assert.Equal(t, "bid-id", bids[0].BidID)
+		assert.Equal(t, 1.0, bids[0].Price)
+		assert.Equal(t, uint64(300), bids[0].Width, "Undimensioned banners should get the size of their ad unit")
+		assert.Equal(t, uint64(250), bids[0].Height)
+	}
+	assert.Equal(t, 1, bidder.NumBids)
+	assert.Equal(t, 20, bidder.ResponseTime)
+	assert.Empty(t, bidder.Error)
+}
+
+// TestProcessUserSyncWithoutSyncer makes sure that bidders which have no syncer are auctioned without a cookie lookup.
+func TestProcessUserSyncWithoutSyncer(t *testing.T) {
+	a := auction{syncers: map[openrtb_ext.BidderName]usersync.Usersyncer{}}
+	bidder := &pbs.PBSBidder{BidderCode: "kidoz"}
+	req := &pbs.PBSRequest{Cookie: usersync.NewPBSCookie(), Bidders: []*pbs.PBSBidder{bidder}}
+	ctx := context.Background()
+
+	assert.False(t, a.processUserSync(req, bidder, pbsmetrics.AdapterLabels{}, nil, &ctx))
+	assert.False(t, bidder.NoCookie)
+	assert.Nil(t, bidder.UsersyncInfo)
+}
+
+func TestOpenRTBAuctionError(t *testing.T) {
+	a := auction{openrtbExchange: &legacyMockExchange{err: errors.New("exchange failed")}}
+	bidders := []*pbs.PBSBidder{
+		{BidderCode: "appnexus", AdUnits: []pbs.PBSAdUnit{{Code: "unit"}}},
+		{BidderCode: "rubicon", AdUnits: []pbs.PBSAdUnit{{Code: "unit"}}},
+	}
+	req := &pbs.PBSRequest{AccountID: "account", Bidders: bidders}
+
+	bids := a.openrtbAuction(context.Background(), req, bidders, pbsmetrics.Labels{})
+
+	assert.Empty(t, bids)
+	for _, bidder := range bidders {
+		assert.Equal(t, "exchange failed", bidder.Error)
+	}
+}
+
+type legacyMockExchange struct {
+	request  *openrtb.BidRequest
+	response *openrtb.BidResponse
+	err      error
+}
+
+func (e *legacyMockExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, ids exchange.IdFetcher, labels pbsmetrics.Labels, categoriesFetcher *stored_requests.CategoryFetcher, debugLog *exchange.DebugLog) (*openrtb.BidResponse, error) {
+	e.request = bidRequest
+	return e.response, e.err
+}
@@ -18,13 +18,17 @@ import (
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/privacy"
+	"github.com/prebid/prebid-server/privacy/ccpa"
+	"github.com/prebid/prebid-server/privacy/gdpr"
 	"github.com/prebid/prebid-server/stored_requests"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/usersync"
 	"github.com/prebid/prebid-server/util/iputil"
 )
@@ -36,6 +40,7 @@ type AmpResponse struct {
 	Debug     *openrtb_ext.ExtResponseDebug                           `json:"debug,omitempty"`
 	Errors    map[openrtb_ext.BidderName][]openrtb_ext.ExtBidderError `json:"errors,omitempty"`
 	Warnings  map[openrtb_ext.BidderName][]openrtb_ext.ExtBidderError `json:"warnings,omitempty"`
+	Trace     []openrtb_ext.ExtTraceEvent                             `json:"trace,omitempty"`
 }
 
 // NewAmpEndpoint modifies the OpenRTB endpoint to handle AMP requests. This will basically modify the parsing
@@ -51,6 +56,7 @@ func NewAmpEndpoint(
 	disabledBidders map[string]string,
 	defReqJSON []byte,
 	bidderMap map[string]openrtb_ext.BidderName,
+	deviceDetector devicedetection.Detector,
 ) (httprouter.Handle, error) {
 
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
@@ -79,7 +85,8 @@ func NewAmpEndpoint(
 		bidderMap,
 		nil,
 		nil,
-		ipValidator}).AmpAuction), nil
+		ipValidator,
+		deviceDetector}).AmpAuction), nil
 
 }
 
@@ -127,6 +134,7 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 	w.Header().Set("AMP-Access-Control-Allow-Source-Origin", origin)
 	w.Header().Set("Access-Control-Expose-Headers", "AMP-Access-Control-Allow-Source-Origin")
 
+	r, trace := deps.startAuctionTrace(r)
 	req, errL := deps.parseAmpRequest(r)
 	ao.Errors = append(ao.Errors, errL...)
 
@@ -139,7 +147,7 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 		return
 	}
 
-	ctx := context.Background()
+	ctx := tracing.Detach(r.Context())
 	var cancel context.CancelFunc
 	if req.TMax > 0 {
 		ctx, cancel = context.WithDeadline(ctx, start.Add(time.Duration(req.TMax)*time.Millisecond))
@@ -172,6 +180,7 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 		ao.Errors = append(ao.Errors, acctIdErr)
 		return
 	}
+	deps.setTraceLevel(trace, req, labels.PubID)
 
 	response, err := deps.ex.HoldAuction(ctx, req, usersyncs, labels, &deps.categories, nil)
 	ao.AuctionResponse = response
@@ -188,7 +197,8 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 	// Need to extract the targeting parameters from the response, as those are all that
 	// go in the AMP response
 	targets := map[string]string{}
-	byteCache := []byte("\"hb_cache_id")
+	targetingPrefix, _ := jsonparser.GetString(req.Ext, "prebid", "targeting", "prefix")
+	byteCache := []byte("\"" + string(openrtb_ext.HbCacheKey.WithPrefix(targetingPrefix)))
 	for _, seatBids := range response.SeatBid {
 		for _, bid := range seatBids.Bid {
 			if bytes.Contains(bid.Ext, byteCache) {
@@ -229,12 +239,16 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 		}
 		warnings[openrtb_ext.BidderNameGeneral] = append(warnings[openrtb_ext.BidderNameGeneral], bidderErr)
 	}
+	for bidder, bidderWarnings := range extResponse.Warnings {
+		warnings[bidder] = append(warnings[bidder], bidderWarnings...)
+	}
 
 	// Now JSONify the targets for the AMP response.
 	ampResponse := AmpResponse{
 		Targeting: targets,
 		Errors:    extResponse.Errors,
 		Warnings:  warnings,
+		Trace:     extResponse.Trace,
 	}
 
 	ao.AmpTargetingValues = targets
@@ -269,6 +283,15 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 //
 // If the errors list has at least one element, then no guarantees are made about the returned request.
 func (deps *endpointDeps) parseAmpRequest(httpRequest *http.Request) (req *openrtb.BidRequest, errs []error) {
+	ctx, span := tracing.StartSpan(httpRequest.Context(), "parse_request")
+	defer func() {
+		if fatalErrs := errortypes.FatalOnly(errs); len(fatalErrs) > 0 {
+			span.SetError(fatalErrs[0])
+		}
+		span.End()
+	}()
+	httpRequest = httpRequest.WithContext(ctx)
+
 	// Load the stored request for the AMP ID.
 	req, e := deps.loadRequestJSONForAmp(httpRequest)
 	if errs = append(errs, e...); errortypes.ContainsFatalError(errs) {
@@ -302,10 +325,10 @@ func (deps *endpointDeps) loadRequestJSONForAmp(httpRequest *http.Request) (req
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(storedRequestTimeoutMillis)*time.Millisecond)
+	ctx, cancel := context.WithTimeout(tracing.Detach(httpRequest.Context()), time.Duration(storedRequestTimeoutMillis)*time.Millisecond)
 	defer cancel()
 
-	storedRequests, _, errs := deps.storedReqFetcher.FetchRequests(ctx, []string{ampID}, nil)
+	storedRequests, _, errs := fetchStoredData(ctx, deps.storedReqFetcher, []string{ampID}, nil)
 	if len(errs) > 0 {
 		return nil, errs
 	}
@@ -315,7 +338,24 @@ func (deps *endpointDeps) loadRequestJSONForAmp(httpRequest *http.Request) (req
 	}
 
 	// The fetched config becomes the entire OpenRTB request
-	requestJSON := storedRequests[ampID]
+	requestJSON, version, err := stored_requests.ResolveVersion(ampID, storedRequests[ampID], deps.userVersionSeed(httpRequest))
+	if err != nil {
+		errs = []error{err}
+		return
+	}
+	if requestJSON, err = setStoredRequestVersion(requestJSON, version); err != nil {
+		errs = []error{err}
+		return
+	}
+	if requestJSON, err = setAmpData(requestJSON, httpRequest.URL.Query()); err != nil {
+		errs = []error{err}
+		return
+	}
+	tracing.AuctionTraceFromContext(ctx).Basic("stored_request", "", "loaded the AMP stored request %s%s", ampID, describeStoredVersion(version))
+	if requestJSON, err = openrtb_ext.NormalizeOpenRTB26Request(requestJSON); err != nil {
+		errs = []error{err}
+		return
+	}
 	if err := json.Unmarshal(requestJSON, req); err != nil {
 		errs = []error{err}
 		return
@@ -393,24 +433,39 @@ func (deps *endpointDeps) overrideWithParams(httpRequest *http.Request, req *ope
 		req.Imp[0].TagID = slot
 	}
 
-	consent := readConsent(httpRequest.URL)
-	if consent != "" {
-		if policies, ok := privacy.ReadPoliciesFromConsent(consent); ok {
-			if err := policies.Write(req); err != nil {
-				return []error{err}
-			}
-		} else {
-			return []error{&errortypes.InvalidPrivacyConsent{
-				Message: fmt.Sprintf("Consent '%s' is not recognized as either CCPA or GDPR TCF.", consent),
-			}}
+	var errs []error
+	query := httpRequest.URL.Query()
+	if err := setAmpTargeting(req, query.Get("targeting")); err != nil {
+		if !isWarning(err) {
+			return []error{err}
 		}
+		errs = append(errs, err)
+	}
+
+	if policies, err := readPolicies(query); err != nil {
+		errs = append(errs, err)
+	} else if policies != (privacy.Policies{}) {
+		if err := policies.Write(req); err != nil {
+			return []error{err}
+		}
+	}
+	if err := setAmpConsentParams(req, query); err != nil {
+		if !isWarning(err) {
+			return []error{err}
+		}
+		errs = append(errs, err)
 	}
 
 	if timeout, err := strconv.ParseInt(httpRequest.FormValue("timeout"), 10, 64); err == nil {
 		req.TMax = timeout - deps.cfg.AMPTimeoutAdjustment
 	}
 
-	return nil
+	return errs
+}
+
+func isWarning(err error) bool {
+	coder, ok := err.(errortypes.Coder)
+	return ok && coder.Severity() == errortypes.SeverityWarning
 }
 
 func makeFormatReplacement(overrideWidth uint64, overrideHeight uint64, width uint64, height uint64, multisize string) []openrtb.Format {
@@ -548,11 +603,181 @@ func setAmpExt(site *openrtb.Site, value string) {
 	}
 }
 
-func readConsent(url *url.URL) string {
-	if v := url.Query().Get("consent_string"); v != "" {
+func readConsent(query url.Values) string {
+	if v := query.Get("consent_string"); v != "" {
 		return v
 	}
 
 	// Fallback to 'gdpr_consent' for compatability until it's no longer used by AMP.
-	return url.Query().Get("gdpr_consent")
+	return query.Get("gdpr_consent")
+}
+
+// The values of the consent_type param, which amp-consent sets to the type of the consent string.
+const (
+	ampConsentTypeTCFV1     = "1"
+	ampConsentTypeTCFV2     = "2"
+	ampConsentTypeUSPrivacy = "3"
+)
+
+// readPolicies reads the consent string as the type given by the consent_type param.
+// If AMP didn't send a consent_type, the type is guessed from the string itself.
+func readPolicies(query url.Values) (privacy.Policies, error) {
+	consent := readConsent(query)
+	if consent == "" {
+		return privacy.Policies{}, nil
+	}
+
+	switch consentType := query.Get("consent_type"); consentType {
+	case "":
+		if policies, ok := privacy.ReadPoliciesFromConsent(consent); ok {
+			return policies, nil
+		}
+		return privacy.Policies{}, &errortypes.InvalidPrivacyConsent{
+			Message: fmt.Sprintf("Consent '%s' is not recognized as either CCPA or GDPR TCF.", consent),
+		}
+	case ampConsentTypeTCFV1, ampConsentTypeTCFV2:
+		if err := gdpr.ValidateConsent(consent); err != nil {
+			return privacy.Policies{}, &errortypes.InvalidPrivacyConsent{
+				Message: fmt.Sprintf("Consent '%s' is not a valid GDPR TCF string.", consent),
+			}
+		}
+		return privacy.Policies{GDPR: gdpr.Policy{Consent: consent}}, nil
+	case ampConsentTypeUSPrivacy:
+		if err := ccpa.ValidateConsent(consent); err != nil {
+			return privacy.Policies{}, &errortypes.InvalidPrivacyConsent{
+				Message: fmt.Sprintf("Consent '%s' is not a valid US Privacy string.", consent),
+			}
+		}
+		return privacy.Policies{CCPA: ccpa.Policy{Value: consent}}, nil
+	default:
+		return privacy.Policies{}, &errortypes.InvalidPrivacyConsent{
+			Message: fmt.Sprintf("Consent type '%s' is not supported, so consent '%s' was ignored.", consentType, consent),
+		}
+	}
+}
+
+// setAmpConsentParams sets regs.ext.gdpr from the gdpr_applies param, and the Additional Consent string of the
+// addtl_consent param in user.ext.ConsentedProvidersSettings.consented_providers.
+func setAmpConsentParams(req *openrtb.BidRequest, query url.Values) error {
+	var warning error
+	if gdprApplies := query.Get("gdpr_applies"); gdprApplies != "" {
+		var signal json.RawMessage
+		switch gdprApplies {
+		case "true":
+			signal = json.RawMessage(`1`)
+		case "false":
+			signal = json.RawMessage(`0`)
+		default:
+			warning = &errortypes.InvalidPrivacyConsent{
+				Message: fmt.Sprintf("gdpr_applies must be \"true\" or \"false\". Got '%s'", gdprApplies),
+			}
+		}
+		if signal != nil {
+			if req.Regs == nil {
+				req.Regs = &openrtb.Regs{}
+			}
+			ext, err := mergeExt(req.Regs.Ext, map[string]json.RawMessage{"gdpr": signal})
+			if err != nil {
+				return fmt.Errorf("request.regs.ext is invalid: %v", err)
+			}
+			req.Regs.Ext = ext
+		}
+	}
+
+	if addtlConsent := query.Get("addtl_consent"); addtlConsent != "" {
+		value, err := json.Marshal(addtlConsent)
+		if err != nil {
+			return err
+		}
+		if req.User == nil {
+			req.User = &openrtb.User{}
+		}
+		ext, err := mergeExt(req.User.Ext, map[string]json.RawMessage{"consented_providers": value}, "ConsentedProvidersSettings")
+		if err != nil {
+			return fmt.Errorf("request.user.ext is invalid: %v", err)
+		}
+		req.User.Ext = ext
+	}
+	return warning
+}
+
+// setAmpTargeting merges the JSON object of the targeting param, which amp-ad sends from its json attribute,
+// into site.ext.data and imp[0].ext.context.data. Its values win over those of the Stored Request.
+func setAmpTargeting(req *openrtb.BidRequest, targeting string) error {
+	if targeting == "" {
+		return nil
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(targeting), &data); err != nil {
+		return &errortypes.Warning{
+			Message: fmt.Sprintf("targeting param is not a JSON object, so it was ignored: %v", err),
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	siteExt, err := mergeExt(req.Site.Ext, data, "data")
+	if err != nil {
+		return fmt.Errorf("request.site.ext is invalid: %v", err)
+	}
+	impExt, err := mergeExt(req.Imp[0].Ext, data, "context", "data")
+	if err != nil {
+		return fmt.Errorf("request.imp[0].ext is invalid: %v", err)
+	}
+	req.Site.Ext = siteExt
+	req.Imp[0].Ext = impExt
+	return nil
+}
+
+// ampDataParams are the query params which are copied into ext.prebid.amp.data. The consent params and curl
+// are left out, since the data shows up in the debug output and the analytics modules.
+var ampDataParams = []string{"tag_id", "account", "slot", "w", "h", "ow", "oh", "ms", "targeting", "timeout", "debug"}
+
+// setAmpData copies the ampDataParams from the query into ext.prebid.amp.data of the Stored Request, before any
+// of the other params are applied, so that they're available to the rest of the auction.
+// Params which are sent more than once keep their first value.
+func setAmpData(requestJSON []byte, query url.Values) ([]byte, error) {
+	params := make(map[string]string, len(ampDataParams))
+	for _, key := range ampDataParams {
+		if value := query.Get(key); value != "" {
+			params[key] = value
+		}
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	// These keys must be kept in sync with openrtb_ext.ExtRequestPrebidAMP
+	requestJSON, err = jsonparser.Set(requestJSON, data, "ext", openrtb_ext.PrebidExtKey, "amp", "data")
+	if err != nil {
+		return nil, fmt.Errorf("request.ext is invalid: %v", err)
+	}
+	return requestJSON, nil
+}
+
+// mergeExt sets the fields of the object at the path of the ext, creating any objects which don't exist yet.
+func mergeExt(ext json.RawMessage, fields map[string]json.RawMessage, path ...string) (json.RawMessage, error) {
+	var object map[string]json.RawMessage
+	if len(ext) > 0 {
+		if err := json.Unmarshal(ext, &object); err != nil {
+			return nil, err
+		}
+	}
+	if object == nil {
+		object = make(map[string]json.RawMessage, len(fields))
+	}
+
+	if len(path) == 0 {
+		for key, value := range fields {
+			object[key] = value
+		}
+	} else {
+		child, err := mergeExt(object[path[0]], fields, path[1:]...)
+		if err != nil {
+			return nil, err
+		}
+		object[path[0]] = child
+	}
+	return json.Marshal(object)
 }
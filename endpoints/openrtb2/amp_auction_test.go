@@ -14,9 +14,11 @@ import (
 	"github.com/prebid/prebid-server/stored_requests"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
 
+	"github.com/buger/jsonparser"
 	"github.com/mxmCherry/openrtb"
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
@@ -53,6 +55,7 @@ func TestGoodAmpRequests(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 
 	for requestID := range goodRequests {
@@ -106,6 +109,7 @@ func TestAMPPageInfo(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 	request := httptest.NewRequest("GET", fmt.Sprintf("/openrtb2/auction/amp?tag_id=1&curl=%s", url.QueryEscape(page)), nil)
 	recorder := httptest.NewRecorder()
@@ -211,6 +215,7 @@ func TestGDPRConsent(t *testing.T) {
 			map[string]string{},
 			[]byte{},
 			openrtb_ext.BidderMap,
+			devicedetection.NilDetector{},
 		)
 
 		// Invoke Endpoint
@@ -364,6 +369,7 @@ func TestCCPAConsent(t *testing.T) {
 			map[string]string{},
 			[]byte{},
 			openrtb_ext.BidderMap,
+			devicedetection.NilDetector{},
 		)
 
 		// Invoke Endpoint
@@ -423,6 +429,7 @@ func TestNoConsent(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 
 	// Invoke Endpoint
@@ -469,6 +476,7 @@ func TestInvalidConsent(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 
 	// Invoke Endpoint
@@ -553,6 +561,7 @@ func TestNewAndLegacyConsentBothProvided(t *testing.T) {
 			map[string]string{},
 			[]byte{},
 			openrtb_ext.BidderMap,
+			devicedetection.NilDetector{},
 		)
 
 		// Invoke Endpoint
@@ -605,6 +614,7 @@ func TestAMPSiteExt(t *testing.T) {
 		nil,
 		nil,
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 	request, err := http.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1", nil)
 	if !assert.NoError(t, err) {
@@ -622,6 +632,134 @@ func TestAMPSiteExt(t *testing.T) {
 	assert.JSONEq(t, `{"amp":1}`, string(exchange.lastRequest.Site.Ext))
 }
 
+func TestAmpTargetingAndConsentParams(t *testing.T) {
+	tcfConsent := "BOu5On0Ou5On0ADACHENAO7pqzAAppY"
+	testCases := []struct {
+		description      string
+		query            string
+		expectedSiteExt  string
+		expectedImpExt   string
+		expectedRegsExt  string
+		expectedUserExt  string
+		expectedWarnings []string
+	}{
+		{
+			description:     "Targeting",
+			query:           `targeting={"section":"sports","keywords":["a","b"]}`,
+			expectedSiteExt: `{"amp":1,"data":{"section":"sports","keywords":["a","b"]}}`,
+			expectedImpExt:  `{"appnexus":{"placementId":12883451},"context":{"data":{"section":"sports","keywords":["a","b"]}}}`,
+		},
+		{
+			description:      "Malformed targeting",
+			query:            `targeting=sports`,
+			expectedSiteExt:  `{"amp":1}`,
+			expectedImpExt:   `{"appnexus":{"placementId":12883451}}`,
+			expectedWarnings: []string{"targeting param is not a JSON object, so it was ignored: invalid character 's' looking for beginning of value"},
+		},
+		{
+			description:     "TCF consent",
+			query:           "consent_string=" + tcfConsent + "&consent_type=1&gdpr_applies=true&addtl_consent=1~7.12",
+			expectedSiteExt: `{"amp":1}`,
+			expectedImpExt:  `{"appnexus":{"placementId":12883451}}`,
+			expectedRegsExt: `{"gdpr":1}`,
+			expectedUserExt: `{"consent":"` + tcfConsent + `","ConsentedProvidersSettings":{"consented_providers":"1~7.12"}}`,
+		},
+		{
+			description:     "US Privacy consent",
+			query:           "consent_string=1YNN&consent_type=3&gdpr_applies=false",
+			expectedSiteExt: `{"amp":1}`,
+			expectedImpExt:  `{"appnexus":{"placementId":12883451}}`,
+			expectedRegsExt: `{"gdpr":0,"us_privacy":"1YNN"}`,
+		},
+		{
+			description:      "Consent of another type",
+			query:            "consent_string=" + tcfConsent + "&consent_type=3",
+			expectedSiteExt:  `{"amp":1}`,
+			expectedImpExt:   `{"appnexus":{"placementId":12883451}}`,
+			expectedWarnings: []string{"Consent '" + tcfConsent + "' is not a valid US Privacy string."},
+		},
+		{
+			description:      "Unsupported consent type and gdpr_applies",
+			query:            "consent_string=1YNN&consent_type=9&gdpr_applies=yes",
+			expectedSiteExt:  `{"amp":1}`,
+			expectedImpExt:   `{"appnexus":{"placementId":12883451}}`,
+			expectedWarnings: []string{"Consent type '9' is not supported, so consent '1YNN' was ignored.", `gdpr_applies must be "true" or "false". Got 'yes'`},
+		},
+	}
+
+	for _, test := range testCases {
+		bid, err := getTestBidRequest(true, nil, true, nil)
+		if err != nil {
+			t.Fatalf("Failed to marshal the complete openrtb.BidRequest object %v", err)
+		}
+		mockExchange := &mockAmpExchange{}
+		endpoint, _ := NewAmpEndpoint(
+			mockExchange,
+			newParamsValidator(t),
+			&mockAmpStoredReqFetcher{map[string]json.RawMessage{"1": json.RawMessage(bid)}},
+			empty_fetcher.EmptyFetcher{},
+			&config.Configuration{MaxRequestSize: maxSize},
+			pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{}),
+			analyticsConf.NewPBSAnalytics(&config.Analytics{}),
+			map[string]string{},
+			[]byte{},
+			openrtb_ext.BidderMap,
+			devicedetection.NilDetector{},
+		)
+
+		query, _ := url.ParseQuery(test.query)
+		query.Set("tag_id", "1")
+		recorder := httptest.NewRecorder()
+		endpoint(recorder, httptest.NewRequest("GET", "/openrtb2/auction/amp?"+query.Encode(), nil), nil)
+
+		var response AmpResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: error unmarshalling response: %v", test.description, err)
+		}
+		result := mockExchange.lastRequest
+		if !assert.NotNil(t, result, test.description) {
+			continue
+		}
+
+		assert.JSONEq(t, test.expectedSiteExt, string(result.Site.Ext), test.description+":site.ext")
+		assert.JSONEq(t, test.expectedImpExt, string(result.Imp[0].Ext), test.description+":imp.ext")
+		if test.expectedRegsExt == "" {
+			assert.Nil(t, result.Regs, test.description+":regs")
+		} else if assert.NotNil(t, result.Regs, test.description+":regs") {
+			assert.JSONEq(t, test.expectedRegsExt, string(result.Regs.Ext), test.description+":regs.ext")
+		}
+		if test.expectedUserExt == "" {
+			assert.Nil(t, result.User, test.description+":user")
+		} else if assert.NotNil(t, result.User, test.description+":user") {
+			assert.JSONEq(t, test.expectedUserExt, string(result.User.Ext), test.description+":user.ext")
+		}
+
+		var warnings []string
+		for _, warning := range response.Warnings[openrtb_ext.BidderNameGeneral] {
+			warnings = append(warnings, warning.Message)
+		}
+		assert.Equal(t, test.expectedWarnings, warnings, test.description+":warnings")
+
+		var requestExt openrtb_ext.ExtRequest
+		if assert.NoError(t, json.Unmarshal(result.Ext, &requestExt), test.description+":ext") && assert.NotNil(t, requestExt.Prebid.AMP, test.description+":ext.prebid.amp") {
+			expectedData := map[string]string{"tag_id": "1"}
+			if targeting := query.Get("targeting"); targeting != "" {
+				expectedData["targeting"] = targeting
+			}
+			assert.Equal(t, expectedData, requestExt.Prebid.AMP.Data, test.description+":ext.prebid.amp.data")
+		}
+	}
+}
+
+func TestSetAmpData(t *testing.T) {
+	query, _ := url.ParseQuery("tag_id=1&tag_id=2&slot=/123/slot&curl=https://publisher.com/page&consent_string=1YNN&gdpr_consent=BOEF&ow=")
+	requestJSON, err := setAmpData([]byte(`{"id":"req","ext":{"prebid":{"debug":true}}}`), query)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"id":"req","ext":{"prebid":{"debug":true,"amp":{"data":{"tag_id":"1","slot":"/123/slot"}}}}}`, string(requestJSON),
+			"Only the allowed params should be copied, with their first value")
+	}
+}
+
 // TestBadRequests makes sure we return 400's on bad requests.
 func TestAmpBadRequests(t *testing.T) {
 	files := fetchFiles(t, "sample-requests/invalid-whole")
@@ -645,6 +783,7 @@ func TestAmpBadRequests(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 	for requestID := range badRequests {
 		request := httptest.NewRequest("GET", fmt.Sprintf("/openrtb2/auction/amp?tag_id=%s", requestID), nil)
@@ -676,6 +815,7 @@ func TestAmpDebug(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 
 	for requestID := range requests {
@@ -732,6 +872,37 @@ func TestAmpTargetingDefaults(t *testing.T) {
 	}
 }
 
+func TestAmpTargetingPrefix(t *testing.T) {
+	stored, err := jsonparser.Set([]byte(validRequest(t, "site.json")), []byte(`"pbs"`), "ext", "prebid", "targeting", "prefix")
+	if !assert.NoError(t, err) {
+		return
+	}
+	endpoint, _ := NewAmpEndpoint(
+		&mockAmpPrefixExchange{},
+		newParamsValidator(t),
+		&mockAmpStoredReqFetcher{map[string]json.RawMessage{"1": stored}},
+		empty_fetcher.EmptyFetcher{},
+		&config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{}),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}),
+		nil,
+		nil,
+		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
+	)
+	request := httptest.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1", nil)
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if !assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String()) {
+		return
+	}
+	var response AmpResponse
+	if assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response)) {
+		assert.Equal(t, map[string]string{"pbs_pb": "1.20", "pbs_cache_id": "some_id"}, response.Targeting, "Only the cached bids' prefixed keys should be returned")
+	}
+}
+
 func TestQueryParamOverrides(t *testing.T) {
 	requests := map[string]json.RawMessage{
 		"1": json.RawMessage(validRequest(t, "site.json")),
@@ -749,6 +920,7 @@ func TestQueryParamOverrides(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 
 	requestID := "1"
@@ -870,6 +1042,34 @@ func TestWidthOnly(t *testing.T) {
 	}.execute(t)
 }
 
+// TestAmpStoredRequestVersion makes sure that each user keeps getting the same version of a versioned AMP Stored Request.
+func TestAmpStoredRequestVersion(t *testing.T) {
+	site := validRequest(t, "site.json")
+	data := json.RawMessage(`{"versions":[{"id":"a","percent":50,"data":` + site + `},{"id":"b","percent":50,"data":` + site + `}]}`)
+	deps := &endpointDeps{
+		storedReqFetcher:          &mockAmpStoredReqFetcher{map[string]json.RawMessage{"1": data}},
+		cfg:                       &config.Configuration{HostCookie: config.HostCookie{Family: "adnxs", CookieName: "userid"}},
+		privateNetworkIPValidator: hardcodedResponseIPValidator{response: true},
+	}
+
+	for _, user := range []string{"user-1", "user-2", "user-3", "user-4", "user-5"} {
+		_, expected, err := stored_requests.ResolveVersion("1", data, user)
+		if !assert.NoError(t, err, user) {
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			request := httptest.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1", nil)
+			request.AddCookie(&http.Cookie{Name: "userid", Value: user})
+			req, errs := deps.loadRequestJSONForAmp(request)
+			if !assert.Empty(t, errs, user) {
+				break
+			}
+			version, _ := jsonparser.GetString(req.Ext, "prebid", "storedrequest", "version")
+			assert.Equal(t, expected, version, "%s should always get the same version", user)
+		}
+	}
+}
+
 type formatOverrideSpec struct {
 	width          uint64
 	height         uint64
@@ -895,6 +1095,7 @@ func (s formatOverrideSpec) execute(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 
 	url := fmt.Sprintf("/openrtb2/auction/amp?tag_id=1&debug=1&w=%d&h=%d&ow=%d&oh=%d&ms=%s", s.width, s.height, s.overrideWidth, s.overrideHeight, s.multisize)
@@ -970,6 +1171,23 @@ func (m *mockAmpExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.B
 	return response, nil
 }
 
+// mockAmpPrefixExchange answers with the keys of a request whose ext.prebid.targeting.prefix is "pbs".
+type mockAmpPrefixExchange struct{}
+
+func (m *mockAmpPrefixExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, ids exchange.IdFetcher, labels pbsmetrics.Labels, categoriesFetcher *stored_requests.CategoryFetcher, debugLog *exchange.DebugLog) (*openrtb.BidResponse, error) {
+	return &openrtb.BidResponse{
+		SeatBid: []openrtb.SeatBid{{
+			Bid: []openrtb.Bid{{
+				AdM: "<script></script>",
+				Ext: json.RawMessage(`{"prebid": {"targeting": {"pbs_pb": "1.20", "pbs_cache_id": "some_id"}}}`),
+			}, {
+				AdM: "<script></script>",
+				Ext: json.RawMessage(`{"prebid": {"targeting": {"pbs_pb_rubicon": "0.80"}}}`),
+			}},
+		}},
+	}, nil
+}
+
 func getTestBidRequest(nilUser bool, userExt *openrtb_ext.ExtUser, nilRegs bool, regsExt *openrtb_ext.ExtRegs) ([]byte, error) {
 	var width uint64 = 300
 	var height uint64 = 300
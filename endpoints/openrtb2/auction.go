@@ -23,6 +23,7 @@ import (
 	nativeRequests "github.com/mxmCherry/openrtb/native/request"
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
@@ -31,15 +32,17 @@ import (
 	"github.com/prebid/prebid-server/privacy/ccpa"
 	"github.com/prebid/prebid-server/stored_requests"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/usersync"
 	"github.com/prebid/prebid-server/util/httputil"
 	"github.com/prebid/prebid-server/util/iputil"
 	"golang.org/x/net/publicsuffix"
+	"golang.org/x/text/currency"
 )
 
 const storedRequestTimeoutMillis = 50
 
-func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName) (httprouter.Handle, error) {
+func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName, deviceDetector devicedetection.Detector) (httprouter.Handle, error) {
 
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
 		return nil, errors.New("NewEndpoint requires non-nil arguments.")
@@ -67,7 +70,8 @@ func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidato
 		bidderMap,
 		nil,
 		nil,
-		ipValidator}).Auction), nil
+		ipValidator,
+		deviceDetector}).Auction), nil
 }
 
 type endpointDeps struct {
@@ -86,6 +90,7 @@ type endpointDeps struct {
 	cache                     prebid_cache_client.Client
 	debugLogRegexp            *regexp.Regexp
 	privateNetworkIPValidator iputil.IPValidator
+	deviceDetector            devicedetection.Detector
 }
 
 func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -116,13 +121,14 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 		deps.analytics.LogAuctionObject(&ao)
 	}()
 
+	r, trace := deps.startAuctionTrace(r)
 	req, errL := deps.parseRequest(r)
 
 	if errortypes.ContainsFatalError(errL) && writeError(errL, w, &labels) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := tracing.Detach(r.Context())
 
 	timeout := deps.cfg.AuctionTimeouts.LimitAuctionTimeout(time.Duration(req.TMax) * time.Millisecond)
 	if timeout > 0 {
@@ -151,6 +157,7 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 		writeError(errL, w, &labels)
 		return
 	}
+	deps.setTraceLevel(trace, req, labels.PubID)
 
 	response, err := deps.ex.HoldAuction(ctx, req, usersyncs, labels, &deps.categories, nil)
 	ao.Request = req
@@ -164,6 +171,7 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 		ao.Errors = append(ao.Errors, err)
 		return
 	}
+	addRequestWarnings(response, errortypes.WarningOnly(errL))
 
 	// Fixes #231
 	enc := json.NewEncoder(w)
@@ -181,6 +189,69 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 	}
 }
 
+// startAuctionTrace starts recording the steps of the auction, if the host lets any account get a trace of its auctions.
+func (deps *endpointDeps) startAuctionTrace(r *http.Request) (*http.Request, *tracing.AuctionTrace) {
+	if !deps.cfg.Debug.Trace.Enabled() {
+		return r, nil
+	}
+	ctx, trace := tracing.StartAuctionTrace(r.Context())
+	return r.WithContext(ctx), trace
+}
+
+// setTraceLevel sets the level of the trace to the one asked for in request.ext.prebid.trace, as far as the account
+// is allowed to get it.
+func (deps *endpointDeps) setTraceLevel(trace *tracing.AuctionTrace, req *openrtb.BidRequest, account string) {
+	if trace == nil {
+		return
+	}
+	requested := openrtb_ext.TraceNone
+	if bidExt, err := deps.parseBidExt(req.Ext); err == nil && bidExt != nil {
+		requested = bidExt.Prebid.Trace
+	}
+	trace.SetLevel(deps.cfg.Debug.Trace.LevelForAccount(account, requested))
+}
+
+// addRequestWarnings adds the warnings found while parsing the request to the response's ext.warnings.prebid,
+// ahead of the ones of the auction.
+func addRequestWarnings(response *openrtb.BidResponse, warnings []error) {
+	if response == nil || len(warnings) == 0 {
+		return
+	}
+	ext := make(map[string]json.RawMessage)
+	if len(response.Ext) > 0 {
+		if err := json.Unmarshal(response.Ext, &ext); err != nil {
+			glog.Errorf("Failed to add the request warnings to the response ext: %v", err)
+			return
+		}
+	}
+	allWarnings := make(map[openrtb_ext.BidderName][]openrtb_ext.ExtBidderError)
+	if rawWarnings, ok := ext["warnings"]; ok {
+		if err := json.Unmarshal(rawWarnings, &allWarnings); err != nil {
+			glog.Errorf("Failed to add the request warnings to the response ext: %v", err)
+			return
+		}
+	}
+
+	requestWarnings := make([]openrtb_ext.ExtBidderError, 0, len(warnings)+len(allWarnings[openrtb_ext.PrebidExtKey]))
+	for _, warning := range warnings {
+		requestWarnings = append(requestWarnings, openrtb_ext.ExtBidderError{
+			Code:    errortypes.ReadCode(warning),
+			Message: warning.Error(),
+		})
+	}
+	allWarnings[openrtb_ext.PrebidExtKey] = append(requestWarnings, allWarnings[openrtb_ext.PrebidExtKey]...)
+
+	rawWarnings, err := json.Marshal(allWarnings)
+	if err != nil {
+		glog.Errorf("Failed to add the request warnings to the response ext: %v", err)
+		return
+	}
+	ext["warnings"] = rawWarnings
+	if response.Ext, err = json.Marshal(ext); err != nil {
+		glog.Errorf("Failed to add the request warnings to the response ext: %v", err)
+	}
+}
+
 // parseRequest turns the HTTP request into an OpenRTB request. This is guaranteed to return:
 //
 //   - A context which times out appropriately, given the request.
@@ -195,6 +266,14 @@ func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.
 	req = &openrtb.BidRequest{}
 	errs = nil
 
+	ctx, span := tracing.StartSpan(httpRequest.Context(), "parse_request")
+	defer func() {
+		if fatalErrs := errortypes.FatalOnly(errs); len(fatalErrs) > 0 {
+			span.SetError(fatalErrs[0])
+		}
+		span.End()
+	}()
+
 	// Pull the request body into a buffer, so we have it for later usage.
 	lr := &io.LimitedReader{
 		R: httpRequest.Body,
@@ -214,7 +293,7 @@ func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.
 	}
 
 	timeout := parseTimeout(requestJson, time.Duration(storedRequestTimeoutMillis)*time.Millisecond)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(tracing.Detach(ctx), timeout)
 	defer cancel()
 
 	// Fetch the Stored Request data and merge it into the HTTP request.
@@ -222,6 +301,12 @@ func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.
 		return
 	}
 
+	// Move the OpenRTB 2.6 fields to the ext locations which the rest of Prebid Server reads.
+	if requestJson, err = openrtb_ext.NormalizeOpenRTB26Request(requestJson); err != nil {
+		errs = []error{err}
+		return
+	}
+
 	if err := json.Unmarshal(requestJson, req); err != nil {
 		errs = []error{err}
 		return
@@ -259,6 +344,12 @@ func parseTimeout(requestJson []byte, defaultTimeout time.Duration) time.Duratio
 }
 
 func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) []error {
+	return deps.validateBidRequest(req, true)
+}
+
+// validateBidRequest validates the request. If impsRequired is false, a request without any imps is allowed,
+// so that partial Stored Requests can be validated on their own.
+func (deps *endpointDeps) validateBidRequest(req *openrtb.BidRequest, impsRequired bool) []error {
 	errL := []error{}
 	if req.ID == "" {
 		return []error{errors.New("request missing required field: \"id\"")}
@@ -268,7 +359,7 @@ func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) []error {
 		return []error{fmt.Errorf("request.tmax must be nonnegative. Got %d", req.TMax)}
 	}
 
-	if len(req.Imp) < 1 {
+	if impsRequired && len(req.Imp) < 1 {
 		return []error{errors.New("request.imp must contain at least one element.")}
 	}
 
@@ -287,9 +378,19 @@ func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) []error {
 			return []error{err}
 		}
 
-		if err := validateBidAdjustmentFactors(bidExt.Prebid.BidAdjustmentFactors, aliases); err != nil {
+		if err := validateBidAdjustmentFactors(bidExt.Prebid.BidAdjustmentFactors, bidExt.Prebid.BidAdjustments, aliases); err != nil {
+			return []error{err}
+		}
+
+		if err := validateTraceLevel(bidExt.Prebid.Trace); err != nil {
 			return []error{err}
 		}
+
+		if bidExt.Prebid.Targeting != nil {
+			if err := deps.validateTargetingPrefixLength(bidExt.Prebid.Targeting.Prefix, req.Site, req.App); err != nil {
+				return []error{fmt.Errorf("request.ext.prebid.targeting.%v", err)}
+			}
+		}
 	}
 
 	if (req.Site == nil && req.App == nil) || (req.Site != nil && req.App != nil) {
@@ -348,7 +449,30 @@ func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) []error {
 	return errL
 }
 
-func validateBidAdjustmentFactors(adjustmentFactors map[string]float64, aliases map[string]string) error {
+func validateTraceLevel(level openrtb_ext.TraceLevel) error {
+	switch level {
+	case openrtb_ext.TraceNone, openrtb_ext.TraceBasic, openrtb_ext.TraceVerbose:
+		return nil
+	}
+	return fmt.Errorf("request.ext.prebid.trace must be \"verbose\" or \"basic\". Got \"%s\"", level)
+}
+
+// validateTargetingPrefixLength makes sure that the keys which start with the prefix can't collide once they're
+// truncated to the max_key_length of the request's account.
+func (deps *endpointDeps) validateTargetingPrefixLength(prefix string, site *openrtb.Site, app *openrtb.App) error {
+	account := pbsmetrics.PublisherUnknown
+	if site != nil {
+		account = effectivePubID(site.Publisher)
+	} else if app != nil {
+		account = effectivePubID(app.Publisher)
+	}
+	if maxLength := deps.cfg.Targeting.MaxPrefixLengthForAccount(account); len(prefix) > maxLength {
+		return fmt.Errorf("prefix must be at most %d characters long, since the keys are truncated to %d characters. Got \"%s\"", maxLength, deps.cfg.Targeting.MaxKeyLengthForAccount(account), prefix)
+	}
+	return nil
+}
+
+func validateBidAdjustmentFactors(adjustmentFactors map[string]float64, adjustments *openrtb_ext.ExtRequestPrebidBidAdjustments, aliases map[string]string) error {
 	for bidderToAdjust, adjustmentFactor := range adjustmentFactors {
 		if adjustmentFactor <= 0 {
 			return fmt.Errorf("request.ext.prebid.bidadjustmentfactors.%s must be a positive number. Got %f", bidderToAdjust, adjustmentFactor)
@@ -359,6 +483,46 @@ func validateBidAdjustmentFactors(adjustmentFactors map[string]float64, aliases
 			}
 		}
 	}
+	if adjustments == nil {
+		return nil
+	}
+
+	for mediaType, byBidder := range adjustments.MediaType {
+		if _, err := openrtb_ext.ParseBidType(string(mediaType)); err != nil {
+			return fmt.Errorf("request.ext.prebid.bidadjustments.mediatype.%s is not a known media type", mediaType)
+		}
+		for bidderToAdjust, byDeal := range byBidder {
+			if bidderToAdjust != openrtb_ext.BidAdjustmentWildcard {
+				if _, isBidder := openrtb_ext.BidderMap[bidderToAdjust]; !isBidder {
+					if _, isAlias := aliases[bidderToAdjust]; !isAlias {
+						return fmt.Errorf("request.ext.prebid.bidadjustments.mediatype.%s.%s is not a known bidder or alias", mediaType, bidderToAdjust)
+					}
+				}
+			}
+			for dealID, adjustmentList := range byDeal {
+				if dealID == "" {
+					return fmt.Errorf("request.ext.prebid.bidadjustments.mediatype.%s.%s has an empty deal ID", mediaType, bidderToAdjust)
+				}
+				path := fmt.Sprintf("request.ext.prebid.bidadjustments.mediatype.%s.%s.%s", mediaType, bidderToAdjust, dealID)
+				for i, adjustment := range adjustmentList {
+					switch adjustment.AdjType {
+					case openrtb_ext.BidAdjustmentMultiplier:
+						if adjustment.Value <= 0 {
+							return fmt.Errorf("%s[%d].value must be a positive number. Got %f", path, i, adjustment.Value)
+						}
+					case openrtb_ext.BidAdjustmentStatic:
+						if adjustment.Currency != "" {
+							if _, err := currency.ParseISO(adjustment.Currency); err != nil {
+								return fmt.Errorf("%s[%d].currency must be an ISO-4217 currency code. Got %s", path, i, adjustment.Currency)
+							}
+						}
+					default:
+						return fmt.Errorf("%s[%d].adjtype must be \"multiplier\" or \"static\". Got \"%s\"", path, i, adjustment.AdjType)
+					}
+				}
+			}
+		}
+	}
 	return nil
 }
 
@@ -383,8 +547,8 @@ func (deps *endpointDeps) validateImp(imp *openrtb.Imp, aliases map[string]strin
 		return []error{fmt.Errorf("request.imp[%d].video.mimes must contain at least one supported MIME type", index)}
 	}
 
-	if imp.Audio != nil && len(imp.Audio.MIMEs) < 1 {
-		return []error{fmt.Errorf("request.imp[%d].audio.mimes must contain at least one supported MIME type", index)}
+	if err := validateAudio(imp.Audio, index); err != nil {
+		return []error{err}
 	}
 
 	if err := fillAndValidateNative(imp.Native, index); err != nil {
@@ -436,6 +600,29 @@ func validateBanner(banner *openrtb.Banner, impIndex int) error {
 	return nil
 }
 
+func validateAudio(audio *openrtb.Audio, impIndex int) error {
+	if audio == nil {
+		return nil
+	}
+
+	if len(audio.MIMEs) < 1 {
+		return fmt.Errorf("request.imp[%d].audio.mimes must contain at least one supported MIME type", impIndex)
+	}
+	if audio.MinDuration < 0 {
+		return fmt.Errorf("request.imp[%d].audio.minduration must be a positive number", impIndex)
+	}
+	if audio.MaxDuration < 0 {
+		return fmt.Errorf("request.imp[%d].audio.maxduration must be a positive number", impIndex)
+	}
+	if audio.MaxDuration > 0 && audio.MaxDuration < audio.MinDuration {
+		return fmt.Errorf("request.imp[%d].audio.maxduration must not be less than audio.minduration. Got %d and %d", impIndex, audio.MaxDuration, audio.MinDuration)
+	}
+	if audio.Feed != 0 && (audio.Feed < openrtb.FeedTypeMusicService || audio.Feed > openrtb.FeedTypePodcast) {
+		return fmt.Errorf("request.imp[%d].audio.feed must be 1 (music service), 2 (FM/AM broadcast) or 3 (podcast). Got %d", impIndex, audio.Feed)
+	}
+	return nil
+}
+
 // fillAndValidateNative validates the request, and assigns the Asset IDs as recommended by the Native v1.2 spec.
 func fillAndValidateNative(n *openrtb.Native, impIndex int) error {
 	if n == nil {
@@ -742,7 +929,7 @@ func (deps *endpointDeps) validateImpExt(imp *openrtb.Imp, aliases map[string]st
 	/* Process all the bidder exts in the request */
 	disabledBidders := []string{}
 	for bidder, ext := range bidderExts {
-		if bidder != openrtb_ext.PrebidExtKey {
+		if bidder != openrtb_ext.PrebidExtKey && bidder != openrtb_ext.FirstPartyDataContextExtKey {
 			coreBidder := bidder
 			if tmp, isAlias := aliases[bidder]; isAlias {
 				coreBidder = tmp
@@ -941,6 +1128,7 @@ func (deps *endpointDeps) setFieldsImplicitly(httpReq *http.Request, bidReq *ope
 	sanitizeRequest(bidReq, deps.privateNetworkIPValidator)
 
 	setDeviceImplicitly(httpReq, bidReq, deps.privateNetworkIPValidator)
+	deps.detectDevice(bidReq)
 
 	// Per the OpenRTB spec: A bid request must not contain both a Site and an App object.
 	if bidReq.App == nil {
@@ -957,6 +1145,36 @@ func setDeviceImplicitly(httpReq *http.Request, bidReq *openrtb.BidRequest, ipVa
 	setUAImplicitly(httpReq, bidReq)
 }
 
+// detectDevice fills the device fields which the caller left empty, using the device of its User-Agent.
+func (deps *endpointDeps) detectDevice(bidReq *openrtb.BidRequest) {
+	if deps.deviceDetector == nil || bidReq.Device == nil || bidReq.Device.UA == "" {
+		return
+	}
+	// Requests aren't counted as unknown devices when detection is disabled.
+	if _, disabled := deps.deviceDetector.(devicedetection.NilDetector); disabled {
+		return
+	}
+	detected, ok := devicedetection.Fill(deps.deviceDetector, bidReq.Device)
+	if deps.metricsEngine == nil {
+		return
+	}
+	deviceType := pbsmetrics.DeviceTypeUnknown
+	if metricsDeviceType, known := metricsDeviceTypes[detected.DeviceType]; ok && known {
+		deviceType = metricsDeviceType
+	}
+	deps.metricsEngine.RecordDeviceDetection(deviceType)
+}
+
+var metricsDeviceTypes = map[openrtb.DeviceType]pbsmetrics.DeviceType{
+	openrtb.DeviceTypeMobileTablet:     pbsmetrics.DeviceTypeMobileTablet,
+	openrtb.DeviceTypePersonalComputer: pbsmetrics.DeviceTypePC,
+	openrtb.DeviceTypeConnectedTV:      pbsmetrics.DeviceTypeCTV,
+	openrtb.DeviceTypePhone:            pbsmetrics.DeviceTypePhone,
+	openrtb.DeviceTypeTablet:           pbsmetrics.DeviceTypeTablet,
+	openrtb.DeviceTypeConnectedDevice:  pbsmetrics.DeviceTypeConnectedDevice,
+	openrtb.DeviceTypeSetTopBox:        pbsmetrics.DeviceTypeSetTopBox,
+}
+
 // setAuctionTypeImplicitly sets the auction type to 1 if it wasn't on the request,
 // since header bidding is generally a first-price auction.
 func setAuctionTypeImplicitly(bidReq *openrtb.BidRequest) {
@@ -1018,7 +1236,23 @@ func getJsonSyntaxError(testJSON []byte) (bool, string) {
 	return false, ""
 }
 
+// fetchStoredData fetches Stored Requests and Stored Imps within a tracing span.
+func fetchStoredData(ctx context.Context, fetcher stored_requests.Fetcher, requestIDs []string, impIDs []string) (map[string]json.RawMessage, map[string]json.RawMessage, []error) {
+	ctx, span := tracing.StartSpan(ctx, "fetch_stored_requests")
+	defer span.End()
+	span.SetAttribute("stored_requests", strconv.Itoa(len(requestIDs)))
+	span.SetAttribute("stored_imps", strconv.Itoa(len(impIDs)))
+
+	requestData, impData, errs := fetcher.FetchRequests(ctx, requestIDs, impIDs)
+	if len(errs) > 0 {
+		span.SetError(errs[0])
+	}
+	return requestData, impData, errs
+}
+
 func (deps *endpointDeps) processStoredRequests(ctx context.Context, requestJson []byte) ([]byte, []error) {
+	trace := tracing.AuctionTraceFromContext(ctx)
+
 	// Parse the Stored Request IDs from the BidRequest and Imps.
 	storedBidRequestId, hasStoredBidRequest, err := getStoredRequestId(requestJson)
 	if err != nil {
@@ -1034,7 +1268,18 @@ func (deps *endpointDeps) processStoredRequests(ctx context.Context, requestJson
 	if hasStoredBidRequest {
 		storedReqIds = []string{storedBidRequestId}
 	}
-	storedRequests, storedImps, errs := deps.storedReqFetcher.FetchRequests(ctx, storedReqIds, impIds)
+	storedRequests, storedImps, errs := fetchStoredData(ctx, deps.storedReqFetcher, storedReqIds, impIds)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+
+	// Pick the version of each Stored Request and Stored Imp which this request should use
+	seed := versionSeed(requestJson)
+	storedRequests, storedRequestVersions, errs := resolveStoredVersions(storedRequests, seed)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	storedImps, storedImpVersions, errs := resolveStoredVersions(storedImps, seed)
 	if len(errs) != 0 {
 		return nil, errs
 	}
@@ -1056,6 +1301,10 @@ func (deps *endpointDeps) processStoredRequests(ctx context.Context, requestJson
 			}
 			return nil, []error{err}
 		}
+		if resolvedRequest, err = setStoredRequestVersion(resolvedRequest, storedRequestVersions[storedBidRequestId]); err != nil {
+			return nil, []error{err}
+		}
+		trace.Basic("stored_request", "", "merged stored request %s%s", storedBidRequestId, describeStoredVersion(storedRequestVersions[storedBidRequestId]))
 	}
 
 	// Apply default aliases, if they are provided
@@ -1074,6 +1323,7 @@ func (deps *endpointDeps) processStoredRequests(ctx context.Context, requestJson
 			return nil, []error{err}
 		}
 		resolvedRequest = aliasedRequest
+		trace.Basic("default_request", "", "merged the default request")
 	}
 
 	// Apply any Stored Imps, if they exist. Since the JSON Merge Patch overrides arrays,
@@ -1093,7 +1343,11 @@ func (deps *endpointDeps) processStoredRequests(ctx context.Context, requestJson
 			}
 			return nil, []error{err}
 		}
+		if resolvedImp, err = setStoredRequestVersion(resolvedImp, storedImpVersions[impIds[i]]); err != nil {
+			return nil, []error{err}
+		}
 		imps[idIndices[i]] = resolvedImp
+		trace.Verbose("stored_request", "", "merged stored imp %s%s into imp[%d]", impIds[i], describeStoredVersion(storedImpVersions[impIds[i]]), idIndices[i])
 	}
 	if len(impIds) > 0 {
 		newImpJson, err := json.Marshal(imps)
@@ -1109,6 +1363,70 @@ func (deps *endpointDeps) processStoredRequests(ctx context.Context, requestJson
 	return resolvedRequest, nil
 }
 
+// describeStoredVersion describes the version of a Stored Request or Stored Imp in the auction trace.
+func describeStoredVersion(version string) string {
+	if version == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (version %s)", version)
+}
+
+// versionSeed picks the versions of the Stored Requests and Stored Imps of a request. It's the request.id,
+// or the device's IP address if the request has no ID, so that a request gets the same versions when it's retried.
+func versionSeed(requestJson []byte) string {
+	for _, path := range [][]string{{"id"}, {"device", "ip"}, {"device", "ipv6"}} {
+		if value, _ := jsonparser.GetString(requestJson, path...); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// userVersionSeed picks the versions of the Stored Requests and Stored Imps of AMP and video requests, since they have
+// no request.id of their own. It's the user's ID in the host cookie if there is one, or their IP address otherwise,
+// so that each user keeps seeing the same versions.
+func (deps *endpointDeps) userVersionSeed(httpRequest *http.Request) string {
+	cookie := usersync.ParsePBSCookieFromRequest(httpRequest, &(deps.cfg.HostCookie))
+	if uid, _, _ := cookie.GetUID(deps.cfg.HostCookie.Family); uid != "" {
+		return uid
+	}
+	if ip, _ := httputil.FindIP(httpRequest, deps.privateNetworkIPValidator); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// resolveStoredVersions picks the version of each fetched Stored Request or Stored Imp which should be used
+// for the request with the given seed. It returns the resolved data along with the chosen version IDs.
+// Entries which aren't versioned are returned as-is, and have no entry in the versions map.
+func resolveStoredVersions(storedData map[string]json.RawMessage, seed string) (map[string]json.RawMessage, map[string]string, []error) {
+	resolved := make(map[string]json.RawMessage, len(storedData))
+	versions := make(map[string]string)
+	var errs []error
+	for id, data := range storedData {
+		versionData, version, err := stored_requests.ResolveVersion(id, data, seed)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resolved[id] = versionData
+		if version != "" {
+			versions[id] = version
+		}
+	}
+	return resolved, versions, errs
+}
+
+// setStoredRequestVersion records the chosen Stored Request version at ext.prebid.storedrequest.version,
+// so that it shows up in the debug output and the analytics modules.
+func setStoredRequestVersion(data []byte, version string) ([]byte, error) {
+	if version == "" {
+		return data, nil
+	}
+	// These keys must be kept in sync with openrtb_ext.ExtStoredRequest
+	return jsonparser.Set(data, []byte(strconv.Quote(version)), "ext", openrtb_ext.PrebidExtKey, "storedrequest", "version")
+}
+
 // parseImpInfo parses the request JSON and returns several things about the Imps
 //
 // 1. A list of the JSON for every Imp.
@@ -12,6 +12,7 @@ import (
 
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
@@ -88,6 +89,7 @@ func BenchmarkOpenrtbEndpoint(b *testing.B) {
 		map[string]string{},
 		[]byte{},
 		nil,
+		devicedetection.NilDetector{},
 	)
 
 	b.ResetTimer()
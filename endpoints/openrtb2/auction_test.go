@@ -25,13 +25,16 @@ import (
 	"github.com/mxmCherry/openrtb"
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/util/iputil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 const maxSize = 1024 * 256
@@ -103,7 +106,7 @@ func TestExplicitUserId(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
-	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, cfg, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap)
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, cfg, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap, devicedetection.NilDetector{})
 
 	endpoint(httptest.NewRecorder(), request, nil)
 
@@ -120,6 +123,38 @@ func TestExplicitUserId(t *testing.T) {
 	}
 }
 
+// TestOpenRTB26Fields makes sure that the Exchange gets the OpenRTB 2.6 fields in their OpenRTB 2.5 ext locations.
+func TestOpenRTB26Fields(t *testing.T) {
+	ex := &mockExchange{}
+	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(`{
+		"id": "some-request-id",
+		"site": {"page": "test.somepage.com"},
+		"source": {"schain": {"complete": 1, "nodes": [{"asi": "directseller.com", "sid": "00001", "hp": 1}], "ver": "1.0"}},
+		"regs": {"gdpr": 1},
+		"user": {"consent": "some-consent-string", "eids": [{"source": "adserver.org", "uids": [{"id": "some-tdid"}]}]},
+		"imp": [{
+			"id": "my-imp-id",
+			"rwdd": 1,
+			"video": {"mimes": ["video/mp4"], "podid": "pod-1", "slotinpod": 1},
+			"ext": {"appnexus": {"placementId": 12883451}}
+		}]
+	}`))
+	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
+	endpoint, _ := NewEndpoint(ex, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap, devicedetection.NilDetector{})
+
+	recorder := httptest.NewRecorder()
+	endpoint(recorder, request, nil)
+
+	if !assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String()) || !assert.NotNil(t, ex.lastRequest) {
+		return
+	}
+	assert.JSONEq(t, `{"gdpr": 1}`, string(ex.lastRequest.Regs.Ext))
+	assert.JSONEq(t, `{"consent": "some-consent-string", "eids": [{"source": "adserver.org", "uids": [{"id": "some-tdid"}]}]}`, string(ex.lastRequest.User.Ext))
+	assert.JSONEq(t, `{"schain": {"complete": 1, "nodes": [{"asi": "directseller.com", "sid": "00001", "hp": 1}], "ver": "1.0"}}`, string(ex.lastRequest.Source.Ext))
+	assert.JSONEq(t, `{"podid": "pod-1", "slotinpod": 1}`, string(ex.lastRequest.Imp[0].Video.Ext))
+	assert.JSONEq(t, `{"appnexus": {"placementId": 12883451}, "prebid": {"is_rewarded_inventory": 1}}`, string(ex.lastRequest.Imp[0].Ext))
+}
+
 // TestGoodRequests makes sure we return 200s on good requests.
 func TestGoodRequests(t *testing.T) {
 	exemplary := &getResponseFromDirectory{
@@ -357,6 +392,7 @@ func (gr *getResponseFromDirectory) doRequest(t *testing.T, requestData []byte)
 		disabledBidders,
 		aliasJSON,
 		bidderMap,
+		devicedetection.NilDetector{},
 	)
 
 	request := httptest.NewRequest("POST", "/openrtb2/auction", bytes.NewReader(requestData))
@@ -390,7 +426,7 @@ func doBadAliasRequest(t *testing.T, filename string, expectMsg string) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
-	endpoint, _ := NewEndpoint(&nobidExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), disabledBidders, aliasJSON, bidderMap)
+	endpoint, _ := NewEndpoint(&nobidExchange{}, newParamsValidator(t), &mockStoredReqFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), disabledBidders, aliasJSON, bidderMap, devicedetection.NilDetector{})
 
 	request := httptest.NewRequest("POST", "/openrtb2/auction", bytes.NewReader(requestData))
 	recorder := httptest.NewRecorder()
@@ -454,7 +490,7 @@ func TestNilExchange(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
-	_, err := NewEndpoint(nil, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap)
+	_, err := NewEndpoint(nil, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap, devicedetection.NilDetector{})
 	if err == nil {
 		t.Errorf("NewEndpoint should return an error when given a nil Exchange.")
 	}
@@ -465,7 +501,7 @@ func TestNilValidator(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
-	_, err := NewEndpoint(&nobidExchange{}, nil, empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap)
+	_, err := NewEndpoint(&nobidExchange{}, nil, empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap, devicedetection.NilDetector{})
 	if err == nil {
 		t.Errorf("NewEndpoint should return an error when given a nil BidderParamValidator.")
 	}
@@ -476,7 +512,7 @@ func TestExchangeError(t *testing.T) {
 	// NewMetrics() will create a new go_metrics MetricsEngine, bypassing the need for a crafted configuration set to support it.
 	// As a side effect this gives us some coverage of the go_metrics piece of the metrics engine.
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
-	endpoint, _ := NewEndpoint(&brokenExchange{}, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap)
+	endpoint, _ := NewEndpoint(&brokenExchange{}, newParamsValidator(t), empty_fetcher.EmptyFetcher{}, empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, theMetrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap, devicedetection.NilDetector{})
 	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	recorder := httptest.NewRecorder()
 	endpoint(recorder, request, nil)
@@ -588,7 +624,7 @@ func TestImplicitIPsEndToEnd(t *testing.T) {
 				IPv6PrivateNetworksParsed: test.privateNetworksIPv6,
 			},
 		}
-		endpoint, _ := NewEndpoint(exchange, newParamsValidator(t), &mockStoredReqFetcher{}, empty_fetcher.EmptyFetcher{}, cfg, metrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap)
+		endpoint, _ := NewEndpoint(exchange, newParamsValidator(t), &mockStoredReqFetcher{}, empty_fetcher.EmptyFetcher{}, cfg, metrics, analyticsConf.NewPBSAnalytics(&config.Analytics{}), map[string]string{}, []byte{}, openrtb_ext.BidderMap, devicedetection.NilDetector{})
 
 		httpReq := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, test.reqJSONFile)))
 		httpReq.Header.Set("X-Forwarded-For", test.xForwardedForHeader)
@@ -673,6 +709,7 @@ func TestStoredRequests(t *testing.T) {
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	for i, requestData := range testStoredRequests {
@@ -693,6 +730,130 @@ func TestStoredRequests(t *testing.T) {
 	}
 }
 
+// TestStoredRequestVersions makes sure that versioned Stored Requests and Stored Imps are resolved,
+// and that the chosen versions are recorded in the request.
+func TestStoredRequestVersions(t *testing.T) {
+	deps := &endpointDeps{
+		&nobidExchange{},
+		newParamsValidator(t),
+		&versionedStoredReqFetcher{},
+		empty_fetcher.EmptyFetcher{},
+		empty_fetcher.EmptyFetcher{},
+		&config.Configuration{MaxRequestSize: maxSize},
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{}),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}),
+		map[string]string{},
+		false,
+		[]byte{},
+		openrtb_ext.BidderMap,
+		nil,
+		nil,
+		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
+	}
+
+	request := `{"id":"ThisID","imp":[{"ext":{"prebid":{"storedrequest":{"id":"imp-1"}}}}],"ext":{"prebid":{"storedrequest":{"id":"req-1"}}}}`
+	expected := `{
+		"id":"ThisID",
+		"tmax":700,
+		"imp":[{"id":"adUnit1","ext":{"prebid":{"storedrequest":{"id":"imp-1","version":"new"}}}}],
+		"ext":{"prebid":{"storedrequest":{"id":"req-1","version":"new"}}}
+	}`
+
+	newRequest, errList := deps.processStoredRequests(context.Background(), json.RawMessage(request))
+	assert.Empty(t, errList, "processStoredRequests should not return errors")
+	assert.JSONEq(t, expected, string(newRequest), "The new version should be merged into the request")
+}
+
+func TestAuctionTrace(t *testing.T) {
+	testCases := []struct {
+		description    string
+		account        string
+		trace          string
+		expectedStatus int
+		expectedTrace  []openrtb_ext.ExtTraceEvent
+	}{
+		{
+			description:    "Accounts allowed verbose traces should get every step",
+			account:        "verbose-pub",
+			trace:          "verbose",
+			expectedStatus: http.StatusOK,
+			expectedTrace: []openrtb_ext.ExtTraceEvent{
+				{Stage: "stored_request", Message: "merged stored request req-1 (version new)"},
+				{Stage: "stored_request", Message: "merged stored imp imp-1 (version new) into imp[0]"},
+			},
+		},
+		{
+			description:    "Other accounts should get the most detailed trace they're allowed",
+			account:        "other-pub",
+			trace:          "verbose",
+			expectedStatus: http.StatusOK,
+			expectedTrace: []openrtb_ext.ExtTraceEvent{
+				{Stage: "stored_request", Message: "merged stored request req-1 (version new)"},
+			},
+		},
+		{
+			description:    "Requests which don't ask for a trace shouldn't get one",
+			account:        "verbose-pub",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:    "Unknown trace levels should be rejected",
+			account:        "verbose-pub",
+			trace:          "full",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	cfg := &config.Configuration{MaxRequestSize: maxSize}
+	cfg.Debug.Trace = config.Trace{
+		MaxLevel:      "basic",
+		AccountLevels: map[string]string{"verbose-pub": "verbose"},
+	}
+	deps := &endpointDeps{
+		&traceExchange{},
+		newParamsValidator(t),
+		&versionedStoredReqFetcher{},
+		empty_fetcher.EmptyFetcher{},
+		empty_fetcher.EmptyFetcher{},
+		cfg,
+		pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{}),
+		analyticsConf.NewPBSAnalytics(&config.Analytics{}),
+		map[string]string{},
+		false,
+		[]byte{},
+		openrtb_ext.BidderMap,
+		nil,
+		nil,
+		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
+	}
+
+	for _, test := range testCases {
+		request := fmt.Sprintf(`{
+			"id":"ThisID",
+			"site":{"page":"prebid.org","publisher":{"id":"%s"}},
+			"imp":[{"banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":12883451},"prebid":{"storedrequest":{"id":"imp-1"}}}}],
+			"ext":{"prebid":{"storedrequest":{"id":"req-1"},"trace":"%s"}}
+		}`, test.account, test.trace)
+		recorder := httptest.NewRecorder()
+		deps.Auction(recorder, httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(request)), nil)
+
+		if !assert.Equal(t, test.expectedStatus, recorder.Code, "%s: %s", test.description, recorder.Body.String()) || recorder.Code != http.StatusOK {
+			continue
+		}
+		var response openrtb.BidResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to unmarshal the response: %v", test.description, err)
+		}
+		var responseExt openrtb_ext.ExtBidResponse
+		if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+			t.Fatalf("%s: failed to unmarshal the response ext: %v", test.description, err)
+		}
+		assert.Equal(t, test.expectedTrace, responseExt.Trace, test.description)
+	}
+}
+
 // TestOversizedRequest makes sure we behave properly when the request size exceeds the configured max.
 func TestOversizedRequest(t *testing.T) {
 	reqBody := validRequest(t, "site.json")
@@ -712,6 +873,7 @@ func TestOversizedRequest(t *testing.T) {
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	req := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody))
@@ -747,6 +909,7 @@ func TestRequestSizeEdgeCase(t *testing.T) {
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	req := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody))
@@ -776,6 +939,7 @@ func TestNoEncoding(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	recorder := httptest.NewRecorder()
@@ -851,6 +1015,7 @@ func TestContentType(t *testing.T) {
 		map[string]string{},
 		[]byte{},
 		openrtb_ext.BidderMap,
+		devicedetection.NilDetector{},
 	)
 	request := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(validRequest(t, "site.json")))
 	recorder := httptest.NewRecorder()
@@ -887,6 +1052,7 @@ func TestDisabledBidder(t *testing.T) {
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	req := httptest.NewRequest("POST", "/openrtb2/auction", strings.NewReader(reqBody))
@@ -923,12 +1089,86 @@ func TestValidateImpExtDisabledBidder(t *testing.T) {
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 	errs := deps.validateImpExt(imp, nil, 0)
 	assert.JSONEq(t, `{"appnexus":{"placement_id":555}}`, string(imp.Ext))
 	assert.Equal(t, []error{&errortypes.BidderTemporarilyDisabled{Message: "The bidder 'unknownbidder' has been disabled."}}, errs)
 }
 
+func TestValidateBidAdjustments(t *testing.T) {
+	testCases := []struct {
+		description string
+		adjustments string
+		expected    string
+	}{
+		{
+			description: "Valid adjustments",
+			adjustments: `{"mediatype":{"video":{"appnexus":{"*":[{"adjtype":"multiplier","value":0.9}],"deal-1":[{"adjtype":"static","value":-0.5,"currency":"EUR"}]},"*":{"*":[{"adjtype":"static","value":0.1}]}}}}`,
+		},
+		{
+			description: "Aliases should be accepted",
+			adjustments: `{"mediatype":{"banner":{"districtm":{"*":[{"adjtype":"multiplier","value":0.9}]}}}}`,
+		},
+		{
+			description: "Unknown media type",
+			adjustments: `{"mediatype":{"display":{"appnexus":{"*":[{"adjtype":"multiplier","value":0.9}]}}}}`,
+			expected:    "request.ext.prebid.bidadjustments.mediatype.display is not a known media type",
+		},
+		{
+			description: "Unknown bidder",
+			adjustments: `{"mediatype":{"video":{"unknown":{"*":[{"adjtype":"multiplier","value":0.9}]}}}}`,
+			expected:    "request.ext.prebid.bidadjustments.mediatype.video.unknown is not a known bidder or alias",
+		},
+		{
+			description: "Empty deal ID",
+			adjustments: `{"mediatype":{"video":{"appnexus":{"":[{"adjtype":"multiplier","value":0.9}]}}}}`,
+			expected:    "request.ext.prebid.bidadjustments.mediatype.video.appnexus has an empty deal ID",
+		},
+		{
+			description: "Unknown adjustment type",
+			adjustments: `{"mediatype":{"video":{"appnexus":{"*":[{"adjtype":"cpm","value":0.9}]}}}}`,
+			expected:    `request.ext.prebid.bidadjustments.mediatype.video.appnexus.*[0].adjtype must be "multiplier" or "static". Got "cpm"`,
+		},
+		{
+			description: "Negative multiplier",
+			adjustments: `{"mediatype":{"video":{"appnexus":{"deal-1":[{"adjtype":"multiplier","value":-1}]}}}}`,
+			expected:    "request.ext.prebid.bidadjustments.mediatype.video.appnexus.deal-1[0].value must be a positive number. Got -1.000000",
+		},
+		{
+			description: "Invalid currency",
+			adjustments: `{"mediatype":{"video":{"*":{"*":[{"adjtype":"static","value":0.1,"currency":"XYZW"}]}}}}`,
+			expected:    "request.ext.prebid.bidadjustments.mediatype.video.*.*[0].currency must be an ISO-4217 currency code. Got XYZW",
+		},
+	}
+
+	for _, test := range testCases {
+		var adjustments openrtb_ext.ExtRequestPrebidBidAdjustments
+		if !assert.NoError(t, json.Unmarshal([]byte(test.adjustments), &adjustments), test.description) {
+			continue
+		}
+		err := validateBidAdjustmentFactors(nil, &adjustments, map[string]string{"districtm": "appnexus"})
+		if test.expected == "" {
+			assert.NoError(t, err, test.description)
+		} else {
+			assert.EqualError(t, err, test.expected, test.description)
+		}
+	}
+}
+
+func TestValidateTargetingPrefixLength(t *testing.T) {
+	deps := &endpointDeps{cfg: &config.Configuration{
+		Targeting: config.Targeting{
+			MaxKeyLength:         20,
+			AccountMaxKeyLengths: map[string]int{"long-keys": 40},
+		},
+	}}
+
+	assert.NoError(t, deps.validateTargetingPrefixLength("pbs123", &openrtb.Site{}, nil))
+	assert.EqualError(t, deps.validateTargetingPrefixLength("pbs1234", &openrtb.Site{}, nil), `prefix must be at most 6 characters long, since the keys are truncated to 20 characters. Got "pbs1234"`)
+	assert.NoError(t, deps.validateTargetingPrefixLength("pbs1234", nil, &openrtb.App{Publisher: &openrtb.Publisher{ID: "long-keys"}}), "The account's max_key_length should be used")
+}
+
 func TestEffectivePubID(t *testing.T) {
 	var pub openrtb.Publisher
 	assert.Equal(t, pbsmetrics.PublisherUnknown, effectivePubID(nil), "effectivePubID failed for nil Publisher.")
@@ -964,6 +1204,7 @@ func TestCurrencyTrunc(t *testing.T) {
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	ui := uint64(1)
@@ -1008,6 +1249,7 @@ func TestCCPAInvalid(t *testing.T) {
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	ui := uint64(1)
@@ -1039,6 +1281,47 @@ func TestCCPAInvalid(t *testing.T) {
 	assert.Empty(t, req.Regs.Ext, "Invalid Consent Removed From Request")
 }
 
+func TestAddRequestWarnings(t *testing.T) {
+	testCases := []struct {
+		description string
+		ext         string
+		warnings    []error
+		expectedExt string
+	}{
+		{
+			description: "No warnings should leave the ext alone",
+			ext:         `{"errors":{"appnexus":[{"code":1,"message":"timeout"}]}}`,
+			expectedExt: `{"errors":{"appnexus":[{"code":1,"message":"timeout"}]}}`,
+		},
+		{
+			description: "Warnings should be added to an ext without any",
+			ext:         `{"errors":{"appnexus":[{"code":1,"message":"timeout"}]}}`,
+			warnings:    []error{&errortypes.Warning{Message: "Taking USD"}},
+			expectedExt: `{"errors":{"appnexus":[{"code":1,"message":"timeout"}]},"warnings":{"prebid":[{"code":10999,"message":"Taking USD"}]}}`,
+		},
+		{
+			description: "Warnings should go ahead of the ones of the auction",
+			ext:         `{"warnings":{"appnexus":[{"code":10004,"message":"repaired"}],"prebid":[{"code":10006,"message":"cache"}]}}`,
+			warnings:    []error{&errortypes.InvalidPrivacyConsent{Message: "CCPA consent is invalid"}},
+			expectedExt: `{"warnings":{"appnexus":[{"code":10004,"message":"repaired"}],"prebid":[{"code":10001,"message":"CCPA consent is invalid"},{"code":10006,"message":"cache"}]}}`,
+		},
+		{
+			description: "Warnings should be added to an empty ext",
+			warnings:    []error{&errortypes.BidderTemporarilyDisabled{Message: "The bidder 'rubicon' has been disabled."}},
+			expectedExt: `{"warnings":{"prebid":[{"code":6,"message":"The bidder 'rubicon' has been disabled."}]}}`,
+		},
+	}
+
+	for _, test := range testCases {
+		response := &openrtb.BidResponse{}
+		if test.ext != "" {
+			response.Ext = json.RawMessage(test.ext)
+		}
+		addRequestWarnings(response, test.warnings)
+		assert.JSONEq(t, test.expectedExt, string(response.Ext), test.description)
+	}
+}
+
 func TestSanitizeRequest(t *testing.T) {
 	testCases := []struct {
 		description  string
@@ -1114,6 +1397,76 @@ func TestSanitizeRequest(t *testing.T) {
 	}
 }
 
+func TestDetectDevice(t *testing.T) {
+	detector := hardcodedDeviceDetector{
+		"Roku/DVP-9.10": devicedetection.Device{
+			DeviceType: openrtb.DeviceTypeSetTopBox,
+			OS:         "Roku OS",
+			OSV:        "9.10",
+			Make:       "Roku",
+		},
+	}
+
+	testCases := []struct {
+		description        string
+		device             *openrtb.Device
+		expectedDevice     *openrtb.Device
+		expectedDeviceType pbsmetrics.DeviceType
+		expectMetric       bool
+	}{
+		{
+			description:        "Detected",
+			device:             &openrtb.Device{UA: "Roku/DVP-9.10"},
+			expectedDevice:     &openrtb.Device{UA: "Roku/DVP-9.10", DeviceType: openrtb.DeviceTypeSetTopBox, OS: "Roku OS", OSV: "9.10", Make: "Roku"},
+			expectedDeviceType: pbsmetrics.DeviceTypeSetTopBox,
+			expectMetric:       true,
+		},
+		{
+			description:        "Detected - Caller Values Kept",
+			device:             &openrtb.Device{UA: "Roku/DVP-9.10", DeviceType: openrtb.DeviceTypeConnectedTV, OS: "Other OS"},
+			expectedDevice:     &openrtb.Device{UA: "Roku/DVP-9.10", DeviceType: openrtb.DeviceTypeConnectedTV, OS: "Other OS", Make: "Roku"},
+			expectedDeviceType: pbsmetrics.DeviceTypeSetTopBox,
+			expectMetric:       true,
+		},
+		{
+			description:        "Not Detected",
+			device:             &openrtb.Device{UA: "Unknown/1.0"},
+			expectedDevice:     &openrtb.Device{UA: "Unknown/1.0"},
+			expectedDeviceType: pbsmetrics.DeviceTypeUnknown,
+			expectMetric:       true,
+		},
+		{
+			description:    "No User-Agent",
+			device:         &openrtb.Device{},
+			expectedDevice: &openrtb.Device{},
+		},
+		{
+			description: "No Device",
+		},
+	}
+
+	for _, test := range testCases {
+		metricsMock := &pbsmetrics.MetricsEngineMock{}
+		metricsMock.On("RecordDeviceDetection", test.expectedDeviceType).Return()
+		deps := &endpointDeps{metricsEngine: metricsMock, deviceDetector: detector}
+
+		req := &openrtb.BidRequest{Device: test.device}
+		deps.detectDevice(req)
+
+		assert.Equal(t, test.expectedDevice, req.Device, test.description)
+		if test.expectMetric {
+			metricsMock.AssertCalled(t, "RecordDeviceDetection", test.expectedDeviceType)
+		} else {
+			metricsMock.AssertNotCalled(t, "RecordDeviceDetection", mock.Anything)
+		}
+	}
+
+	metricsMock := &pbsmetrics.MetricsEngineMock{}
+	deps := &endpointDeps{metricsEngine: metricsMock, deviceDetector: devicedetection.NilDetector{}}
+	deps.detectDevice(&openrtb.BidRequest{Device: &openrtb.Device{UA: "Roku/DVP-9.10"}})
+	metricsMock.AssertNotCalled(t, "RecordDeviceDetection", mock.Anything)
+}
+
 // nobidExchange is a well-behaved exchange which always bids "no bid".
 type nobidExchange struct {
 	gotRequest *openrtb.BidRequest
@@ -1486,6 +1839,27 @@ func (cf mockStoredReqFetcher) FetchRequests(ctx context.Context, requestIDs []s
 	return testStoredRequestData, testStoredImpData, nil
 }
 
+// versionedStoredReqFetcher returns data which uses the versioned format, with all the traffic on the "new" version.
+type versionedStoredReqFetcher struct{}
+
+func (cf versionedStoredReqFetcher) FetchRequests(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage, errs []error) {
+	requestData = map[string]json.RawMessage{
+		"req-1": json.RawMessage(`{"versions":[{"id":"old","percent":0,"data":{"tmax":500}},{"id":"new","percent":100,"data":{"tmax":700}}]}`),
+	}
+	impData = map[string]json.RawMessage{
+		"imp-1": json.RawMessage(`{"versions":[{"id":"old","percent":0,"data":{"id":"adUnit0"}},{"id":"new","percent":100,"data":{"id":"adUnit1"}}]}`),
+	}
+	return
+}
+
+// traceExchange returns the steps which were recorded before the auction as its response's trace.
+type traceExchange struct{}
+
+func (e *traceExchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidRequest, ids exchange.IdFetcher, labels pbsmetrics.Labels, categoriesFetcher *stored_requests.CategoryFetcher, debugLog *exchange.DebugLog) (*openrtb.BidResponse, error) {
+	ext, err := json.Marshal(openrtb_ext.ExtBidResponse{Trace: tracing.AuctionTraceFromContext(ctx).Events()})
+	return &openrtb.BidResponse{ID: bidRequest.ID, Ext: ext}, err
+}
+
 type mockExchange struct {
 	lastRequest *openrtb.BidRequest
 }
@@ -1545,3 +1919,52 @@ type hardcodedResponseIPValidator struct {
 func (v hardcodedResponseIPValidator) IsValid(net.IP, iputil.IPVersion) bool {
 	return v.response
 }
+
+type hardcodedDeviceDetector map[string]devicedetection.Device
+
+func (d hardcodedDeviceDetector) Detect(userAgent string) (devicedetection.Device, bool) {
+	device, ok := d[userAgent]
+	return device, ok
+}
+
+func TestVersionSeed(t *testing.T) {
+	testCases := []struct {
+		description string
+		request     string
+		expected    string
+	}{
+		{"Request ID", `{"id":"req-1","device":{"ip":"192.0.2.1"}}`, "req-1"},
+		{"No request ID", `{"device":{"ip":"192.0.2.1"}}`, "192.0.2.1"},
+		{"IPv6", `{"id":"","device":{"ipv6":"2001:db8::1"}}`, "2001:db8::1"},
+		{"Neither", `{"imp":[]}`, ""},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.expected, versionSeed([]byte(test.request)), test.description)
+	}
+}
+
+func TestUserVersionSeed(t *testing.T) {
+	testCases := []struct {
+		description string
+		hostCookie  string
+		validIP     bool
+		expected    string
+	}{
+		{description: "Host cookie", hostCookie: "user-1", validIP: true, expected: "user-1"},
+		{description: "No host cookie", validIP: true, expected: "192.0.2.1"},
+		{description: "No host cookie or public IP", expected: ""},
+	}
+
+	for _, test := range testCases {
+		deps := &endpointDeps{
+			cfg:                       &config.Configuration{HostCookie: config.HostCookie{Family: "adnxs", CookieName: "userid"}},
+			privateNetworkIPValidator: hardcodedResponseIPValidator{response: test.validIP},
+		}
+		request := httptest.NewRequest("GET", "/openrtb2/auction/amp?tag_id=1", nil)
+		if test.hostCookie != "" {
+			request.AddCookie(&http.Cookie{Name: "userid", Value: test.hostCookie})
+		}
+		assert.Equal(t, test.expected, deps.userVersionSeed(request), test.description)
+	}
+}
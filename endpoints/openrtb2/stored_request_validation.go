@@ -0,0 +1,351 @@
+package openrtb2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/stored_requests"
+	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
+	"github.com/prebid/prebid-server/util/iputil"
+)
+
+const (
+	storedDataTypeRequest = "request"
+	storedDataTypeImp     = "imp"
+
+	// validationPlaceholderID fills in required fields which would normally come from the HTTP request,
+	// so that partial Stored Requests and Stored Imps can be validated on their own.
+	validationPlaceholderID = "stored-request-validation"
+
+	catalogValidationTimeout = 10 * time.Second
+)
+
+// StoredRequestValidator checks Stored Requests and Stored Imps the same way that the /openrtb2/auction endpoint would,
+// so that bad data can be found before it causes auctions to fail.
+type StoredRequestValidator struct {
+	deps *endpointDeps
+}
+
+// NewStoredRequestValidator returns a StoredRequestValidator which validates Stored Requests from the given Fetcher.
+func NewStoredRequestValidator(validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, cfg *config.Configuration, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName) (*StoredRequestValidator, error) {
+	if validator == nil || requestsById == nil || cfg == nil {
+		return nil, errors.New("NewStoredRequestValidator requires non-nil arguments.")
+	}
+
+	defRequest := defReqJSON != nil && len(defReqJSON) > 0
+
+	ipValidator := iputil.PublicNetworkIPValidator{
+		IPv4PrivateNetworks: cfg.RequestValidation.IPv4PrivateNetworksParsed,
+		IPv6PrivateNetworks: cfg.RequestValidation.IPv6PrivateNetworksParsed,
+	}
+
+	return &StoredRequestValidator{
+		deps: &endpointDeps{
+			paramsValidator:           validator,
+			storedReqFetcher:          requestsById,
+			videoFetcher:              empty_fetcher.EmptyFetcher{},
+			cfg:                       cfg,
+			disabledBidders:           disabledBidders,
+			defaultRequest:            defRequest,
+			defReqJSON:                defReqJSON,
+			bidderMap:                 bidderMap,
+			privateNetworkIPValidator: ipValidator,
+			deviceDetector:            devicedetection.NilDetector{},
+		},
+	}, nil
+}
+
+// storedDataValidationRequest is the body accepted by the validation endpoint.
+type storedDataValidationRequest struct {
+	// Type is either "request" or "imp".
+	Type string `json:"type"`
+	// ID is the Stored Request or Stored Imp ID. It is optional, and only used in error messages.
+	ID string `json:"id,omitempty"`
+	// Data is the Stored Request or Stored Imp data to validate. It may use the versioned format.
+	// The data can also be sent as a JSON string, so that data with syntax errors can be checked too.
+	Data json.RawMessage `json:"data"`
+	// Request is an optional incoming HTTP request which refers to the stored data.
+	// If omitted, a minimal request which only refers to the stored data is used.
+	Request json.RawMessage `json:"request,omitempty"`
+}
+
+// StoredDataValidation describes the problems found in a single Stored Request or Stored Imp.
+type StoredDataValidation struct {
+	Type     string            `json:"type"`
+	ID       string            `json:"id"`
+	Valid    bool              `json:"valid"`
+	Errors   []StoredDataError `json:"errors,omitempty"`
+	Warnings []StoredDataError `json:"warnings,omitempty"`
+}
+
+// StoredDataError is a single problem found in some stored data.
+type StoredDataError struct {
+	// Version is the version of the stored data which has the problem, if it is versioned.
+	Version string `json:"version,omitempty"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Handle validates the stored data in the body of a POST request.
+// A GET request validates every Stored Request and Stored Imp which the Fetcher can list.
+func (v *StoredRequestValidator) Handle(w http.ResponseWriter, r *http.Request) {
+	var response interface{}
+
+	switch r.Method {
+	case http.MethodGet:
+		ctx, cancel := context.WithTimeout(context.Background(), catalogValidationTimeout)
+		defer cancel()
+		response = v.ValidateCatalog(ctx)
+	case http.MethodPost:
+		validationRequest, err := v.parseValidationRequest(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("Invalid request: %s\n", err.Error())))
+			return
+		}
+		response = v.validate(r.Context(), validationRequest)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	jsonOutput, err := json.Marshal(response)
+	if err != nil {
+		glog.Errorf("/storedrequests/validate Critical error when trying to marshal the validation results: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonOutput)
+}
+
+func (v *StoredRequestValidator) parseValidationRequest(r *http.Request) (*storedDataValidationRequest, error) {
+	lr := &io.LimitedReader{
+		R: r.Body,
+		N: v.deps.cfg.MaxRequestSize,
+	}
+	body, err := ioutil.ReadAll(lr)
+	if err != nil {
+		return nil, err
+	}
+	if lr.N <= 0 {
+		return nil, fmt.Errorf("Request size exceeded max size of %d bytes.", v.deps.cfg.MaxRequestSize)
+	}
+
+	var validationRequest storedDataValidationRequest
+	if err := json.Unmarshal(body, &validationRequest); err != nil {
+		return nil, err
+	}
+	if validationRequest.Type != storedDataTypeRequest && validationRequest.Type != storedDataTypeImp {
+		return nil, fmt.Errorf(`type must be "%s" or "%s". Got "%s"`, storedDataTypeRequest, storedDataTypeImp, validationRequest.Type)
+	}
+	if len(validationRequest.Data) == 0 {
+		return nil, errors.New("data is required")
+	}
+	if validationRequest.Data[0] == '"' {
+		var rawData string
+		if err := json.Unmarshal(validationRequest.Data, &rawData); err != nil {
+			return nil, err
+		}
+		validationRequest.Data = json.RawMessage(rawData)
+	}
+	if validationRequest.ID == "" {
+		validationRequest.ID = validationPlaceholderID
+	}
+	return &validationRequest, nil
+}
+
+// ValidateCatalog validates every Stored Request and Stored Imp which the Fetcher can list,
+// and returns the results for the ones which have problems.
+//
+// If the Fetcher doesn't implement stored_requests.Lister, nothing is validated.
+func (v *StoredRequestValidator) ValidateCatalog(ctx context.Context) []StoredDataValidation {
+	results := make([]StoredDataValidation, 0)
+
+	lister, ok := v.deps.storedReqFetcher.(stored_requests.Lister)
+	if !ok {
+		glog.Warning("The Stored Request backend can't list its IDs, so the catalog can't be validated.")
+		return results
+	}
+
+	requestIDs, impIDs := lister.ListIDs(ctx)
+	storedRequests, storedImps, errs := v.deps.storedReqFetcher.FetchRequests(ctx, requestIDs, impIDs)
+	for _, err := range errs {
+		glog.Errorf("Failed to fetch stored data for validation: %v", err)
+	}
+
+	for _, id := range requestIDs {
+		if data, ok := storedRequests[id]; ok {
+			if result := v.validate(ctx, &storedDataValidationRequest{Type: storedDataTypeRequest, ID: id, Data: data}); !result.Valid {
+				results = append(results, result)
+			}
+		}
+	}
+	for _, id := range impIDs {
+		if data, ok := storedImps[id]; ok {
+			if result := v.validate(ctx, &storedDataValidationRequest{Type: storedDataTypeImp, ID: id, Data: data}); !result.Valid {
+				results = append(results, result)
+			}
+		}
+	}
+	return results
+}
+
+// LogCatalogErrors validates the whole catalog, and logs the IDs of any invalid Stored Requests or Stored Imps.
+func (v *StoredRequestValidator) LogCatalogErrors() {
+	ctx, cancel := context.WithTimeout(context.Background(), catalogValidationTimeout)
+	defer cancel()
+
+	results := v.ValidateCatalog(ctx)
+	for _, result := range results {
+		for _, err := range result.Errors {
+			if err.Version != "" {
+				glog.Errorf("Stored %s with ID=\"%s\" (version %s) is invalid: %s", result.Type, result.ID, err.Version, err.Message)
+			} else {
+				glog.Errorf("Stored %s with ID=\"%s\" is invalid: %s", result.Type, result.ID, err.Message)
+			}
+		}
+	}
+	if len(results) > 0 {
+		glog.Errorf("Found %d invalid Stored Requests or Stored Imps.", len(results))
+	}
+}
+
+// validate checks every version of the stored data in the request.
+func (v *StoredRequestValidator) validate(ctx context.Context, validationRequest *storedDataValidationRequest) StoredDataValidation {
+	result := StoredDataValidation{
+		Type: validationRequest.Type,
+		ID:   validationRequest.ID,
+	}
+
+	versions, err := stored_requests.AllVersions(validationRequest.ID, validationRequest.Data)
+	if err != nil {
+		result.Errors = append(result.Errors, newStoredDataError("", err))
+		return result
+	}
+
+	for _, version := range versions {
+		for _, err := range v.validateVersion(ctx, validationRequest, version.Data) {
+			if errortypes.ContainsFatalError([]error{err}) {
+				result.Errors = append(result.Errors, newStoredDataError(version.ID, err))
+			} else {
+				result.Warnings = append(result.Warnings, newStoredDataError(version.ID, err))
+			}
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result
+}
+
+// validateVersion merges a single version of the stored data into the incoming request exactly like
+// processStoredRequests does, and then runs the same validations as the auction endpoint.
+func (v *StoredRequestValidator) validateVersion(ctx context.Context, validationRequest *storedDataValidationRequest, data json.RawMessage) []error {
+	deps := *v.deps
+	fetcher := &singleEntryFetcher{id: validationRequest.ID, data: data}
+	if validationRequest.Type == storedDataTypeImp {
+		fetcher.isImp = true
+	}
+	deps.storedReqFetcher = fetcher
+
+	incomingRequest := validationRequest.Request
+	if len(incomingRequest) == 0 {
+		incomingRequest = placeholderIncomingRequest(validationRequest.Type, validationRequest.ID)
+	}
+
+	resolvedRequest, errs := deps.processStoredRequests(ctx, incomingRequest)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// Move the OpenRTB 2.6 fields to their ext locations, like the auction endpoint does before validating.
+	resolvedRequest, err := openrtb_ext.NormalizeOpenRTB26Request(resolvedRequest)
+	if err != nil {
+		return []error{err}
+	}
+
+	req := &openrtb.BidRequest{}
+	if err := json.Unmarshal(resolvedRequest, req); err != nil {
+		return []error{err}
+	}
+	fillValidationPlaceholders(req)
+
+	if err := processInterstitials(req); err != nil {
+		return []error{err}
+	}
+
+	return deps.validateBidRequest(req, validationRequest.Type == storedDataTypeImp)
+}
+
+// placeholderIncomingRequest builds the smallest incoming request which refers to the stored data.
+func placeholderIncomingRequest(dataType string, id string) json.RawMessage {
+	// These keys must be kept in sync with openrtb_ext.ExtStoredRequest
+	ext := fmt.Sprintf(`{"%s":{"storedrequest":{"id":%s}}}`, openrtb_ext.PrebidExtKey, strconv.Quote(id))
+	if dataType == storedDataTypeImp {
+		return json.RawMessage(fmt.Sprintf(`{"imp":[{"ext":%s}]}`, ext))
+	}
+	return json.RawMessage(fmt.Sprintf(`{"ext":%s}`, ext))
+}
+
+// fillValidationPlaceholders fills in the required fields which are usually sent by the caller
+// rather than stored, so that they don't get reported as errors.
+func fillValidationPlaceholders(req *openrtb.BidRequest) {
+	if req.ID == "" {
+		req.ID = validationPlaceholderID
+	}
+	if req.Site == nil && req.App == nil {
+		req.Site = &openrtb.Site{ID: validationPlaceholderID}
+	}
+	for i := range req.Imp {
+		if req.Imp[i].ID == "" {
+			req.Imp[i].ID = fmt.Sprintf("%s-%d", validationPlaceholderID, i)
+		}
+	}
+}
+
+func newStoredDataError(version string, err error) StoredDataError {
+	return StoredDataError{
+		Version: version,
+		Code:    errortypes.ReadCode(err),
+		Message: err.Error(),
+	}
+}
+
+// singleEntryFetcher is a Fetcher which only knows about the stored data being validated.
+type singleEntryFetcher struct {
+	id    string
+	data  json.RawMessage
+	isImp bool
+}
+
+func (f *singleEntryFetcher) FetchRequests(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage, errs []error) {
+	requestData = f.find(requestIDs, !f.isImp, "Request", &errs)
+	impData = f.find(impIDs, f.isImp, "Imp", &errs)
+	return
+}
+
+func (f *singleEntryFetcher) find(ids []string, matchesType bool, dataType string, errs *[]error) map[string]json.RawMessage {
+	data := make(map[string]json.RawMessage, len(ids))
+	for _, id := range ids {
+		if matchesType && id == f.id {
+			data[id] = f.data
+		} else {
+			*errs = append(*errs, stored_requests.NotFoundError{ID: id, DataType: dataType})
+		}
+	}
+	return data
+}
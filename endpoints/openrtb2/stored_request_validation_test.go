@@ -0,0 +1,206 @@
+package openrtb2
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStoredData(t *testing.T) {
+	testCases := []struct {
+		description    string
+		body           string
+		expectValid    bool
+		expectErrors   []string
+		expectVersions []string
+	}{
+		{
+			description: "Valid Stored Imp",
+			body:        `{"type":"imp","id":"imp-1","data":{"banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":12345}}}}`,
+			expectValid: true,
+		},
+		{
+			description:  "Stored Imp with bad bidder params",
+			body:         `{"type":"imp","id":"imp-1","data":{"banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":"abc"}}}}`,
+			expectValid:  false,
+			expectErrors: []string{"request.imp[0].ext.appnexus failed validation."},
+		},
+		{
+			description: "Partial Stored Request",
+			body:        `{"type":"request","id":"req-1","data":{"tmax":500,"ext":{"prebid":{"targeting":{"pricegranularity":"low"}}}}}`,
+			expectValid: true,
+		},
+		{
+			description:  "Stored Request with invalid JSON",
+			body:         `{"type":"request","id":"req-1","data":"{\"tmax\":500}}"}`,
+			expectValid:  false,
+			expectErrors: []string{"ext.prebid.storedrequest.id refers to Stored Request"},
+		},
+		{
+			description:  "Stored Request with an invalid field",
+			body:         `{"type":"request","id":"req-1","data":{"tmax":-1}}`,
+			expectValid:  false,
+			expectErrors: []string{"request.tmax must be nonnegative. Got -1"},
+		},
+		{
+			description:    "Versioned Stored Imp with one bad version",
+			body:           `{"type":"imp","id":"imp-1","data":{"versions":[{"id":"good","percent":50,"data":{"banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":12345}}}},{"id":"bad","percent":50,"data":{"banner":{"format":[{"w":300,"h":250}]},"ext":{"unknownbidder":{}}}}]}}`,
+			expectValid:    false,
+			expectErrors:   []string{"request.imp[0].ext contains unknown bidder: unknownbidder"},
+			expectVersions: []string{"bad"},
+		},
+		{
+			description:  "Stored Imp with broken versions",
+			body:         `{"type":"imp","id":"imp-1","data":{"versions":[{"id":"v1","percent":50,"data":{}}]}}`,
+			expectValid:  false,
+			expectErrors: []string{"version percentages must add up to 100"},
+		},
+		{
+			description:  "Stored Request with an invalid OpenRTB 2.6 field",
+			body:         `{"type":"request","id":"req-1","data":{"regs":{"gdpr":2}}}`,
+			expectValid:  false,
+			expectErrors: []string{"request.regs.ext.gdpr must be either 0 or 1."},
+		},
+		{
+			description: "Stored Request with OpenRTB 2.6 fields",
+			body:        `{"type":"request","id":"req-1","data":{"regs":{"gdpr":1},"user":{"consent":"BONV8oqONXwgmADACHENAO7pqzAAppY"}}}`,
+			expectValid: true,
+		},
+		{
+			description: "Stored Imp merged into a given request",
+			body:        `{"type":"imp","id":"imp-1","data":{"banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":12345}}},"request":{"id":"some-request","app":{"id":"some-app"},"imp":[{"id":"some-imp","ext":{"prebid":{"storedrequest":{"id":"imp-1"}}}}]}}`,
+			expectValid: true,
+		},
+	}
+
+	validator := newTestStoredRequestValidator(t)
+	for _, test := range testCases {
+		req := httptest.NewRequest("POST", "/storedrequests/validate", strings.NewReader(test.body))
+		recorder := httptest.NewRecorder()
+		validator.Handle(recorder, req)
+
+		if !assert.Equal(t, http.StatusOK, recorder.Code, test.description) {
+			continue
+		}
+		var result StoredDataValidation
+		if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: Failed to unmarshal the response: %v", test.description, err)
+		}
+
+		assert.Equal(t, test.expectValid, result.Valid, test.description)
+		assert.Len(t, result.Errors, len(test.expectErrors), test.description)
+		for i, expected := range test.expectErrors {
+			if i < len(result.Errors) {
+				assert.Contains(t, result.Errors[i].Message, expected, test.description)
+			}
+		}
+		for i, expected := range test.expectVersions {
+			if i < len(result.Errors) {
+				assert.Equal(t, expected, result.Errors[i].Version, test.description)
+			}
+		}
+	}
+}
+
+func TestValidateStoredDataBadRequests(t *testing.T) {
+	testCases := []struct {
+		description string
+		method      string
+		body        string
+		expectCode  int
+	}{
+		{
+			description: "Unknown type",
+			method:      "POST",
+			body:        `{"type":"video","data":{}}`,
+			expectCode:  http.StatusBadRequest,
+		},
+		{
+			description: "Missing data",
+			method:      "POST",
+			body:        `{"type":"imp"}`,
+			expectCode:  http.StatusBadRequest,
+		},
+		{
+			description: "Malformed body",
+			method:      "POST",
+			body:        `{`,
+			expectCode:  http.StatusBadRequest,
+		},
+		{
+			description: "Unsupported method",
+			method:      "PUT",
+			body:        `{}`,
+			expectCode:  http.StatusMethodNotAllowed,
+		},
+	}
+
+	validator := newTestStoredRequestValidator(t)
+	for _, test := range testCases {
+		req := httptest.NewRequest(test.method, "/storedrequests/validate", strings.NewReader(test.body))
+		recorder := httptest.NewRecorder()
+		validator.Handle(recorder, req)
+		assert.Equal(t, test.expectCode, recorder.Code, test.description)
+	}
+}
+
+func TestValidateCatalog(t *testing.T) {
+	validator, err := NewStoredRequestValidator(newParamsValidator(t), &listingStoredReqFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, map[string]string{}, nil, openrtb_ext.BidderMap)
+	if err != nil {
+		t.Fatalf("Failed to create the validator: %v", err)
+	}
+
+	results := validator.ValidateCatalog(context.Background())
+	if assert.Len(t, results, 2, "Only the invalid Stored Requests and Stored Imps should be reported") {
+		assert.Equal(t, "request", results[0].Type)
+		assert.Equal(t, "bad-request", results[0].ID)
+		assert.Equal(t, "imp", results[1].Type)
+		assert.Equal(t, "bad-imp", results[1].ID)
+	}
+}
+
+func TestValidateCatalogWithoutLister(t *testing.T) {
+	validator, err := NewStoredRequestValidator(newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, map[string]string{}, nil, openrtb_ext.BidderMap)
+	if err != nil {
+		t.Fatalf("Failed to create the validator: %v", err)
+	}
+
+	assert.Empty(t, validator.ValidateCatalog(context.Background()), "Nothing can be validated if the Fetcher can't list its IDs")
+}
+
+func newTestStoredRequestValidator(t *testing.T) *StoredRequestValidator {
+	validator, err := NewStoredRequestValidator(newParamsValidator(t), empty_fetcher.EmptyFetcher{}, &config.Configuration{MaxRequestSize: maxSize}, map[string]string{}, nil, openrtb_ext.BidderMap)
+	if err != nil {
+		t.Fatalf("Failed to create the validator: %v", err)
+	}
+	return validator
+}
+
+// listingStoredReqFetcher is a Fetcher and Lister with one good and one bad entry of each type.
+type listingStoredReqFetcher struct{}
+
+var listingStoredRequests = map[string]json.RawMessage{
+	"good-request": json.RawMessage(`{"tmax":500}`),
+	"bad-request":  json.RawMessage(`{"tmax":-1}`),
+}
+
+var listingStoredImps = map[string]json.RawMessage{
+	"good-imp": json.RawMessage(`{"banner":{"format":[{"w":300,"h":250}]},"ext":{"appnexus":{"placementId":12345}}}`),
+	"bad-imp":  json.RawMessage(`{"banner":{},"ext":{"appnexus":{"placementId":12345}}}`),
+}
+
+func (f *listingStoredReqFetcher) FetchRequests(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage, errs []error) {
+	return listingStoredRequests, listingStoredImps, nil
+}
+
+func (f *listingStoredReqFetcher) ListIDs(ctx context.Context) (requestIDs []string, impIDs []string) {
+	return []string{"bad-request", "good-request"}, []string{"bad-imp", "good-imp"}
+}
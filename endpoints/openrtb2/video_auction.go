@@ -25,17 +25,19 @@ import (
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/prebid_cache_client"
 	"github.com/prebid/prebid-server/stored_requests"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/usersync"
 )
 
 var defaultRequestTimeout int64 = 5000
 
-func NewVideoEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, videoFetcher stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName, cache prebid_cache_client.Client) (httprouter.Handle, error) {
+func NewVideoEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, videoFetcher stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName, cache prebid_cache_client.Client, deviceDetector devicedetection.Detector) (httprouter.Handle, error) {
 
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
 		return nil, errors.New("NewVideoEndpoint requires non-nil arguments.")
@@ -65,7 +67,8 @@ func NewVideoEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamVal
 		bidderMap,
 		cache,
 		videoEndpointRegexp,
-		ipValidator}).VideoAuctionEndpoint), nil
+		ipValidator,
+		deviceDetector}).VideoAuctionEndpoint), nil
 }
 
 /*
@@ -142,6 +145,9 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	parseCtx, parseSpan := tracing.StartSpan(r.Context(), "parse_request")
+	defer parseSpan.End()
+
 	resolvedRequest := requestJson
 	if debugLog.Enabled {
 		debugLog.Data.Request = string(requestJson)
@@ -152,6 +158,9 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	// Video requests have no request.id, so the versions of their Stored Requests and Stored Imps depend on the user.
+	versionSeed := deps.userVersionSeed(r)
+
 	//load additional data - stored simplified req
 	storedRequestId, err := getVideoStoredRequestId(requestJson)
 
@@ -161,7 +170,7 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 			return
 		}
 	} else {
-		storedRequest, errs := deps.loadStoredVideoRequest(context.Background(), storedRequestId)
+		storedRequest, errs := deps.loadStoredVideoRequest(tracing.Detach(parseCtx), storedRequestId, versionSeed)
 		if len(errs) > 0 {
 			handleError(&labels, w, errs, &vo, &debugLog)
 			return
@@ -181,6 +190,14 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if output := r.URL.Query().Get("output"); output != "" {
+		if err := validateVideoOutput(output); err != nil {
+			handleError(&labels, w, []error{err}, &vo, &debugLog)
+			return
+		}
+		videoBidReq.Output = output
+	}
+
 	vo.VideoRequest = videoBidReq
 
 	var bidReq = &openrtb.BidRequest{}
@@ -206,7 +223,7 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 	}
 
 	//create impressions array
-	imps, podErrors := deps.createImpressions(videoBidReq, podErrors)
+	imps, podErrors := deps.createImpressions(videoBidReq, podErrors, versionSeed)
 
 	if len(podErrors) == initialPodNumber {
 		resPodErr := make([]string, 0)
@@ -231,7 +248,9 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	ctx := context.Background()
+	parseSpan.End()
+
+	ctx := tracing.Detach(r.Context())
 	timeout := deps.cfg.AuctionTimeouts.LimitAuctionTimeout(time.Duration(bidReq.TMax) * time.Millisecond)
 	if timeout > 0 {
 		var cancel context.CancelFunc
@@ -267,9 +286,10 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 		handleError(&labels, w, errL, &vo, &debugLog)
 		return
 	}
+	addRequestWarnings(response, errortypes.WarningOnly(errL))
 
 	//build simplified response
-	bidResp, err := buildVideoResponse(response, podErrors)
+	bidResp, err := buildVideoResponse(response, podErrors, videoBidReq)
 	if err != nil {
 		errL := []error{err}
 		handleError(&labels, w, errL, &vo, &debugLog)
@@ -277,10 +297,23 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 	}
 	if bidReq.Test == 1 {
 		bidResp.Ext = response.Ext
+	} else {
+		bidResp.Ext = warningsExt(response.Ext)
 	}
 
 	vo.VideoResponse = bidResp
 
+	if videoBidReq.Output == openrtb_ext.VideoOutputVMAP {
+		vmap, err := buildVMAP(bidResp, videoBidReq.PodConfig.Pods, deps.cfg.GetCachedAssetURL)
+		if err != nil {
+			handleError(&labels, w, []error{err}, &vo, &debugLog)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(vmap)
+		return
+	}
+
 	resp, err := json.Marshal(bidResp)
 	//resp, err := json.Marshal(response)
 	if err != nil {
@@ -359,18 +392,17 @@ func handleError(labels *pbsmetrics.Labels, w http.ResponseWriter, errL []error,
 	vo.Errors = append(vo.Errors, errL...)
 }
 
-func (deps *endpointDeps) createImpressions(videoReq *openrtb_ext.BidRequestVideo, podErrors []PodError) ([]openrtb.Imp, []PodError) {
+func (deps *endpointDeps) createImpressions(videoReq *openrtb_ext.BidRequestVideo, podErrors []PodError, versionSeed string) ([]openrtb.Imp, []PodError) {
 	videoDur := videoReq.PodConfig.DurationRangeSec
 	minDuration, maxDuration := minMax(videoDur)
 	reqExactDur := videoReq.PodConfig.RequireExactDuration
-	videoData := videoReq.Video
 
 	finalImpsArray := make([]openrtb.Imp, 0)
 	for ind, pod := range videoReq.PodConfig.Pods {
 
 		//load stored impression
 		storedImpressionId := string(pod.ConfigId)
-		storedImp, errs := deps.loadStoredImp(storedImpressionId)
+		storedImp, errs := deps.loadStoredImp(storedImpressionId, versionSeed)
 		if errs != nil {
 			err := fmt.Sprintf("unable to load configid %s, Pod id: %d", storedImpressionId, pod.PodId)
 			podErr := PodError{}
@@ -391,19 +423,25 @@ func (deps *endpointDeps) createImpressions(videoReq *openrtb_ext.BidRequestVide
 
 		impsArray := make([]openrtb.Imp, numImps)
 		for impInd := range impsArray {
-			newImp := createImpressionTemplate(storedImp, videoData)
+			var newImp openrtb.Imp
+			if videoReq.Audio != nil {
+				newImp = createAudioImpressionTemplate(storedImp, videoReq.Audio)
+			} else {
+				newImp = createImpressionTemplate(storedImp, videoReq.Video)
+			}
 			impsArray[impInd] = newImp
+			impMinDuration, impMaxDuration := podImpDurations(&impsArray[impInd])
 			if reqExactDur {
 				//floor := int(math.Floor(ind/impDivNumber))
 				durationIndex := impInd / impDivNumber
 				if durationIndex > len(videoDur)-1 {
 					durationIndex = len(videoDur) - 1
 				}
-				impsArray[impInd].Video.MaxDuration = int64(videoDur[durationIndex])
-				impsArray[impInd].Video.MinDuration = int64(videoDur[durationIndex])
+				*impMaxDuration = int64(videoDur[durationIndex])
+				*impMinDuration = int64(videoDur[durationIndex])
 				//fmt.Println("Imp ind  ", impInd, "duration ", videoDur[durationIndex])
 			} else {
-				impsArray[impInd].Video.MaxDuration = int64(maxDuration)
+				*impMaxDuration = int64(maxDuration)
 			}
 
 			impsArray[impInd].ID = fmt.Sprintf("%d_%d", pod.PodId, impInd)
@@ -428,7 +466,22 @@ func createImpressionTemplate(imp openrtb.Imp, video *openrtb.Video) openrtb.Imp
 	return imp
 }
 
-func (deps *endpointDeps) loadStoredImp(storedImpId string) (openrtb.Imp, []error) {
+func createAudioImpressionTemplate(imp openrtb.Imp, audio *openrtb.Audio) openrtb.Imp {
+	//like the video ones, every audio impression needs its own copy of the audio object
+	newAudio := *audio
+	imp.Audio = &newAudio
+	return imp
+}
+
+// podImpDurations returns the duration fields of the imp's audio object, or of its video object if it has no audio.
+func podImpDurations(imp *openrtb.Imp) (minDuration *int64, maxDuration *int64) {
+	if imp.Audio != nil {
+		return &imp.Audio.MinDuration, &imp.Audio.MaxDuration
+	}
+	return &imp.Video.MinDuration, &imp.Video.MaxDuration
+}
+
+func (deps *endpointDeps) loadStoredImp(storedImpId string, versionSeed string) (openrtb.Imp, []error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(storedRequestTimeoutMillis)*time.Millisecond)
 	defer cancel()
 
@@ -438,7 +491,11 @@ func (deps *endpointDeps) loadStoredImp(storedImpId string) (openrtb.Imp, []erro
 		return impr, err
 	}
 
-	if err := json.Unmarshal(imp[storedImpId], &impr); err != nil {
+	impJson, _, versionErr := stored_requests.ResolveVersion(storedImpId, imp[storedImpId], versionSeed)
+	if versionErr != nil {
+		return impr, []error{versionErr}
+	}
+	if err := json.Unmarshal(impJson, &impr); err != nil {
 		return impr, []error{err}
 	}
 	return impr, nil
@@ -458,19 +515,37 @@ func minMax(array []int) (int, int) {
 	return min, max
 }
 
-func buildVideoResponse(bidresponse *openrtb.BidResponse, podErrors []PodError) (*openrtb_ext.BidResponseVideo, error) {
+// warningsExt returns an ext which only holds the warnings of the auction response's ext, or nil if there are none.
+func warningsExt(responseExt json.RawMessage) json.RawMessage {
+	var ext struct {
+		Warnings map[openrtb_ext.BidderName][]openrtb_ext.ExtBidderError `json:"warnings,omitempty"`
+	}
+	if len(responseExt) == 0 || json.Unmarshal(responseExt, &ext) != nil || len(ext.Warnings) == 0 {
+		return nil
+	}
+	warnings, err := json.Marshal(ext)
+	if err != nil {
+		return nil
+	}
+	return warnings
+}
+
+func buildVideoResponse(bidresponse *openrtb.BidResponse, podErrors []PodError, videoReq *openrtb_ext.BidRequestVideo) (*openrtb_ext.BidResponseVideo, error) {
 
+	targetingPrefix := videoReq.TargetingPrefix
 	adPods := make([]*openrtb_ext.AdPod, 0)
+	candidates := make(map[int64][]podCandidate)
 	anyBidsReturned := false
 	for _, seatBid := range bidresponse.SeatBid {
-		for _, bid := range seatBid.Bid {
+		for i := range seatBid.Bid {
+			bid := &seatBid.Bid[i]
 			anyBidsReturned = true
 
 			var tempRespBidExt openrtb_ext.ExtBid
 			if err := json.Unmarshal(bid.Ext, &tempRespBidExt); err != nil {
 				return nil, err
 			}
-			if tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbVastCacheKey)] == "" {
+			if tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbVastCacheKey.WithPrefix(targetingPrefix))] == "" {
 				continue
 			}
 
@@ -479,9 +554,10 @@ func buildVideoResponse(bidresponse *openrtb.BidResponse, podErrors []PodError)
 			podId, _ := strconv.ParseInt(podNum, 0, 64)
 
 			videoTargeting := openrtb_ext.VideoTargeting{
-				HbPb:       tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbpbConstantKey)],
-				HbPbCatDur: tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbCategoryDurationKey)],
-				HbCacheID:  tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbVastCacheKey)],
+				HbPb:       tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbpbConstantKey.WithPrefix(targetingPrefix))],
+				HbPbCatDur: tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbCategoryDurationKey.WithPrefix(targetingPrefix))],
+				HbCacheID:  tempRespBidExt.Prebid.Targeting[string(openrtb_ext.HbVastCacheKey.WithPrefix(targetingPrefix))],
+				Prefix:     targetingPrefix,
 			}
 
 			adPod := findAdPod(podId, adPods)
@@ -493,7 +569,16 @@ func buildVideoResponse(bidresponse *openrtb.BidResponse, podErrors []PodError)
 				adPods = append(adPods, adPod)
 			}
 			adPod.Targeting = append(adPod.Targeting, videoTargeting)
+			candidates[podId] = append(candidates[podId], newPodCandidate(bid, &tempRespBidExt, videoTargeting))
+		}
+	}
 
+	// The ad breaks of a VMAP response play every bid, so they need the optimized pods.
+	if videoReq.PodConfig.Optimize || videoReq.Output == openrtb_ext.VideoOutputVMAP {
+		for _, adPod := range adPods {
+			if pod, ok := findPod(adPod.PodId, videoReq.PodConfig.Pods); ok {
+				adPod.Targeting = optimizePod(candidates[adPod.PodId], pod)
+			}
 		}
 	}
 
@@ -528,10 +613,25 @@ func findAdPod(podInd int64, pods []*openrtb_ext.AdPod) *openrtb_ext.AdPod {
 	return nil
 }
 
-func (deps *endpointDeps) loadStoredVideoRequest(ctx context.Context, storedRequestId string) ([]byte, []error) {
-	storedRequests, _, errs := deps.videoFetcher.FetchRequests(ctx, []string{storedRequestId}, []string{})
-	jsonString := storedRequests[storedRequestId]
-	return jsonString, errs
+func findPod(podId int64, pods []openrtb_ext.Pod) (openrtb_ext.Pod, bool) {
+	for _, pod := range pods {
+		if int64(pod.PodId) == podId {
+			return pod, true
+		}
+	}
+	return openrtb_ext.Pod{}, false
+}
+
+func (deps *endpointDeps) loadStoredVideoRequest(ctx context.Context, storedRequestId string, versionSeed string) ([]byte, []error) {
+	storedRequests, _, errs := fetchStoredData(ctx, deps.videoFetcher, []string{storedRequestId}, []string{})
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	jsonString, _, err := stored_requests.ResolveVersion(storedRequestId, storedRequests[storedRequestId], versionSeed)
+	if err != nil {
+		return nil, []error{err}
+	}
+	return jsonString, nil
 }
 
 func getVideoStoredRequestId(request []byte) (string, error) {
@@ -626,6 +726,7 @@ func createBidExtension(videoRequest *openrtb_ext.BidRequestVideo) ([]byte, erro
 		IncludeWinners:       true,
 		IncludeBrandCategory: inclBrandCat,
 		DurationRangeSec:     durationRangeSec,
+		Prefix:               videoRequest.TargetingPrefix,
 	}
 
 	vastXml := openrtb_ext.ExtRequestPrebidCacheVAST{}
@@ -650,6 +751,12 @@ func createBidExtension(videoRequest *openrtb_ext.BidRequestVideo) ([]byte, erro
 func (deps *endpointDeps) parseVideoRequest(request []byte, headers http.Header) (req *openrtb_ext.BidRequestVideo, errs []error, podErrors []PodError) {
 	req = &openrtb_ext.BidRequestVideo{}
 
+	request, err := openrtb_ext.NormalizeOpenRTB26Request(request)
+	if err != nil {
+		errs = []error{err}
+		return
+	}
+
 	if err := json.Unmarshal(request, &req); err != nil {
 		errs = []error{err}
 		return
@@ -722,10 +829,18 @@ func (deps *endpointDeps) validateVideoRequest(req *openrtb_ext.BidRequestVideo)
 			err := fmt.Sprintf("request missing or incorrect required field: PodConfig.Pods.AdPodDurationSec, Pod index: %d", ind)
 			podErr.ErrMsgs = append(podErr.ErrMsgs, err)
 		}
+		if pod.MaxAds < 0 {
+			err := fmt.Sprintf("request incorrect field: PodConfig.Pods.MaxAds is negative, Pod index: %d", ind)
+			podErr.ErrMsgs = append(podErr.ErrMsgs, err)
+		}
 		if pod.AdPodDurationSec < 0 {
 			err := fmt.Sprintf("request incorrect required field: PodConfig.Pods.AdPodDurationSec is negative, Pod index: %d", ind)
 			podErr.ErrMsgs = append(podErr.ErrMsgs, err)
 		}
+		if pod.TimeOffset != "" && !vmapTimeOffset.MatchString(pod.TimeOffset) {
+			err := fmt.Sprintf("request incorrect field: PodConfig.Pods.TimeOffset must be \"start\", \"end\", \"hh:mm:ss[.mmm]\", \"n%%\" or \"#m\", Pod index: %d", ind)
+			podErr.ErrMsgs = append(podErr.ErrMsgs, err)
+		}
 		if pod.ConfigId == "" {
 			err := fmt.Sprintf("request missing or incorrect required field: PodConfig.Pods.ConfigId, Pod index: %d", ind)
 			podErr.ErrMsgs = append(podErr.ErrMsgs, err)
@@ -736,6 +851,14 @@ func (deps *endpointDeps) validateVideoRequest(req *openrtb_ext.BidRequestVideo)
 			podErrors = append(podErrors, podErr)
 		}
 	}
+	if err := openrtb_ext.ValidateTargetingPrefix(req.TargetingPrefix); err != nil {
+		errL = append(errL, fmt.Errorf("request.targeting%v", err))
+	} else if err := deps.validateTargetingPrefixLength(req.TargetingPrefix, req.Site, req.App); err != nil {
+		errL = append(errL, fmt.Errorf("request.targeting%v", err))
+	}
+	if err := validateVideoOutput(req.Output); err != nil {
+		errL = append(errL, err)
+	}
 	if req.App == nil && req.Site == nil {
 		err := errors.New("request missing required field: site or app")
 		errL = append(errL, err)
@@ -760,7 +883,10 @@ func (deps *endpointDeps) validateVideoRequest(req *openrtb_ext.BidRequestVideo)
 		}
 	}
 
-	if req.Video != nil {
+	if req.Video != nil && req.Audio != nil {
+		err := errors.New("request.video or request.audio must be defined, but not both")
+		errL = append(errL, err)
+	} else if req.Video != nil {
 		if len(req.Video.MIMEs) == 0 {
 			err := errors.New("request missing required field: Video.Mimes")
 			errL = append(errL, err)
@@ -782,14 +908,43 @@ func (deps *endpointDeps) validateVideoRequest(req *openrtb_ext.BidRequestVideo)
 			err := errors.New("request missing required field: Video.Protocols")
 			errL = append(errL, err)
 		}
+	} else if req.Audio != nil {
+		if len(req.Audio.MIMEs) == 0 {
+			err := errors.New("request missing required field: Audio.Mimes")
+			errL = append(errL, err)
+		} else {
+			mimes := make([]string, 0, len(req.Audio.MIMEs))
+			for _, mime := range req.Audio.MIMEs {
+				if mime != "" {
+					mimes = append(mimes, mime)
+				}
+			}
+			if len(mimes) == 0 {
+				err := errors.New("request missing required field: Audio.Mimes, mime types contains empty strings only")
+				errL = append(errL, err)
+			}
+			req.Audio.MIMEs = mimes
+		}
+
+		if req.Audio.Feed != 0 && (req.Audio.Feed < openrtb.FeedTypeMusicService || req.Audio.Feed > openrtb.FeedTypePodcast) {
+			err := fmt.Errorf("request incorrect field: Audio.Feed must be 1, 2 or 3. Got %d", req.Audio.Feed)
+			errL = append(errL, err)
+		}
 	} else {
-		err := errors.New("request missing required field: Video")
+		err := errors.New("request missing required field: Video or Audio")
 		errL = append(errL, err)
 	}
 
 	return errL, podErrors
 }
 
+func validateVideoOutput(output string) error {
+	if output != "" && output != openrtb_ext.VideoOutputJSON && output != openrtb_ext.VideoOutputVMAP {
+		return fmt.Errorf("request.output must be \"%s\" or \"%s\". Got \"%s\"", openrtb_ext.VideoOutputJSON, openrtb_ext.VideoOutputVMAP, output)
+	}
+	return nil
+}
+
 func isZeroOrNegativeDuration(duration []int) bool {
 	for _, value := range duration {
 		if value <= 0 {
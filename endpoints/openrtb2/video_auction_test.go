@@ -16,6 +16,7 @@ import (
 	"github.com/prebid/prebid-server/analytics"
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
@@ -100,6 +101,41 @@ func TestVideoEndpointImpressionsDuration(t *testing.T) {
 
 }
 
+func TestVideoEndpointAudioPods(t *testing.T) {
+	ex := &mockExchangeVideo{}
+	reqData, err := ioutil.ReadFile("sample-requests/video/audio_valid_sample.json")
+	if err != nil {
+		t.Fatalf("Failed to fetch a valid request: %v", err)
+	}
+	reqBody := string(getRequestPayload(t, reqData))
+	req := httptest.NewRequest("POST", "/openrtb2/video", strings.NewReader(reqBody))
+	recorder := httptest.NewRecorder()
+
+	deps := mockDeps(t, ex)
+	deps.VideoAuctionEndpoint(recorder, req, nil)
+
+	if ex.lastRequest == nil {
+		t.Fatalf("The request never made it into the Exchange: %s", recorder.Body.String())
+	}
+
+	assert.Len(t, ex.lastRequest.Imp, 11, "Incorrect number of impressions in request")
+	for _, imp := range ex.lastRequest.Imp {
+		if assert.NotNil(t, imp.Audio, "Imp %s should have an audio object", imp.ID) {
+			assert.Equal(t, []string{"audio/mp4", "audio/mpeg"}, imp.Audio.MIMEs, "Incorrect audio mimes in imp %s", imp.ID)
+			assert.Equal(t, int64(30), imp.Audio.MinDuration, "Incorrect audio min duration in imp %s", imp.ID)
+			assert.Equal(t, int64(30), imp.Audio.MaxDuration, "Incorrect audio max duration in imp %s", imp.ID)
+		}
+	}
+	ex.lastRequest.Imp[0].Audio.MaxDuration = 60
+	assert.Equal(t, int64(30), ex.lastRequest.Imp[1].Audio.MaxDuration, "Each imp should have its own copy of the audio object")
+
+	resp := &openrtb_ext.BidResponseVideo{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), resp); err != nil {
+		t.Fatalf("Unable to unmarshal response.")
+	}
+	assert.Len(t, resp.AdPods, 5, "Incorrect number of Ad Pods in response")
+}
+
 func TestCreateBidExtension(t *testing.T) {
 	durationRange := make([]int, 0)
 	durationRange = append(durationRange, 15)
@@ -299,6 +335,48 @@ func TestVideoEndpointNoPods(t *testing.T) {
 	assert.Equal(t, "Critical error while running the video endpoint:  request missing required field: PodConfig.DurationRangeSec request missing required field: PodConfig.Pods", errorMessage, "Incorrect request validation message")
 }
 
+func TestVideoEndpointVMAPOutput(t *testing.T) {
+	ex := &mockExchangeVideo{
+		cache: &mockCacheClient{},
+	}
+	reqData, err := ioutil.ReadFile("sample-requests/video/video_valid_sample.json")
+	if err != nil {
+		t.Fatalf("Failed to fetch a valid request: %v", err)
+	}
+	reqBody := string(getRequestPayload(t, reqData))
+	req := httptest.NewRequest("POST", "/openrtb2/video?output=vmap", strings.NewReader(reqBody))
+	recorder := httptest.NewRecorder()
+
+	deps := mockDeps(t, ex)
+	deps.cfg.CacheURL = config.Cache{Scheme: "https", Host: "prebid-cache.net", Query: "uuid=%PBS_CACHE_UUID%"}
+	deps.VideoAuctionEndpoint(recorder, req, nil)
+
+	vmap := recorder.Body.String()
+	assert.Equal(t, http.StatusOK, recorder.Code, "The VMAP should be returned: %s", vmap)
+	assert.Equal(t, "application/xml", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, 2, strings.Count(vmap, "<vmap:AdBreak "), "Each pod should have an ad break")
+	assert.Equal(t, 2, strings.Count(vmap, "<Ad "), "The bids of each pod share a category, so only one of them fits in the pod")
+	assert.Contains(t, vmap, "<VASTAdTagURI><![CDATA[https://prebid-cache.net/cache?uuid=837ea3b7-5598-4958-8c45-8e9ef2bf7cc1]]></VASTAdTagURI>")
+}
+
+func TestVideoEndpointInvalidOutput(t *testing.T) {
+	ex := &mockExchangeVideo{}
+	reqData, err := ioutil.ReadFile("sample-requests/video/video_valid_sample.json")
+	if err != nil {
+		t.Fatalf("Failed to fetch a valid request: %v", err)
+	}
+	reqBody := string(getRequestPayload(t, reqData))
+	req := httptest.NewRequest("POST", "/openrtb2/video?output=vast", strings.NewReader(reqBody))
+	recorder := httptest.NewRecorder()
+
+	deps := mockDeps(t, ex)
+	deps.VideoAuctionEndpoint(recorder, req, nil)
+
+	assert.Equal(t, 500, recorder.Code, "Should catch error in request")
+	assert.Contains(t, recorder.Body.String(), `request.output must be "json" or "vmap". Got "vast"`)
+	assert.Nil(t, ex.lastRequest, "The auction shouldn't run")
+}
+
 func TestVideoEndpointValidationsPositive(t *testing.T) {
 	ex := &mockExchangeVideo{}
 	deps := mockDeps(t, ex)
@@ -481,6 +559,34 @@ func TestVideoEndpointValidationsPodErrors(t *testing.T) {
 	assert.Equal(t, "request missing or incorrect required field: PodConfig.Pods.ConfigId, Pod index: 3", podErrors[1].ErrMsgs[2], "Pod error ind 1 should have missing config id")
 }
 
+func TestVideoEndpointValidationsOutputAndTimeOffset(t *testing.T) {
+	ex := &mockExchangeVideo{}
+	deps := mockDeps(t, ex)
+
+	req := openrtb_ext.BidRequestVideo{
+		PodConfig: openrtb_ext.PodConfig{
+			DurationRangeSec: []int{15, 30},
+			Pods: []openrtb_ext.Pod{
+				{PodId: 1, AdPodDurationSec: 30, ConfigId: "qwerty", TimeOffset: "00:05:00"},
+				{PodId: 2, AdPodDurationSec: 30, ConfigId: "qwerty", TimeOffset: "middle"},
+			},
+		},
+		App:    &openrtb.App{Bundle: "pbs.com"},
+		Video:  &openrtb.Video{MIMEs: []string{"mp4"}, Protocols: []openrtb.Protocol{1}},
+		Output: "xml",
+	}
+
+	errors, podErrors := deps.validateVideoRequest(&req)
+
+	if assert.Len(t, errors, 1) {
+		assert.EqualError(t, errors[0], `request.output must be "json" or "vmap". Got "xml"`)
+	}
+	if assert.Len(t, podErrors, 1) {
+		assert.Equal(t, 2, podErrors[0].PodId)
+		assert.Equal(t, []string{`request incorrect field: PodConfig.Pods.TimeOffset must be "start", "end", "hh:mm:ss[.mmm]", "n%" or "#m", Pod index: 1`}, podErrors[0].ErrMsgs)
+	}
+}
+
 func TestVideoEndpointValidationsSiteAndApp(t *testing.T) {
 	ex := &mockExchangeVideo{}
 	deps := mockDeps(t, ex)
@@ -628,7 +734,38 @@ func TestVideoEndpointValidationsMissingVideo(t *testing.T) {
 	errors, podErrors := deps.validateVideoRequest(&req)
 	assert.Len(t, podErrors, 0, "Pod errors should be empty")
 	assert.Len(t, errors, 1, "Errors array should contain 1 error message")
-	assert.Equal(t, "request missing required field: Video", errors[0].Error(), "Errors array should contain message regarding missing Video field")
+	assert.Equal(t, "request missing required field: Video or Audio", errors[0].Error(), "Errors array should contain message regarding missing Video field")
+}
+
+func TestVideoEndpointValidationsAudio(t *testing.T) {
+	ex := &mockExchangeVideo{}
+	deps := mockDeps(t, ex)
+
+	req := openrtb_ext.BidRequestVideo{
+		PodConfig: openrtb_ext.PodConfig{
+			DurationRangeSec: []int{15, 30},
+			Pods:             []openrtb_ext.Pod{{PodId: 1, AdPodDurationSec: 30, ConfigId: "qwerty"}},
+		},
+		App:   &openrtb.App{Bundle: "pbs.com"},
+		Audio: &openrtb.Audio{MIMEs: []string{"audio/mp4", ""}, Feed: openrtb.FeedTypePodcast},
+	}
+	errors, _ := deps.validateVideoRequest(&req)
+	assert.Empty(t, errors, "Audio should replace video")
+	assert.Equal(t, []string{"audio/mp4"}, req.Audio.MIMEs, "Empty mime types should be removed")
+
+	req.Audio = &openrtb.Audio{MIMEs: []string{""}, Feed: 4}
+	errors, _ = deps.validateVideoRequest(&req)
+	if assert.Len(t, errors, 2) {
+		assert.EqualError(t, errors[0], "request missing required field: Audio.Mimes, mime types contains empty strings only")
+		assert.EqualError(t, errors[1], "request incorrect field: Audio.Feed must be 1, 2 or 3. Got 4")
+	}
+
+	req.Audio = &openrtb.Audio{MIMEs: []string{"audio/mp4"}}
+	req.Video = &openrtb.Video{MIMEs: []string{"video/mp4"}, Protocols: []openrtb.Protocol{1}}
+	errors, _ = deps.validateVideoRequest(&req)
+	if assert.Len(t, errors, 1) {
+		assert.EqualError(t, errors[0], "request.video or request.audio must be defined, but not both")
+	}
 }
 
 func TestVideoBuildVideoResponseMissedCacheForOneBid(t *testing.T) {
@@ -660,7 +797,7 @@ func TestVideoBuildVideoResponseMissedCacheForOneBid(t *testing.T) {
 	seatBids = append(seatBids, seatBid)
 	openRtbBidResp.SeatBid = seatBids
 
-	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors)
+	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors, &openrtb_ext.BidRequestVideo{})
 	assert.NoError(t, err, "Should be no error")
 	assert.Len(t, bidRespVideo.AdPods, 1, "AdPods length should be 1")
 	assert.Len(t, bidRespVideo.AdPods[0].Targeting, 2, "AdPod Targeting length should be 2")
@@ -668,6 +805,70 @@ func TestVideoBuildVideoResponseMissedCacheForOneBid(t *testing.T) {
 	assert.Equal(t, "17.00_456_30s", bidRespVideo.AdPods[0].Targeting[1].HbPbCatDur, "AdPod Targeting first element hb_pb_cat_dur should be 17.00_456_30s")
 }
 
+func TestWarningsExt(t *testing.T) {
+	assert.Nil(t, warningsExt(nil))
+	assert.Nil(t, warningsExt(json.RawMessage(`{"errors":{"appnexus":[{"code":1,"message":"timeout"}]}}`)), "An ext without warnings shouldn't be returned")
+	assert.JSONEq(t, `{"warnings":{"prebid":[{"code":10006,"message":"Error calling cache"}]}}`,
+		string(warningsExt(json.RawMessage(`{"errors":{"appnexus":[{"code":1,"message":"timeout"}]},"responsetimemillis":{"appnexus":5},"warnings":{"prebid":[{"code":10006,"message":"Error calling cache"}]}}`))),
+		"Only the warnings should be kept")
+}
+
+func TestVideoBuildVideoResponseTargetingPrefix(t *testing.T) {
+	openRtbBidResp := openrtb.BidResponse{
+		SeatBid: []openrtb.SeatBid{{
+			Bid: []openrtb.Bid{{
+				ImpID: "1_0",
+				Ext:   []byte(`{"prebid":{"targeting":{"pbs_bidder":"appnexus","pbs_pb":"17.00","pbs_pb_cat_dur":"17.00_123_30s","pbs_uuid":"837ea3b7-5598-4958-8c45-8e9ef2bf7cc1"}}}`),
+			}},
+		}},
+	}
+
+	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, nil, &openrtb_ext.BidRequestVideo{TargetingPrefix: "pbs"})
+	if !assert.NoError(t, err) || !assert.Len(t, bidRespVideo.AdPods, 1) {
+		return
+	}
+	resp, err := json.Marshal(bidRespVideo.AdPods[0].Targeting)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `[{"pbs_pb":"17.00","pbs_pb_cat_dur":"17.00_123_30s","pbs_cache_id":"837ea3b7-5598-4958-8c45-8e9ef2bf7cc1"}]`, string(resp))
+	}
+}
+
+func TestVideoBuildVideoResponseOptimizedPods(t *testing.T) {
+	openRtbBidResp := openrtb.BidResponse{
+		SeatBid: []openrtb.SeatBid{{
+			Bid: []openrtb.Bid{{
+				ImpID: "1_0",
+				Price: 10,
+				Ext:   []byte(`{"prebid":{"targeting":{"hb_pb":"10.00","hb_pb_cat_dur":"10.00_cars_30s","hb_uuid":"long"}}}`),
+			}, {
+				ImpID: "1_1",
+				Price: 6,
+				Ext:   []byte(`{"prebid":{"targeting":{"hb_pb":"6.00","hb_pb_cat_dur":"6.00_food_15s","hb_uuid":"short-1"}}}`),
+			}, {
+				ImpID: "1_2",
+				Price: 5,
+				Ext:   []byte(`{"prebid":{"targeting":{"hb_pb":"5.00","hb_pb_cat_dur":"5.00_sports_15s","hb_uuid":"short-2"}}}`),
+			}},
+		}},
+	}
+	videoReq := &openrtb_ext.BidRequestVideo{
+		PodConfig: openrtb_ext.PodConfig{
+			Optimize: true,
+			Pods:     []openrtb_ext.Pod{{PodId: 1, AdPodDurationSec: 30}},
+		},
+	}
+
+	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, nil, videoReq)
+
+	if !assert.NoError(t, err) || !assert.Len(t, bidRespVideo.AdPods, 1) {
+		return
+	}
+	assert.Equal(t, []openrtb_ext.VideoTargeting{
+		{HbPb: "6.00", HbPbCatDur: "6.00_food_15s", HbCacheID: "short-1", Position: 1},
+		{HbPb: "5.00", HbPbCatDur: "5.00_sports_15s", HbCacheID: "short-2", Position: 2},
+	}, bidRespVideo.AdPods[0].Targeting)
+}
+
 func TestVideoBuildVideoResponseMissedCacheForAllBids(t *testing.T) {
 	openRtbBidResp := openrtb.BidResponse{}
 	podErrors := make([]PodError, 0)
@@ -697,7 +898,7 @@ func TestVideoBuildVideoResponseMissedCacheForAllBids(t *testing.T) {
 	seatBids = append(seatBids, seatBid)
 	openRtbBidResp.SeatBid = seatBids
 
-	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors)
+	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors, &openrtb_ext.BidRequestVideo{})
 	assert.Nil(t, bidRespVideo, "bid response should be nil")
 	assert.Equal(t, "caching failed for all bids", err.Error(), "error should be caching failed for all bids")
 }
@@ -736,7 +937,7 @@ func TestVideoBuildVideoResponsePodErrors(t *testing.T) {
 	podErr2.PodIndex = 2
 	podErrors = append(podErrors, podErr2)
 
-	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors)
+	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors, &openrtb_ext.BidRequestVideo{})
 	assert.NoError(t, err, "Error should be nil")
 	assert.Len(t, bidRespVideo.AdPods, 3, "AdPods length should be 3")
 	assert.Len(t, bidRespVideo.AdPods[0].Targeting, 2, "First ad pod should be correct and contain 2 targeting elements")
@@ -748,7 +949,7 @@ func TestVideoBuildVideoResponseNoBids(t *testing.T) {
 	openRtbBidResp := openrtb.BidResponse{}
 	podErrors := make([]PodError, 0, 0)
 	openRtbBidResp.SeatBid = make([]openrtb.SeatBid, 0)
-	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors)
+	bidRespVideo, err := buildVideoResponse(&openRtbBidResp, podErrors, &openrtb_ext.BidRequestVideo{})
 	assert.NoError(t, err, "Error should be nil")
 	assert.Len(t, bidRespVideo.AdPods, 0, "AdPods length should be 0")
 }
@@ -1107,6 +1308,20 @@ func TestCCPA(t *testing.T) {
 	}
 }
 
+func TestVideoStoredVersions(t *testing.T) {
+	deps := mockDeps(t, &mockExchangeVideo{})
+	deps.storedReqFetcher = versionedStoredReqFetcher{}
+	deps.videoFetcher = versionedStoredReqFetcher{}
+
+	storedRequest, errs := deps.loadStoredVideoRequest(context.Background(), "req-1", "user-1")
+	assert.Empty(t, errs)
+	assert.JSONEq(t, `{"tmax":700}`, string(storedRequest), "The Stored Request's version should be picked")
+
+	storedImp, errs := deps.loadStoredImp("imp-1", "user-1")
+	assert.Empty(t, errs)
+	assert.Equal(t, "adUnit1", storedImp.ID, "The Stored Imp's version should be picked")
+}
+
 func mockDepsWithMetrics(t *testing.T, ex *mockExchangeVideo) (*endpointDeps, *pbsmetrics.Metrics, *mockAnalyticsModule) {
 	theMetrics := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
 	mockModule := &mockAnalyticsModule{}
@@ -1126,6 +1341,7 @@ func mockDepsWithMetrics(t *testing.T, ex *mockExchangeVideo) (*endpointDeps, *p
 		nil,
 		nil,
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	return deps, theMetrics, mockModule
@@ -1168,6 +1384,7 @@ func mockDeps(t *testing.T, ex *mockExchangeVideo) *endpointDeps {
 		ex.cache,
 		regexp.MustCompile(`[<>]`),
 		hardcodedResponseIPValidator{response: true},
+		devicedetection.NilDetector{},
 	}
 
 	return deps
@@ -1209,22 +1426,22 @@ func (m *mockExchangeVideo) HoldAuction(ctx context.Context, bidRequest *openrtb
 	return &openrtb.BidResponse{
 		SeatBid: []openrtb.SeatBid{{
 			Bid: []openrtb.Bid{
-				{ID: "01", ImpID: "1_0", Ext: ext},
-				{ID: "02", ImpID: "1_1", Ext: ext},
-				{ID: "03", ImpID: "1_2", Ext: ext},
-				{ID: "04", ImpID: "1_3", Ext: ext},
-				{ID: "05", ImpID: "2_0", Ext: ext},
-				{ID: "06", ImpID: "2_1", Ext: ext},
-				{ID: "07", ImpID: "2_2", Ext: ext},
-				{ID: "08", ImpID: "3_0", Ext: ext},
-				{ID: "09", ImpID: "3_1", Ext: ext},
-				{ID: "10", ImpID: "3_2", Ext: ext},
-				{ID: "11", ImpID: "3_3", Ext: ext},
-				{ID: "12", ImpID: "3_5", Ext: ext},
-				{ID: "13", ImpID: "4_0", Ext: ext},
-				{ID: "14", ImpID: "5_0", Ext: ext},
-				{ID: "15", ImpID: "5_1", Ext: ext},
-				{ID: "16", ImpID: "5_2", Ext: ext},
+				{ID: "01", ImpID: "1_0", Price: 20, Ext: ext},
+				{ID: "02", ImpID: "1_1", Price: 20, Ext: ext},
+				{ID: "03", ImpID: "1_2", Price: 20, Ext: ext},
+				{ID: "04", ImpID: "1_3", Price: 20, Ext: ext},
+				{ID: "05", ImpID: "2_0", Price: 20, Ext: ext},
+				{ID: "06", ImpID: "2_1", Price: 20, Ext: ext},
+				{ID: "07", ImpID: "2_2", Price: 20, Ext: ext},
+				{ID: "08", ImpID: "3_0", Price: 20, Ext: ext},
+				{ID: "09", ImpID: "3_1", Price: 20, Ext: ext},
+				{ID: "10", ImpID: "3_2", Price: 20, Ext: ext},
+				{ID: "11", ImpID: "3_3", Price: 20, Ext: ext},
+				{ID: "12", ImpID: "3_5", Price: 20, Ext: ext},
+				{ID: "13", ImpID: "4_0", Price: 20, Ext: ext},
+				{ID: "14", ImpID: "5_0", Price: 20, Ext: ext},
+				{ID: "15", ImpID: "5_1", Price: 20, Ext: ext},
+				{ID: "16", ImpID: "5_2", Price: 20, Ext: ext},
 			},
 		}},
 	}, nil
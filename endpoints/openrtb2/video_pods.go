@@ -0,0 +1,155 @@
+package openrtb2
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// maxPodSearchSteps bounds the search for the best bids of a pod, since it grows exponentially with the number of bids.
+// Once it's reached, the best selection found so far is used.
+const maxPodSearchSteps = 100000
+
+// podCandidate is a cached bid which may fill a slot of an ad pod.
+type podCandidate struct {
+	targeting openrtb_ext.VideoTargeting
+	price     float64
+	duration  int
+	category  string
+	domains   []string
+}
+
+// newPodCandidate reads the duration and the category of the bid from ext.prebid.video, or from its hb_pb_cat_dur key.
+// If the durations differ, the longest one is used, since the ad server's slot may be as long as the rounded duration.
+func newPodCandidate(bid *openrtb.Bid, bidExt *openrtb_ext.ExtBid, targeting openrtb_ext.VideoTargeting) podCandidate {
+	candidate := podCandidate{
+		targeting: targeting,
+		price:     bid.Price,
+		domains:   bid.ADomain,
+	}
+	if bidExt.Prebid != nil && bidExt.Prebid.Video != nil {
+		candidate.duration = bidExt.Prebid.Video.Duration
+		candidate.category = bidExt.Prebid.Video.PrimaryCategory
+	}
+
+	// hb_pb_cat_dur is either "{price}_{category}_{duration}s" or "{price}_{duration}s".
+	parts := strings.Split(targeting.HbPbCatDur, "_")
+	if duration, err := strconv.Atoi(strings.TrimSuffix(parts[len(parts)-1], "s")); err == nil && duration > candidate.duration {
+		candidate.duration = duration
+	}
+	if candidate.category == "" && len(parts) == 3 {
+		candidate.category = parts[1]
+	}
+	if candidate.category == "" && len(bid.Cat) > 0 {
+		candidate.category = bid.Cat[0]
+	}
+	return candidate
+}
+
+// optimizePod picks the bids which earn the most while fitting in the pod. The picked bids must fit in the pod's duration
+// and its maxads, and no two of them can share a category or an advertiser domain. Bids without a known duration are left out,
+// since they might not fit.
+//
+// The picked bids are ordered by decreasing price, and their Position is their slot in the pod.
+func optimizePod(candidates []podCandidate, pod openrtb_ext.Pod) []openrtb_ext.VideoTargeting {
+	search := &podSearch{
+		maxDuration: pod.AdPodDurationSec,
+		maxAds:      pod.MaxAds,
+		categories:  make(map[string]bool),
+		domains:     make(map[string]bool),
+	}
+	for _, candidate := range candidates {
+		if candidate.duration > 0 && candidate.duration <= pod.AdPodDurationSec {
+			search.candidates = append(search.candidates, candidate)
+		}
+	}
+	if search.maxAds <= 0 || search.maxAds > len(search.candidates) {
+		search.maxAds = len(search.candidates)
+	}
+	sort.SliceStable(search.candidates, func(i, j int) bool {
+		return search.candidates[i].price > search.candidates[j].price
+	})
+
+	search.run(0, 0, 0)
+
+	picked := make([]openrtb_ext.VideoTargeting, 0, len(search.best))
+	for i, index := range search.best {
+		targeting := search.candidates[index].targeting
+		targeting.Position = i + 1
+		picked = append(picked, targeting)
+	}
+	return picked
+}
+
+// podSearch is a branch and bound search over the candidates, which are sorted by decreasing price.
+// Taking the candidates in that order makes the first selection a greedy one, which the rest of the search can only improve.
+type podSearch struct {
+	candidates  []podCandidate
+	maxDuration int
+	maxAds      int
+	steps       int
+
+	chosen     []int
+	best       []int
+	bestPrice  float64
+	categories map[string]bool
+	domains    map[string]bool
+}
+
+func (s *podSearch) run(next int, duration int, price float64) {
+	if price > s.bestPrice {
+		s.bestPrice = price
+		s.best = append(s.best[:0], s.chosen...)
+	}
+	if next == len(s.candidates) || len(s.chosen) == s.maxAds || s.steps >= maxPodSearchSteps {
+		return
+	}
+	if price+s.bound(next) <= s.bestPrice {
+		return
+	}
+	s.steps++
+
+	candidate := &s.candidates[next]
+	if duration+candidate.duration <= s.maxDuration && s.compatible(candidate) {
+		s.take(candidate, true)
+		s.chosen = append(s.chosen, next)
+		s.run(next+1, duration+candidate.duration, price+candidate.price)
+		s.chosen = s.chosen[:len(s.chosen)-1]
+		s.take(candidate, false)
+	}
+	s.run(next+1, duration, price)
+}
+
+// bound is the most which the free slots can still earn: the sum of the highest remaining prices.
+func (s *podSearch) bound(next int) float64 {
+	var bound float64
+	for i := next; i < len(s.candidates) && i < next+s.maxAds-len(s.chosen); i++ {
+		bound += s.candidates[i].price
+	}
+	return bound
+}
+
+// compatible returns false if the candidate shares a category or an advertiser domain with a chosen bid.
+func (s *podSearch) compatible(candidate *podCandidate) bool {
+	if candidate.category != "" && s.categories[candidate.category] {
+		return false
+	}
+	for _, domain := range candidate.domains {
+		if s.domains[strings.ToLower(domain)] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *podSearch) take(candidate *podCandidate, taken bool) {
+	if candidate.category != "" {
+		s.categories[candidate.category] = taken
+	}
+	for _, domain := range candidate.domains {
+		s.domains[strings.ToLower(domain)] = taken
+	}
+}
@@ -0,0 +1,121 @@
+package openrtb2
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimizePod(t *testing.T) {
+	testCases := []struct {
+		description string
+		pod         openrtb_ext.Pod
+		candidates  []podCandidate
+		expected    []string
+	}{
+		{
+			description: "The bids should be ordered by decreasing price",
+			pod:         openrtb_ext.Pod{AdPodDurationSec: 60},
+			candidates: []podCandidate{
+				testCandidate("a", 1, 15, "", ""),
+				testCandidate("b", 3, 15, "", ""),
+				testCandidate("c", 2, 15, "", ""),
+			},
+			expected: []string{"b", "c", "a"},
+		},
+		{
+			description: "Two shorter bids should beat a longer bid which pays more on its own",
+			pod:         openrtb_ext.Pod{AdPodDurationSec: 30},
+			candidates: []podCandidate{
+				testCandidate("long", 5, 30, "", ""),
+				testCandidate("short-1", 3, 15, "", ""),
+				testCandidate("short-2", 3, 15, "", ""),
+			},
+			expected: []string{"short-1", "short-2"},
+		},
+		{
+			description: "The pod shouldn't have more than maxads bids",
+			pod:         openrtb_ext.Pod{AdPodDurationSec: 60, MaxAds: 2},
+			candidates: []podCandidate{
+				testCandidate("a", 1, 15, "", ""),
+				testCandidate("b", 3, 15, "", ""),
+				testCandidate("c", 2, 15, "", ""),
+			},
+			expected: []string{"b", "c"},
+		},
+		{
+			description: "The pod shouldn't have two bids from the same category",
+			pod:         openrtb_ext.Pod{AdPodDurationSec: 60},
+			candidates: []podCandidate{
+				testCandidate("car-1", 3, 15, "cars", ""),
+				testCandidate("car-2", 2, 15, "cars", ""),
+				testCandidate("food", 1, 15, "food", ""),
+			},
+			expected: []string{"car-1", "food"},
+		},
+		{
+			description: "The pod shouldn't have two bids from the same advertiser",
+			pod:         openrtb_ext.Pod{AdPodDurationSec: 60},
+			candidates: []podCandidate{
+				testCandidate("ford-1", 3, 15, "", "ford.com"),
+				testCandidate("ford-2", 2, 15, "", "FORD.com"),
+				testCandidate("kia", 1, 15, "", "kia.com"),
+			},
+			expected: []string{"ford-1", "kia"},
+		},
+		{
+			description: "Bids without a known duration, or which are longer than the pod, should be left out",
+			pod:         openrtb_ext.Pod{AdPodDurationSec: 30},
+			candidates: []podCandidate{
+				testCandidate("unknown", 3, 0, "", ""),
+				testCandidate("too-long", 3, 45, "", ""),
+				testCandidate("fits", 1, 30, "", ""),
+			},
+			expected: []string{"fits"},
+		},
+	}
+
+	for _, test := range testCases {
+		picked := optimizePod(test.candidates, test.pod)
+		cacheIDs := make([]string, 0, len(picked))
+		for i, targeting := range picked {
+			cacheIDs = append(cacheIDs, targeting.HbCacheID)
+			assert.Equal(t, i+1, targeting.Position, "%s: the positions should start at 1", test.description)
+		}
+		assert.Equal(t, test.expected, cacheIDs, test.description)
+	}
+}
+
+func TestNewPodCandidate(t *testing.T) {
+	bid := &openrtb.Bid{Price: 2, Cat: []string{"IAB1"}, ADomain: []string{"ford.com"}}
+
+	candidate := newPodCandidate(bid, &openrtb_ext.ExtBid{Prebid: &openrtb_ext.ExtBidPrebid{}}, openrtb_ext.VideoTargeting{HbPbCatDur: "2.00_cars_30s"})
+	assert.Equal(t, 30, candidate.duration)
+	assert.Equal(t, "cars", candidate.category)
+	assert.Equal(t, []string{"ford.com"}, candidate.domains)
+
+	candidate = newPodCandidate(bid, &openrtb_ext.ExtBid{Prebid: &openrtb_ext.ExtBidPrebid{
+		Video: &openrtb_ext.ExtBidPrebidVideo{Duration: 12, PrimaryCategory: "autos"},
+	}}, openrtb_ext.VideoTargeting{HbPbCatDur: "2.00_15s"})
+	assert.Equal(t, 15, candidate.duration, "The rounded duration should be used if it's longer")
+	assert.Equal(t, "autos", candidate.category)
+
+	candidate = newPodCandidate(bid, &openrtb_ext.ExtBid{Prebid: &openrtb_ext.ExtBidPrebid{}}, openrtb_ext.VideoTargeting{})
+	assert.Equal(t, 0, candidate.duration)
+	assert.Equal(t, "IAB1", candidate.category, "The bid's category should be the last resort")
+}
+
+func testCandidate(cacheID string, price float64, duration int, category string, domain string) podCandidate {
+	candidate := podCandidate{
+		targeting: openrtb_ext.VideoTargeting{HbCacheID: cacheID},
+		price:     price,
+		duration:  duration,
+		category:  category,
+	}
+	if domain != "" {
+		candidate.domains = []string{domain}
+	}
+	return candidate
+}
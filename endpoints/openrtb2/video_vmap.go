@@ -0,0 +1,111 @@
+package openrtb2
+
+import (
+	"encoding/xml"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// vmapTimeOffset matches the timeOffset values of VMAP 1.0: "start", "end", "hh:mm:ss[.mmm]", "n%" and "#m".
+var vmapTimeOffset = regexp.MustCompile(`^(start|end|\d{2}:[0-5]\d:[0-5]\d(\.\d{3})?|(100|\d{1,2})(\.\d+)?%|#[1-9]\d*)$`)
+
+type vmapDocument struct {
+	XMLName   xml.Name      `xml:"vmap:VMAP"`
+	Namespace string        `xml:"xmlns:vmap,attr"`
+	Version   string        `xml:"version,attr"`
+	AdBreaks  []vmapAdBreak `xml:"vmap:AdBreak"`
+}
+
+type vmapAdBreak struct {
+	TimeOffset string       `xml:"timeOffset,attr"`
+	BreakType  string       `xml:"breakType,attr"`
+	BreakID    string       `xml:"breakId,attr"`
+	AdSource   vmapAdSource `xml:"vmap:AdSource"`
+}
+
+type vmapAdSource struct {
+	ID               string  `xml:"id,attr"`
+	AllowMultipleAds bool    `xml:"allowMultipleAds,attr"`
+	FollowRedirects  bool    `xml:"followRedirects,attr"`
+	VAST             vastPod `xml:"vmap:VASTAdData>VAST"`
+}
+
+type vastPod struct {
+	Version string      `xml:"version,attr"`
+	Ads     []vastPodAd `xml:"Ad"`
+}
+
+type vastPodAd struct {
+	ID       string         `xml:"id,attr"`
+	Sequence int            `xml:"sequence,attr"`
+	Wrapper  vastPodWrapper `xml:"Wrapper"`
+}
+
+type vastPodWrapper struct {
+	AdSystem     string    `xml:"AdSystem"`
+	VASTAdTagURI cdataText `xml:"VASTAdTagURI"`
+	Impression   string    `xml:"Impression"`
+	Creatives    string    `xml:"Creatives"`
+}
+
+type cdataText struct {
+	Text string `xml:",cdata"`
+}
+
+// buildVMAP returns a VMAP document with an ad break for each of the pods, in the order of the request.
+// Each break has a VAST ad pod which wraps the pod's cached bids, in the order of their Position.
+//
+// Pods without bids get a break with an empty VAST, so that the player knows they weren't filled.
+func buildVMAP(videoResp *openrtb_ext.BidResponseVideo, pods []openrtb_ext.Pod, cachedAssetURL func(uuid string) string) ([]byte, error) {
+	vmap := vmapDocument{
+		Namespace: "http://www.iab.net/videosuite/vmap",
+		Version:   "1.0",
+		AdBreaks:  make([]vmapAdBreak, 0, len(pods)),
+	}
+	for i, pod := range pods {
+		podID := strconv.Itoa(pod.PodId)
+		timeOffset := pod.TimeOffset
+		if timeOffset == "" {
+			timeOffset = "#" + strconv.Itoa(i+1)
+		}
+		adBreak := vmapAdBreak{
+			TimeOffset: timeOffset,
+			BreakType:  "linear",
+			BreakID:    podID,
+			AdSource: vmapAdSource{
+				ID:               podID,
+				AllowMultipleAds: true,
+				FollowRedirects:  true,
+				VAST:             vastPod{Version: "3.0"},
+			},
+		}
+
+		if adPod := findAdPod(int64(pod.PodId), videoResp.AdPods); adPod != nil {
+			targeting := make([]openrtb_ext.VideoTargeting, len(adPod.Targeting))
+			copy(targeting, adPod.Targeting)
+			sort.SliceStable(targeting, func(i, j int) bool {
+				return targeting[i].Position < targeting[j].Position
+			})
+			for sequence, bid := range targeting {
+				adBreak.AdSource.VAST.Ads = append(adBreak.AdSource.VAST.Ads, vastPodAd{
+					ID:       bid.HbCacheID,
+					Sequence: sequence + 1,
+					Wrapper: vastPodWrapper{
+						AdSystem:     "prebid.org wrapper",
+						VASTAdTagURI: cdataText{cachedAssetURL(bid.HbCacheID)},
+					},
+				})
+			}
+		}
+		vmap.AdBreaks = append(vmap.AdBreaks, adBreak)
+	}
+
+	output, err := xml.Marshal(vmap)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), output...), nil
+}
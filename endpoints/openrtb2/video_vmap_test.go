@@ -0,0 +1,58 @@
+package openrtb2
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildVMAP(t *testing.T) {
+	videoResp := &openrtb_ext.BidResponseVideo{
+		AdPods: []*openrtb_ext.AdPod{{
+			PodId: 2,
+			Targeting: []openrtb_ext.VideoTargeting{
+				{HbCacheID: "second", Position: 2},
+				{HbCacheID: "first", Position: 1},
+			},
+		}},
+	}
+	pods := []openrtb_ext.Pod{
+		{PodId: 1, TimeOffset: "start"},
+		{PodId: 2},
+	}
+	cachedAssetURL := func(uuid string) string {
+		return "https://prebid-cache.net/cache?uuid=" + uuid + "&ttl=60"
+	}
+
+	vmap, err := buildVMAP(videoResp, pods, cachedAssetURL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<vmap:VMAP xmlns:vmap="http://www.iab.net/videosuite/vmap" version="1.0">`+
+		`<vmap:AdBreak timeOffset="start" breakType="linear" breakId="1">`+
+		`<vmap:AdSource id="1" allowMultipleAds="true" followRedirects="true">`+
+		`<vmap:VASTAdData><VAST version="3.0"></VAST></vmap:VASTAdData>`+
+		`</vmap:AdSource></vmap:AdBreak>`+
+		`<vmap:AdBreak timeOffset="#2" breakType="linear" breakId="2">`+
+		`<vmap:AdSource id="2" allowMultipleAds="true" followRedirects="true">`+
+		`<vmap:VASTAdData><VAST version="3.0">`+
+		`<Ad id="first" sequence="1"><Wrapper><AdSystem>prebid.org wrapper</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[https://prebid-cache.net/cache?uuid=first&ttl=60]]></VASTAdTagURI>`+
+		`<Impression></Impression><Creatives></Creatives></Wrapper></Ad>`+
+		`<Ad id="second" sequence="2"><Wrapper><AdSystem>prebid.org wrapper</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[https://prebid-cache.net/cache?uuid=second&ttl=60]]></VASTAdTagURI>`+
+		`<Impression></Impression><Creatives></Creatives></Wrapper></Ad>`+
+		`</VAST></vmap:VASTAdData>`+
+		`</vmap:AdSource></vmap:AdBreak>`+
+		`</vmap:VMAP>`, string(vmap))
+}
+
+func TestVMAPTimeOffset(t *testing.T) {
+	for _, valid := range []string{"start", "end", "00:10:30", "01:00:00.500", "50%", "12.5%", "100%", "#3"} {
+		assert.True(t, vmapTimeOffset.MatchString(valid), "%s should be a valid timeOffset", valid)
+	}
+	for _, invalid := range []string{"middle", "10:30", "00:61:00", "101%", "#0", "#", "5"} {
+		assert.False(t, vmapTimeOffset.MatchString(invalid), "%s shouldn't be a valid timeOffset", invalid)
+	}
+}
@@ -11,12 +11,23 @@ const (
 	BidderTemporarilyDisabledErrorCode
 	BlacklistedAcctErrorCode
 	AcctRequiredErrorCode
+	BlockedBidErrorCode
+	InvalidMarkupErrorCode
+	InvalidVASTErrorCode
 )
 
 // Defines numeric codes for well-known warnings.
 const (
 	UnknownWarningCode               = 10999
 	InvalidPrivacyConsentWarningCode = iota + 10000
+	BlockedBidWarningCode
+	InvalidMarkupWarningCode
+	InvalidNativeWarningCode
+	UnsupportedMediaTypeWarningCode
+	CacheWarningCode
+	CategoryRejectionWarningCode
+	InvalidDealTierWarningCode
+	BidAdjustmentWarningCode
 )
 
 // Coder provides an error or warning code with severity.
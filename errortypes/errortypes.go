@@ -165,6 +165,77 @@ func (err *BidderTemporarilyDisabled) Severity() Severity {
 	return SeverityWarning
 }
 
+// BlockedBid should be used when a bid is removed from the response because it breaks one of the request's
+// blocking rules, like badv or bcat.
+type BlockedBid struct {
+	Message string
+}
+
+func (err *BlockedBid) Error() string {
+	return err.Message
+}
+
+func (err *BlockedBid) Code() int {
+	return BlockedBidErrorCode
+}
+
+func (err *BlockedBid) Severity() Severity {
+	return SeverityFatal
+}
+
+// InvalidMarkup should be used when a bid is removed from the response because its adm or nurl
+// is too big, insecure, or runs a disallowed script.
+type InvalidMarkup struct {
+	Message string
+}
+
+func (err *InvalidMarkup) Error() string {
+	return err.Message
+}
+
+func (err *InvalidMarkup) Code() int {
+	return InvalidMarkupErrorCode
+}
+
+func (err *InvalidMarkup) Severity() Severity {
+	return SeverityFatal
+}
+
+// InvalidVAST should be used when a video bid is removed from the response because its adm isn't well-formed VAST XML.
+type InvalidVAST struct {
+	Message string
+}
+
+func (err *InvalidVAST) Error() string {
+	return err.Message
+}
+
+func (err *InvalidVAST) Code() int {
+	return InvalidVASTErrorCode
+}
+
+func (err *InvalidVAST) Severity() Severity {
+	return SeverityFatal
+}
+
+// InvalidNative should be used when a native bid's adm doesn't match the native request of its imp.
+// The message says whether the bid was repaired or removed from the response.
+type InvalidNative struct {
+	Message string
+}
+
+func (err *InvalidNative) Error() string {
+	return err.Message
+}
+
+func (err *InvalidNative) Code() int {
+	return InvalidNativeWarningCode
+}
+
+func (err *InvalidNative) Severity() Severity {
+	return SeverityWarning
+}
+
 // Warning is a generic non-fatal error.
 type Warning struct {
 	Message string
@@ -198,3 +269,127 @@ func (err *InvalidPrivacyConsent) Code() int {
 func (err *InvalidPrivacyConsent) Severity() Severity {
 	return SeverityWarning
 }
+
+// BlockedBidWarning is a warning for when a bid breaks one of the request's blocking rules,
+// but the host has configured Prebid Server to let it through anyway.
+type BlockedBidWarning struct {
+	Message string
+}
+
+func (err *BlockedBidWarning) Error() string {
+	return err.Message
+}
+
+func (err *BlockedBidWarning) Code() int {
+	return BlockedBidWarningCode
+}
+
+func (err *BlockedBidWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+// InvalidMarkupWarning is a warning for when a bid's adm or nurl is too big, insecure, or runs a disallowed script,
+// but Prebid Server is configured to let the bid through anyway.
+type InvalidMarkupWarning struct {
+	Message string
+}
+
+func (err *InvalidMarkupWarning) Error() string {
+	return err.Message
+}
+
+func (err *InvalidMarkupWarning) Code() int {
+	return InvalidMarkupWarningCode
+}
+
+func (err *InvalidMarkupWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+// UnsupportedMediaType should be used when an imp, or one of its media types, isn't sent to a bidder
+// because the bidder doesn't support it.
+type UnsupportedMediaType struct {
+	Message string
+}
+
+func (err *UnsupportedMediaType) Error() string {
+	return err.Message
+}
+
+func (err *UnsupportedMediaType) Code() int {
+	return UnsupportedMediaTypeWarningCode
+}
+
+func (err *UnsupportedMediaType) Severity() Severity {
+	return SeverityWarning
+}
+
+// CacheWarning should be used when the bids couldn't be cached. They're still returned, but without cache IDs.
+type CacheWarning struct {
+	Message string
+}
+
+func (err *CacheWarning) Error() string {
+	return err.Message
+}
+
+func (err *CacheWarning) Code() int {
+	return CacheWarningCode
+}
+
+func (err *CacheWarning) Severity() Severity {
+	return SeverityWarning
+}
+
+// CategoryRejection should be used when a bid is removed from the auction by the category mapping,
+// e.g. because it has no category, or because it was deduplicated.
+type CategoryRejection struct {
+	Message string
+}
+
+func (err *CategoryRejection) Error() string {
+	return err.Message
+}
+
+func (err *CategoryRejection) Code() int {
+	return CategoryRejectionWarningCode
+}
+
+func (err *CategoryRejection) Severity() Severity {
+	return SeverityWarning
+}
+
+// InvalidDealTier should be used when the dealTier config of a bidder is invalid, so its deals don't get a tier.
+type InvalidDealTier struct {
+	Message string
+}
+
+func (err *InvalidDealTier) Error() string {
+	return err.Message
+}
+
+func (err *InvalidDealTier) Code() int {
+	return InvalidDealTierWarningCode
+}
+
+func (err *InvalidDealTier) Severity() Severity {
+	return SeverityWarning
+}
+
+// BidAdjustmentWarning should be used when a bid adjustment can't be applied, or when it removes a bid
+// by lowering its price to zero or less.
+type BidAdjustmentWarning struct {
+	Message string
+}
+
+func (err *BidAdjustmentWarning) Error() string {
+	return err.Message
+}
+
+func (err *BidAdjustmentWarning) Code() int {
+	return BidAdjustmentWarningCode
+}
+
+func (err *BidAdjustmentWarning) Severity() Severity {
+	return SeverityWarning
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/prebid/prebid-server/adapters/eplanning"
 	"github.com/prebid/prebid-server/adapters/gamma"
 	"github.com/prebid/prebid-server/adapters/gamoshi"
+	"github.com/prebid/prebid-server/adapters/generic"
 	"github.com/prebid/prebid-server/adapters/grid"
 	"github.com/prebid/prebid-server/adapters/gumgum"
 	"github.com/prebid/prebid-server/adapters/improvedigital"
@@ -92,99 +93,9 @@ import (
 // to register itself. No wading through Exchange code to find it.
 
 func newAdapterMap(client *http.Client, cfg *config.Configuration, infos adapters.BidderInfos, me pbsmetrics.MetricsEngine) map[openrtb_ext.BidderName]adaptedBidder {
-	ortbBidders := map[openrtb_ext.BidderName]adapters.Bidder{
-		openrtb_ext.Bidder33Across:     ttx.New33AcrossBidder(cfg.Adapters[string(openrtb_ext.Bidder33Across)].Endpoint),
-		openrtb_ext.BidderAdform:       adform.NewAdformBidder(client, cfg.Adapters[string(openrtb_ext.BidderAdform)].Endpoint),
-		openrtb_ext.BidderAdgeneration: adgeneration.NewAdgenerationAdapter(cfg.Adapters[string(openrtb_ext.BidderAdgeneration)].Endpoint),
-		openrtb_ext.BidderAdhese:       adhese.NewAdheseBidder(cfg.Adapters[string(openrtb_ext.BidderAdhese)].Endpoint),
-		openrtb_ext.BidderAdkernel:     adkernel.NewAdkernelAdapter(cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderAdkernel))].Endpoint),
-		openrtb_ext.BidderAdkernelAdn:  adkernelAdn.NewAdkernelAdnAdapter(cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderAdkernelAdn))].Endpoint),
-		openrtb_ext.BidderAdman:        adman.NewAdmanBidder(cfg.Adapters[string(openrtb_ext.BidderAdman)].Endpoint),
-		openrtb_ext.BidderAdmixer:      admixer.NewAdmixerBidder(cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderAdmixer))].Endpoint),
-		openrtb_ext.BidderAdOcean:      adocean.NewAdOceanBidder(client, cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderAdOcean))].Endpoint),
-		openrtb_ext.BidderAdoppler:     adoppler.NewAdopplerBidder(cfg.Adapters[string(openrtb_ext.BidderAdoppler)].Endpoint),
-		openrtb_ext.BidderAdpone:       adpone.NewAdponeBidder(cfg.Adapters[string(openrtb_ext.BidderAdpone)].Endpoint),
-		openrtb_ext.BidderAdtarget:     adtarget.NewAdtargetBidder(cfg.Adapters[string(openrtb_ext.BidderAdtarget)].Endpoint),
-		openrtb_ext.BidderAdtelligent:  adtelligent.NewAdtelligentBidder(cfg.Adapters[string(openrtb_ext.BidderAdtelligent)].Endpoint),
-		openrtb_ext.BidderAdvangelists: advangelists.NewAdvangelistsBidder(cfg.Adapters[string(openrtb_ext.BidderAdvangelists)].Endpoint),
-		openrtb_ext.BidderAJA:          aja.NewAJABidder(cfg.Adapters[string(openrtb_ext.BidderAJA)].Endpoint),
-		openrtb_ext.BidderApplogy:      applogy.NewApplogyBidder(cfg.Adapters[string(openrtb_ext.BidderApplogy)].Endpoint),
-		openrtb_ext.BidderAppnexus:     appnexus.NewAppNexusBidder(client, cfg.Adapters[string(openrtb_ext.BidderAppnexus)].Endpoint, cfg.Adapters[string(openrtb_ext.BidderAppnexus)].PlatformID),
-		openrtb_ext.BidderAvocet:       avocet.NewAvocetAdapter(cfg.Adapters[string(openrtb_ext.BidderAvocet)].Endpoint),
-		openrtb_ext.BidderBeachfront:   beachfront.NewBeachfrontBidder(cfg.Adapters[string(openrtb_ext.BidderBeachfront)].Endpoint, cfg.Adapters[string(openrtb_ext.BidderBeachfront)].ExtraAdapterInfo),
-		openrtb_ext.BidderBeintoo:      beintoo.NewBeintooBidder(cfg.Adapters[string(openrtb_ext.BidderBeintoo)].Endpoint),
-		openrtb_ext.BidderBrightroll:   brightroll.NewBrightrollBidder(cfg.Adapters[string(openrtb_ext.BidderBrightroll)].Endpoint),
-		openrtb_ext.BidderConsumable:   consumable.NewConsumableBidder(cfg.Adapters[string(openrtb_ext.BidderConsumable)].Endpoint),
-		openrtb_ext.BidderCpmstar:      cpmstar.NewCpmstarBidder(cfg.Adapters[string(openrtb_ext.BidderCpmstar)].Endpoint),
-		openrtb_ext.BidderDatablocks:   datablocks.NewDatablocksBidder(cfg.Adapters[string(openrtb_ext.BidderDatablocks)].Endpoint),
-		openrtb_ext.BidderDmx:          dmx.NewDmxBidder(cfg.Adapters[string(openrtb_ext.BidderDmx)].Endpoint),
-		openrtb_ext.BidderEmxDigital:   emx_digital.NewEmxDigitalBidder(cfg.Adapters[string(openrtb_ext.BidderEmxDigital)].Endpoint),
-		openrtb_ext.BidderEngageBDR:    engagebdr.NewEngageBDRBidder(client, cfg.Adapters[string(openrtb_ext.BidderEngageBDR)].Endpoint),
-		openrtb_ext.BidderEPlanning:    eplanning.NewEPlanningBidder(client, cfg.Adapters[string(openrtb_ext.BidderEPlanning)].Endpoint),
-		openrtb_ext.BidderFacebook: audienceNetwork.NewFacebookBidder(
-			cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderFacebook))].PlatformID,
-			cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderFacebook))].AppSecret),
-		openrtb_ext.BidderGamma:           gamma.NewGammaBidder(cfg.Adapters[string(openrtb_ext.BidderGamma)].Endpoint),
-		openrtb_ext.BidderGamoshi:         gamoshi.NewGamoshiBidder(cfg.Adapters[string(openrtb_ext.BidderGamoshi)].Endpoint),
-		openrtb_ext.BidderGrid:            grid.NewGridBidder(cfg.Adapters[string(openrtb_ext.BidderGrid)].Endpoint),
-		openrtb_ext.BidderGumGum:          gumgum.NewGumGumBidder(cfg.Adapters[string(openrtb_ext.BidderGumGum)].Endpoint),
-		openrtb_ext.BidderImprovedigital:  improvedigital.NewImprovedigitalBidder(cfg.Adapters[string(openrtb_ext.BidderImprovedigital)].Endpoint),
-		openrtb_ext.BidderKidoz:           kidoz.NewKidozBidder(cfg.Adapters[string(openrtb_ext.BidderKidoz)].Endpoint),
-		openrtb_ext.BidderKubient:         kubient.NewKubientBidder(cfg.Adapters[string(openrtb_ext.BidderKubient)].Endpoint),
-		openrtb_ext.BidderLockerDome:      lockerdome.NewLockerDomeBidder(cfg.Adapters[string(openrtb_ext.BidderLockerDome)].Endpoint),
-		openrtb_ext.BidderLunaMedia:       lunamedia.NewLunaMediaBidder(cfg.Adapters[string(openrtb_ext.BidderLunaMedia)].Endpoint),
-		openrtb_ext.BidderMarsmedia:       marsmedia.NewMarsmediaBidder(cfg.Adapters[string(openrtb_ext.BidderMarsmedia)].Endpoint),
-		openrtb_ext.BidderMgid:            mgid.NewMgidBidder(cfg.Adapters[string(openrtb_ext.BidderMgid)].Endpoint),
-		openrtb_ext.BidderMobileFuse:      mobilefuse.NewMobileFuseBidder(cfg.Adapters[string(openrtb_ext.BidderMobileFuse)].Endpoint),
-		openrtb_ext.BidderNanoInteractive: nanointeractive.NewNanoIneractiveBidder(cfg.Adapters[string(openrtb_ext.BidderNanoInteractive)].Endpoint),
-		openrtb_ext.BidderNinthDecimal:    ninthdecimal.NewNinthDecimalBidder(cfg.Adapters[string(openrtb_ext.BidderNinthDecimal)].Endpoint),
-		openrtb_ext.BidderOrbidder:        orbidder.NewOrbidderBidder(cfg.Adapters[string(openrtb_ext.BidderOrbidder)].Endpoint),
-		openrtb_ext.BidderOpenx:           openx.NewOpenxBidder(cfg.Adapters[string(openrtb_ext.BidderOpenx)].Endpoint),
-		openrtb_ext.BidderPubmatic:        pubmatic.NewPubmaticBidder(client, cfg.Adapters[string(openrtb_ext.BidderPubmatic)].Endpoint),
-		openrtb_ext.BidderPubnative:       pubnative.NewPubnativeBidder(cfg.Adapters[string(openrtb_ext.BidderPubnative)].Endpoint),
-		openrtb_ext.BidderRhythmone:       rhythmone.NewRhythmoneBidder(cfg.Adapters[string(openrtb_ext.BidderRhythmone)].Endpoint),
-		openrtb_ext.BidderRTBHouse:        rtbhouse.NewRTBHouseBidder(cfg.Adapters[string(openrtb_ext.BidderRTBHouse)].Endpoint),
-		openrtb_ext.BidderRubicon: rubicon.NewRubiconBidder(
-			client,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].Endpoint,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].XAPI.Username,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].XAPI.Password,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].XAPI.Tracker),
-
-		openrtb_ext.BidderSharethrough:     sharethrough.NewSharethroughBidder(cfg.Adapters[string(openrtb_ext.BidderSharethrough)].Endpoint),
-		openrtb_ext.BidderSmartadserver:    smartadserver.NewSmartadserverBidder(cfg.Adapters[string(openrtb_ext.BidderSmartadserver)].Endpoint),
-		openrtb_ext.BidderSmartRTB:         smartrtb.NewSmartRTBBidder(cfg.Adapters[string(openrtb_ext.BidderSmartRTB)].Endpoint),
-		openrtb_ext.BidderSomoaudience:     somoaudience.NewSomoaudienceBidder(cfg.Adapters[string(openrtb_ext.BidderSomoaudience)].Endpoint),
-		openrtb_ext.BidderSonobi:           sonobi.NewSonobiBidder(client, cfg.Adapters[string(openrtb_ext.BidderSonobi)].Endpoint),
-		openrtb_ext.BidderSovrn:            sovrn.NewSovrnBidder(client, cfg.Adapters[string(openrtb_ext.BidderSovrn)].Endpoint),
-		openrtb_ext.BidderSynacormedia:     synacormedia.NewSynacorMediaBidder(cfg.Adapters[string(openrtb_ext.BidderSynacormedia)].Endpoint),
-		openrtb_ext.BidderTappx:            tappx.NewTappxBidder(client, cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderTappx))].Endpoint),
-		openrtb_ext.BidderTelaria:          telaria.NewTelariaBidder(cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderTelaria))].Endpoint),
-		openrtb_ext.BidderTriplelift:       triplelift.NewTripleliftBidder(client, cfg.Adapters[string(openrtb_ext.BidderTriplelift)].Endpoint),
-		openrtb_ext.BidderTripleliftNative: triplelift_native.NewTripleliftNativeBidder(client, cfg.Adapters[string(openrtb_ext.BidderTripleliftNative)].Endpoint, cfg.Adapters[string(openrtb_ext.BidderTripleliftNative)].ExtraAdapterInfo),
-		openrtb_ext.BidderUcfunnel:         ucfunnel.NewUcfunnelBidder(cfg.Adapters[string(openrtb_ext.BidderUcfunnel)].Endpoint),
-		openrtb_ext.BidderUnruly:           unruly.NewUnrulyBidder(client, cfg.Adapters[string(openrtb_ext.BidderUnruly)].Endpoint),
-		openrtb_ext.BidderValueImpression:  valueimpression.NewValueImpressionBidder(cfg.Adapters[string(openrtb_ext.BidderValueImpression)].Endpoint),
-		openrtb_ext.BidderYieldlab:         yieldlab.NewYieldlabBidder(cfg.Adapters[string(openrtb_ext.BidderYieldlab)].Endpoint),
-		openrtb_ext.BidderVerizonMedia:     verizonmedia.NewVerizonMediaBidder(client, cfg.Adapters[string(openrtb_ext.BidderVerizonMedia)].Endpoint),
-		openrtb_ext.BidderVisx:             visx.NewVisxBidder(cfg.Adapters[string(openrtb_ext.BidderVisx)].Endpoint),
-		openrtb_ext.BidderVrtcal:           vrtcal.NewVrtcalBidder(cfg.Adapters[string(openrtb_ext.BidderVrtcal)].Endpoint),
-		openrtb_ext.BidderYeahmobi:         yeahmobi.NewYeahmobiBidder(cfg.Adapters[string(openrtb_ext.BidderYeahmobi)].Endpoint),
-		openrtb_ext.BidderYieldmo:          yieldmo.NewYieldmoBidder(cfg.Adapters[string(openrtb_ext.BidderYieldmo)].Endpoint),
-		openrtb_ext.BidderYieldone:         yieldone.NewYieldoneBidder(cfg.Adapters[string(openrtb_ext.BidderYieldone)].Endpoint),
-		openrtb_ext.BidderZeroClickFraud:   zeroclickfraud.NewZeroClickFraudBidder(cfg.Adapters[string(openrtb_ext.BidderZeroClickFraud)].Endpoint),
-	}
-
-	legacyBidders := map[openrtb_ext.BidderName]adapters.Adapter{
-		// TODO #267: Upgrade the Conversant adapter
-		openrtb_ext.BidderConversant: conversant.NewConversantAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[string(openrtb_ext.BidderConversant)].Endpoint),
-		// TODO #212: Upgrade the Index adapter
-		openrtb_ext.BidderIx: ix.NewIxAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderIx))].Endpoint),
-		// TODO #213: Upgrade the Lifestreet adapter
-		openrtb_ext.BidderLifestreet: lifestreet.NewLifestreetAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[string(openrtb_ext.BidderLifestreet)].Endpoint),
-		// TODO #215: Upgrade the Pulsepoint adapter
-		openrtb_ext.BidderPulsepoint: pulsepoint.NewPulsePointAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[string(openrtb_ext.BidderPulsepoint)].Endpoint),
-	}
+	bidderClients := newBidderHTTPClients(client, cfg.Adapters)
+	ortbBidders, legacyBidders := newBidders(bidderClients.forBidder, cfg.Adapters)
+	addHostAliases(bidderClients.forBidder, cfg.Adapters, ortbBidders, legacyBidders)
 
 	allBidders := make(map[openrtb_ext.BidderName]adaptedBidder, len(ortbBidders)+len(legacyBidders))
 
@@ -199,18 +110,178 @@ func newAdapterMap(client *http.Client, cfg *config.Configuration, infos adapter
 	for name, bidder := range ortbBidders {
 		// Clean out any disabled bidders
 		if infos[string(name)].Status == adapters.StatusActive {
-			allBidders[name] = adaptBidder(adapters.EnforceBidderInfo(bidder, infos[string(name)]), client, cfg, me)
+			bidder = adapters.EnforceBidderInfo(bidder, infos[string(name)])
+			if infos.OpenRTBVersion(name) == openrtb_ext.OpenRTBVersion26 {
+				bidder = adapters.UpconvertOpenRTB26(bidder)
+			}
+			adapted := adaptBidder(bidder, bidderClients.forBidder(name), cfg, me)
+			allBidders[name] = captureFixtures(adapted, name, cfg.Debug.FixtureCapture)
 		}
 	}
 
 	// Apply any middleware used for global Bidder logic.
 	for name, bidder := range allBidders {
-		allBidders[name] = ensureValidBids(bidder)
+		bidder = ensureValidBids(bidder)
+		bidder = ensureValidVAST(bidder, cfg.VAST)
+		bidder = ensureValidNative(bidder, cfg.Native)
+		bidder = enforceAdQuality(bidder, cfg.AdQuality, me)
+		bidder = ensureSecureMarkup(bidder, name, cfg.SecureMarkup, me)
+		allBidders[name] = bidder
 	}
 
 	return allBidders
 }
 
+// newBidders builds every bidder with its config from adapterCfgs. The adapters which make requests of their own
+// get the HTTP client of their bidder from bidderClient.
+func newBidders(bidderClient func(openrtb_ext.BidderName) *http.Client, adapterCfgs map[string]config.Adapter) (map[openrtb_ext.BidderName]adapters.Bidder, map[openrtb_ext.BidderName]adapters.Adapter) {
+	ortbBidders := map[openrtb_ext.BidderName]adapters.Bidder{
+		openrtb_ext.Bidder33Across:     ttx.New33AcrossBidder(adapterCfgs[string(openrtb_ext.Bidder33Across)].Endpoint),
+		openrtb_ext.BidderAdform:       adform.NewAdformBidder(bidderClient(openrtb_ext.BidderAdform), adapterCfgs[string(openrtb_ext.BidderAdform)].Endpoint),
+		openrtb_ext.BidderAdgeneration: adgeneration.NewAdgenerationAdapter(adapterCfgs[string(openrtb_ext.BidderAdgeneration)].Endpoint),
+		openrtb_ext.BidderAdhese:       adhese.NewAdheseBidder(adapterCfgs[string(openrtb_ext.BidderAdhese)].Endpoint),
+		openrtb_ext.BidderAdkernel:     adkernel.NewAdkernelAdapter(adapterCfgs[strings.ToLower(string(openrtb_ext.BidderAdkernel))].Endpoint),
+		openrtb_ext.BidderAdkernelAdn:  adkernelAdn.NewAdkernelAdnAdapter(adapterCfgs[strings.ToLower(string(openrtb_ext.BidderAdkernelAdn))].Endpoint),
+		openrtb_ext.BidderAdman:        adman.NewAdmanBidder(adapterCfgs[string(openrtb_ext.BidderAdman)].Endpoint),
+		openrtb_ext.BidderAdmixer:      admixer.NewAdmixerBidder(adapterCfgs[strings.ToLower(string(openrtb_ext.BidderAdmixer))].Endpoint),
+		openrtb_ext.BidderAdOcean:      adocean.NewAdOceanBidder(bidderClient(openrtb_ext.BidderAdOcean), adapterCfgs[strings.ToLower(string(openrtb_ext.BidderAdOcean))].Endpoint),
+		openrtb_ext.BidderAdoppler:     adoppler.NewAdopplerBidder(adapterCfgs[string(openrtb_ext.BidderAdoppler)].Endpoint),
+		openrtb_ext.BidderAdpone:       adpone.NewAdponeBidder(adapterCfgs[string(openrtb_ext.BidderAdpone)].Endpoint),
+		openrtb_ext.BidderAdtarget:     adtarget.NewAdtargetBidder(adapterCfgs[string(openrtb_ext.BidderAdtarget)].Endpoint),
+		openrtb_ext.BidderAdtelligent:  adtelligent.NewAdtelligentBidder(adapterCfgs[string(openrtb_ext.BidderAdtelligent)].Endpoint),
+		openrtb_ext.BidderAdvangelists: advangelists.NewAdvangelistsBidder(adapterCfgs[string(openrtb_ext.BidderAdvangelists)].Endpoint),
+		openrtb_ext.BidderAJA:          aja.NewAJABidder(adapterCfgs[string(openrtb_ext.BidderAJA)].Endpoint),
+		openrtb_ext.BidderApplogy:      applogy.NewApplogyBidder(adapterCfgs[string(openrtb_ext.BidderApplogy)].Endpoint),
+		openrtb_ext.BidderAppnexus:     appnexus.NewAppNexusBidder(bidderClient(openrtb_ext.BidderAppnexus), adapterCfgs[string(openrtb_ext.BidderAppnexus)].Endpoint, adapterCfgs[string(openrtb_ext.BidderAppnexus)].PlatformID),
+		openrtb_ext.BidderAvocet:       avocet.NewAvocetAdapter(adapterCfgs[string(openrtb_ext.BidderAvocet)].Endpoint),
+		openrtb_ext.BidderBeachfront:   beachfront.NewBeachfrontBidder(adapterCfgs[string(openrtb_ext.BidderBeachfront)].Endpoint, adapterCfgs[string(openrtb_ext.BidderBeachfront)].ExtraAdapterInfo),
+		openrtb_ext.BidderBeintoo:      beintoo.NewBeintooBidder(adapterCfgs[string(openrtb_ext.BidderBeintoo)].Endpoint),
+		openrtb_ext.BidderBrightroll:   brightroll.NewBrightrollBidder(adapterCfgs[string(openrtb_ext.BidderBrightroll)].Endpoint),
+		openrtb_ext.BidderConsumable:   consumable.NewConsumableBidder(adapterCfgs[string(openrtb_ext.BidderConsumable)].Endpoint),
+		openrtb_ext.BidderCpmstar:      cpmstar.NewCpmstarBidder(adapterCfgs[string(openrtb_ext.BidderCpmstar)].Endpoint),
+		openrtb_ext.BidderDatablocks:   datablocks.NewDatablocksBidder(adapterCfgs[string(openrtb_ext.BidderDatablocks)].Endpoint),
+		openrtb_ext.BidderDmx:          dmx.NewDmxBidder(adapterCfgs[string(openrtb_ext.BidderDmx)].Endpoint),
+		openrtb_ext.BidderEmxDigital:   emx_digital.NewEmxDigitalBidder(adapterCfgs[string(openrtb_ext.BidderEmxDigital)].Endpoint),
+		openrtb_ext.BidderEngageBDR:    engagebdr.NewEngageBDRBidder(bidderClient(openrtb_ext.BidderEngageBDR), adapterCfgs[string(openrtb_ext.BidderEngageBDR)].Endpoint),
+		openrtb_ext.BidderEPlanning:    eplanning.NewEPlanningBidder(bidderClient(openrtb_ext.BidderEPlanning), adapterCfgs[string(openrtb_ext.BidderEPlanning)].Endpoint),
+		openrtb_ext.BidderFacebook: audienceNetwork.NewFacebookBidder(
+			adapterCfgs[strings.ToLower(string(openrtb_ext.BidderFacebook))].PlatformID,
+			adapterCfgs[strings.ToLower(string(openrtb_ext.BidderFacebook))].AppSecret),
+		openrtb_ext.BidderGamma:           gamma.NewGammaBidder(adapterCfgs[string(openrtb_ext.BidderGamma)].Endpoint),
+		openrtb_ext.BidderGamoshi:         gamoshi.NewGamoshiBidder(adapterCfgs[string(openrtb_ext.BidderGamoshi)].Endpoint),
+		openrtb_ext.BidderGrid:            grid.NewGridBidder(adapterCfgs[string(openrtb_ext.BidderGrid)].Endpoint),
+		openrtb_ext.BidderGumGum:          gumgum.NewGumGumBidder(adapterCfgs[string(openrtb_ext.BidderGumGum)].Endpoint),
+		openrtb_ext.BidderImprovedigital:  improvedigital.NewImprovedigitalBidder(adapterCfgs[string(openrtb_ext.BidderImprovedigital)].Endpoint),
+		openrtb_ext.BidderKidoz:           kidoz.NewKidozBidder(adapterCfgs[string(openrtb_ext.BidderKidoz)].Endpoint),
+		openrtb_ext.BidderKubient:         kubient.NewKubientBidder(adapterCfgs[string(openrtb_ext.BidderKubient)].Endpoint),
+		openrtb_ext.BidderLockerDome:      lockerdome.NewLockerDomeBidder(adapterCfgs[string(openrtb_ext.BidderLockerDome)].Endpoint),
+		openrtb_ext.BidderLunaMedia:       lunamedia.NewLunaMediaBidder(adapterCfgs[string(openrtb_ext.BidderLunaMedia)].Endpoint),
+		openrtb_ext.BidderMarsmedia:       marsmedia.NewMarsmediaBidder(adapterCfgs[string(openrtb_ext.BidderMarsmedia)].Endpoint),
+		openrtb_ext.BidderMgid:            mgid.NewMgidBidder(adapterCfgs[string(openrtb_ext.BidderMgid)].Endpoint),
+		openrtb_ext.BidderMobileFuse:      mobilefuse.NewMobileFuseBidder(adapterCfgs[string(openrtb_ext.BidderMobileFuse)].Endpoint),
+		openrtb_ext.BidderNanoInteractive: nanointeractive.NewNanoIneractiveBidder(adapterCfgs[string(openrtb_ext.BidderNanoInteractive)].Endpoint),
+		openrtb_ext.BidderNinthDecimal:    ninthdecimal.NewNinthDecimalBidder(adapterCfgs[string(openrtb_ext.BidderNinthDecimal)].Endpoint),
+		openrtb_ext.BidderOrbidder:        orbidder.NewOrbidderBidder(adapterCfgs[string(openrtb_ext.BidderOrbidder)].Endpoint),
+		openrtb_ext.BidderOpenx:           openx.NewOpenxBidder(adapterCfgs[string(openrtb_ext.BidderOpenx)].Endpoint),
+		openrtb_ext.BidderPubmatic:        pubmatic.NewPubmaticBidder(bidderClient(openrtb_ext.BidderPubmatic), adapterCfgs[string(openrtb_ext.BidderPubmatic)].Endpoint),
+		openrtb_ext.BidderPubnative:       pubnative.NewPubnativeBidder(adapterCfgs[string(openrtb_ext.BidderPubnative)].Endpoint),
+		openrtb_ext.BidderRhythmone:       rhythmone.NewRhythmoneBidder(adapterCfgs[string(openrtb_ext.BidderRhythmone)].Endpoint),
+		openrtb_ext.BidderRTBHouse:        rtbhouse.NewRTBHouseBidder(adapterCfgs[string(openrtb_ext.BidderRTBHouse)].Endpoint),
+		openrtb_ext.BidderRubicon: rubicon.NewRubiconBidder(
+			bidderClient(openrtb_ext.BidderRubicon),
+			adapterCfgs[string(openrtb_ext.BidderRubicon)].Endpoint,
+			adapterCfgs[string(openrtb_ext.BidderRubicon)].XAPI.Username,
+			adapterCfgs[string(openrtb_ext.BidderRubicon)].XAPI.Password,
+			adapterCfgs[string(openrtb_ext.BidderRubicon)].XAPI.Tracker),
+
+		openrtb_ext.BidderSharethrough:     sharethrough.NewSharethroughBidder(adapterCfgs[string(openrtb_ext.BidderSharethrough)].Endpoint),
+		openrtb_ext.BidderSmartadserver:    smartadserver.NewSmartadserverBidder(adapterCfgs[string(openrtb_ext.BidderSmartadserver)].Endpoint),
+		openrtb_ext.BidderSmartRTB:         smartrtb.NewSmartRTBBidder(adapterCfgs[string(openrtb_ext.BidderSmartRTB)].Endpoint),
+		openrtb_ext.BidderSomoaudience:     somoaudience.NewSomoaudienceBidder(adapterCfgs[string(openrtb_ext.BidderSomoaudience)].Endpoint),
+		openrtb_ext.BidderSonobi:           sonobi.NewSonobiBidder(bidderClient(openrtb_ext.BidderSonobi), adapterCfgs[string(openrtb_ext.BidderSonobi)].Endpoint),
+		openrtb_ext.BidderSovrn:            sovrn.NewSovrnBidder(bidderClient(openrtb_ext.BidderSovrn), adapterCfgs[string(openrtb_ext.BidderSovrn)].Endpoint),
+		openrtb_ext.BidderSynacormedia:     synacormedia.NewSynacorMediaBidder(adapterCfgs[string(openrtb_ext.BidderSynacormedia)].Endpoint),
+		openrtb_ext.BidderTappx:            tappx.NewTappxBidder(bidderClient(openrtb_ext.BidderTappx), adapterCfgs[strings.ToLower(string(openrtb_ext.BidderTappx))].Endpoint),
+		openrtb_ext.BidderTelaria:          telaria.NewTelariaBidder(adapterCfgs[strings.ToLower(string(openrtb_ext.BidderTelaria))].Endpoint),
+		openrtb_ext.BidderTriplelift:       triplelift.NewTripleliftBidder(bidderClient(openrtb_ext.BidderTriplelift), adapterCfgs[string(openrtb_ext.BidderTriplelift)].Endpoint),
+		openrtb_ext.BidderTripleliftNative: triplelift_native.NewTripleliftNativeBidder(bidderClient(openrtb_ext.BidderTripleliftNative), adapterCfgs[string(openrtb_ext.BidderTripleliftNative)].Endpoint, adapterCfgs[string(openrtb_ext.BidderTripleliftNative)].ExtraAdapterInfo),
+		openrtb_ext.BidderUcfunnel:         ucfunnel.NewUcfunnelBidder(adapterCfgs[string(openrtb_ext.BidderUcfunnel)].Endpoint),
+		openrtb_ext.BidderUnruly:           unruly.NewUnrulyBidder(bidderClient(openrtb_ext.BidderUnruly), adapterCfgs[string(openrtb_ext.BidderUnruly)].Endpoint),
+		openrtb_ext.BidderValueImpression:  valueimpression.NewValueImpressionBidder(adapterCfgs[string(openrtb_ext.BidderValueImpression)].Endpoint),
+		openrtb_ext.BidderYieldlab:         yieldlab.NewYieldlabBidder(adapterCfgs[string(openrtb_ext.BidderYieldlab)].Endpoint),
+		openrtb_ext.BidderVerizonMedia:     verizonmedia.NewVerizonMediaBidder(bidderClient(openrtb_ext.BidderVerizonMedia), adapterCfgs[string(openrtb_ext.BidderVerizonMedia)].Endpoint),
+		openrtb_ext.BidderVisx:             visx.NewVisxBidder(adapterCfgs[string(openrtb_ext.BidderVisx)].Endpoint),
+		openrtb_ext.BidderVrtcal:           vrtcal.NewVrtcalBidder(adapterCfgs[string(openrtb_ext.BidderVrtcal)].Endpoint),
+		openrtb_ext.BidderYeahmobi:         yeahmobi.NewYeahmobiBidder(adapterCfgs[string(openrtb_ext.BidderYeahmobi)].Endpoint),
+		openrtb_ext.BidderYieldmo:          yieldmo.NewYieldmoBidder(adapterCfgs[string(openrtb_ext.BidderYieldmo)].Endpoint),
+		openrtb_ext.BidderYieldone:         yieldone.NewYieldoneBidder(adapterCfgs[string(openrtb_ext.BidderYieldone)].Endpoint),
+		openrtb_ext.BidderZeroClickFraud:   zeroclickfraud.NewZeroClickFraudBidder(adapterCfgs[string(openrtb_ext.BidderZeroClickFraud)].Endpoint),
+	}
+
+	for name, declaration := range generic.Declarations() {
+		ortbBidders[name] = generic.NewGenericBidder(name, declaration, adapterCfgs[string(name)])
+	}
+
+	legacyBidders := map[openrtb_ext.BidderName]adapters.Adapter{
+		// TODO #267: Upgrade the Conversant adapter
+		openrtb_ext.BidderConversant: conversant.NewConversantAdapter(adapters.DefaultHTTPAdapterConfig, adapterCfgs[string(openrtb_ext.BidderConversant)].Endpoint),
+		// TODO #212: Upgrade the Index adapter
+		openrtb_ext.BidderIx: ix.NewIxAdapter(adapters.DefaultHTTPAdapterConfig, adapterCfgs[strings.ToLower(string(openrtb_ext.BidderIx))].Endpoint),
+		// TODO #213: Upgrade the Lifestreet adapter
+		openrtb_ext.BidderLifestreet: lifestreet.NewLifestreetAdapter(adapters.DefaultHTTPAdapterConfig, adapterCfgs[string(openrtb_ext.BidderLifestreet)].Endpoint),
+		// TODO #215: Upgrade the Pulsepoint adapter
+		openrtb_ext.BidderPulsepoint: pulsepoint.NewPulsePointAdapter(adapters.DefaultHTTPAdapterConfig, adapterCfgs[string(openrtb_ext.BidderPulsepoint)].Endpoint),
+	}
+
+	return ortbBidders, legacyBidders
+}
+
+// addHostAliases adds the aliases from the host config. Each one is built like its core bidder, but with its own config.
+//
+// newBidders builds every bidder at once, so each round of it can only build one alias of a core bidder.
+// Hosts rarely have more than one alias of the same bidder, so this usually takes a single round.
+func addHostAliases(bidderClient func(openrtb_ext.BidderName) *http.Client, adapterCfgs map[string]config.Adapter, ortbBidders map[openrtb_ext.BidderName]adapters.Bidder, legacyBidders map[openrtb_ext.BidderName]adapters.Adapter) {
+	pending := make(map[string]config.Adapter)
+	for name, aliasCfg := range adapterCfgs {
+		if aliasCfg.AliasOf != "" {
+			pending[name] = aliasCfg
+		}
+	}
+
+	for len(pending) > 0 {
+		aliasCfgs := make(map[string]config.Adapter, len(adapterCfgs))
+		for adapterName, adapterCfg := range adapterCfgs {
+			aliasCfgs[adapterName] = adapterCfg
+		}
+		aliasNames := make(map[openrtb_ext.BidderName]string, len(pending))
+		for name, aliasCfg := range pending {
+			core := openrtb_ext.BidderName(aliasCfg.AliasOf)
+			if _, ok := aliasNames[core]; ok {
+				continue
+			}
+			aliasNames[core] = name
+			aliasCfgs[string(core)] = aliasCfg
+			aliasCfgs[strings.ToLower(string(core))] = aliasCfg
+			delete(pending, name)
+		}
+
+		aliasClient := func(core openrtb_ext.BidderName) *http.Client {
+			if name, ok := aliasNames[core]; ok {
+				return bidderClient(openrtb_ext.BidderName(name))
+			}
+			return bidderClient(core)
+		}
+		aliasOrtbBidders, aliasLegacyBidders := newBidders(aliasClient, aliasCfgs)
+		for core, name := range aliasNames {
+			if bidder, ok := aliasOrtbBidders[core]; ok {
+				ortbBidders[openrtb_ext.BidderName(name)] = bidder
+			} else if adapter, ok := aliasLegacyBidders[core]; ok {
+				legacyBidders[openrtb_ext.BidderName(name)] = adapter
+			}
+		}
+	}
+}
+
 // DisableBidders get all bidders but disabled ones
 func DisableBidders(biddersInfo adapters.BidderInfos, disabledBidders map[string]string) (bidderMap map[string]openrtb_ext.BidderName) {
 	bidderMap = make(map[string]openrtb_ext.BidderName)
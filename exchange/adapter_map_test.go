@@ -1,13 +1,20 @@
 package exchange
 
 import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	metricsConfig "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewAdapterMap(t *testing.T) {
@@ -53,6 +60,99 @@ func TestNewAdapterMapDisabledAdapters(t *testing.T) {
 	}
 }
 
+func TestNewAdapterMapHostAliases(t *testing.T) {
+	cfgAdapters := blankAdapterConfig(openrtb_ext.BidderList())
+	cfgAdapters["appnexus_eu"] = config.Adapter{AliasOf: string(openrtb_ext.BidderAppnexus), Endpoint: "https://eu.adnxs.com/openrtb2"}
+	cfgAdapters["conversant_eu"] = config.Adapter{AliasOf: string(openrtb_ext.BidderConversant), Endpoint: "https://eu.conversant.com"}
+	bidderList := append(openrtb_ext.BidderList(), "appnexus_eu", "conversant_eu")
+
+	adapterMap := newAdapterMap(nil, &config.Configuration{Adapters: cfgAdapters}, adapters.ParseBidderInfos(cfgAdapters, "../static/bidder-info", bidderList), &metricsConfig.DummyMetricsEngine{})
+
+	assert.NotNil(t, adapterMap["appnexus_eu"], "An alias of an OpenRTB bidder should be in the adapterMap")
+	assert.NotNil(t, adapterMap["conversant_eu"], "An alias of a legacy adapter should be in the adapterMap")
+	assert.NotNil(t, adapterMap[openrtb_ext.BidderAppnexus], "The core bidder should still be in the adapterMap")
+}
+
+func TestNewAdapterMapOpenRTB26(t *testing.T) {
+	bodies := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodies[r.URL.Path], _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cfgAdapters := blankAdapterConfig(openrtb_ext.BidderList())
+	cfgAdapters["appnexus"] = config.Adapter{Endpoint: server.URL + "/appnexus"}
+	cfgAdapters["appnexus_eu"] = config.Adapter{AliasOf: string(openrtb_ext.BidderAppnexus), Endpoint: server.URL + "/appnexus_eu"}
+	infos := adapters.ParseBidderInfos(cfgAdapters, "../static/bidder-info", append(openrtb_ext.BidderList(), "appnexus_eu"))
+	appnexusInfo := infos["appnexus"]
+	appnexusInfo.OpenRTB = &adapters.OpenRTBInfo{Version: openrtb_ext.OpenRTBVersion26}
+	infos["appnexus"] = appnexusInfo
+
+	adapterMap := newAdapterMap(server.Client(), &config.Configuration{Adapters: cfgAdapters}, infos, &metricsConfig.DummyMetricsEngine{})
+
+	for _, name := range []openrtb_ext.BidderName{"appnexus", "appnexus_eu"} {
+		request := &openrtb.BidRequest{
+			ID: "req",
+			Imp: []openrtb.Imp{{
+				ID:     "imp",
+				Banner: &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}}},
+				Ext:    json.RawMessage(`{"bidder":{"placementId":1}}`),
+			}},
+			Site:   &openrtb.Site{Page: "https://publisher.com"},
+			Regs:   &openrtb.Regs{Ext: json.RawMessage(`{"gdpr":1}`)},
+			User:   &openrtb.User{Ext: json.RawMessage(`{"consent":"BOEF"}`)},
+			Source: &openrtb.Source{Ext: json.RawMessage(`{"schain":{"complete":1,"nodes":[],"ver":"1.0"}}`)},
+		}
+		_, errs := adapterMap[name].requestBid(context.Background(), request, name, 1.0, nil, &adapters.ExtraRequestInfo{})
+		assert.Empty(t, errs)
+
+		var sent map[string]json.RawMessage
+		if assert.NoError(t, json.Unmarshal(bodies["/"+string(name)], &sent), "%s should get a request", name) {
+			assert.JSONEq(t, `{"gdpr":1}`, string(sent["regs"]), "%s should get regs.gdpr in its OpenRTB 2.6 location", name)
+			assert.JSONEq(t, `{"consent":"BOEF"}`, string(sent["user"]), "%s should get user.consent in its OpenRTB 2.6 location", name)
+			assert.JSONEq(t, `{"schain":{"complete":1,"nodes":[],"ver":"1.0"}}`, string(sent["source"]), "%s should get source.schain in its OpenRTB 2.6 location", name)
+		}
+	}
+}
+
+func TestAddHostAliases(t *testing.T) {
+	cfgAdapters := map[string]config.Adapter{
+		"appnexus_eu":   {AliasOf: string(openrtb_ext.BidderAppnexus), Endpoint: "https://eu.adnxs.com/openrtb2"},
+		"appnexus_us":   {AliasOf: string(openrtb_ext.BidderAppnexus), Endpoint: "https://us.adnxs.com/openrtb2"},
+		"conversant_eu": {AliasOf: string(openrtb_ext.BidderConversant), Endpoint: "https://eu.conversant.com"},
+		"unknown_eu":    {AliasOf: "unknown", Endpoint: "https://eu.unknown.com"},
+	}
+	ortbBidders := make(map[openrtb_ext.BidderName]adapters.Bidder)
+	legacyBidders := make(map[openrtb_ext.BidderName]adapters.Adapter)
+
+	addHostAliases(newBidderHTTPClients(nil, cfgAdapters).forBidder, cfgAdapters, ortbBidders, legacyBidders)
+
+	assert.Len(t, ortbBidders, 2, "Only the aliases should be added, and not every other bidder")
+	if assert.NotNil(t, ortbBidders["appnexus_eu"], "An alias of an OpenRTB bidder should be built") {
+		assert.NotSame(t, ortbBidders["appnexus_eu"], ortbBidders["appnexus_us"], "Each alias of a bidder should be built with its own config")
+	}
+	assert.NotNil(t, ortbBidders["appnexus_us"], "Every alias of an OpenRTB bidder should be built")
+	assert.Len(t, legacyBidders, 1, "Only the alias should be added, and not every other adapter")
+	assert.NotNil(t, legacyBidders["conversant_eu"], "An alias of a legacy adapter should be built")
+}
+
+func TestNewBiddersClients(t *testing.T) {
+	requested := make(map[openrtb_ext.BidderName]bool)
+	bidderClient := func(name openrtb_ext.BidderName) *http.Client {
+		requested[name] = true
+		return nil
+	}
+	newBidders(bidderClient, nil)
+	assert.True(t, requested[openrtb_ext.BidderAppnexus], "Adapters which take a client should get their bidder's client")
+	assert.True(t, requested[openrtb_ext.BidderRubicon], "Adapters which take a client should get their bidder's client")
+
+	requested = make(map[openrtb_ext.BidderName]bool)
+	addHostAliases(bidderClient, map[string]config.Adapter{"appnexus_eu": {AliasOf: string(openrtb_ext.BidderAppnexus)}}, make(map[openrtb_ext.BidderName]adapters.Bidder), make(map[openrtb_ext.BidderName]adapters.Adapter))
+	assert.True(t, requested["appnexus_eu"], "An alias should get its own client rather than its core bidder's")
+	assert.False(t, requested[openrtb_ext.BidderAppnexus], "An alias should get its own client rather than its core bidder's")
+}
+
 func inList(list []openrtb_ext.BidderName, name openrtb_ext.BidderName) bool {
 	for _, v := range list {
 		if v == name {
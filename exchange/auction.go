@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	uuid "github.com/gofrs/uuid"
@@ -15,6 +16,7 @@ import (
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/prebid_cache_client"
+	"github.com/prebid/prebid-server/tracing"
 )
 
 type DebugLog struct {
@@ -121,7 +123,7 @@ func (a *auction) doCache(ctx context.Context, cache prebid_cache_client.Client,
 		expByImp[imp.ID] = imp.Exp
 	}
 	for _, topBidsPerImp := range a.winningBidsByBidder {
-		for _, topBidPerBidder := range topBidsPerImp {
+		for bidderName, topBidPerBidder := range topBidsPerImp {
 			impID := topBidPerBidder.bid.ImpID
 			isOverallWinner := a.winningBids[impID] == topBidPerBidder
 			if !includeBidderKeys && !isOverallWinner {
@@ -154,8 +156,8 @@ func (a *auction) doCache(ctx context.Context, cache prebid_cache_client.Client,
 					errs = append(errs, err)
 				}
 			}
-			if vast && topBidPerBidder.bidType == openrtb_ext.BidTypeVideo {
-				vast := makeVAST(topBidPerBidder.bid)
+			if vast && hasVASTMarkup(topBidPerBidder.bidType) {
+				vast := targData.vastWrapper.vast(topBidPerBidder.bid, bidderName, targData.account, bidRequest.ID)
 				if jsonBytes, err := json.Marshal(vast); err == nil {
 					if useCustomCacheKey {
 						toCache = append(toCache, prebid_cache_client.Cacheable{
@@ -192,10 +194,14 @@ func (a *auction) doCache(ctx context.Context, cache prebid_cache_client.Client,
 		}
 	}
 
-	ids, err := cache.PutJson(ctx, toCache)
+	cacheCtx, span := tracing.StartSpan(ctx, "prebid_cache")
+	span.SetAttribute("cacheables", strconv.Itoa(len(toCache)))
+	ids, err := cache.PutJson(cacheCtx, toCache)
 	if err != nil {
 		errs = append(errs, err...)
+		span.SetError(err[0])
 	}
+	span.End()
 
 	if bids {
 		a.cacheIds = make(map[*openrtb.Bid]string, len(bidIndices))
@@ -234,6 +240,11 @@ func makeVAST(bid *openrtb.Bid) string {
 	return bid.AdM
 }
 
+// hasVASTMarkup returns true for the bid types whose markup is VAST. Audio bids may use DAAST too.
+func hasVASTMarkup(bidType openrtb_ext.BidType) bool {
+	return bidType == openrtb_ext.BidTypeVideo || bidType == openrtb_ext.BidTypeAudio
+}
+
 func valOrZero(useVal bool, val int) int {
 	if useVal {
 		return val
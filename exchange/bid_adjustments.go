@@ -0,0 +1,87 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/tracing"
+)
+
+// applyBidAdjustments applies request.ext.prebid.bidadjustments to the bids of a bidder. The bids must already be
+// in the seat's currency, so that static adjustments can be converted to it.
+//
+// Bids whose price isn't positive anymore are removed.
+func applyBidAdjustments(ctx context.Context, seatBid *pbsOrtbSeatBid, bidder openrtb_ext.BidderName, adjustments *openrtb_ext.ExtRequestPrebidBidAdjustments, conversions currencies.Conversions) []error {
+	if seatBid == nil || adjustments == nil || len(adjustments.MediaType) == 0 {
+		return nil
+	}
+
+	trace := tracing.AuctionTraceFromContext(ctx)
+	seatCurrency := seatBid.currency
+	if seatCurrency == "" {
+		seatCurrency = "USD"
+	}
+	var errs []error
+	kept := seatBid.bids[:0]
+	for _, bid := range seatBid.bids {
+		if bid.bid == nil {
+			kept = append(kept, bid)
+			continue
+		}
+		for _, adjustment := range findBidAdjustments(adjustments, bid.bidType, bidder, bid.bid.DealID) {
+			originalPrice := bid.bid.Price
+			switch adjustment.AdjType {
+			case openrtb_ext.BidAdjustmentMultiplier:
+				bid.bid.Price = bid.bid.Price * adjustment.Value
+			case openrtb_ext.BidAdjustmentStatic:
+				from := adjustment.Currency
+				if from == "" {
+					from = "USD"
+				}
+				rate, err := conversions.GetRate(from, seatCurrency)
+				if err != nil {
+					errs = append(errs, &errortypes.BidAdjustmentWarning{
+						Message: fmt.Sprintf("Bid \"%s\" couldn't get a static bid adjustment: %v", bid.bid.ID, err),
+					})
+					continue
+				}
+				bid.bid.Price = bid.bid.Price + adjustment.Value*rate
+			}
+			trace.Verbose("bid_adjustment", bidder, "bid %s: %s adjustment of %f changed the price from %f to %f %s", bid.bid.ID, adjustment.AdjType, adjustment.Value, originalPrice, bid.bid.Price, seatCurrency)
+		}
+		if bid.bid.Price <= 0 {
+			errs = append(errs, &errortypes.BidAdjustmentWarning{
+				Message: fmt.Sprintf("Bid \"%s\" was removed, since its bid adjustments lowered its price to %f", bid.bid.ID, bid.bid.Price),
+			})
+			trace.Basic("bid_adjustment", bidder, "bid %s was removed, since its bid adjustments lowered its price to %f", bid.bid.ID, bid.bid.Price)
+			continue
+		}
+		kept = append(kept, bid)
+	}
+	seatBid.bids = kept
+	return errs
+}
+
+// findBidAdjustments returns the most specific adjustments for a bid, or nil if there are none.
+// An exact bidder beats the wildcard bidder, and then an exact deal ID beats the wildcard deal ID.
+func findBidAdjustments(adjustments *openrtb_ext.ExtRequestPrebidBidAdjustments, bidType openrtb_ext.BidType, bidder openrtb_ext.BidderName, dealID string) []openrtb_ext.ExtBidAdjustment {
+	byBidder := adjustments.MediaType[bidType]
+	for _, bidderKey := range []string{string(bidder), openrtb_ext.BidAdjustmentWildcard} {
+		byDeal, ok := byBidder[bidderKey]
+		if !ok {
+			continue
+		}
+		if dealID != "" {
+			if found, ok := byDeal[dealID]; ok {
+				return found
+			}
+		}
+		if found, ok := byDeal[openrtb_ext.BidAdjustmentWildcard]; ok {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyBidAdjustments(t *testing.T) {
+	var adjustments openrtb_ext.ExtRequestPrebidBidAdjustments
+	err := json.Unmarshal([]byte(`{
+		"mediatype": {
+			"video": {
+				"appnexus": {
+					"*": [{"adjtype": "multiplier", "value": 0.5}],
+					"deal-1": [{"adjtype": "static", "value": -0.5, "currency": "USD"}]
+				},
+				"*": {
+					"*": [{"adjtype": "multiplier", "value": 0.9}, {"adjtype": "static", "value": 1}]
+				}
+			},
+			"banner": {
+				"appnexus": {
+					"deal-2": [{"adjtype": "static", "value": -3}]
+				}
+			}
+		}
+	}`), &adjustments)
+	if !assert.NoError(t, err) {
+		return
+	}
+	conversions := currencies.NewRates(time.Now(), map[string]map[string]float64{"USD": {"EUR": 0.5}})
+
+	testCases := []struct {
+		description string
+		bidder      openrtb_ext.BidderName
+		bidType     openrtb_ext.BidType
+		dealID      string
+		currency    string
+		expected    float64
+	}{
+		{
+			description: "The bidder's adjustment should beat the wildcard",
+			bidder:      "appnexus",
+			bidType:     openrtb_ext.BidTypeVideo,
+			expected:    1,
+		},
+		{
+			description: "The deal's adjustment should beat the wildcard deal",
+			bidder:      "appnexus",
+			bidType:     openrtb_ext.BidTypeVideo,
+			dealID:      "deal-1",
+			expected:    1.5,
+		},
+		{
+			description: "Other deals should get the wildcard deal's adjustment",
+			bidder:      "appnexus",
+			bidType:     openrtb_ext.BidTypeVideo,
+			dealID:      "deal-3",
+			expected:    1,
+		},
+		{
+			description: "Other bidders should get the wildcard bidder's adjustments, in order",
+			bidder:      "rubicon",
+			bidType:     openrtb_ext.BidTypeVideo,
+			expected:    2.8,
+		},
+		{
+			description: "Static adjustments should be converted to the bid's currency",
+			bidder:      "rubicon",
+			bidType:     openrtb_ext.BidTypeVideo,
+			currency:    "EUR",
+			expected:    2.3,
+		},
+		{
+			description: "Other media types shouldn't be adjusted",
+			bidder:      "appnexus",
+			bidType:     openrtb_ext.BidTypeNative,
+			expected:    2,
+		},
+		{
+			description: "Bids without a matching deal shouldn't be adjusted",
+			bidder:      "appnexus",
+			bidType:     openrtb_ext.BidTypeBanner,
+			expected:    2,
+		},
+	}
+
+	for _, test := range testCases {
+		seatBid := &pbsOrtbSeatBid{
+			currency: test.currency,
+			bids: []*pbsOrtbBid{{
+				bid:     &openrtb.Bid{ID: "bid", Price: 2, DealID: test.dealID},
+				bidType: test.bidType,
+			}},
+		}
+		errs := applyBidAdjustments(context.Background(), seatBid, test.bidder, &adjustments, conversions)
+		assert.Empty(t, errs, test.description)
+		if assert.Len(t, seatBid.bids, 1, test.description) {
+			assert.InDelta(t, test.expected, seatBid.bids[0].bid.Price, 0.0001, test.description)
+		}
+	}
+}
+
+func TestApplyBidAdjustmentsRemovesBids(t *testing.T) {
+	adjustments := &openrtb_ext.ExtRequestPrebidBidAdjustments{
+		MediaType: map[openrtb_ext.BidType]map[string]map[string][]openrtb_ext.ExtBidAdjustment{
+			openrtb_ext.BidTypeBanner: {"*": {"deal-1": {{AdjType: openrtb_ext.BidAdjustmentStatic, Value: -3}}}},
+		},
+	}
+	seatBid := &pbsOrtbSeatBid{
+		currency: "USD",
+		bids: []*pbsOrtbBid{
+			{bid: &openrtb.Bid{ID: "deal-bid", Price: 2, DealID: "deal-1"}, bidType: openrtb_ext.BidTypeBanner},
+			{bid: &openrtb.Bid{ID: "open-bid", Price: 2}, bidType: openrtb_ext.BidTypeBanner},
+		},
+	}
+
+	errs := applyBidAdjustments(context.Background(), seatBid, "appnexus", adjustments, currencies.NewConstantRates())
+
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], "Bid \"deal-bid\" was removed, since its bid adjustments lowered its price to -1.000000")
+		assert.Equal(t, errortypes.BidAdjustmentWarningCode, errortypes.ReadCode(errs[0]))
+	}
+	if assert.Len(t, seatBid.bids, 1) {
+		assert.Equal(t, "open-bid", seatBid.bids[0].bid.ID)
+	}
+}
+
+func TestApplyBidAdjustmentsUnknownCurrency(t *testing.T) {
+	adjustments := &openrtb_ext.ExtRequestPrebidBidAdjustments{
+		MediaType: map[openrtb_ext.BidType]map[string]map[string][]openrtb_ext.ExtBidAdjustment{
+			openrtb_ext.BidTypeBanner: {"*": {"*": {{AdjType: openrtb_ext.BidAdjustmentStatic, Value: 1, Currency: "JPY"}}}},
+		},
+	}
+	seatBid := &pbsOrtbSeatBid{
+		currency: "USD",
+		bids:     []*pbsOrtbBid{{bid: &openrtb.Bid{ID: "bid", Price: 2}, bidType: openrtb_ext.BidTypeBanner}},
+	}
+
+	errs := applyBidAdjustments(context.Background(), seatBid, "appnexus", adjustments, currencies.NewConstantRates())
+
+	if assert.Len(t, errs, 1, "The missing conversion rate should be reported") {
+		assert.Equal(t, errortypes.BidAdjustmentWarningCode, errortypes.ReadCode(errs[0]))
+	}
+	if assert.Len(t, seatBid.bids, 1) {
+		assert.Equal(t, 2.0, seatBid.bids[0].bid.Price, "The bid should keep its price")
+	}
+}
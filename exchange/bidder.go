@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -22,6 +24,7 @@ import (
 	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/tracing"
 	"golang.org/x/net/context/ctxhttp"
 )
 
@@ -55,7 +58,7 @@ type adaptedBidder interface {
 // pbsOrtbBid.bid.Ext will become "response.seatbid[i].bid.ext.bidder" in the final OpenRTB response.
 // pbsOrtbBid.bidType will become "response.seatbid[i].bid.ext.prebid.type" in the final OpenRTB response.
 // pbsOrtbBid.bidTargets does not need to be filled out by the Bidder. It will be set later by the exchange.
-// pbsOrtbBid.bidVideo is optional but should be filled out by the Bidder if bidType is video.
+// pbsOrtbBid.bidVideo is optional but should be filled out by the Bidder if bidType is video or audio.
 // pbsOrtbBid.dealPriority will become "response.seatbid[i].bid.dealPriority" in the final OpenRTB response.
 type pbsOrtbBid struct {
 	bid          *openrtb.Bid
@@ -101,10 +104,22 @@ type bidderAdapter struct {
 	Client      *http.Client
 	DebugConfig config.Debug
 	me          pbsmetrics.MetricsEngine
+	fixtures    *fixtureRecorder
 }
 
 func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions, reqInfo *adapters.ExtraRequestInfo) (*pbsOrtbSeatBid, []error) {
+	// The request is copied before the bidder gets it, since some bidders modify it.
+	var fixtureRequest json.RawMessage
+	if bidder.fixtures != nil && bidder.fixtures.sample() {
+		fixtureRequest, _ = json.Marshal(request)
+	}
+
+	trace := tracing.AuctionTraceFromContext(ctx)
+
+	_, span := tracing.StartSpan(ctx, "make_requests")
 	reqData, errs := bidder.Bidder.MakeRequests(request, reqInfo)
+	span.SetAttribute("requests", strconv.Itoa(len(reqData)))
+	span.End()
 
 	if len(reqData) == 0 {
 		// If the adapter failed to generate both requests and errors, this is an error.
@@ -133,6 +148,10 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 		currency:  defaultCurrency,
 		httpCalls: make([]*openrtb_ext.ExtHttpCall, 0, len(reqData)),
 	}
+	var fixtureCalls []*httpCallInfo
+	if fixtureRequest != nil {
+		fixtureCalls = make([]*httpCallInfo, len(reqData))
+	}
 
 	// If the bidder made multiple requests, we still want them to enter as many bids as possible...
 	// even if the timeout occurs sometime halfway through.
@@ -142,10 +161,22 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 		if request.Test == 1 {
 			seatBid.httpCalls = append(seatBid.httpCalls, makeExt(httpInfo))
 		}
+		if fixtureCalls != nil {
+			for j := range reqData {
+				if reqData[j] == httpInfo.request {
+					fixtureCalls[j] = httpInfo
+				}
+			}
+		}
 
 		if httpInfo.err == nil {
+			_, span := tracing.StartSpan(ctx, "make_bids")
 			bidResponse, moreErrs := bidder.Bidder.MakeBids(request, httpInfo.request, httpInfo.response)
 			errs = append(errs, moreErrs...)
+			if bidResponse != nil {
+				span.SetAttribute("bids", strconv.Itoa(len(bidResponse.Bids)))
+			}
+			span.End()
 
 			if bidResponse != nil {
 				// Setup default currency as `USD` is not set in bid request nor bid response
@@ -159,6 +190,8 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 				// Try to get a conversion rate
 				// Try to get the first currency from request.cur having a match in the rate converter,
 				// and use it as currency
+				_, span := tracing.StartSpan(ctx, "currency_conversion")
+				span.SetAttribute("from", bidResponse.Currency)
 				var conversionRate float64
 				var err error
 				for _, bidReqCur := range request.Cur {
@@ -167,6 +200,14 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 						break
 					}
 				}
+				span.SetAttribute("to", seatBid.currency)
+				span.SetError(err)
+				span.End()
+				if err != nil {
+					trace.Basic("currency_conversion", name, "no rate from %s to %s, so %d bids were dropped", bidResponse.Currency, strings.Join(request.Cur, ", "), len(bidResponse.Bids))
+				} else if bidResponse.Currency != seatBid.currency {
+					trace.Basic("currency_conversion", name, "converted the bids from %s to %s at a rate of %f", bidResponse.Currency, seatBid.currency, conversionRate)
+				}
 
 				// Only do this for request from mobile app
 				if request.App != nil {
@@ -191,7 +232,9 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 					// Conversion rate found, using it for conversion
 					for i := 0; i < len(bidResponse.Bids); i++ {
 						if bidResponse.Bids[i].Bid != nil {
+							originalPrice := bidResponse.Bids[i].Bid.Price
 							bidResponse.Bids[i].Bid.Price = bidResponse.Bids[i].Bid.Price * bidAdjustment * conversionRate
+							trace.Verbose("currency_conversion", name, "bid %s: price %f %s became %f %s with a bid adjustment factor of %f", bidResponse.Bids[i].Bid.ID, originalPrice, bidResponse.Currency, bidResponse.Bids[i].Bid.Price, seatBid.currency, bidAdjustment)
 						}
 						seatBid.bids = append(seatBid.bids, &pbsOrtbBid{
 							bid:          bidResponse.Bids[i].Bid,
@@ -210,6 +253,9 @@ func (bidder *bidderAdapter) requestBid(ctx context.Context, request *openrtb.Bi
 		}
 	}
 
+	if fixtureRequest != nil {
+		bidder.fixtures.enqueue(bidder.Bidder, fixtureRequest, *reqInfo, fixtureCalls)
+	}
 	return seatBid, errs
 }
 
@@ -311,7 +357,19 @@ func makeExt(httpInfo *httpCallInfo) *openrtb_ext.ExtHttpCall {
 
 // doRequest makes a request, handles the response, and returns the data needed by the
 // Bidder interface.
-func (bidder *bidderAdapter) doRequest(ctx context.Context, req *adapters.RequestData) *httpCallInfo {
+func (bidder *bidderAdapter) doRequest(ctx context.Context, req *adapters.RequestData) (info *httpCallInfo) {
+	ctx, span := tracing.StartSpan(ctx, "http_request")
+	span.SetAttribute("http.method", req.Method)
+	// Some bidders put credentials in the query string, so it's left out of the trace.
+	span.SetAttribute("http.url", strings.SplitN(req.Uri, "?", 2)[0])
+	defer func() {
+		if info.response != nil {
+			span.SetAttribute("http.status_code", strconv.Itoa(info.response.StatusCode))
+		}
+		span.SetError(info.err)
+		span.End()
+	}()
+
 	httpReq, err := http.NewRequest(req.Method, req.Uri, bytes.NewBuffer(req.Body))
 	if err != nil {
 		return &httpCallInfo{
@@ -320,6 +378,14 @@ func (bidder *bidderAdapter) doRequest(ctx context.Context, req *adapters.Reques
 		}
 	}
 	httpReq.Header = req.Headers
+	if traceparent := tracing.Traceparent(ctx); traceparent != "" {
+		// Copy the headers, so that the adapter's RequestData isn't modified.
+		httpReq.Header = req.Headers.Clone()
+		if httpReq.Header == nil {
+			httpReq.Header = http.Header{}
+		}
+		httpReq.Header.Set(tracing.TraceparentHeader, traceparent)
+	}
 
 	httpResp, err := ctxhttp.Do(ctx, bidder.Client, httpReq)
 	if err != nil {
@@ -0,0 +1,223 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// enforceAdQuality returns a bidder that checks the argument bidder's bids against the blocking rules
+// of the request: badv, bcat, the imp's battr and bapp.
+//
+// Depending on the host config, each rule either removes the offending bids, or keeps them and adds a warning.
+// Either way, the error names the rule which was broken so that publishers can tell why a bid was flagged.
+func enforceAdQuality(bidder adaptedBidder, cfg config.AdQuality, me pbsmetrics.MetricsEngine) adaptedBidder {
+	if !cfg.Enabled() {
+		return bidder
+	}
+	return &adQualityBidder{
+		bidder:  bidder,
+		cfg:     cfg,
+		metrics: me,
+	}
+}
+
+type adQualityBidder struct {
+	bidder  adaptedBidder
+	cfg     config.AdQuality
+	metrics pbsmetrics.MetricsEngine
+}
+
+func (a *adQualityBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions, reqInfo *adapters.ExtraRequestInfo) (*pbsOrtbSeatBid, []error) {
+	seatBid, errs := a.bidder.requestBid(ctx, request, name, bidAdjustment, conversions, reqInfo)
+	if seatBid == nil || len(seatBid.bids) == 0 {
+		return seatBid, errs
+	}
+
+	allowedBids := make([]*pbsOrtbBid, 0, len(seatBid.bids))
+	for _, bid := range seatBid.bids {
+		rejected := false
+		for _, violation := range a.findViolations(request, bid) {
+			switch a.action(violation.rule) {
+			case config.AdQualityReject:
+				if !rejected {
+					rejected = true
+					// The name is the request alias, if the bid is for one, rather than the core bidder.
+					a.metrics.RecordAdQualityRejection(name, violation.rule)
+					errs = append(errs, &errortypes.BlockedBid{
+						Message: fmt.Sprintf("Bid \"%s\" rejected: %s", bid.bid.ID, violation.message),
+					})
+				}
+			case config.AdQualityWarn:
+				errs = append(errs, &errortypes.BlockedBidWarning{
+					Message: fmt.Sprintf("Bid \"%s\" allowed, but %s", bid.bid.ID, violation.message),
+				})
+			}
+		}
+		if !rejected {
+			allowedBids = append(allowedBids, bid)
+		}
+	}
+	seatBid.bids = allowedBids
+	return seatBid, errs
+}
+
+func (a *adQualityBidder) action(rule pbsmetrics.AdQualityRule) string {
+	var action string
+	switch rule {
+	case pbsmetrics.AdQualityBlockedAdvertisers:
+		action = a.cfg.BlockedAdvertisers
+	case pbsmetrics.AdQualityBlockedCategories:
+		action = a.cfg.BlockedCategories
+	case pbsmetrics.AdQualityBlockedAttributes:
+		action = a.cfg.BlockedAttributes
+	case pbsmetrics.AdQualityBlockedApps:
+		action = a.cfg.BlockedApps
+	default:
+		return config.AdQualityOff
+	}
+	if action == "" {
+		return config.AdQualityWarn
+	}
+	return action
+}
+
+type adQualityViolation struct {
+	rule    pbsmetrics.AdQualityRule
+	message string
+}
+
+// findViolations returns every rule broken by the bid, skipping the rules which are turned off.
+func (a *adQualityBidder) findViolations(request *openrtb.BidRequest, bid *pbsOrtbBid) []adQualityViolation {
+	var violations []adQualityViolation
+
+	if a.cfg.BlockedAdvertisers != config.AdQualityOff {
+		for _, domain := range bid.bid.ADomain {
+			if blocked, ok := findBlockedDomain(domain, request.BAdv); ok {
+				violations = append(violations, adQualityViolation{
+					rule:    pbsmetrics.AdQualityBlockedAdvertisers,
+					message: fmt.Sprintf("adomain \"%s\" is blocked by request.badv \"%s\"", domain, blocked),
+				})
+				break
+			}
+		}
+	}
+
+	if a.cfg.BlockedCategories != config.AdQualityOff {
+		for _, category := range bid.bid.Cat {
+			if blocked, ok := findBlockedCategory(category, request.BCat); ok {
+				violations = append(violations, adQualityViolation{
+					rule:    pbsmetrics.AdQualityBlockedCategories,
+					message: fmt.Sprintf("cat \"%s\" is blocked by request.bcat \"%s\"", category, blocked),
+				})
+				break
+			}
+		}
+	}
+
+	if a.cfg.BlockedAttributes != config.AdQualityOff {
+		if blockedAttrs, field := blockedAttributes(request, bid); len(blockedAttrs) > 0 {
+			for _, attr := range bid.bid.Attr {
+				if containsAttribute(blockedAttrs, attr) {
+					violations = append(violations, adQualityViolation{
+						rule:    pbsmetrics.AdQualityBlockedAttributes,
+						message: fmt.Sprintf("attr %d is blocked by request.imp[id=%s].%s", attr, bid.bid.ImpID, field),
+					})
+					break
+				}
+			}
+		}
+	}
+
+	if a.cfg.BlockedApps != config.AdQualityOff && bid.bid.Bundle != "" {
+		for _, blocked := range request.BApp {
+			if strings.EqualFold(bid.bid.Bundle, blocked) {
+				violations = append(violations, adQualityViolation{
+					rule:    pbsmetrics.AdQualityBlockedApps,
+					message: fmt.Sprintf("bundle \"%s\" is blocked by request.bapp", bid.bid.Bundle),
+				})
+				break
+			}
+		}
+	}
+
+	return violations
+}
+
+// findBlockedDomain returns the entry of badv which blocks the domain.
+// Blocking a domain also blocks all of its subdomains.
+func findBlockedDomain(domain string, badv []string) (string, bool) {
+	domain = strings.ToLower(domain)
+	for _, blocked := range badv {
+		lowerBlocked := strings.ToLower(blocked)
+		if lowerBlocked == "" {
+			continue
+		}
+		if domain == lowerBlocked || strings.HasSuffix(domain, "."+lowerBlocked) {
+			return blocked, true
+		}
+	}
+	return "", false
+}
+
+// findBlockedCategory returns the entry of bcat which blocks the category.
+// Blocking an IAB tier 1 category (like "IAB25") also blocks its tier 2 categories (like "IAB25-3").
+func findBlockedCategory(category string, bcat []string) (string, bool) {
+	for _, blocked := range bcat {
+		if blocked == "" {
+			continue
+		}
+		if strings.EqualFold(category, blocked) || strings.HasPrefix(strings.ToUpper(category), strings.ToUpper(blocked)+"-") {
+			return blocked, true
+		}
+	}
+	return "", false
+}
+
+// blockedAttributes returns the battr of the bid's imp which applies to the bid's media type,
+// along with the path of that field for the error messages.
+func blockedAttributes(request *openrtb.BidRequest, bid *pbsOrtbBid) ([]openrtb.CreativeAttribute, string) {
+	for i := range request.Imp {
+		imp := &request.Imp[i]
+		if imp.ID != bid.bid.ImpID {
+			continue
+		}
+		switch bid.bidType {
+		case openrtb_ext.BidTypeBanner:
+			if imp.Banner != nil {
+				return imp.Banner.BAttr, "banner.battr"
+			}
+		case openrtb_ext.BidTypeVideo:
+			if imp.Video != nil {
+				return imp.Video.BAttr, "video.battr"
+			}
+		case openrtb_ext.BidTypeAudio:
+			if imp.Audio != nil {
+				return imp.Audio.BAttr, "audio.battr"
+			}
+		case openrtb_ext.BidTypeNative:
+			if imp.Native != nil {
+				return imp.Native.BAttr, "native.battr"
+			}
+		}
+		return nil, ""
+	}
+	return nil, ""
+}
+
+func containsAttribute(attrs []openrtb.CreativeAttribute, attr openrtb.CreativeAttribute) bool {
+	for _, a := range attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
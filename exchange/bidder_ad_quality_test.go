@@ -0,0 +1,202 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdQualityRejections(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "banner-imp", Banner: &openrtb.Banner{BAttr: []openrtb.CreativeAttribute{1, 3}}},
+			{ID: "video-imp", Video: &openrtb.Video{BAttr: []openrtb.CreativeAttribute{16}}},
+		},
+		BAdv: []string{"blocked.com"},
+		BCat: []string{"IAB25", "IAB7-39"},
+		BApp: []string{"com.blocked.app"},
+	}
+
+	testCases := []struct {
+		description string
+		bid         *pbsOrtbBid
+		rule        pbsmetrics.AdQualityRule
+		message     string
+	}{
+		{
+			description: "Blocked advertiser",
+			bid:         &pbsOrtbBid{bid: &openrtb.Bid{ID: "bid", ImpID: "banner-imp", ADomain: []string{"good.com", "Blocked.com"}}, bidType: openrtb_ext.BidTypeBanner},
+			rule:        pbsmetrics.AdQualityBlockedAdvertisers,
+			message:     `Bid "bid" rejected: adomain "Blocked.com" is blocked by request.badv "blocked.com"`,
+		},
+		{
+			description: "Subdomain of a blocked advertiser",
+			bid:         &pbsOrtbBid{bid: &openrtb.Bid{ID: "bid", ImpID: "banner-imp", ADomain: []string{"ads.blocked.com"}}, bidType: openrtb_ext.BidTypeBanner},
+			rule:        pbsmetrics.AdQualityBlockedAdvertisers,
+			message:     `Bid "bid" rejected: adomain "ads.blocked.com" is blocked by request.badv "blocked.com"`,
+		},
+		{
+			description: "Blocked category",
+			bid:         &pbsOrtbBid{bid: &openrtb.Bid{ID: "bid", ImpID: "banner-imp", Cat: []string{"IAB7-39"}}, bidType: openrtb_ext.BidTypeBanner},
+			rule:        pbsmetrics.AdQualityBlockedCategories,
+			message:     `Bid "bid" rejected: cat "IAB7-39" is blocked by request.bcat "IAB7-39"`,
+		},
+		{
+			description: "Subcategory of a blocked category",
+			bid:         &pbsOrtbBid{bid: &openrtb.Bid{ID: "bid", ImpID: "banner-imp", Cat: []string{"IAB25-3"}}, bidType: openrtb_ext.BidTypeBanner},
+			rule:        pbsmetrics.AdQualityBlockedCategories,
+			message:     `Bid "bid" rejected: cat "IAB25-3" is blocked by request.bcat "IAB25"`,
+		},
+		{
+			description: "Blocked banner attribute",
+			bid:         &pbsOrtbBid{bid: &openrtb.Bid{ID: "bid", ImpID: "banner-imp", Attr: []openrtb.CreativeAttribute{3}}, bidType: openrtb_ext.BidTypeBanner},
+			rule:        pbsmetrics.AdQualityBlockedAttributes,
+			message:     `Bid "bid" rejected: attr 3 is blocked by request.imp[id=banner-imp].banner.battr`,
+		},
+		{
+			description: "Blocked video attribute",
+			bid:         &pbsOrtbBid{bid: &openrtb.Bid{ID: "bid", ImpID: "video-imp", Attr: []openrtb.CreativeAttribute{16}}, bidType: openrtb_ext.BidTypeVideo},
+			rule:        pbsmetrics.AdQualityBlockedAttributes,
+			message:     `Bid "bid" rejected: attr 16 is blocked by request.imp[id=video-imp].video.battr`,
+		},
+		{
+			description: "Blocked app",
+			bid:         &pbsOrtbBid{bid: &openrtb.Bid{ID: "bid", ImpID: "banner-imp", Bundle: "com.blocked.app"}, bidType: openrtb_ext.BidTypeBanner},
+			rule:        pbsmetrics.AdQualityBlockedApps,
+			message:     `Bid "bid" rejected: bundle "com.blocked.app" is blocked by request.bapp`,
+		},
+	}
+
+	for _, test := range testCases {
+		metrics := &pbsmetrics.MetricsEngineMock{}
+		metrics.On("RecordAdQualityRejection", openrtb_ext.BidderAppnexus, test.rule).Return()
+
+		bidder := enforceAdQuality(&mockAdaptedBidder{
+			bidResponse: &pbsOrtbSeatBid{bids: []*pbsOrtbBid{test.bid}},
+		}, rejectAll(), metrics)
+		seatBid, errs := bidder.requestBid(context.Background(), request, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates(), &adapters.ExtraRequestInfo{})
+
+		assert.Empty(t, seatBid.bids, test.description)
+		if assert.Len(t, errs, 1, test.description) {
+			assert.EqualError(t, errs[0], test.message, test.description)
+			assert.Equal(t, errortypes.BlockedBidErrorCode, errortypes.ReadCode(errs[0]), test.description)
+		}
+		metrics.AssertExpectations(t)
+	}
+}
+
+func TestAdQualityAllowedBids(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "banner-imp", Banner: &openrtb.Banner{}, Video: &openrtb.Video{BAttr: []openrtb.CreativeAttribute{3}}},
+		},
+		BAdv: []string{"blocked.com"},
+		BCat: []string{"IAB25"},
+	}
+	bids := []*pbsOrtbBid{
+		{bid: &openrtb.Bid{ID: "similar-domain", ImpID: "banner-imp", ADomain: []string{"notblocked.com"}}, bidType: openrtb_ext.BidTypeBanner},
+		{bid: &openrtb.Bid{ID: "similar-category", ImpID: "banner-imp", Cat: []string{"IAB2"}}, bidType: openrtb_ext.BidTypeBanner},
+		{bid: &openrtb.Bid{ID: "other-media-type", ImpID: "banner-imp", Attr: []openrtb.CreativeAttribute{3}}, bidType: openrtb_ext.BidTypeBanner},
+	}
+
+	bidder := enforceAdQuality(&mockAdaptedBidder{
+		bidResponse: &pbsOrtbSeatBid{bids: bids},
+	}, rejectAll(), &pbsmetrics.MetricsEngineMock{})
+	seatBid, errs := bidder.requestBid(context.Background(), request, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates(), &adapters.ExtraRequestInfo{})
+
+	assert.Len(t, seatBid.bids, 3)
+	assert.Empty(t, errs)
+}
+
+func TestAdQualityWarnings(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp:  []openrtb.Imp{{ID: "imp", Banner: &openrtb.Banner{}}},
+		BAdv: []string{"blocked.com"},
+		BCat: []string{"IAB25"},
+	}
+	bids := []*pbsOrtbBid{
+		{bid: &openrtb.Bid{ID: "both", ImpID: "imp", ADomain: []string{"blocked.com"}, Cat: []string{"IAB25"}}, bidType: openrtb_ext.BidTypeBanner},
+		{bid: &openrtb.Bid{ID: "category", ImpID: "imp", Cat: []string{"IAB25"}}, bidType: openrtb_ext.BidTypeBanner},
+	}
+	cfg := rejectAll()
+	cfg.BlockedCategories = config.AdQualityWarn
+
+	metrics := &pbsmetrics.MetricsEngineMock{}
+	metrics.On("RecordAdQualityRejection", openrtb_ext.BidderAppnexus, pbsmetrics.AdQualityBlockedAdvertisers).Return()
+
+	bidder := enforceAdQuality(&mockAdaptedBidder{
+		bidResponse: &pbsOrtbSeatBid{bids: bids},
+	}, cfg, metrics)
+	seatBid, errs := bidder.requestBid(context.Background(), request, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates(), &adapters.ExtraRequestInfo{})
+
+	if assert.Len(t, seatBid.bids, 1) {
+		assert.Equal(t, "category", seatBid.bids[0].bid.ID, "A bid which only breaks a warned rule should be kept")
+	}
+	if assert.Len(t, errs, 3) {
+		assert.EqualError(t, errs[0], `Bid "both" rejected: adomain "blocked.com" is blocked by request.badv "blocked.com"`)
+		assert.EqualError(t, errs[1], `Bid "both" allowed, but cat "IAB25" is blocked by request.bcat "IAB25"`)
+		assert.EqualError(t, errs[2], `Bid "category" allowed, but cat "IAB25" is blocked by request.bcat "IAB25"`)
+		assert.Equal(t, errortypes.BlockedBidWarningCode, errortypes.ReadCode(errs[2]))
+	}
+	metrics.AssertNumberOfCalls(t, "RecordAdQualityRejection", 1)
+}
+
+func TestAdQualityRequestAlias(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp:  []openrtb.Imp{{ID: "imp", Banner: &openrtb.Banner{}}},
+		BAdv: []string{"blocked.com"},
+	}
+	metrics := &pbsmetrics.MetricsEngineMock{}
+	metrics.On("RecordAdQualityRejection", openrtb_ext.BidderName("requestAlias"), pbsmetrics.AdQualityBlockedAdvertisers).Return()
+
+	bidder := enforceAdQuality(&mockAdaptedBidder{
+		bidResponse: &pbsOrtbSeatBid{bids: []*pbsOrtbBid{{bid: &openrtb.Bid{ID: "bid", ImpID: "imp", ADomain: []string{"blocked.com"}}, bidType: openrtb_ext.BidTypeBanner}}},
+	}, rejectAll(), metrics)
+	bidder.requestBid(context.Background(), request, "requestAlias", 1.0, currencies.NewConstantRates(), &adapters.ExtraRequestInfo{})
+
+	metrics.AssertExpectations(t)
+}
+
+func TestAdQualityDefaultAction(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Imp:  []openrtb.Imp{{ID: "imp", Banner: &openrtb.Banner{}}},
+		BCat: []string{"IAB25"},
+	}
+	bidder := enforceAdQuality(&mockAdaptedBidder{
+		bidResponse: &pbsOrtbSeatBid{bids: []*pbsOrtbBid{{bid: &openrtb.Bid{ID: "bid", ImpID: "imp", Cat: []string{"IAB25"}}, bidType: openrtb_ext.BidTypeBanner}}},
+	}, config.AdQuality{}, &pbsmetrics.MetricsEngineMock{})
+	seatBid, errs := bidder.requestBid(context.Background(), request, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates(), &adapters.ExtraRequestInfo{})
+
+	assert.Len(t, seatBid.bids, 1, "Rules without an action should only warn")
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, errortypes.BlockedBidWarningCode, errortypes.ReadCode(errs[0]))
+	}
+}
+
+func TestAdQualityOff(t *testing.T) {
+	cfg := config.AdQuality{
+		BlockedAdvertisers: config.AdQualityOff,
+		BlockedCategories:  config.AdQualityOff,
+		BlockedAttributes:  config.AdQualityOff,
+		BlockedApps:        config.AdQualityOff,
+	}
+	bidder := &mockAdaptedBidder{}
+	assert.Equal(t, bidder, enforceAdQuality(bidder, cfg, &pbsmetrics.MetricsEngineMock{}), "The bidder shouldn't be wrapped if every rule is off")
+}
+
+func rejectAll() config.AdQuality {
+	return config.AdQuality{
+		BlockedAdvertisers: config.AdQualityReject,
+		BlockedCategories:  config.AdQualityReject,
+		BlockedAttributes:  config.AdQualityReject,
+		BlockedApps:        config.AdQualityReject,
+	}
+}
@@ -0,0 +1,304 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+// captureFixtures makes the bidder save a sample of its auctions as adapterstest JSON files, if the host asked for it.
+//
+// The files are written to {directory}/{bidder}test/{supplemental|amp|video}, so that a directory of captures
+// can be replayed with adapterstest.RunJSONBidderTest.
+func captureFixtures(bidder adaptedBidder, name openrtb_ext.BidderName, cfg config.FixtureCapture) adaptedBidder {
+	if !cfg.Captures(string(name)) {
+		return bidder
+	}
+	if adapter, ok := bidder.(*bidderAdapter); ok {
+		adapter.fixtures = &fixtureRecorder{
+			name:  name,
+			cfg:   cfg,
+			queue: make(chan fixtureRecord, fixtureQueueSize),
+		}
+		go adapter.fixtures.run()
+	}
+	return bidder
+}
+
+// fixtureQueueSize is the number of auctions which can wait to be written for each bidder.
+// Captures are dropped while the queue is full, so that a slow disk can't pile up goroutines.
+const fixtureQueueSize = 100
+
+// fixtureRecorder writes the fixture files of a bidder.
+type fixtureRecorder struct {
+	name  openrtb_ext.BidderName
+	cfg   config.FixtureCapture
+	files int32
+	queue chan fixtureRecord
+}
+
+// fixtureRecord is an auction which is waiting to be written. See record.
+type fixtureRecord struct {
+	bidder  adapters.Bidder
+	request json.RawMessage
+	reqInfo adapters.ExtraRequestInfo
+	calls   []*httpCallInfo
+}
+
+// The fixture file format of adapterstest.RunJSONBidderTest
+type fixtureSpec struct {
+	BidRequest        json.RawMessage        `json:"mockBidRequest"`
+	HttpCalls         []fixtureHttpCall      `json:"httpCalls"`
+	BidResponses      []fixtureBidResponse   `json:"expectedBidResponses"`
+	MakeRequestErrors []fixtureExpectedError `json:"expectedMakeRequestsErrors,omitempty"`
+	MakeBidsErrors    []fixtureExpectedError `json:"expectedMakeBidsErrors,omitempty"`
+}
+
+type fixtureHttpCall struct {
+	Request  fixtureHttpRequest  `json:"expectedRequest"`
+	Response fixtureHttpResponse `json:"mockResponse"`
+}
+
+type fixtureHttpRequest struct {
+	Uri     string          `json:"uri"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body"`
+}
+
+type fixtureHttpResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+type fixtureBidResponse struct {
+	Currency string       `json:"currency"`
+	Bids     []fixtureBid `json:"bids"`
+}
+
+type fixtureBid struct {
+	Bid  *openrtb.Bid `json:"bid"`
+	Type string       `json:"type"`
+}
+
+type fixtureExpectedError struct {
+	Value      string `json:"value"`
+	Comparison string `json:"comparison"`
+}
+
+// credentialHeader finds the request headers which may hold the host's credentials.
+var credentialHeader = []string{"authorization", "cookie", "key", "token", "secret", "password"}
+
+// sample returns true if the current auction should be captured.
+func (r *fixtureRecorder) sample() bool {
+	if r.cfg.MaxFiles > 0 && atomic.LoadInt32(&r.files) >= int32(r.cfg.MaxFiles) {
+		return false
+	}
+	return r.cfg.SamplingRate >= 1.0 || rand.Float32() < r.cfg.SamplingRate
+}
+
+// enqueue queues an auction for the recorder's worker, or drops it if the queue is full.
+func (r *fixtureRecorder) enqueue(bidder adapters.Bidder, request json.RawMessage, reqInfo adapters.ExtraRequestInfo, calls []*httpCallInfo) {
+	select {
+	case r.queue <- fixtureRecord{bidder: bidder, request: request, reqInfo: reqInfo, calls: calls}:
+	default:
+		glog.V(2).Infof("Skipping a fixture for %s: too many captures are waiting to be written", r.name)
+	}
+}
+
+// run writes the queued auctions, one at a time.
+func (r *fixtureRecorder) run() {
+	for rec := range r.queue {
+		r.record(rec.bidder, rec.request, rec.reqInfo, rec.calls)
+	}
+}
+
+// record writes the fixture of an auction. The request is the JSON of the BidRequest which the bidder got, and
+// the calls are the HTTP calls it made, in the order of reqData.
+//
+// Rather than scrubbing the PII out of every file, the bidder is run again on a scrubbed copy of the request.
+// This way, the fixture is what the bidder does with the scrubbed request, and can be replayed as it is.
+// Auctions are skipped if the bidder's requests change with the scrubbing, or if an HTTP call failed
+// or got a response which isn't JSON, since those can't be replayed.
+func (r *fixtureRecorder) record(bidder adapters.Bidder, request json.RawMessage, reqInfo adapters.ExtraRequestInfo, calls []*httpCallInfo) {
+	var bidRequest openrtb.BidRequest
+	if err := json.Unmarshal(request, &bidRequest); err != nil {
+		glog.Errorf("Failed to capture a fixture for %s: %v", r.name, err)
+		return
+	}
+	originalUser := bidRequest.User
+	originalDevice := bidRequest.Device
+	scrubber := privacy.NewScrubber()
+	bidRequest.Device = scrubber.ScrubDevice(bidRequest.Device, privacy.ScrubStrategyIPV6Lowest32, privacy.ScrubStrategyGeoReducedPrecision)
+	bidRequest.User = scrubber.ScrubUser(bidRequest.User, privacy.ScrubStrategyUserIDAndDemographic, privacy.ScrubStrategyGeoReducedPrecision)
+
+	mockRequest, err := json.Marshal(&bidRequest)
+	if err != nil {
+		glog.Errorf("Failed to capture a fixture for %s: %v", r.name, err)
+		return
+	}
+	spec := fixtureSpec{BidRequest: mockRequest}
+
+	reqData, errs := bidder.MakeRequests(&bidRequest, &reqInfo)
+	spec.MakeRequestErrors = toFixtureErrors(errs)
+	if len(reqData) != len(calls) {
+		glog.V(2).Infof("Skipping a fixture for %s: it makes %d requests once scrubbed, instead of %d", r.name, len(reqData), len(calls))
+		return
+	}
+
+	for i, call := range calls {
+		if call.response == nil || !json.Valid(call.response.Body) {
+			return
+		}
+		responseBody := scrubResponse(call.response.Body, originalUser, originalDevice)
+		uri := redactQuery(reqData[i].Uri)
+		spec.HttpCalls = append(spec.HttpCalls, fixtureHttpCall{
+			Request: fixtureHttpRequest{
+				Uri:     uri,
+				Headers: fixtureHeaders(reqData[i].Headers),
+				Body:    reqData[i].Body,
+			},
+			Response: fixtureHttpResponse{
+				Status: call.response.StatusCode,
+				Body:   responseBody,
+			},
+		})
+
+		// This is what adapterstest passes to MakeBids.
+		bidderRequest := &adapters.RequestData{Method: "POST", Uri: uri, Body: reqData[i].Body}
+		bidderResponse := &adapters.ResponseData{StatusCode: call.response.StatusCode, Body: responseBody}
+		bidResponse, errs := bidder.MakeBids(&bidRequest, bidderRequest, bidderResponse)
+		spec.MakeBidsErrors = append(spec.MakeBidsErrors, toFixtureErrors(errs)...)
+		fixtureResponse := fixtureBidResponse{Bids: []fixtureBid{}}
+		if bidResponse != nil {
+			fixtureResponse.Currency = bidResponse.Currency
+			for _, bid := range bidResponse.Bids {
+				fixtureResponse.Bids = append(fixtureResponse.Bids, fixtureBid{Bid: bid.Bid, Type: string(bid.BidType)})
+			}
+		}
+		spec.BidResponses = append(spec.BidResponses, fixtureResponse)
+	}
+
+	r.write(spec, reqInfo.PbsEntryPoint)
+}
+
+func (r *fixtureRecorder) write(spec fixtureSpec, entryPoint pbsmetrics.RequestType) {
+	hasErrors := len(spec.MakeRequestErrors) > 0 || len(spec.MakeBidsErrors) > 0
+	var directory string
+	switch {
+	case entryPoint == pbsmetrics.ReqTypeAMP:
+		directory = "amp"
+	case entryPoint == pbsmetrics.ReqTypeVideo && !hasErrors:
+		directory = "video"
+	case entryPoint == pbsmetrics.ReqTypeVideo:
+		// adapterstest doesn't allow errors from video requests.
+		return
+	default:
+		directory = "supplemental"
+	}
+
+	if r.cfg.MaxFiles > 0 && atomic.AddInt32(&r.files, 1) > int32(r.cfg.MaxFiles) {
+		return
+	}
+
+	fileData, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		glog.Errorf("Failed to capture a fixture for %s: %v", r.name, err)
+		return
+	}
+	path := filepath.Join(r.cfg.Directory, string(r.name)+"test", directory)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		glog.Errorf("Failed to capture a fixture for %s: %v", r.name, err)
+		return
+	}
+	fileName := filepath.Join(path, fmt.Sprintf("capture-%d.json", time.Now().UnixNano()))
+	if err := ioutil.WriteFile(fileName, fileData, 0644); err != nil {
+		glog.Errorf("Failed to capture a fixture for %s: %v", r.name, err)
+	}
+}
+
+func toFixtureErrors(errs []error) []fixtureExpectedError {
+	fixtureErrors := make([]fixtureExpectedError, 0, len(errs))
+	for _, err := range errs {
+		fixtureErrors = append(fixtureErrors, fixtureExpectedError{Value: err.Error(), Comparison: "literal"})
+	}
+	return fixtureErrors
+}
+
+// fixtureHeaders leaves the headers out of the fixture if they may hold the host's credentials.
+// adapterstest then doesn't compare them.
+func fixtureHeaders(headers http.Header) http.Header {
+	for header := range headers {
+		for _, credential := range credentialHeader {
+			if strings.Contains(strings.ToLower(header), credential) {
+				return nil
+			}
+		}
+	}
+	return headers
+}
+
+// redactQuery replaces the values of the query params of a URI, since some bidders pass the host's API keys there.
+// The param names are kept, in their order, so that the fixture still shows how the URI is built.
+func redactQuery(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.RawQuery == "" {
+		return uri
+	}
+	params := strings.Split(parsed.RawQuery, "&")
+	for i, param := range params {
+		if equals := strings.IndexByte(param, '='); equals >= 0 {
+			params[i] = param[:equals+1] + "redacted"
+		}
+	}
+	parsed.RawQuery = strings.Join(params, "&")
+	return parsed.String()
+}
+
+// redactedQueryBidder redacts the query params of a bidder's request URIs, the same way they are in its fixtures,
+// so that captures can be replayed against it.
+type redactedQueryBidder struct {
+	adapters.Bidder
+}
+
+func (bidder redactedQueryBidder) MakeRequests(request *openrtb.BidRequest, reqInfo *adapters.ExtraRequestInfo) ([]*adapters.RequestData, []error) {
+	reqData, errs := bidder.Bidder.MakeRequests(request, reqInfo)
+	for _, data := range reqData {
+		data.Uri = redactQuery(data.Uri)
+	}
+	return reqData, errs
+}
+
+// scrubResponse removes the IDs of the user from a response body, in case the bidder sends them back.
+func scrubResponse(body []byte, user *openrtb.User, device *openrtb.Device) []byte {
+	var ids []string
+	if user != nil {
+		ids = append(ids, user.ID, user.BuyerUID)
+	}
+	if device != nil {
+		ids = append(ids, device.IFA, device.IP, device.IPv6)
+	}
+	for _, id := range ids {
+		// Short values could be found by chance in any response.
+		if len(id) >= 6 {
+			body = bytes.Replace(body, []byte(id), nil, -1)
+		}
+	}
+	return body
+}
@@ -0,0 +1,117 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/adapters/adapterstest"
+	"github.com/prebid/prebid-server/adapters/appnexus"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureFixtures(t *testing.T) {
+	directory, err := ioutil.TempDir("", "fixtures")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(directory)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"test-request-id","seatbid":[{"seat":"958","bid":[{"id":"bid","impid":"test-imp-id","price":0.5,"adm":"<a>ad</a>","crid":"29681110","ext":{"appnexus":{"bid_ad_type":0}}}]}],"cur":"USD"}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Configuration{Debug: config.Debug{FixtureCapture: config.FixtureCapture{
+		Bidders:      []string{"appnexus"},
+		SamplingRate: 1,
+		Directory:    directory,
+		MaxFiles:     1,
+	}}}
+	appnexusBidder := appnexus.NewAppNexusBidder(server.Client(), server.URL+"?key=host-api-key", "")
+	bidder := captureFixtures(adaptBidder(appnexusBidder, server.Client(), cfg, nil), openrtb_ext.BidderAppnexus, cfg.Debug.FixtureCapture)
+
+	request := &openrtb.BidRequest{
+		ID: "test-request-id",
+		Imp: []openrtb.Imp{{
+			ID:     "test-imp-id",
+			Banner: &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}}},
+			Ext:    json.RawMessage(`{"bidder":{"placement_id":1}}`),
+		}},
+		Device: &openrtb.Device{IP: "203.0.113.77", IFA: "a0b1c2d3-e4f5-6789-abcd-ef0123456789"},
+		User:   &openrtb.User{BuyerUID: "buyer-uid-123456"},
+	}
+	seatBid, errs := bidder.requestBid(context.Background(), request, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates(), &adapters.ExtraRequestInfo{})
+	assert.Empty(t, errs)
+	assert.Len(t, seatBid.bids, 1)
+
+	captures := filepath.Join(directory, "appnexustest", "supplemental")
+	var fixture []byte
+	assert.Eventually(t, func() bool {
+		files, _ := ioutil.ReadDir(captures)
+		if len(files) != 1 {
+			return false
+		}
+		fixture, _ = ioutil.ReadFile(filepath.Join(captures, files[0].Name()))
+		return json.Valid(fixture)
+	}, time.Second, 10*time.Millisecond, "The auction should be captured")
+	assert.Contains(t, string(fixture), `"ip": "203.0.113.0"`, "The IP address should be scrubbed")
+	assert.NotContains(t, string(fixture), "203.0.113.77")
+	assert.NotContains(t, string(fixture), "a0b1c2d3-e4f5-6789-abcd-ef0123456789")
+	assert.NotContains(t, string(fixture), "buyer-uid-123456")
+	assert.NotContains(t, string(fixture), "host-api-key", "The query params should be redacted")
+
+	// The capture should pass against the adapter which made it.
+	adapterstest.RunJSONBidderTest(t, filepath.Join(directory, "appnexustest"), redactedQueryBidder{appnexusBidder})
+}
+
+func TestCaptureFixturesSampling(t *testing.T) {
+	bidder := &mockAdaptedBidder{}
+	assert.Equal(t, bidder, captureFixtures(bidder, openrtb_ext.BidderAppnexus, config.FixtureCapture{Bidders: []string{"rubicon"}, SamplingRate: 1}),
+		"Only the configured bidders should be captured")
+
+	adapter := captureFixtures(&bidderAdapter{}, openrtb_ext.BidderAppnexus, config.FixtureCapture{Bidders: []string{"appnexus"}, SamplingRate: 1, MaxFiles: 2}).(*bidderAdapter)
+	if assert.NotNil(t, adapter.fixtures) {
+		assert.True(t, adapter.fixtures.sample())
+		adapter.fixtures.files = 2
+		assert.False(t, adapter.fixtures.sample(), "Nothing should be captured after max_files")
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	testCases := []struct {
+		description string
+		uri         string
+		expected    string
+	}{
+		{"No query", "https://bidder.com/bid", "https://bidder.com/bid"},
+		{"Keys", "https://bidder.com/bid?apikey=secret&pub=123", "https://bidder.com/bid?apikey=redacted&pub=redacted"},
+		{"Param without a value", "https://bidder.com/bid?debug&token=secret", "https://bidder.com/bid?debug&token=redacted"},
+		{"Invalid URI", "://bidder.com?token=secret", "://bidder.com?token=secret"},
+	}
+	for _, test := range testCases {
+		assert.Equal(t, test.expected, redactQuery(test.uri), test.description)
+	}
+}
+
+func TestFixtureQueueFull(t *testing.T) {
+	// No worker reads the queue, so it stays full.
+	recorder := &fixtureRecorder{name: openrtb_ext.BidderAppnexus, queue: make(chan fixtureRecord, 1)}
+	recorder.enqueue(nil, json.RawMessage(`{"id":"first"}`), adapters.ExtraRequestInfo{}, nil)
+	recorder.enqueue(nil, json.RawMessage(`{"id":"second"}`), adapters.ExtraRequestInfo{}, nil)
+
+	if assert.Len(t, recorder.queue, 1) {
+		assert.JSONEq(t, `{"id":"first"}`, string((<-recorder.queue).request), "Auctions should be dropped while the queue is full")
+	}
+}
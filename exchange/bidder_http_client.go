@@ -0,0 +1,140 @@
+package exchange
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// bidderHTTPClients makes the HTTP client of each bidder once, so that the adapter and the exchange
+// send the bidder's requests through the same connection pool.
+type bidderHTTPClients struct {
+	shared      *http.Client
+	adapterCfgs map[string]config.Adapter
+	clients     map[string]*http.Client
+}
+
+func newBidderHTTPClients(shared *http.Client, adapterCfgs map[string]config.Adapter) *bidderHTTPClients {
+	return &bidderHTTPClients{
+		shared:      shared,
+		adapterCfgs: adapterCfgs,
+		clients:     make(map[string]*http.Client, len(adapterCfgs)),
+	}
+}
+
+// forBidder returns the client of the bidder or host alias with the given name.
+func (c *bidderHTTPClients) forBidder(name openrtb_ext.BidderName) *http.Client {
+	key := strings.ToLower(string(name))
+	if client, ok := c.clients[key]; ok {
+		return client
+	}
+	client := newBidderHTTPClient(c.shared, c.adapterCfgs[key].HTTPClient)
+	c.clients[key] = client
+	return client
+}
+
+// newBidderHTTPClient returns the client which sends the requests of a bidder with the given http_client config.
+//
+// Bidders without overrides share the argument client. The others get a copy of its transport with the
+// overrides applied, so that they have a connection pool of their own.
+func newBidderHTTPClient(client *http.Client, cfg config.AdapterHTTPClient) *http.Client {
+	if !cfg.OwnsConnections() && !cfg.GzipRequests {
+		return client
+	}
+
+	bidderClient := &http.Client{}
+	if client != nil {
+		*bidderClient = *client
+	}
+
+	var transport http.RoundTripper = bidderClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if cfg.OwnsConnections() {
+		transport = newBidderTransport(transport, cfg)
+	}
+	if cfg.GzipRequests {
+		transport = &gzipTransport{transport: transport}
+	}
+	bidderClient.Transport = transport
+	return bidderClient
+}
+
+// newBidderTransport copies the shared transport and applies the overrides of a bidder.
+func newBidderTransport(shared http.RoundTripper, cfg config.AdapterHTTPClient) *http.Transport {
+	var transport *http.Transport
+	if sharedTransport, ok := shared.(*http.Transport); ok {
+		transport = sharedTransport.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	if cfg.MaxConnsPerHost != nil {
+		transport.MaxConnsPerHost = *cfg.MaxConnsPerHost
+	}
+	if cfg.MaxIdleConns != nil {
+		transport.MaxIdleConns = *cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost != nil {
+		transport.MaxIdleConnsPerHost = *cfg.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout != nil {
+		transport.IdleConnTimeout = time.Duration(*cfg.IdleConnTimeout) * time.Second
+	}
+	if cfg.TLSMinVersion != "" || cfg.TLSInsecureSkipVerify {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.MinVersion = cfg.TLSVersion()
+		transport.TLSClientConfig.InsecureSkipVerify = cfg.TLSInsecureSkipVerify
+	}
+	// The shared transport sets a custom TLSClientConfig, which turns off HTTP/2 unless it's forced.
+	if cfg.HTTP2 {
+		transport.ForceAttemptHTTP2 = true
+	}
+	return transport
+}
+
+// gzipTransport compresses the bodies of the requests sent through it.
+type gzipTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *gzipTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Header.Get("Content-Encoding") != "" {
+		return t.transport.RoundTrip(req)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	// A RoundTripper must not modify the request it was given.
+	gzipReq := req.Clone(req.Context())
+	gzipReq.Header.Set("Content-Encoding", "gzip")
+	gzipReq.ContentLength = int64(compressed.Len())
+	gzipReq.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed.Bytes())), nil
+	}
+	gzipReq.Body, _ = gzipReq.GetBody()
+	return t.transport.RoundTrip(gzipReq)
+}
@@ -0,0 +1,98 @@
+package exchange
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidderHTTPClientShared(t *testing.T) {
+	client := &http.Client{Transport: &http.Transport{}}
+	assert.True(t, client == newBidderHTTPClient(client, config.AdapterHTTPClient{}), "Bidders without overrides should share the client")
+}
+
+func TestBidderHTTPClientOverrides(t *testing.T) {
+	sharedTransport := &http.Transport{
+		MaxConnsPerHost:     0,
+		MaxIdleConns:        400,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     60 * time.Second,
+		TLSClientConfig:     &tls.Config{},
+	}
+	client := &http.Client{Transport: sharedTransport, Timeout: time.Second}
+	maxConns, idleTimeout := 100, 30
+
+	bidderClient := newBidderHTTPClient(client, config.AdapterHTTPClient{
+		MaxConnsPerHost: &maxConns,
+		IdleConnTimeout: &idleTimeout,
+		TLSMinVersion:   "1.2",
+		HTTP2:           true,
+	})
+
+	assert.Equal(t, time.Second, bidderClient.Timeout)
+	transport, ok := bidderClient.Transport.(*http.Transport)
+	if !assert.True(t, ok, "The bidder should have a transport of its own") {
+		return
+	}
+	assert.False(t, transport == sharedTransport, "The bidder should have a connection pool of its own")
+	assert.Equal(t, 100, transport.MaxConnsPerHost)
+	assert.Equal(t, 400, transport.MaxIdleConns, "Unset limits should be copied from the shared transport")
+	assert.Equal(t, 10, transport.MaxIdleConnsPerHost, "Unset limits should be copied from the shared transport")
+	assert.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+	assert.True(t, transport.ForceAttemptHTTP2)
+
+	assert.Equal(t, uint16(0), sharedTransport.TLSClientConfig.MinVersion, "The shared transport shouldn't be modified")
+	assert.False(t, sharedTransport.ForceAttemptHTTP2, "The shared transport shouldn't be modified")
+}
+
+func TestBidderHTTPClients(t *testing.T) {
+	shared := &http.Client{Transport: &http.Transport{}}
+	clients := newBidderHTTPClients(shared, map[string]config.Adapter{
+		"appnexus": {HTTPClient: config.AdapterHTTPClient{GzipRequests: true}},
+	})
+
+	appnexusClient := clients.forBidder("appnexus")
+	assert.False(t, appnexusClient == shared, "A bidder with overrides should get a client of its own")
+	assert.True(t, appnexusClient == clients.forBidder("appnexus"), "A bidder's client should only be made once")
+	assert.True(t, shared == clients.forBidder("rubicon"), "Bidders without overrides should share the client")
+}
+
+func TestGzipRequests(t *testing.T) {
+	var encoding, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		reader, err := gzip.NewReader(r.Body)
+		if assert.NoError(t, err) {
+			decompressed, _ := ioutil.ReadAll(reader)
+			body = string(decompressed)
+		}
+	}))
+	defer server.Close()
+
+	sharedTransport := &http.Transport{}
+	client := newBidderHTTPClient(&http.Client{Transport: sharedTransport}, config.AdapterHTTPClient{GzipRequests: true})
+	if gzipped, ok := client.Transport.(*gzipTransport); assert.True(t, ok) {
+		assert.True(t, gzipped.transport == sharedTransport, "Compression alone shouldn't need a connection pool of its own")
+	}
+
+	req, _ := http.NewRequest("POST", server.URL, bytes.NewBufferString(`{"id":"request"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, "gzip", encoding)
+	assert.Equal(t, `{"id":"request"}`, body)
+	assert.Empty(t, req.Header.Get("Content-Encoding"), "The bidder's request shouldn't be modified")
+}
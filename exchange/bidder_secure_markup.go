@@ -0,0 +1,182 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// insecureURL captures an http:// URL, with or without JSON-escaped slashes, up to the end of its attribute, string or element.
+// The matchers below only differ in where they expect a URL to be loaded as the ad renders.
+// Links which are only followed on a click, like an href or a VAST ClickThrough,
+// and identifiers like the xmlns of a VAST document don't match any of them.
+const insecureURL = `\s*(http:(?:\\?/){2}[^\s"'<>()\]\\]*(?:\\/[^\s"'<>()\]\\]*)*)`
+
+// insecureHTMLResource finds the URLs loaded by HTML attributes (src, srcset, poster and background) and CSS (url(http://...)).
+var insecureHTMLResource = regexp.MustCompile(`(?i)(?:\b(?:src|poster|background)\s*=\s*\\?["']?|\bsrcset\s*=\s*\\?["']?[^"'>]*?|url\(\s*\\?["']?)` + insecureURL)
+
+// insecureVASTResource finds the URLs held by the VAST elements for media files, resources and trackers
+// (<MediaFile><![CDATA[http://...]]></MediaFile>).
+var insecureVASTResource = regexp.MustCompile(`(?i)<(?:MediaFile|Tracking|Impression|Error|StaticResource|IFrameResource|JavaScriptResource)\b[^>]*>\s*(?:<!\[CDATA\[)?` + insecureURL)
+
+// insecureNativeResource finds the URLs of the native images and trackers ("img":{"url":"http://..."}).
+var insecureNativeResource = regexp.MustCompile(`(?i)(?:"img"\s*:\s*\{[^{}]*?"url"\s*:\s*"|"(?:imptrackers|eventtrackers)"\s*:\s*\[[^\]]*?(?:"url"\s*:\s*)?")` + insecureURL)
+
+// insecureResourceMatchers holds the matchers which apply to each type of markup.
+// A VAST companion and a native jstracker are HTML, so video and native markups are also checked for HTML resources.
+var insecureResourceMatchers = map[openrtb_ext.BidType][]*regexp.Regexp{
+	openrtb_ext.BidTypeBanner: {insecureHTMLResource},
+	openrtb_ext.BidTypeVideo:  {insecureVASTResource, insecureHTMLResource},
+	openrtb_ext.BidTypeAudio:  {insecureVASTResource},
+	openrtb_ext.BidTypeNative: {insecureNativeResource, insecureHTMLResource},
+}
+
+// findInsecureResource returns the first http:// URL which the markup would load, or "" if it doesn't load any.
+// Markups of an unknown type are checked with every matcher.
+func findInsecureResource(adm string, bidType openrtb_ext.BidType) string {
+	matchers, ok := insecureResourceMatchers[bidType]
+	if !ok {
+		matchers = []*regexp.Regexp{insecureHTMLResource, insecureVASTResource, insecureNativeResource}
+	}
+	for _, matcher := range matchers {
+		if match := matcher.FindStringSubmatch(adm); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// ensureSecureMarkup returns a bidder that checks the markup of the argument bidder's bids.
+//
+// Bids are flagged if their adm is bigger than the host allows, if it matches one of the disallowed script patterns,
+// or if their adm or nurl loads an http:// resource although the imp asked for secure creatives.
+// Depending on the account, flagged bids are either removed from the response, or kept with a warning.
+func ensureSecureMarkup(bidder adaptedBidder, name openrtb_ext.BidderName, cfg config.SecureMarkup, me pbsmetrics.MetricsEngine) adaptedBidder {
+	if cfg.Mode == config.SecureMarkupOff && len(cfg.AccountModes) == 0 {
+		return bidder
+	}
+
+	scripts := make([]*regexp.Regexp, 0, len(cfg.DisallowedScripts))
+	for _, pattern := range cfg.DisallowedScripts {
+		script, err := regexp.Compile(pattern)
+		if err != nil {
+			// The config validation should have caught this already.
+			glog.Errorf("Ignoring the invalid secure_markup.disallowed_script_patterns entry %q: %v", pattern, err)
+			continue
+		}
+		scripts = append(scripts, script)
+	}
+
+	return &secureMarkupBidder{
+		bidder:  bidder,
+		name:    name,
+		cfg:     cfg,
+		scripts: scripts,
+		metrics: me,
+	}
+}
+
+type secureMarkupBidder struct {
+	bidder  adaptedBidder
+	name    openrtb_ext.BidderName
+	cfg     config.SecureMarkup
+	scripts []*regexp.Regexp
+	metrics pbsmetrics.MetricsEngine
+}
+
+func (s *secureMarkupBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions, reqInfo *adapters.ExtraRequestInfo) (*pbsOrtbSeatBid, []error) {
+	seatBid, errs := s.bidder.requestBid(ctx, request, name, bidAdjustment, conversions, reqInfo)
+	if seatBid == nil || len(seatBid.bids) == 0 {
+		return seatBid, errs
+	}
+
+	account, _ := toAccountId(request)
+	mode := s.cfg.ModeForAccount(account)
+	if mode == config.SecureMarkupOff {
+		return seatBid, errs
+	}
+
+	allowedBids := make([]*pbsOrtbBid, 0, len(seatBid.bids))
+	for _, bid := range seatBid.bids {
+		violations := s.findViolations(request, bid)
+		for _, violation := range violations {
+			s.metrics.RecordMarkupViolation(s.name, violation.kind)
+			if mode == config.SecureMarkupWarn {
+				errs = append(errs, &errortypes.InvalidMarkupWarning{
+					Message: fmt.Sprintf("Bid \"%s\" allowed, but %s", bid.bid.ID, violation.message),
+				})
+			}
+		}
+		if len(violations) > 0 && mode == config.SecureMarkupEnforce {
+			errs = append(errs, &errortypes.InvalidMarkup{
+				Message: fmt.Sprintf("Bid \"%s\" rejected: %s", bid.bid.ID, violations[0].message),
+			})
+			continue
+		}
+		allowedBids = append(allowedBids, bid)
+	}
+	seatBid.bids = allowedBids
+	return seatBid, errs
+}
+
+type markupViolation struct {
+	kind    pbsmetrics.MarkupViolation
+	message string
+}
+
+func (s *secureMarkupBidder) findViolations(request *openrtb.BidRequest, bid *pbsOrtbBid) []markupViolation {
+	// An oversized adm isn't scanned any further. It will be rejected anyway, or the host wants to know about it first.
+	if s.cfg.MaxMarkupBytes > 0 && len(bid.bid.AdM) > s.cfg.MaxMarkupBytes {
+		return []markupViolation{{
+			kind:    pbsmetrics.MarkupOversized,
+			message: fmt.Sprintf("adm is %d bytes, which is more than the limit of %d bytes", len(bid.bid.AdM), s.cfg.MaxMarkupBytes),
+		}}
+	}
+
+	var violations []markupViolation
+	if requiresSecureMarkup(request, bid.bid.ImpID) {
+		if strings.HasPrefix(strings.ToLower(bid.bid.NURL), "http://") {
+			violations = append(violations, markupViolation{
+				kind:    pbsmetrics.MarkupInsecure,
+				message: fmt.Sprintf("nurl \"%s\" is insecure for imp \"%s\", which requires secure creatives", bid.bid.NURL, bid.bid.ImpID),
+			})
+		}
+		if resource := findInsecureResource(bid.bid.AdM, bid.bidType); resource != "" {
+			violations = append(violations, markupViolation{
+				kind:    pbsmetrics.MarkupInsecure,
+				message: fmt.Sprintf("adm loads the insecure resource \"%s\" for imp \"%s\", which requires secure creatives", resource, bid.bid.ImpID),
+			})
+		}
+	}
+
+	for _, script := range s.scripts {
+		if script.MatchString(bid.bid.AdM) {
+			violations = append(violations, markupViolation{
+				kind:    pbsmetrics.MarkupDisallowedScript,
+				message: fmt.Sprintf("adm matches the disallowed script pattern \"%s\"", script.String()),
+			})
+			break
+		}
+	}
+	return violations
+}
+
+// requiresSecureMarkup returns true if the imp has secure=1.
+func requiresSecureMarkup(request *openrtb.BidRequest, impID string) bool {
+	for i := range request.Imp {
+		if request.Imp[i].ID == impID {
+			return request.Imp[i].Secure != nil && *request.Imp[i].Secure == 1
+		}
+	}
+	return false
+}
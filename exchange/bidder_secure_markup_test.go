@@ -0,0 +1,268 @@
+package exchange
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInsecureMarkup(t *testing.T) {
+	testCases := []struct {
+		description string
+		bidType     openrtb_ext.BidType
+		bid         *openrtb.Bid
+		message     string
+	}{
+		{
+			description: "Insecure nurl",
+			bidType:     openrtb_ext.BidTypeBanner,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", NURL: "http://win.bidder.com/notice"},
+			message:     `Bid "bid" rejected: nurl "http://win.bidder.com/notice" is insecure for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure banner image",
+			bidType:     openrtb_ext.BidTypeBanner,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `<a href="https://click.com"><img src='http://cdn.com/ad.png'></a>`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http://cdn.com/ad.png" for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure CSS background",
+			bidType:     openrtb_ext.BidTypeBanner,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `<div style="background: url(http://cdn.com/bg.png)"></div>`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http://cdn.com/bg.png" for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure VAST media file",
+			bidType:     openrtb_ext.BidTypeVideo,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `<VAST version="3.0"><MediaFile><![CDATA[http://cdn.com/ad.mp4]]></MediaFile></VAST>`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http://cdn.com/ad.mp4" for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure native image",
+			bidType:     openrtb_ext.BidTypeNative,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `{"assets":[{"img":{"url":"http:\/\/cdn.com\/img.png"}}]}`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http:\/\/cdn.com\/img.png" for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure image in a srcset",
+			bidType:     openrtb_ext.BidTypeBanner,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `<img srcset="https://cdn.com/ad.png 1x, http://cdn.com/ad@2x.png 2x">`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http://cdn.com/ad@2x.png" for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure VAST tracker",
+			bidType:     openrtb_ext.BidTypeVideo,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `<VAST version="3.0"><TrackingEvents><Tracking event="start">http://track.com/start</Tracking></TrackingEvents></VAST>`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http://track.com/start" for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure native impression tracker",
+			bidType:     openrtb_ext.BidTypeNative,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `{"assets":[],"imptrackers":["https://track.com/a","http://track.com/b"]}`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http://track.com/b" for imp "secure-imp", which requires secure creatives`,
+		},
+		{
+			description: "Insecure native jstracker script",
+			bidType:     openrtb_ext.BidTypeNative,
+			bid:         &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `{"assets":[],"jstracker":"<script src=\"http://track.com/js\"></script>"}`},
+			message:     `Bid "bid" rejected: adm loads the insecure resource "http://track.com/js" for imp "secure-imp", which requires secure creatives`,
+		},
+	}
+
+	for _, test := range testCases {
+		metrics := &pbsmetrics.MetricsEngineMock{}
+		metrics.On("RecordMarkupViolation", openrtb_ext.BidderAppnexus, pbsmetrics.MarkupInsecure).Return()
+
+		seatBid, errs := runSecureMarkupBids(config.SecureMarkup{Mode: config.SecureMarkupEnforce}, metrics, secureMarkupRequest(), &pbsOrtbBid{bid: test.bid, bidType: test.bidType})
+
+		assert.Empty(t, seatBid.bids, test.description)
+		if assert.Len(t, errs, 1, test.description) {
+			assert.EqualError(t, errs[0], test.message, test.description)
+			assert.Equal(t, errortypes.InvalidMarkupErrorCode, errortypes.ReadCode(errs[0]), test.description)
+		}
+		metrics.AssertExpectations(t)
+	}
+}
+
+func TestFindInsecureResource(t *testing.T) {
+	const (
+		html   = `<div style="background: url(https://cdn.com/bg.png)"><img src="http://cdn.com/ad.png"></div>`
+		vast   = `<VAST version="3.0"><Impression><![CDATA[http://track.com/imp]]></Impression><MediaFile>https://cdn.com/ad.mp4</MediaFile></VAST>`
+		native = `{"assets":[{"img":{"url":"https://cdn.com/img.png"}}],"eventtrackers":[{"event":1,"method":1,"url":"http://track.com/event"}]}`
+	)
+	testCases := []struct {
+		description string
+		bidType     openrtb_ext.BidType
+		adm         string
+		resource    string
+	}{
+		{description: "HTML in a banner", bidType: openrtb_ext.BidTypeBanner, adm: html, resource: "http://cdn.com/ad.png"},
+		{description: "VAST in a banner", bidType: openrtb_ext.BidTypeBanner, adm: vast, resource: ""},
+		{description: "Native in a banner", bidType: openrtb_ext.BidTypeBanner, adm: native, resource: ""},
+		{description: "VAST in a video", bidType: openrtb_ext.BidTypeVideo, adm: vast, resource: "http://track.com/imp"},
+		{description: "VAST companion in a video", bidType: openrtb_ext.BidTypeVideo, adm: `<VAST version="3.0"><HTMLResource><![CDATA[<img src="http://cdn.com/companion.png">]]></HTMLResource></VAST>`, resource: "http://cdn.com/companion.png"},
+		{description: "Native in a video", bidType: openrtb_ext.BidTypeVideo, adm: native, resource: ""},
+		{description: "VAST in an audio", bidType: openrtb_ext.BidTypeAudio, adm: vast, resource: "http://track.com/imp"},
+		{description: "HTML in an audio", bidType: openrtb_ext.BidTypeAudio, adm: html, resource: ""},
+		{description: "Native in a native", bidType: openrtb_ext.BidTypeNative, adm: native, resource: "http://track.com/event"},
+		{description: "VAST in a native", bidType: openrtb_ext.BidTypeNative, adm: vast, resource: ""},
+		{description: "HTML of unknown type", bidType: "", adm: html, resource: "http://cdn.com/ad.png"},
+		{description: "VAST of unknown type", bidType: "", adm: vast, resource: "http://track.com/imp"},
+		{description: "Native of unknown type", bidType: "", adm: native, resource: "http://track.com/event"},
+	}
+
+	for _, test := range testCases {
+		assert.Equal(t, test.resource, findInsecureResource(test.adm, test.bidType), test.description)
+	}
+}
+
+func TestSecureMarkupAllowedBids(t *testing.T) {
+	request := secureMarkupRequest()
+	bids := []*openrtb.Bid{
+		{ID: "secure", ImpID: "secure-imp", NURL: "https://win.bidder.com", AdM: `<img src="https://cdn.com/ad.png">`},
+		{ID: "namespace", ImpID: "secure-imp", AdM: `<VAST xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><MediaFile>https://cdn.com/ad.mp4</MediaFile></VAST>`},
+		{ID: "insecure-link", ImpID: "secure-imp", AdM: `<a href="http://landing.com" target="_blank"><img src="https://cdn.com/ad.png"></a>`},
+		{ID: "insecure-click-through", ImpID: "secure-imp", AdM: `<VAST version="3.0"><Linear><VideoClicks><ClickThrough><![CDATA[http://landing.com]]></ClickThrough></VideoClicks><MediaFiles><MediaFile>https://cdn.com/ad.mp4</MediaFile></MediaFiles></Linear></VAST>`},
+		{ID: "insecure-native-link", ImpID: "secure-imp", AdM: `{"link":{"url":"http://landing.com"},"assets":[{"img":{"url":"https://cdn.com/img.png"}}]}`},
+		{ID: "insecure-imp", ImpID: "insecure-imp", NURL: "http://win.bidder.com", AdM: `<img src="http://cdn.com/ad.png">`},
+		{ID: "same-origin-redirect", ImpID: "insecure-imp", AdM: `<script>if (top.location == self.location) {}</script>`},
+	}
+	cfg := config.SecureMarkup{
+		Mode:              config.SecureMarkupEnforce,
+		MaxMarkupBytes:    300,
+		DisallowedScripts: []string{`(?i)\btop\.location(\.href)?\s*=[^=]`},
+	}
+
+	seatBid, errs := runSecureMarkup(cfg, &pbsmetrics.MetricsEngineMock{}, request, bids...)
+
+	assert.Len(t, seatBid.bids, 7)
+	assert.Empty(t, errs)
+}
+
+func TestInsecureNURLAndMarkup(t *testing.T) {
+	metrics := &pbsmetrics.MetricsEngineMock{}
+	metrics.On("RecordMarkupViolation", openrtb_ext.BidderAppnexus, pbsmetrics.MarkupInsecure).Return()
+
+	seatBid, errs := runSecureMarkup(config.SecureMarkup{Mode: config.SecureMarkupWarn}, metrics, secureMarkupRequest(),
+		&openrtb.Bid{ID: "bid", ImpID: "secure-imp", NURL: "http://win.bidder.com", AdM: `<img src="http://cdn.com/ad.png">`})
+
+	assert.Len(t, seatBid.bids, 1)
+	if assert.Len(t, errs, 2, "The adm should be scanned even if the nurl is insecure") {
+		assert.EqualError(t, errs[0], `Bid "bid" allowed, but nurl "http://win.bidder.com" is insecure for imp "secure-imp", which requires secure creatives`)
+		assert.EqualError(t, errs[1], `Bid "bid" allowed, but adm loads the insecure resource "http://cdn.com/ad.png" for imp "secure-imp", which requires secure creatives`)
+	}
+	metrics.AssertNumberOfCalls(t, "RecordMarkupViolation", 2)
+}
+
+func TestOversizedAndScriptMarkup(t *testing.T) {
+	cfg := config.SecureMarkup{
+		Mode:              config.SecureMarkupEnforce,
+		MaxMarkupBytes:    100,
+		DisallowedScripts: []string{`(?i)\btop\.location(\.href)?\s*=[^=]`},
+	}
+	metrics := &pbsmetrics.MetricsEngineMock{}
+	metrics.On("RecordMarkupViolation", openrtb_ext.BidderAppnexus, mock.Anything).Return()
+
+	seatBid, errs := runSecureMarkup(cfg, metrics, secureMarkupRequest(),
+		&openrtb.Bid{ID: "big", ImpID: "insecure-imp", AdM: strings.Repeat("a", 101)},
+		&openrtb.Bid{ID: "redirect", ImpID: "insecure-imp", AdM: `<script>window.top.location.href = "https://scam.com"</script>`},
+	)
+
+	assert.Empty(t, seatBid.bids)
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], `Bid "big" rejected: adm is 101 bytes, which is more than the limit of 100 bytes`)
+		assert.EqualError(t, errs[1], `Bid "redirect" rejected: adm matches the disallowed script pattern "(?i)\btop\.location(\.href)?\s*=[^=]"`)
+	}
+	metrics.AssertCalled(t, "RecordMarkupViolation", openrtb_ext.BidderAppnexus, pbsmetrics.MarkupOversized)
+	metrics.AssertCalled(t, "RecordMarkupViolation", openrtb_ext.BidderAppnexus, pbsmetrics.MarkupDisallowedScript)
+}
+
+func TestSecureMarkupAccountModes(t *testing.T) {
+	cfg := config.SecureMarkup{
+		Mode:         config.SecureMarkupEnforce,
+		AccountModes: map[string]string{"lenient-account": config.SecureMarkupWarn},
+	}
+	bid := &openrtb.Bid{ID: "bid", ImpID: "secure-imp", AdM: `<img src="http://cdn.com/ad.png">`}
+
+	metrics := &pbsmetrics.MetricsEngineMock{}
+	metrics.On("RecordMarkupViolation", openrtb_ext.BidderAppnexus, pbsmetrics.MarkupInsecure).Return()
+
+	request := secureMarkupRequest()
+	request.Site = &openrtb.Site{Publisher: &openrtb.Publisher{ID: "lenient-account"}}
+	seatBid, errs := runSecureMarkup(cfg, metrics, request, bid)
+
+	assert.Len(t, seatBid.bids, 1, "The bid should be kept for an account in warn mode")
+	if assert.Len(t, errs, 1) {
+		assert.EqualError(t, errs[0], `Bid "bid" allowed, but adm loads the insecure resource "http://cdn.com/ad.png" for imp "secure-imp", which requires secure creatives`)
+		assert.Equal(t, errortypes.InvalidMarkupWarningCode, errortypes.ReadCode(errs[0]))
+	}
+
+	request.Site.Publisher.ID = "strict-account"
+	seatBid, errs = runSecureMarkup(cfg, metrics, request, bid)
+	assert.Empty(t, seatBid.bids, "The bid should be removed for an account in the host's mode")
+	assert.Len(t, errs, 1)
+	metrics.AssertNumberOfCalls(t, "RecordMarkupViolation", 2)
+}
+
+func TestSecureMarkupOff(t *testing.T) {
+	bidder := &mockAdaptedBidder{}
+	assert.Equal(t, bidder, ensureSecureMarkup(bidder, openrtb_ext.BidderAppnexus, config.SecureMarkup{Mode: config.SecureMarkupOff}, &pbsmetrics.MetricsEngineMock{}),
+		"The bidder shouldn't be wrapped if the checks are off for every account")
+
+	cfg := config.SecureMarkup{
+		Mode:         config.SecureMarkupOff,
+		AccountModes: map[string]string{"strict-account": config.SecureMarkupEnforce},
+	}
+	seatBid, errs := runSecureMarkup(cfg, &pbsmetrics.MetricsEngineMock{}, secureMarkupRequest(),
+		&openrtb.Bid{ID: "bid", ImpID: "secure-imp", NURL: "http://win.bidder.com"})
+	assert.Len(t, seatBid.bids, 1, "The bids of other accounts shouldn't be checked")
+	assert.Empty(t, errs)
+}
+
+func TestSecureMarkupOffAccount(t *testing.T) {
+	cfg := config.SecureMarkup{
+		Mode:         config.SecureMarkupEnforce,
+		AccountModes: map[string]string{"trusted-account": config.SecureMarkupOff},
+	}
+	request := secureMarkupRequest()
+	request.App = &openrtb.App{Publisher: &openrtb.Publisher{ID: "trusted-account"}}
+
+	seatBid, errs := runSecureMarkup(cfg, &pbsmetrics.MetricsEngineMock{}, request,
+		&openrtb.Bid{ID: "bid", ImpID: "secure-imp", NURL: "http://win.bidder.com", AdM: `<img src="http://cdn.com/ad.png">`})
+	assert.Len(t, seatBid.bids, 1, "The bids of an account in off mode shouldn't be checked")
+	assert.Empty(t, errs)
+}
+
+func secureMarkupRequest() *openrtb.BidRequest {
+	secure := int8(1)
+	return &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "secure-imp", Secure: &secure},
+			{ID: "insecure-imp"},
+		},
+	}
+}
+
+func runSecureMarkup(cfg config.SecureMarkup, metrics pbsmetrics.MetricsEngine, request *openrtb.BidRequest, bids ...*openrtb.Bid) (*pbsOrtbSeatBid, []error) {
+	typedBids := make([]*pbsOrtbBid, 0, len(bids))
+	for _, bid := range bids {
+		typedBids = append(typedBids, &pbsOrtbBid{bid: bid, bidType: openrtb_ext.BidTypeBanner})
+	}
+	return runSecureMarkupBids(cfg, metrics, request, typedBids...)
+}
+
+func runSecureMarkupBids(cfg config.SecureMarkup, metrics pbsmetrics.MetricsEngine, request *openrtb.BidRequest, bids ...*pbsOrtbBid) (*pbsOrtbSeatBid, []error) {
+	seatBid := &pbsOrtbSeatBid{bids: bids}
+	bidder := ensureSecureMarkup(&mockAdaptedBidder{bidResponse: seatBid}, openrtb_ext.BidderAppnexus, cfg, metrics)
+	return bidder.requestBid(context.Background(), request, openrtb_ext.BidderAppnexus, 1.0, currencies.NewConstantRates(), &adapters.ExtraRequestInfo{})
+}
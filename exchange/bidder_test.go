@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -16,6 +17,7 @@ import (
 	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	metricsConfig "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/stretchr/testify/assert"
 
 	nativeRequests "github.com/mxmCherry/openrtb/native/request"
@@ -246,6 +248,42 @@ func TestConnectionClose(t *testing.T) {
 	}
 }
 
+// TestTraceparentHeader makes sure that bidderAdapter.doRequest passes the trace on to the bidder,
+// without changing the adapter's headers.
+func TestTraceparentHeader(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	bidder := &bidderAdapter{
+		Bidder: &mixedMultiBidder{},
+		Client: server.Client(),
+	}
+
+	tracer := tracing.NewTracer(&config.Tracing{SampleRate: 1, BatchSize: 10, FlushInterval: 1000}, tracing.NewWriterExporter(ioutil.Discard))
+	defer tracer.Shutdown()
+	ctx, _ := tracer.StartRootSpan(context.Background(), "auction", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	headers := http.Header{"Content-Type": []string{"application/json"}}
+	bidder.doRequest(ctx, &adapters.RequestData{
+		Method:  "POST",
+		Uri:     server.URL,
+		Headers: headers,
+	})
+
+	assert.Regexp(t, "^00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01$", received)
+	assert.NotContains(t, received, "b7ad6b7169203331", "The bidder's parent should be the http_request span")
+	assert.Equal(t, http.Header{"Content-Type": []string{"application/json"}}, headers, "The adapter's headers shouldn't be modified")
+
+	bidder.doRequest(context.Background(), &adapters.RequestData{
+		Method: "POST",
+		Uri:    server.URL,
+	})
+	assert.Empty(t, received, "Untraced requests shouldn't send a traceparent")
+}
+
 type bid struct {
 	currency string
 	price    float64
@@ -0,0 +1,312 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/mxmCherry/openrtb/native"
+	nativeRequests "github.com/mxmCherry/openrtb/native/request"
+	nativeResponse "github.com/mxmCherry/openrtb/native/response"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/macros"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// ensureValidNative returns a bidder that checks the native bids' markup against the native request of their imp,
+// and adds the host's impression trackers to it.
+//
+// Assets which the request didn't ask for, or which break its rules, are removed from the markup. So are the event
+// trackers whose event or method the request didn't allow. Bids which are left without one of the required assets
+// are removed from the response. Each change is reported with an errortypes.InvalidNative warning.
+//
+// Bids whose markup isn't a native response object are left alone, since some bidders use their own formats.
+func ensureValidNative(bidder adaptedBidder, cfg config.Native) adaptedBidder {
+	if !cfg.Validate && len(cfg.ImpressionTrackers) == 0 {
+		return bidder
+	}
+	return &nativeValidatedBidder{
+		bidder:             bidder,
+		validate:           cfg.Validate,
+		impressionTrackers: parseTrackers(cfg.ImpressionTrackers),
+	}
+}
+
+type nativeValidatedBidder struct {
+	bidder             adaptedBidder
+	validate           bool
+	impressionTrackers []*template.Template
+}
+
+func (v *nativeValidatedBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions, reqInfo *adapters.ExtraRequestInfo) (*pbsOrtbSeatBid, []error) {
+	seatBid, errs := v.bidder.requestBid(ctx, request, name, bidAdjustment, conversions, reqInfo)
+	if seatBid == nil || len(seatBid.bids) == 0 {
+		return seatBid, errs
+	}
+
+	account, _ := toAccountId(request)
+	validBids := make([]*pbsOrtbBid, 0, len(seatBid.bids))
+	for _, bid := range seatBid.bids {
+		if bid.bidType != openrtb_ext.BidTypeNative || bid.bid == nil || bid.bid.AdM == "" {
+			validBids = append(validBids, bid)
+			continue
+		}
+		markup, ok := parseNativeMarkup(bid.bid.AdM)
+		if !ok {
+			validBids = append(validBids, bid)
+			continue
+		}
+
+		changed := false
+		if v.validate {
+			if nativeRequest, ok := nativeRequestForImp(request, bid.bid.ImpID); ok {
+				problems, err := reconcileNativeMarkup(markup.response, nativeRequest)
+				if err != nil {
+					errs = append(errs, &errortypes.InvalidNative{
+						Message: fmt.Sprintf("Bid \"%s\" rejected: %v", bid.bid.ID, err),
+					})
+					continue
+				}
+				for _, problem := range problems {
+					errs = append(errs, &errortypes.InvalidNative{
+						Message: fmt.Sprintf("Bid \"%s\" repaired: %s", bid.bid.ID, problem),
+					})
+				}
+				changed = len(problems) > 0
+			}
+		}
+
+		if len(v.impressionTrackers) > 0 {
+			v.addImpressionTrackers(markup.response, request, name, account, bid.bid)
+			changed = true
+		}
+
+		if changed {
+			adm, err := markup.marshal()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				bid.bid.AdM = adm
+			}
+		}
+		validBids = append(validBids, bid)
+	}
+	seatBid.bids = validBids
+	return seatBid, errs
+}
+
+// addImpressionTrackers adds the host's trackers as impression event trackers if the imp allows image pixels for
+// impressions, or as imptrackers otherwise.
+func (v *nativeValidatedBidder) addImpressionTrackers(markup *nativeResponse.Response, request *openrtb.BidRequest, bidder openrtb_ext.BidderName, account string, bid *openrtb.Bid) {
+	params := macros.TrackerTemplateParams{
+		Bidder:    url.QueryEscape(string(bidder)),
+		AccountID: url.QueryEscape(account),
+		AuctionID: url.QueryEscape(request.ID),
+		BidID:     url.QueryEscape(bid.ID),
+	}
+	nativeRequest, _ := nativeRequestForImp(request, bid.ImpID)
+	useEventTrackers := nativeRequest != nil && allowsEventTracker(nativeRequest.EventTrackers, native.EventTypeImpression, native.EventTrackingMethodImage)
+
+	for _, trackerTemplate := range v.impressionTrackers {
+		tracker, err := macros.ResolveMacros(*trackerTemplate, params)
+		if err != nil {
+			continue
+		}
+		if useEventTrackers {
+			markup.EventTrackers = append(markup.EventTrackers, nativeResponse.EventTracker{
+				Event:  native.EventTypeImpression,
+				Method: native.EventTrackingMethodImage,
+				URL:    tracker,
+			})
+		} else {
+			markup.ImpTrackers = append(markup.ImpTrackers, tracker)
+		}
+	}
+}
+
+// reconcileNativeMarkup removes the assets and the event trackers of the markup which the request didn't allow,
+// and returns a description of each one. It returns an error if the markup lacks one of the required assets.
+func reconcileNativeMarkup(markup *nativeResponse.Response, request *nativeRequests.Request) ([]string, error) {
+	var problems []string
+
+	validAssets := make([]nativeResponse.Asset, 0, len(markup.Assets))
+	for _, asset := range markup.Assets {
+		requested := findNativeAsset(request.Assets, asset.ID)
+		if requested == nil {
+			problems = append(problems, fmt.Sprintf("removed asset %d, which the request didn't ask for", asset.ID))
+			continue
+		}
+		if problem := checkNativeAsset(asset, *requested); problem != "" {
+			problems = append(problems, fmt.Sprintf("removed asset %d, %s", asset.ID, problem))
+			continue
+		}
+		validAssets = append(validAssets, asset)
+	}
+	markup.Assets = validAssets
+
+	for _, requested := range request.Assets {
+		if requested.Required == 1 && !hasNativeAsset(validAssets, requested.ID) {
+			if len(problems) > 0 {
+				return nil, fmt.Errorf("required asset %d is missing or invalid (%s)", requested.ID, strings.Join(problems, "; "))
+			}
+			return nil, fmt.Errorf("required asset %d is missing", requested.ID)
+		}
+	}
+
+	// Requests without eventtrackers leave the bidders free to use the trackers of Native 1.1.
+	if len(request.EventTrackers) > 0 {
+		validTrackers := make([]nativeResponse.EventTracker, 0, len(markup.EventTrackers))
+		for _, tracker := range markup.EventTrackers {
+			if !allowsEventTracker(request.EventTrackers, tracker.Event, tracker.Method) {
+				problems = append(problems, fmt.Sprintf("removed an event tracker with event %d and method %d, which the request doesn't allow", tracker.Event, tracker.Method))
+				continue
+			}
+			validTrackers = append(validTrackers, tracker)
+		}
+		markup.EventTrackers = validTrackers
+	}
+
+	return problems, nil
+}
+
+// checkNativeAsset returns why the asset doesn't fit the requested one, or an empty string if it does.
+func checkNativeAsset(asset nativeResponse.Asset, requested nativeRequests.Asset) string {
+	if kind, requestedKind := nativeResponseAssetKind(asset), nativeRequestAssetKind(requested); kind != requestedKind {
+		return fmt.Sprintf("which is a %s asset rather than a %s one", kind, requestedKind)
+	}
+	if asset.Img == nil || requested.Img == nil || asset.Img.W == 0 || asset.Img.H == 0 {
+		return ""
+	}
+
+	img, requestedImg := asset.Img, requested.Img
+	if requestedImg.WMin > 0 || requestedImg.HMin > 0 {
+		if img.W < requestedImg.WMin || img.H < requestedImg.HMin {
+			return fmt.Sprintf("whose %dx%d image is smaller than the minimum %dx%d", img.W, img.H, requestedImg.WMin, requestedImg.HMin)
+		}
+		return ""
+	}
+	if requestedImg.W > 0 && requestedImg.H > 0 && (img.W != requestedImg.W || img.H != requestedImg.H) {
+		return fmt.Sprintf("whose %dx%d image isn't the requested %dx%d", img.W, img.H, requestedImg.W, requestedImg.H)
+	}
+	return ""
+}
+
+func nativeRequestAssetKind(asset nativeRequests.Asset) string {
+	switch {
+	case asset.Title != nil:
+		return "title"
+	case asset.Img != nil:
+		return "img"
+	case asset.Video != nil:
+		return "video"
+	case asset.Data != nil:
+		return "data"
+	}
+	return "empty"
+}
+
+func nativeResponseAssetKind(asset nativeResponse.Asset) string {
+	switch {
+	case asset.Title != nil:
+		return "title"
+	case asset.Img != nil:
+		return "img"
+	case asset.Video != nil:
+		return "video"
+	case asset.Data != nil:
+		return "data"
+	}
+	return "empty"
+}
+
+func findNativeAsset(assets []nativeRequests.Asset, id int64) *nativeRequests.Asset {
+	for i := range assets {
+		if assets[i].ID == id {
+			return &assets[i]
+		}
+	}
+	return nil
+}
+
+func hasNativeAsset(assets []nativeResponse.Asset, id int64) bool {
+	for _, asset := range assets {
+		if asset.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func allowsEventTracker(trackers []nativeRequests.EventTracker, event native.EventType, method native.EventTrackingMethod) bool {
+	for _, tracker := range trackers {
+		if tracker.Event != event {
+			continue
+		}
+		for _, allowed := range tracker.Methods {
+			if allowed == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nativeRequestForImp returns the native request of the imp, unwrapping the "native" object of Native 1.0 requests.
+func nativeRequestForImp(request *openrtb.BidRequest, impID string) (*nativeRequests.Request, bool) {
+	nativeImp, err := getNativeImpByImpID(impID, request)
+	if err != nil {
+		return nil, false
+	}
+	var wrapper struct {
+		Native *nativeRequests.Request `json:"native"`
+	}
+	if err := json.Unmarshal([]byte(nativeImp.Request), &wrapper); err == nil && wrapper.Native != nil {
+		return wrapper.Native, true
+	}
+	var nativeRequest nativeRequests.Request
+	if err := json.Unmarshal([]byte(nativeImp.Request), &nativeRequest); err != nil {
+		return nil, false
+	}
+	return &nativeRequest, true
+}
+
+// nativeMarkup is a bid's native response, along with whether the bidder wrapped it in a "native" object
+// like Native 1.0 responses do.
+type nativeMarkup struct {
+	response *nativeResponse.Response
+	wrapped  bool
+}
+
+func parseNativeMarkup(adm string) (*nativeMarkup, bool) {
+	var wrapper struct {
+		Native *nativeResponse.Response `json:"native"`
+	}
+	if err := json.Unmarshal([]byte(adm), &wrapper); err == nil && wrapper.Native != nil {
+		return &nativeMarkup{response: wrapper.Native, wrapped: true}, true
+	}
+	var response nativeResponse.Response
+	if err := json.Unmarshal([]byte(adm), &response); err != nil || len(response.Assets) == 0 {
+		return nil, false
+	}
+	return &nativeMarkup{response: &response}, true
+}
+
+func (m *nativeMarkup) marshal() (string, error) {
+	var adm []byte
+	var err error
+	if m.wrapped {
+		adm, err = json.Marshal(struct {
+			Native *nativeResponse.Response `json:"native"`
+		}{m.response})
+	} else {
+		adm, err = json.Marshal(m.response)
+	}
+	return string(adm), err
+}
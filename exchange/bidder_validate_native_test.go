@@ -0,0 +1,146 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	nativeRequests "github.com/mxmCherry/openrtb/native/request"
+	nativeResponse "github.com/mxmCherry/openrtb/native/response"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+const testNativeRequest = `{"ver":"1.2","assets":[` +
+	`{"id":1,"required":1,"title":{"len":90}},` +
+	`{"id":2,"required":1,"img":{"type":3,"wmin":300,"hmin":250}},` +
+	`{"id":3,"img":{"type":1,"w":50,"h":50}},` +
+	`{"id":4,"data":{"type":2}}],` +
+	`"eventtrackers":[{"event":1,"methods":[1]},{"event":2,"methods":[1,2]}]}`
+
+func TestReconcileNativeMarkup(t *testing.T) {
+	testCases := []struct {
+		description      string
+		markup           string
+		expectedMarkup   string
+		expectedProblems []string
+		expectedErr      string
+	}{
+		{
+			description:    "Markup which fits the request should be unchanged",
+			markup:         `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg","w":600,"h":500}},{"id":3,"img":{"url":"https://img.example.com/icon.jpg","w":50,"h":50}}],"link":{"url":"https://example.com"},"eventtrackers":[{"event":1,"method":1,"url":"https://t.example.com/imp"},{"event":2,"method":2,"url":"https://t.example.com/om.js"}]}`,
+			expectedMarkup: `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg","w":600,"h":500}},{"id":3,"img":{"url":"https://img.example.com/icon.jpg","w":50,"h":50}}],"link":{"url":"https://example.com"},"eventtrackers":[{"event":1,"method":1,"url":"https://t.example.com/imp"},{"event":2,"method":2,"url":"https://t.example.com/om.js"}]}`,
+		},
+		{
+			description:    "Unknown and mismatched assets should be removed",
+			markup:         `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}},{"id":4,"title":{"text":"Not data"}},{"id":9,"data":{"value":"Unknown"}}],"link":{"url":"https://example.com"}}`,
+			expectedMarkup: `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}}],"link":{"url":"https://example.com"}}`,
+			expectedProblems: []string{
+				"removed asset 4, which is a title asset rather than a data one",
+				"removed asset 9, which the request didn't ask for",
+			},
+		},
+		{
+			description:      "Images of the wrong size should be removed",
+			markup:           `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg","w":300,"h":250}},{"id":3,"img":{"url":"https://img.example.com/icon.jpg","w":100,"h":100}}],"link":{"url":"https://example.com"}}`,
+			expectedMarkup:   `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg","w":300,"h":250}}],"link":{"url":"https://example.com"}}`,
+			expectedProblems: []string{"removed asset 3, whose 100x100 image isn't the requested 50x50"},
+		},
+		{
+			description:    "Event trackers which the request doesn't allow should be removed",
+			markup:         `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}}],"link":{"url":"https://example.com"},"eventtrackers":[{"event":1,"method":2,"url":"https://t.example.com/imp.js"},{"event":3,"method":1,"url":"https://t.example.com/view"},{"event":1,"method":1,"url":"https://t.example.com/imp"}]}`,
+			expectedMarkup: `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}}],"link":{"url":"https://example.com"},"eventtrackers":[{"event":1,"method":1,"url":"https://t.example.com/imp"}]}`,
+			expectedProblems: []string{
+				"removed an event tracker with event 1 and method 2, which the request doesn't allow",
+				"removed an event tracker with event 3 and method 1, which the request doesn't allow",
+			},
+		},
+		{
+			description: "Markup without a required asset should be rejected",
+			markup:      `{"assets":[{"id":2,"img":{"url":"https://img.example.com/main.jpg"}}],"link":{"url":"https://example.com"}}`,
+			expectedErr: "required asset 1 is missing",
+		},
+		{
+			description: "Markup whose required image is too small should be rejected",
+			markup:      `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg","w":150,"h":125}}],"link":{"url":"https://example.com"}}`,
+			expectedErr: "required asset 2 is missing or invalid (removed asset 2, whose 150x125 image is smaller than the minimum 300x250)",
+		},
+	}
+
+	var request nativeRequests.Request
+	if err := json.Unmarshal([]byte(testNativeRequest), &request); err != nil {
+		t.Fatalf("Failed to unmarshal the native request: %v", err)
+	}
+
+	for _, test := range testCases {
+		var markup nativeResponse.Response
+		if err := json.Unmarshal([]byte(test.markup), &markup); err != nil {
+			t.Fatalf("%s: failed to unmarshal the markup: %v", test.description, err)
+		}
+		problems, err := reconcileNativeMarkup(&markup, &request)
+		if test.expectedErr != "" {
+			assert.EqualError(t, err, test.expectedErr, test.description)
+			continue
+		}
+		if assert.NoError(t, err, test.description) {
+			assert.Equal(t, test.expectedProblems, problems, test.description)
+			actual, _ := json.Marshal(markup)
+			assert.JSONEq(t, test.expectedMarkup, string(actual), test.description)
+		}
+	}
+}
+
+func TestEnsureValidNative(t *testing.T) {
+	mockBidder := &mockAdaptedBidder{bidResponse: &pbsOrtbSeatBid{
+		bids: []*pbsOrtbBid{
+			{bid: &openrtb.Bid{ID: "valid", ImpID: "imp-1", AdM: `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}}],"link":{"url":"https://example.com"}}`}, bidType: openrtb_ext.BidTypeNative},
+			{bid: &openrtb.Bid{ID: "repaired", ImpID: "imp-1", AdM: `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}},{"id":9,"data":{"value":"Unknown"}}],"link":{"url":"https://example.com"}}`}, bidType: openrtb_ext.BidTypeNative},
+			{bid: &openrtb.Bid{ID: "rejected", ImpID: "imp-1", AdM: `{"assets":[{"id":1,"title":{"text":"Title"}}],"link":{"url":"https://example.com"}}`}, bidType: openrtb_ext.BidTypeNative},
+			{bid: &openrtb.Bid{ID: "legacy", ImpID: "imp-2", AdM: `{"native":{"assets":[{"id":1,"title":{"text":"Title"}}],"link":{"url":"https://example.com"}}}`}, bidType: openrtb_ext.BidTypeNative},
+			{bid: &openrtb.Bid{ID: "custom", ImpID: "imp-1", AdM: `<div>Not native JSON</div>`}, bidType: openrtb_ext.BidTypeNative},
+			{bid: &openrtb.Bid{ID: "banner", ImpID: "imp-1", AdM: `<div>`}, bidType: openrtb_ext.BidTypeBanner},
+		},
+	}}
+	bidder := ensureValidNative(mockBidder, config.Native{
+		Validate:           true,
+		ImpressionTrackers: []string{"https://t.example.com/imp?bidder={{.Bidder}}&account={{.AccountID}}&auction={{.AuctionID}}&bid={{.BidID}}"},
+	})
+	request := &openrtb.BidRequest{
+		ID:   "auction-1",
+		Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "acct-1"}},
+		Imp: []openrtb.Imp{
+			{ID: "imp-1", Native: &openrtb.Native{Request: testNativeRequest}},
+			{ID: "imp-2", Native: &openrtb.Native{Request: `{"native":{"ver":"1.0","assets":[{"id":1,"required":1,"title":{"len":25}}]}}`}},
+		},
+	}
+
+	seatBid, errs := bidder.requestBid(context.Background(), request, "appnexus", 1, currencies.NewConstantRates(), nil)
+
+	bids := make(map[string]string, len(seatBid.bids))
+	for _, bid := range seatBid.bids {
+		bids[bid.bid.ID] = bid.bid.AdM
+	}
+	assert.Len(t, bids, 5)
+	assert.NotContains(t, bids, "rejected")
+	assert.JSONEq(t, `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}}],"link":{"url":"https://example.com"},"eventtrackers":[{"event":1,"method":1,"url":"https://t.example.com/imp?bidder=appnexus&account=acct-1&auction=auction-1&bid=valid"}]}`, bids["valid"])
+	assert.JSONEq(t, `{"assets":[{"id":1,"title":{"text":"Title"}},{"id":2,"img":{"url":"https://img.example.com/main.jpg"}}],"link":{"url":"https://example.com"},"eventtrackers":[{"event":1,"method":1,"url":"https://t.example.com/imp?bidder=appnexus&account=acct-1&auction=auction-1&bid=repaired"}]}`, bids["repaired"])
+	assert.JSONEq(t, `{"native":{"assets":[{"id":1,"title":{"text":"Title"}}],"link":{"url":"https://example.com"},"imptrackers":["https://t.example.com/imp?bidder=appnexus&account=acct-1&auction=auction-1&bid=legacy"]}}`, bids["legacy"])
+	assert.Equal(t, `<div>Not native JSON</div>`, bids["custom"])
+	assert.Equal(t, `<div>`, bids["banner"])
+
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], `Bid "repaired" repaired: removed asset 9, which the request didn't ask for`)
+		assert.Equal(t, errortypes.InvalidNativeWarningCode, errortypes.ReadCode(errs[0]))
+		assert.EqualError(t, errs[1], `Bid "rejected" rejected: required asset 2 is missing`)
+		assert.Equal(t, errortypes.InvalidNativeWarningCode, errortypes.ReadCode(errs[1]))
+	}
+}
+
+func TestEnsureValidNativeDisabled(t *testing.T) {
+	mockBidder := &mockAdaptedBidder{}
+	assert.Equal(t, mockBidder, ensureValidNative(mockBidder, config.Native{}))
+}
@@ -0,0 +1,86 @@
+package exchange
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// ensureValidVAST returns a bidder that removes the video and audio bids whose adm isn't well-formed VAST XML.
+// Audio bids may also use DAAST. Bids without an adm are left alone, since their VAST comes from the nurl.
+func ensureValidVAST(bidder adaptedBidder, cfg config.VAST) adaptedBidder {
+	if !cfg.ValidateXML {
+		return bidder
+	}
+	return &vastValidatedBidder{bidder: bidder}
+}
+
+type vastValidatedBidder struct {
+	bidder adaptedBidder
+}
+
+func (v *vastValidatedBidder) requestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions, reqInfo *adapters.ExtraRequestInfo) (*pbsOrtbSeatBid, []error) {
+	seatBid, errs := v.bidder.requestBid(ctx, request, name, bidAdjustment, conversions, reqInfo)
+	if seatBid == nil || len(seatBid.bids) == 0 {
+		return seatBid, errs
+	}
+
+	validBids := make([]*pbsOrtbBid, 0, len(seatBid.bids))
+	for _, bid := range seatBid.bids {
+		if hasVASTMarkup(bid.bidType) && bid.bid.AdM != "" {
+			if err := validateVASTXML(bid.bid.AdM, bid.bidType); err != nil {
+				errs = append(errs, &errortypes.InvalidVAST{
+					Message: fmt.Sprintf("Bid \"%s\" rejected: its VAST XML is malformed: %v", bid.bid.ID, err),
+				})
+				continue
+			}
+		}
+		validBids = append(validBids, bid)
+	}
+	seatBid.bids = validBids
+	return seatBid, errs
+}
+
+// validateVASTXML makes sure that the adm is well-formed XML, and that its root element is <VAST>.
+// The root of audio bids may be <DAAST> as well.
+func validateVASTXML(adm string, bidType openrtb_ext.BidType) error {
+	decoder := xml.NewDecoder(strings.NewReader(adm))
+	// The declared encoding doesn't matter for well-formedness, and JSON responses are always UTF-8 anyway.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok && root == "" {
+			root = start.Name.Local
+		}
+	}
+	if root == "" {
+		return errors.New("it has no elements")
+	}
+	if root == "DAAST" && bidType == openrtb_ext.BidTypeAudio {
+		return nil
+	}
+	if root != "VAST" {
+		return fmt.Errorf("its root element is <%s> rather than <VAST>", root)
+	}
+	return nil
+}
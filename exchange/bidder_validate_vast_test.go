@@ -0,0 +1,107 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateVASTXML(t *testing.T) {
+	testCases := []struct {
+		description string
+		adm         string
+		bidType     openrtb_ext.BidType
+		expectedErr string
+	}{
+		{
+			description: "Well-formed VAST should be valid",
+			adm:         `<?xml version="1.0" encoding="UTF-8"?><VAST version="3.0"><Ad id="1"><InLine><AdSystem>test</AdSystem></InLine></Ad></VAST>`,
+		},
+		{
+			description: "Other encodings should be accepted",
+			adm:         `<?xml version="1.0" encoding="ISO-8859-1"?><VAST version="2.0"></VAST>`,
+		},
+		{
+			description: "Unclosed elements should be malformed",
+			adm:         `<VAST version="3.0"><Ad><InLine></Ad></VAST>`,
+			expectedErr: "XML syntax error on line 1: element <InLine> closed by </Ad>",
+		},
+		{
+			description: "Truncated VAST should be malformed",
+			adm:         `<VAST version="3.0"><Ad>`,
+			expectedErr: "XML syntax error on line 1: unexpected EOF",
+		},
+		{
+			description: "Other documents should be rejected",
+			adm:         `<html><body></body></html>`,
+			expectedErr: "its root element is <html> rather than <VAST>",
+		},
+		{
+			description: "Audio bids may use DAAST",
+			adm:         `<DAAST version="1.0"><Ad id="1"><InLine><AdSystem>test</AdSystem></InLine></Ad></DAAST>`,
+			bidType:     openrtb_ext.BidTypeAudio,
+		},
+		{
+			description: "Video bids may not use DAAST",
+			adm:         `<DAAST version="1.0"></DAAST>`,
+			bidType:     openrtb_ext.BidTypeVideo,
+			expectedErr: "its root element is <DAAST> rather than <VAST>",
+		},
+		{
+			description: "Plain text should be rejected",
+			adm:         `https://vast.example.com/ad.xml`,
+			expectedErr: "it has no elements",
+		},
+	}
+
+	for _, test := range testCases {
+		bidType := test.bidType
+		if bidType == "" {
+			bidType = openrtb_ext.BidTypeVideo
+		}
+		err := validateVASTXML(test.adm, bidType)
+		if test.expectedErr == "" {
+			assert.NoError(t, err, test.description)
+		} else {
+			assert.EqualError(t, err, test.expectedErr, test.description)
+		}
+	}
+}
+
+func TestEnsureValidVAST(t *testing.T) {
+	mockBidder := &mockAdaptedBidder{bidResponse: &pbsOrtbSeatBid{
+		bids: []*pbsOrtbBid{
+			{bid: &openrtb.Bid{ID: "valid", AdM: `<VAST version="3.0"></VAST>`}, bidType: openrtb_ext.BidTypeVideo},
+			{bid: &openrtb.Bid{ID: "malformed", AdM: `<VAST version="3.0">`}, bidType: openrtb_ext.BidTypeVideo},
+			{bid: &openrtb.Bid{ID: "nurl", NURL: "https://win.example.com"}, bidType: openrtb_ext.BidTypeVideo},
+			{bid: &openrtb.Bid{ID: "banner", AdM: `<div>`}, bidType: openrtb_ext.BidTypeBanner},
+			{bid: &openrtb.Bid{ID: "audio", AdM: `<DAAST version="1.0"></DAAST>`}, bidType: openrtb_ext.BidTypeAudio},
+			{bid: &openrtb.Bid{ID: "malformed-audio", AdM: `<DAAST>`}, bidType: openrtb_ext.BidTypeAudio},
+		},
+	}}
+	bidder := ensureValidVAST(mockBidder, config.VAST{ValidateXML: true})
+
+	seatBid, errs := bidder.requestBid(context.Background(), &openrtb.BidRequest{}, "appnexus", 1, currencies.NewConstantRates(), nil)
+
+	bidIDs := make([]string, 0, len(seatBid.bids))
+	for _, bid := range seatBid.bids {
+		bidIDs = append(bidIDs, bid.bid.ID)
+	}
+	assert.Equal(t, []string{"valid", "nurl", "banner", "audio"}, bidIDs)
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], `Bid "malformed" rejected: its VAST XML is malformed: XML syntax error on line 1: unexpected EOF`)
+		assert.Equal(t, errortypes.InvalidVASTErrorCode, errortypes.ReadCode(errs[0]))
+		assert.EqualError(t, errs[1], `Bid "malformed-audio" rejected: its VAST XML is malformed: XML syntax error on line 1: unexpected EOF`)
+	}
+}
+
+func TestEnsureValidVASTDisabled(t *testing.T) {
+	mockBidder := &mockAdaptedBidder{}
+	assert.Equal(t, mockBidder, ensureValidVAST(mockBidder, config.VAST{}))
+}
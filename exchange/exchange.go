@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/prebid_cache_client"
+	"github.com/prebid/prebid-server/tracing"
 )
 
 // Exchange runs Auctions. Implementations must be threadsafe, and will be shared across many goroutines.
@@ -49,12 +51,19 @@ type exchange struct {
 	UsersyncIfAmbiguous bool
 	defaultTTLs         config.DefaultTTLs
 	privacyConfig       config.Privacy
+	throttler           *bidderThrottler
+	targeting           config.Targeting
+	vastWrapper         *vastWrapper
+	bidderInfos         adapters.BidderInfos
 }
 
 // Container to pass out response ext data from the GetAllBids goroutines back into the main thread
 type seatResponseExtra struct {
 	ResponseTimeMillis int
 	Errors             []openrtb_ext.ExtBidderError
+	// Warnings are the problems which didn't keep the bidder's bids from the auction, like bids which were repaired.
+	// This will become response.ext.warnings.{bidder} on the final Response.
+	Warnings []openrtb_ext.ExtBidderError
 	// httpCalls is the list of debugging info. It should only be populated if the request.test == 1.
 	// This will become response.ext.debug.httpcalls.{bidder} on the final Response.
 	HttpCalls []*openrtb_ext.ExtHttpCall
@@ -82,6 +91,10 @@ func NewExchange(client *http.Client, cache prebid_cache_client.Client, cfg *con
 		GDPR: cfg.GDPR,
 		LMT:  cfg.LMT,
 	}
+	e.throttler = newBidderThrottler(cfg.Throttling)
+	e.targeting = cfg.Targeting
+	e.vastWrapper = newVASTWrapper(cfg.VAST)
+	e.bidderInfos = infos
 	return e
 }
 
@@ -102,9 +115,12 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 		e.me.RecordImps(impLabels)
 	}
 
+	trace := tracing.AuctionTraceFromContext(ctx)
+
 	// Slice of BidRequests, each a copy of the original cleaned to only contain bidder data for the named bidder
 	blabels := make(map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels)
-	cleanRequests, aliases, errs := cleanOpenRTBRequests(ctx, bidRequest, usersyncs, blabels, labels, e.gDPR, e.UsersyncIfAmbiguous, e.privacyConfig)
+	cleanRequests, aliases, throttled, errs := cleanOpenRTBRequests(ctx, bidRequest, usersyncs, blabels, labels, e.gDPR, e.UsersyncIfAmbiguous, e.privacyConfig, e.throttler, e.bidderInfos)
+	e.recordThrottled(throttled, aliases, labels)
 
 	// List of bidders we have requests for.
 	liveAdapters := listBiddersWithRequests(cleanRequests)
@@ -114,6 +130,7 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 	shouldCacheBids := false
 	shouldCacheVAST := false
 	var bidAdjustmentFactors map[string]float64
+	var bidAdjustments *openrtb_ext.ExtRequestPrebidBidAdjustments
 	var requestExt openrtb_ext.ExtRequest
 	if len(bidRequest.Ext) > 0 {
 		err := json.Unmarshal(bidRequest.Ext, &requestExt)
@@ -121,6 +138,7 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 			return nil, fmt.Errorf("Error decoding Request.ext : %s", err.Error())
 		}
 		bidAdjustmentFactors = requestExt.Prebid.BidAdjustmentFactors
+		bidAdjustments = requestExt.Prebid.BidAdjustments
 		if requestExt.Prebid.Cache != nil {
 			shouldCacheBids = requestExt.Prebid.Cache.Bids != nil
 			shouldCacheVAST = requestExt.Prebid.Cache.VastXML != nil
@@ -133,6 +151,15 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 				includeBidderKeys: requestExt.Prebid.Targeting.IncludeBidderKeys,
 				includeCacheBids:  shouldCacheBids,
 				includeCacheVast:  shouldCacheVAST,
+				prefix:            requestExt.Prebid.Targeting.Prefix,
+				maxKeyLength:      e.targeting.MaxKeyLengthForAccount(labels.PubID),
+				includeFormat:     requestExt.Prebid.Targeting.IncludeFormat,
+				includeADomain:    requestExt.Prebid.Targeting.IncludeADomain,
+				includeSource:     requestExt.Prebid.Targeting.IncludeSource,
+				includeCrID:       requestExt.Prebid.Targeting.IncludeCrID,
+				vastWrapper:       e.vastWrapper,
+				account:           labels.PubID,
+				aliases:           aliases,
 			}
 			targData.cacheHost, targData.cachePath = e.cache.GetExtCacheData()
 		}
@@ -146,7 +173,7 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 	// Get currency rates conversions for the auction
 	conversions := e.currencyConverter.Rates()
 
-	adapterBids, adapterExtra, anyBidsReturned := e.getAllBids(auctionCtx, cleanRequests, aliases, bidAdjustmentFactors, blabels, conversions)
+	adapterBids, adapterExtra, anyBidsReturned := e.getAllBids(auctionCtx, cleanRequests, aliases, bidAdjustmentFactors, bidAdjustments, blabels, conversions)
 
 	var auc *auction = nil
 	var bidResponseExt *openrtb_ext.ExtBidResponse = nil
@@ -157,13 +184,18 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 		if requestExt.Prebid.Targeting != nil && requestExt.Prebid.Targeting.IncludeBrandCategory != nil {
 			var err error
 			var rejections []string
-			bidCategory, adapterBids, rejections, err = applyCategoryMapping(ctx, requestExt, adapterBids, *categoriesFetcher, targData)
+			categoryCtx, span := tracing.StartSpan(ctx, "category_mapping")
+			bidCategory, adapterBids, rejections, err = applyCategoryMapping(categoryCtx, requestExt, adapterBids, *categoriesFetcher, targData)
+			span.SetError(err)
+			span.End()
 			if err != nil {
 				return nil, fmt.Errorf("Error in category mapping : %s", err.Error())
 			}
 			for _, message := range rejections {
-				errs = append(errs, errors.New(message))
+				errs = append(errs, &errortypes.CategoryRejection{Message: message})
+				trace.Basic("category_mapping", "", "%s", message)
 			}
+			traceCategories(trace, bidCategory)
 		}
 
 		auc = newAuction(adapterBids, len(bidRequest.Imp))
@@ -186,25 +218,45 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 				}
 			}
 
-			cacheErrs := auc.doCache(ctx, e.cache, targData, bidRequest, 60, &e.defaultTTLs, bidCategory, debugLog)
+			cacheErrs := cacheWarnings(auc.doCache(ctx, e.cache, targData, bidRequest, 60, &e.defaultTTLs, bidCategory, debugLog))
 			if len(cacheErrs) > 0 {
 				errs = append(errs, cacheErrs...)
 			}
+			traceCache(trace, auc, targData, cacheErrs)
 			targData.setTargeting(auc, bidRequest.App != nil, bidCategory)
 
-			// Ensure caching errors are added if the bid response ext has already been created
+			// Ensure caching warnings are added if the bid response ext has already been created
 			if bidResponseExt != nil && len(cacheErrs) > 0 {
 				bidderCacheErrs := errsToBidderErrors(cacheErrs)
-				bidResponseExt.Errors[openrtb_ext.PrebidExtKey] = append(bidResponseExt.Errors[openrtb_ext.PrebidExtKey], bidderCacheErrs...)
+				bidResponseExt.Warnings[openrtb_ext.PrebidExtKey] = append(bidResponseExt.Warnings[openrtb_ext.PrebidExtKey], bidderCacheErrs...)
 			}
 		}
 
 	}
 
+	if bidRequest.Test == 1 && len(throttled) > 0 {
+		if bidResponseExt == nil {
+			bidResponseExt = e.makeExtBidResponse(adapterBids, adapterExtra, bidRequest, resolvedRequest, errs)
+		}
+		bidResponseExt.Debug.Throttled = throttled
+	}
+
 	// Build the response
 	return e.buildBidResponse(ctx, liveAdapters, adapterBids, bidRequest, resolvedRequest, adapterExtra, auc, bidResponseExt, errs)
 }
 
+func (e *exchange) recordThrottled(throttled map[openrtb_ext.BidderName][]string, aliases map[string]string, labels pbsmetrics.Labels) {
+	for bidder := range throttled {
+		e.me.RecordAdapterThrottled(pbsmetrics.AdapterLabels{
+			Source:  labels.Source,
+			RType:   labels.RType,
+			Adapter: resolveBidder(bidder.String(), aliases),
+			PubID:   labels.PubID,
+			Browser: labels.Browser,
+		})
+	}
+}
+
 type DealTierInfo struct {
 	Prefix      string `json:"prefix"`
 	MinDealTier int    `json:"minDealTier"`
@@ -232,7 +284,9 @@ func applyDealSupport(bidRequest *openrtb.BidRequest, auc *auction, bidCategory
 				if validateAndNormalizeDealTier(impDeal[bidderString]) {
 					updateHbPbCatDur(topBidPerBidder, impDeal[bidderString].Info, bidCategory)
 				} else {
-					errs = append(errs, fmt.Errorf("dealTier configuration invalid for bidder '%s', imp ID '%s'", bidderString, impID))
+					errs = append(errs, &errortypes.InvalidDealTier{
+						Message: fmt.Sprintf("dealTier configuration invalid for bidder '%s', imp ID '%s'", bidderString, impID),
+					})
 				}
 			}
 		}
@@ -293,7 +347,7 @@ func (e *exchange) makeAuctionContext(ctx context.Context, needsCache bool) (auc
 }
 
 // This piece sends all the requests to the bidder adapters and gathers the results.
-func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, bidAdjustments map[string]float64, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, conversions currencies.Conversions) (map[openrtb_ext.BidderName]*pbsOrtbSeatBid, map[openrtb_ext.BidderName]*seatResponseExtra, bool) {
+func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, bidAdjustmentFactors map[string]float64, bidAdjustments *openrtb_ext.ExtRequestPrebidBidAdjustments, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, conversions currencies.Conversions) (map[openrtb_ext.BidderName]*pbsOrtbSeatBid, map[openrtb_ext.BidderName]*seatResponseExtra, bool) {
 	// Set up pointers to the bid results
 	adapterBids := make(map[openrtb_ext.BidderName]*pbsOrtbSeatBid, len(cleanRequests))
 	adapterExtra := make(map[openrtb_ext.BidderName]*seatResponseExtra, len(cleanRequests))
@@ -318,12 +372,24 @@ func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext
 			start := time.Now()
 
 			adjustmentFactor := 1.0
-			if givenAdjustment, ok := bidAdjustments[string(aName)]; ok {
+			if givenAdjustment, ok := bidAdjustmentFactors[string(aName)]; ok {
 				adjustmentFactor = givenAdjustment
 			}
 			var reqInfo adapters.ExtraRequestInfo
 			reqInfo.PbsEntryPoint = bidlabels.RType
-			bids, err := e.adapterMap[coreBidder].requestBid(ctx, request, aName, adjustmentFactor, conversions, &reqInfo)
+			bidderCtx, span := tracing.StartSpan(ctx, "bidder")
+			span.SetAttribute("bidder", string(aName))
+			bids, err := e.adapterMap[coreBidder].requestBid(bidderCtx, request, aName, adjustmentFactor, conversions, &reqInfo)
+			traceFilteredImps(bidderCtx, aName, err)
+			err = append(err, applyBidAdjustments(bidderCtx, bids, aName, bidAdjustments, conversions)...)
+			traceBids(bidderCtx, aName, bids)
+			if bids != nil {
+				span.SetAttribute("bids", strconv.Itoa(len(bids.bids)))
+			}
+			if len(err) > 0 {
+				span.SetError(err[0])
+			}
+			span.End()
 
 			// Add in time reporting
 			elapsed := time.Since(start)
@@ -337,11 +403,11 @@ func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext
 
 			// Timing statistics
 			e.me.RecordAdapterTime(*bidlabels, time.Since(start))
-			serr := errsToBidderErrors(err)
 			bidlabels.AdapterBids = bidsToMetric(brw.adapterBids)
 			bidlabels.AdapterErrors = errorsToMetric(err)
 			// Append any bid validation errors to the error list
-			ae.Errors = serr
+			ae.Errors = errsToBidderErrors(errortypes.FatalOnly(err))
+			ae.Warnings = errsToBidderErrors(errortypes.WarningOnly(err))
 			brw.adapterExtra = ae
 			if bids != nil {
 				for _, bid := range bids.bids {
@@ -425,6 +491,10 @@ func errorsToMetric(errs []error) map[pbsmetrics.AdapterError]struct{} {
 			ret[pbsmetrics.AdapterErrorBadServerResponse] = s
 		case errortypes.FailedToRequestBidsErrorCode:
 			ret[pbsmetrics.AdapterErrorFailedToRequestBids] = s
+		case errortypes.BlockedBidErrorCode, errortypes.BlockedBidWarningCode, errortypes.InvalidMarkupErrorCode, errortypes.InvalidMarkupWarningCode:
+			// These are problems with the bids themselves, which RecordAdQualityRejection and RecordMarkupViolation count.
+		case errortypes.InvalidNativeWarningCode, errortypes.UnsupportedMediaTypeWarningCode, errortypes.BidAdjustmentWarningCode:
+			// These aren't errors of the bidder. They're reported in the response's ext.warnings.
 		default:
 			ret[pbsmetrics.AdapterErrorUnknown] = s
 		}
@@ -432,6 +502,60 @@ func errorsToMetric(errs []error) map[pbsmetrics.AdapterError]struct{} {
 	return ret
 }
 
+// traceFilteredImps records the imps and media types which weren't sent to the bidder, since it doesn't support them.
+func traceFilteredImps(ctx context.Context, bidder openrtb_ext.BidderName, errs []error) {
+	trace := tracing.AuctionTraceFromContext(ctx)
+	for _, err := range errs {
+		if errortypes.ReadCode(err) == errortypes.UnsupportedMediaTypeWarningCode {
+			trace.Basic("imp_filtering", bidder, "%s", err.Error())
+		}
+	}
+}
+
+// traceBids records how many of the bidder's bids are left for the auction.
+func traceBids(ctx context.Context, bidder openrtb_ext.BidderName, seatBid *pbsOrtbSeatBid) {
+	numBids := 0
+	if seatBid != nil {
+		numBids = len(seatBid.bids)
+	}
+	tracing.AuctionTraceFromContext(ctx).Basic("bidder", bidder, "returned %d bids", numBids)
+}
+
+// traceCategories records the category key of each bid, in the order of the bid IDs.
+func traceCategories(trace *tracing.AuctionTrace, bidCategory map[string]string) {
+	if trace == nil {
+		return
+	}
+	bidIDs := make([]string, 0, len(bidCategory))
+	for bidID := range bidCategory {
+		bidIDs = append(bidIDs, bidID)
+	}
+	sort.Strings(bidIDs)
+	for _, bidID := range bidIDs {
+		trace.Verbose("category_mapping", "", "bid %s got the category key %s", bidID, bidCategory[bidID])
+	}
+}
+
+// traceCache records whether the bids of the auction were cached.
+func traceCache(trace *tracing.AuctionTrace, auc *auction, targData *targetData, cacheErrs []error) {
+	if trace == nil || !((targData.includeCacheBids || targData.includeCacheVast) && (targData.includeBidderKeys || targData.includeWinners)) {
+		return
+	}
+	for _, err := range cacheErrs {
+		trace.Basic("cache", "", "%s", err.Error())
+	}
+	trace.Basic("cache", "", "cached %d bids and %d VAST documents", len(auc.cacheIds), len(auc.vastCacheIds))
+}
+
+// cacheWarnings turns the errors of the cache into warnings, since the bids are still returned without their cache IDs.
+func cacheWarnings(errs []error) []error {
+	warnings := make([]error, 0, len(errs))
+	for _, err := range errs {
+		warnings = append(warnings, &errortypes.CacheWarning{Message: err.Error()})
+	}
+	return warnings
+}
+
 func errsToBidderErrors(errs []error) []openrtb_ext.ExtBidderError {
 	serr := make([]openrtb_ext.ExtBidderError, len(errs))
 	for i := 0; i < len(errs); i++ {
@@ -468,6 +592,7 @@ func (e *exchange) buildBidResponse(ctx context.Context, liveAdapters []openrtb_
 	if bidResponseExt == nil {
 		bidResponseExt = e.makeExtBidResponse(adapterBids, adapterExtra, bidRequest, resolvedRequest, errList)
 	}
+	bidResponseExt.Trace = tracing.AuctionTraceFromContext(ctx).Events()
 	buffer := &bytes.Buffer{}
 	enc := json.NewEncoder(buffer)
 	enc.SetEscapeHTML(false)
@@ -656,6 +781,7 @@ func getPrimaryAdServer(adServerId int) (string, error) {
 func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*pbsOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*seatResponseExtra, req *openrtb.BidRequest, resolvedRequest json.RawMessage, errList []error) *openrtb_ext.ExtBidResponse {
 	bidResponseExt := &openrtb_ext.ExtBidResponse{
 		Errors:               make(map[openrtb_ext.BidderName][]openrtb_ext.ExtBidderError, len(adapterBids)),
+		Warnings:             make(map[openrtb_ext.BidderName][]openrtb_ext.ExtBidderError),
 		ResponseTimeMillis:   make(map[openrtb_ext.BidderName]int, len(adapterBids)),
 		RequestTimeoutMillis: req.TMax,
 	}
@@ -677,8 +803,14 @@ func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*pb
 		if len(responseExtra.Errors) > 0 {
 			bidResponseExt.Errors[bidderName] = responseExtra.Errors
 		}
-		if len(errList) > 0 {
-			bidResponseExt.Errors[openrtb_ext.PrebidExtKey] = errsToBidderErrors(errList)
+		if len(responseExtra.Warnings) > 0 {
+			bidResponseExt.Warnings[bidderName] = responseExtra.Warnings
+		}
+		if errs := errortypes.FatalOnly(errList); len(errs) > 0 {
+			bidResponseExt.Errors[openrtb_ext.PrebidExtKey] = errsToBidderErrors(errs)
+		}
+		if warnings := errortypes.WarningOnly(errList); len(warnings) > 0 {
+			bidResponseExt.Warnings[openrtb_ext.PrebidExtKey] = errsToBidderErrors(warnings)
 		}
 		bidResponseExt.ResponseTimeMillis[bidderName] = responseExtra.ResponseTimeMillis
 		// Defering the filling of bidResponseExt.Usersync[bidderName] until later
@@ -18,9 +18,11 @@ import (
 	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/tracing"
 	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
 	pbc "github.com/prebid/prebid-server/prebid_cache_client"
 	"github.com/prebid/prebid-server/stored_requests"
@@ -685,6 +687,136 @@ func TestPanicRecoveryHighLevel(t *testing.T) {
 
 }
 
+func TestBidderWarnings(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: blankAdapterConfig(openrtb_ext.BidderList()),
+	}
+	e := NewExchange(&http.Client{}, &mockCache{}, cfg, pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{}), adapters.ParseBidderInfos(cfg.Adapters, "../static/bidder-info", openrtb_ext.BidderList()), gdpr.AlwaysAllow{}, currencies.NewRateConverterDefault()).(*exchange)
+	e.adapterMap = map[openrtb_ext.BidderName]adaptedBidder{
+		openrtb_ext.BidderAppnexus: &mockAdaptedBidder{errorResponse: []error{
+			&errortypes.BadServerResponse{Message: "bad response"},
+			&errortypes.InvalidNative{Message: `Bid "bid-1" repaired: removed asset 9, which the request didn't ask for`},
+		}},
+	}
+
+	request := &openrtb.BidRequest{
+		ID:   "some-request-id",
+		Site: &openrtb.Site{Page: "www.some.domain.com"},
+		Imp: []openrtb.Imp{{
+			ID:     "some-imp-id",
+			Banner: &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}}},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1}}`),
+		}},
+	}
+
+	response, err := e.HoldAuction(context.Background(), request, &emptyUsersync{}, pbsmetrics.Labels{}, nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var responseExt openrtb_ext.ExtBidResponse
+	if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+		t.Fatalf("Failed to unmarshal the response ext: %v", err)
+	}
+	assert.Equal(t, []openrtb_ext.ExtBidderError{{Code: errortypes.BadServerResponseErrorCode, Message: "bad response"}}, responseExt.Errors[openrtb_ext.BidderAppnexus])
+	assert.Equal(t, []openrtb_ext.ExtBidderError{{Code: errortypes.InvalidNativeWarningCode, Message: `Bid "bid-1" repaired: removed asset 9, which the request didn't ask for`}}, responseExt.Warnings[openrtb_ext.BidderAppnexus])
+}
+
+func TestAuctionTrace(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: blankAdapterConfig(openrtb_ext.BidderList()),
+	}
+	e := NewExchange(&http.Client{}, &mockCache{}, cfg, pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{}), adapters.ParseBidderInfos(cfg.Adapters, "../static/bidder-info", openrtb_ext.BidderList()), gdpr.AlwaysAllow{}, currencies.NewRateConverterDefault()).(*exchange)
+	e.adapterMap = map[openrtb_ext.BidderName]adaptedBidder{
+		openrtb_ext.BidderAppnexus: &mockAdaptedBidder{
+			bidResponse: &pbsOrtbSeatBid{
+				bids:     []*pbsOrtbBid{{bid: &openrtb.Bid{ID: "bid-1", ImpID: "some-imp-id", Price: 1}, bidType: openrtb_ext.BidTypeBanner}},
+				currency: "USD",
+			},
+			errorResponse: []error{&errortypes.UnsupportedMediaType{Message: "request.imp[0] uses video, but this bidder doesn't support it"}},
+		},
+	}
+
+	request := &openrtb.BidRequest{
+		ID:   "some-request-id",
+		Site: &openrtb.Site{Page: "www.some.domain.com"},
+		Regs: &openrtb.Regs{COPPA: 1},
+		Imp: []openrtb.Imp{{
+			ID:     "some-imp-id",
+			Banner: &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}}},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1}}`),
+		}},
+		Ext: json.RawMessage(`{"prebid":{"bidadjustments":{"mediatype":{"banner":{"*":{"*":[{"adjtype":"multiplier","value":0.5}]}}}}}}`),
+	}
+
+	testCases := []struct {
+		description   string
+		level         openrtb_ext.TraceLevel
+		expectedTrace []openrtb_ext.ExtTraceEvent
+	}{
+		{
+			description: "Verbose traces should have the steps of each bid",
+			level:       openrtb_ext.TraceVerbose,
+			expectedTrace: []openrtb_ext.ExtTraceEvent{
+				{Stage: "privacy", Bidder: "appnexus", Message: "enforced COPPA"},
+				{Stage: "imp_filtering", Bidder: "appnexus", Message: "request.imp[0] uses video, but this bidder doesn't support it"},
+				{Stage: "bid_adjustment", Bidder: "appnexus", Message: "bid bid-1: multiplier adjustment of 0.500000 changed the price from 1.000000 to 0.500000 USD"},
+				{Stage: "bidder", Bidder: "appnexus", Message: "returned 1 bids"},
+			},
+		},
+		{
+			description: "Basic traces should only have the steps of the request and the bidders",
+			level:       openrtb_ext.TraceBasic,
+			expectedTrace: []openrtb_ext.ExtTraceEvent{
+				{Stage: "privacy", Bidder: "appnexus", Message: "enforced COPPA"},
+				{Stage: "imp_filtering", Bidder: "appnexus", Message: "request.imp[0] uses video, but this bidder doesn't support it"},
+				{Stage: "bidder", Bidder: "appnexus", Message: "returned 1 bids"},
+			},
+		},
+		{
+			description: "Auctions without a trace level shouldn't return a trace",
+			level:       openrtb_ext.TraceNone,
+		},
+	}
+
+	for _, test := range testCases {
+		e.adapterMap[openrtb_ext.BidderAppnexus].(*mockAdaptedBidder).bidResponse.bids[0].bid.Price = 1
+		ctx, trace := tracing.StartAuctionTrace(context.Background())
+		trace.SetLevel(test.level)
+
+		response, err := e.HoldAuction(ctx, request, &emptyUsersync{}, pbsmetrics.Labels{}, nil, nil)
+		if !assert.NoError(t, err, test.description) {
+			continue
+		}
+		var responseExt openrtb_ext.ExtBidResponse
+		if err := json.Unmarshal(response.Ext, &responseExt); err != nil {
+			t.Fatalf("%s: failed to unmarshal the response ext: %v", test.description, err)
+		}
+		assert.Equal(t, test.expectedTrace, responseExt.Trace, test.description)
+	}
+}
+
+func TestPrebidWarnings(t *testing.T) {
+	e := &exchange{}
+	adapterExtra := map[openrtb_ext.BidderName]*seatResponseExtra{
+		openrtb_ext.BidderAppnexus: {ResponseTimeMillis: 5},
+	}
+	errList := []error{
+		errors.New("some error"),
+		&errortypes.CategoryRejection{Message: "Category mapping file for primary ad server: 'freewheel', publisher: '' not found"},
+		&errortypes.CacheWarning{Message: "Error calling cache"},
+		&errortypes.InvalidDealTier{Message: "dealTier configuration invalid for bidder 'appnexus', imp ID 'imp-1'"},
+	}
+
+	responseExt := e.makeExtBidResponse(nil, adapterExtra, &openrtb.BidRequest{}, nil, errList)
+
+	assert.Equal(t, []openrtb_ext.ExtBidderError{{Code: errortypes.UnknownErrorCode, Message: "some error"}}, responseExt.Errors[openrtb_ext.PrebidExtKey])
+	assert.Equal(t, []openrtb_ext.ExtBidderError{
+		{Code: errortypes.CategoryRejectionWarningCode, Message: "Category mapping file for primary ad server: 'freewheel', publisher: '' not found"},
+		{Code: errortypes.CacheWarningCode, Message: "Error calling cache"},
+		{Code: errortypes.InvalidDealTierWarningCode, Message: "dealTier configuration invalid for bidder 'appnexus', imp ID 'imp-1'"},
+	}, responseExt.Warnings[openrtb_ext.PrebidExtKey])
+}
+
 func TestTimeoutComputation(t *testing.T) {
 	cacheTimeMillis := 10
 	ex := exchange{
@@ -1448,7 +1580,7 @@ func TestApplyDealSupport(t *testing.T) {
 
 		assert.Equal(t, test.expectedHbPbCatDur, bidCategory[auc.winningBidsByBidder["imp_id1"][bidderName].bid.ID], test.description)
 		if len(test.expectedDealErr) > 0 {
-			assert.Containsf(t, dealErrs, errors.New(test.expectedDealErr), "Expected error message not found in deal errors")
+			assert.Containsf(t, dealErrs, &errortypes.InvalidDealTier{Message: test.expectedDealErr}, "Expected error message not found in deal errors")
 		}
 	}
 }
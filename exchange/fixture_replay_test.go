@@ -0,0 +1,79 @@
+package exchange
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/adapters/adapterstest"
+	"github.com/prebid/prebid-server/adapters/generic"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/spf13/viper"
+)
+
+var replayFixtures = flag.String("replay-fixtures", "", "Directory of fixtures captured by debug.fixture_capture, to replay against the current adapters")
+var replayConfig = flag.String("replay-config", "", "Config file of the host which captured the fixtures, so that the adapters have the same endpoints")
+
+// TestReplayFixtures replays the fixtures captured by debug.fixture_capture, to catch adapter regressions on real traffic.
+// It's skipped unless the directory is given:
+//
+//	go test ./exchange -run TestReplayFixtures -args -replay-fixtures=/var/fixtures -replay-config=/etc/config/pbs.yaml
+func TestReplayFixtures(t *testing.T) {
+	if *replayFixtures == "" {
+		t.Skip("Set -replay-fixtures to replay captured fixtures")
+	}
+
+	cfg, err := loadReplayConfig(*replayConfig)
+	if err != nil {
+		t.Fatalf("Failed to load the config: %v", err)
+	}
+	for name, adapter := range cfg.Adapters {
+		if adapter.AliasOf != "" {
+			if err := openrtb_ext.RegisterAlias(name, openrtb_ext.BidderName(adapter.AliasOf)); err != nil {
+				t.Fatalf("Failed to register the host alias %s: %v", name, err)
+			}
+		}
+	}
+	bidderClients := newBidderHTTPClients(&http.Client{}, cfg.Adapters)
+	ortbBidders, legacyBidders := newBidders(bidderClients.forBidder, cfg.Adapters)
+	addHostAliases(bidderClients.forBidder, cfg.Adapters, ortbBidders, legacyBidders)
+	infos := adapters.ParseBidderInfos(cfg.Adapters, "../static/bidder-info", openrtb_ext.BidderList())
+
+	directories, err := ioutil.ReadDir(*replayFixtures)
+	if err != nil {
+		t.Fatalf("Failed to read the fixtures: %v", err)
+	}
+	for _, directory := range directories {
+		name := openrtb_ext.BidderName(strings.TrimSuffix(directory.Name(), "test"))
+		bidder, ok := ortbBidders[name]
+		if !directory.IsDir() || !ok {
+			continue
+		}
+		t.Run(string(name), func(t *testing.T) {
+			adapterstest.RunJSONBidderTest(t, filepath.Join(*replayFixtures, directory.Name()), redactedQueryBidder{adapters.EnforceBidderInfo(bidder, infos[string(name)])})
+		})
+	}
+}
+
+// loadReplayConfig loads the config like main does.
+func loadReplayConfig(configFile string) (*config.Configuration, error) {
+	if err := generic.RegisterBidders("../static/bidder-info"); err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	config.SetupViper(v, "")
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, err
+		}
+	}
+	generic.SetDefaults(v)
+	return config.New(v)
+}
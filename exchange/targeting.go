@@ -1,14 +1,14 @@
 package exchange
 
 import (
+	"fmt"
+	"hash/fnv"
 	"strconv"
 
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/openrtb_ext"
 )
 
-const maxKeyLength = 20
-
 // targetData tracks information about the winning Bid in each Imp.
 //
 // All functions on this struct are nil-safe. If the targetData struct is nil, then they behave
@@ -25,6 +25,22 @@ type targetData struct {
 	// cacheHost and cachePath exist to supply cache host and path as targeting parameters
 	cacheHost string
 	cachePath string
+	// prefix replaces the "hb" at the start of the keys, and maxKeyLength truncates the bidder-specific keys.
+	prefix       string
+	maxKeyLength int
+	// The optional keys which the request opted in to.
+	includeFormat  bool
+	includeADomain bool
+	includeSource  bool
+	includeCrID    bool
+	// vastWrapper adds the host's trackers to the cached VAST of the account's video bids.
+	vastWrapper *vastWrapper
+	account     string
+	// aliases are the request's bidder aliases. Their keys may collide with the ones of the other bidders.
+	aliases map[string]string
+	// bidderKeys caches the bidder-specific keys of the auction, since they depend on which other bidders exist.
+	bidderKeys map[openrtb_ext.TargetingKey]map[openrtb_ext.BidderName]string
+	bidders    []openrtb_ext.BidderName
 }
 
 // setTargeting writes all the targeting params into the bids.
@@ -34,6 +50,7 @@ type targetData struct {
 // it's ok if those stay in the auction. For now, this method implements a very naive cache strategy.
 // In the future, we should implement a more clever retry & backoff strategy to balance the success rate & performance.
 func (targData *targetData) setTargeting(auc *auction, isApp bool, categoryMapping map[string]string) {
+	targData.setBidders()
 	for impId, topBidsPerImp := range auc.winningBidsByBidder {
 		overallWinner := auc.winningBids[impId]
 		for bidderName, topBidPerBidder := range topBidsPerImp {
@@ -71,6 +88,18 @@ func (targData *targetData) setTargeting(auc *auction, isApp bool, categoryMappi
 			if len(categoryMapping) > 0 {
 				targData.addKeys(targets, openrtb_ext.HbCategoryDurationKey, categoryMapping[topBidPerBidder.bid.ID], bidderName, isOverallWinner)
 			}
+			if targData.includeFormat {
+				targData.addKeys(targets, openrtb_ext.HbFormatKey, string(topBidPerBidder.bidType), bidderName, isOverallWinner)
+			}
+			if targData.includeADomain && len(topBidPerBidder.bid.ADomain) > 0 {
+				targData.addKeys(targets, openrtb_ext.HbADomainKey, topBidPerBidder.bid.ADomain[0], bidderName, isOverallWinner)
+			}
+			if targData.includeSource {
+				targData.addKeys(targets, openrtb_ext.HbSourceKey, openrtb_ext.HbSourceS2S, bidderName, isOverallWinner)
+			}
+			if targData.includeCrID && topBidPerBidder.bid.CrID != "" {
+				targData.addKeys(targets, openrtb_ext.HbCrIDKey, topBidPerBidder.bid.CrID, bidderName, isOverallWinner)
+			}
 
 			topBidPerBidder.bidTargets = targets
 		}
@@ -79,11 +108,72 @@ func (targData *targetData) setTargeting(auc *auction, isApp bool, categoryMappi
 
 func (targData *targetData) addKeys(keys map[string]string, key openrtb_ext.TargetingKey, value string, bidderName openrtb_ext.BidderName, overallWinner bool) {
 	if targData.includeBidderKeys {
-		keys[key.BidderKey(bidderName, maxKeyLength)] = value
+		keys[targData.bidderKey(key, bidderName)] = value
 	}
 	if targData.includeWinners && overallWinner {
-		keys[string(key)] = value
+		keys[string(key.WithPrefix(targData.prefix))] = value
+	}
+}
+
+// setBidders remembers every bidder which could be in the auction, since their keys may collide once they're truncated.
+// These are the host's bidders and aliases, and the request's aliases, rather than the bidders which won,
+// so that a bidder's keys don't change from one auction to the next.
+func (targData *targetData) setBidders() {
+	targData.bidders = openrtb_ext.BidderList()
+	for alias := range targData.aliases {
+		if _, ok := openrtb_ext.BidderMap[alias]; !ok {
+			targData.bidders = append(targData.bidders, openrtb_ext.BidderName(alias))
+		}
+	}
+	targData.bidderKeys = make(map[openrtb_ext.TargetingKey]map[openrtb_ext.BidderName]string)
+}
+
+// bidderKey returns the bidder-specific version of the key, like hb_pb_appnexus.
+func (targData *targetData) bidderKey(key openrtb_ext.TargetingKey, bidderName openrtb_ext.BidderName) string {
+	keysByBidder, ok := targData.bidderKeys[key]
+	if !ok {
+		keysByBidder = makeBidderKeys(key.WithPrefix(targData.prefix), targData.bidders, targData.maxKeyLength)
+		if targData.bidderKeys == nil {
+			targData.bidderKeys = make(map[openrtb_ext.TargetingKey]map[openrtb_ext.BidderName]string)
+		}
+		targData.bidderKeys[key] = keysByBidder
+	}
+	if bidderKey, ok := keysByBidder[bidderName]; ok {
+		return bidderKey
+	}
+	return key.WithPrefix(targData.prefix).BidderKey(bidderName, targData.maxKeyLength)
+}
+
+// makeBidderKeys truncates the bidder-specific keys to maxLength. If the truncated keys of two bidders collide,
+// like hb_cache_host_appnex for appnexus and appnexus_eu, each of them ends with a hash of the bidder's name instead.
+// Publishers can then still tell the bidders apart, and the keys of the other bidders don't change.
+// Since the bidders are all the ones which exist, rather than the ones in the auction, each key is the same in every auction.
+func makeBidderKeys(key openrtb_ext.TargetingKey, bidders []openrtb_ext.BidderName, maxLength int) map[openrtb_ext.BidderName]string {
+	keys := make(map[openrtb_ext.BidderName]string, len(bidders))
+	counts := make(map[string]int, len(bidders))
+	for _, bidder := range bidders {
+		truncated := key.BidderKey(bidder, maxLength)
+		keys[bidder] = truncated
+		counts[truncated]++
+	}
+	for _, bidder := range bidders {
+		if counts[keys[bidder]] > 1 {
+			keys[bidder] = hashedBidderKey(key, bidder, maxLength)
+		}
+	}
+	return keys
+}
+
+// hashedBidderKey replaces the last four characters of the truncated key with a hash of the bidder's name.
+func hashedBidderKey(key openrtb_ext.TargetingKey, bidder openrtb_ext.BidderName, maxLength int) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(bidder))
+	suffix := fmt.Sprintf("%08x", hash.Sum32())[:4]
+	full := key.BidderKey(bidder, 0)
+	if maxLength <= len(suffix) || len(full) <= maxLength {
+		return full
 	}
+	return full[:maxLength-len(suffix)] + suffix
 }
 
 func makeHbSize(bid *openrtb.Bid) string {
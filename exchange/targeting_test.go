@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -50,13 +51,13 @@ func TestTargetingCache(t *testing.T) {
 
 	// Make sure that the cache keys exist on the bids where they're expected to
 	assertKeyExists(t, bids["winning-bid"], string(openrtb_ext.HbCacheKey), true)
-	assertKeyExists(t, bids["winning-bid"], openrtb_ext.HbCacheKey.BidderKey(openrtb_ext.BidderAppnexus, maxKeyLength), true)
+	assertKeyExists(t, bids["winning-bid"], openrtb_ext.HbCacheKey.BidderKey(openrtb_ext.BidderAppnexus, 20), true)
 
 	assertKeyExists(t, bids["contending-bid"], string(openrtb_ext.HbCacheKey), false)
-	assertKeyExists(t, bids["contending-bid"], openrtb_ext.HbCacheKey.BidderKey(openrtb_ext.BidderRubicon, maxKeyLength), true)
+	assertKeyExists(t, bids["contending-bid"], openrtb_ext.HbCacheKey.BidderKey(openrtb_ext.BidderRubicon, 20), true)
 
 	assertKeyExists(t, bids["losing-bid"], string(openrtb_ext.HbCacheKey), false)
-	assertKeyExists(t, bids["losing-bid"], openrtb_ext.HbCacheKey.BidderKey(openrtb_ext.BidderAppnexus, maxKeyLength), false)
+	assertKeyExists(t, bids["losing-bid"], openrtb_ext.HbCacheKey.BidderKey(openrtb_ext.BidderAppnexus, 20), false)
 
 	//assert hb_cache_host was included
 	assert.Contains(t, string(bids["winning-bid"].Ext), string(openrtb_ext.HbConstantCacheHostKey))
@@ -247,3 +248,146 @@ func (f *mockFetcher) GetId(bidder openrtb_ext.BidderName) (string, bool) {
 func mockServer(w http.ResponseWriter, req *http.Request) {
 	w.Write([]byte("{}"))
 }
+
+func TestSetTargetingPrefixAndOptionalKeys(t *testing.T) {
+	winner := &pbsOrtbBid{
+		bid:     &openrtb.Bid{ID: "winning-bid", ImpID: "imp", CrID: "creative-1", ADomain: []string{"advertiser.com", "other.com"}},
+		bidType: openrtb_ext.BidTypeVideo,
+	}
+	auc := &auction{
+		winningBids:         map[string]*pbsOrtbBid{"imp": winner},
+		winningBidsByBidder: map[string]map[openrtb_ext.BidderName]*pbsOrtbBid{"imp": {openrtb_ext.BidderAppnexus: winner}},
+		roundedPrices:       map[*pbsOrtbBid]string{winner: "1.00"},
+	}
+	targData := &targetData{
+		includeWinners:    true,
+		includeBidderKeys: true,
+		prefix:            "pbs",
+		maxKeyLength:      20,
+		includeFormat:     true,
+		includeADomain:    true,
+		includeSource:     true,
+		includeCrID:       true,
+	}
+
+	targData.setTargeting(auc, false, nil)
+
+	assert.Equal(t, map[string]string{
+		"pbs_pb":               "1.00",
+		"pbs_pb_appnexus":      "1.00",
+		"pbs_bidder":           "appnexus",
+		"pbs_bidder_appnexus":  "appnexus",
+		"pbs_format":           "video",
+		"pbs_format_appnexus":  "video",
+		"pbs_adomain":          "advertiser.com",
+		"pbs_adomain_appnexus": "advertiser.com",
+		"pbs_source":           "s2s",
+		"pbs_source_appnexus":  "s2s",
+		"pbs_crid":             "creative-1",
+		"pbs_crid_appnexus":    "creative-1",
+	}, winner.bidTargets)
+}
+
+func TestSetTargetingTruncationCollisions(t *testing.T) {
+	appnexusBid := &pbsOrtbBid{bid: &openrtb.Bid{ID: "appnexus-bid", ImpID: "imp"}}
+	aliasBid := &pbsOrtbBid{bid: &openrtb.Bid{ID: "alias-bid", ImpID: "imp"}}
+	rubiconBid := &pbsOrtbBid{bid: &openrtb.Bid{ID: "rubicon-bid", ImpID: "imp"}}
+	auc := &auction{
+		winningBids: map[string]*pbsOrtbBid{"imp": appnexusBid},
+		winningBidsByBidder: map[string]map[openrtb_ext.BidderName]*pbsOrtbBid{"imp": {
+			"appnexus":    appnexusBid,
+			"appnexus_eu": aliasBid,
+			"rubicon":     rubiconBid,
+		}},
+	}
+	targData := &targetData{
+		includeBidderKeys: true,
+		cacheHost:         "cache.prebid.org",
+		maxKeyLength:      20,
+		aliases:           map[string]string{"appnexus_eu": "appnexus"},
+	}
+
+	targData.setTargeting(auc, false, nil)
+
+	assert.Equal(t, "hb_bidder_appnexus", findKey(appnexusBid.bidTargets, "appnexus"), "Keys which fit shouldn't change")
+	assert.Equal(t, "hb_bidder_appnexus_e", findKey(aliasBid.bidTargets, "appnexus_eu"), "Keys which don't collide should just be truncated")
+	assert.Equal(t, "hb_cache_host_rubico", findKey(rubiconBid.bidTargets, "cache.prebid.org"), "Keys which don't collide should just be truncated")
+
+	appnexusKey := findKey(appnexusBid.bidTargets, "cache.prebid.org")
+	aliasKey := findKey(aliasBid.bidTargets, "cache.prebid.org")
+	assert.NotEqual(t, appnexusKey, aliasKey, "Colliding keys should be told apart")
+	for _, key := range []string{appnexusKey, aliasKey} {
+		assert.Len(t, key, 20)
+		assert.Equal(t, "hb_cache_host_a", key[:15])
+	}
+
+	// The hash only depends on the bidder, so publishers can set up their line items in advance.
+	assert.Equal(t, hashedBidderKey(openrtb_ext.HbConstantCacheHostKey, "appnexus", 20), appnexusKey)
+
+	// The key shouldn't change when the alias doesn't bid.
+	onlyAppnexusBid := &pbsOrtbBid{bid: &openrtb.Bid{ID: "appnexus-bid", ImpID: "imp"}}
+	targData.setTargeting(&auction{
+		winningBids:         map[string]*pbsOrtbBid{"imp": onlyAppnexusBid},
+		winningBidsByBidder: map[string]map[openrtb_ext.BidderName]*pbsOrtbBid{"imp": {"appnexus": onlyAppnexusBid}},
+	}, false, nil)
+	assert.Equal(t, appnexusKey, findKey(onlyAppnexusBid.bidTargets, "cache.prebid.org"), "Keys shouldn't depend on which bidders won")
+}
+
+func TestSetTargetingLongestPrefix(t *testing.T) {
+	bidders := []openrtb_ext.BidderName{"appnexus", "appnexus_eu", "rubicon"}
+	auc := &auction{
+		winningBidsByBidder: map[string]map[openrtb_ext.BidderName]*pbsOrtbBid{"imp": {}},
+		roundedPrices:       map[*pbsOrtbBid]string{},
+		cacheIds:            map[*openrtb.Bid]string{},
+		vastCacheIds:        map[*openrtb.Bid]string{},
+	}
+	for _, bidder := range bidders {
+		bid := &pbsOrtbBid{
+			bid:     &openrtb.Bid{ID: string(bidder), ImpID: "imp", W: 300, H: 250, DealID: "deal", CrID: "creative", ADomain: []string{"advertiser.com"}},
+			bidType: openrtb_ext.BidTypeVideo,
+		}
+		auc.winningBidsByBidder["imp"][bidder] = bid
+		auc.roundedPrices[bid] = "1.00"
+		auc.cacheIds[bid.bid] = "cache-id"
+		auc.vastCacheIds[bid.bid] = "vast-id"
+	}
+	cfg := config.Targeting{MaxKeyLength: 20}
+	targData := &targetData{
+		includeBidderKeys: true,
+		prefix:            strings.Repeat("p", cfg.MaxPrefixLengthForAccount("")),
+		maxKeyLength:      cfg.MaxKeyLengthForAccount(""),
+		cacheHost:         "cache.prebid.org",
+		cachePath:         "/cache",
+		includeFormat:     true,
+		includeADomain:    true,
+		includeSource:     true,
+		includeCrID:       true,
+		aliases:           map[string]string{"appnexus_eu": "appnexus"},
+	}
+
+	targData.setTargeting(auc, true, map[string]string{"appnexus": "1.00_food_30s", "appnexus_eu": "1.00_food_30s", "rubicon": "1.00_food_30s"})
+
+	// hb_pb, hb_bidder, hb_size, hb_cache_id, hb_uuid, hb_cache_host, hb_cache_path, hb_deal, hb_env, hb_pb_cat_dur,
+	// hb_format, hb_adomain, hb_source and hb_crid.
+	const keysPerBidder = 14
+	allKeys := make(map[string]bool)
+	for _, bidder := range bidders {
+		targets := auc.winningBidsByBidder["imp"][bidder].bidTargets
+		assert.Len(t, targets, keysPerBidder, "No key of %s should overwrite another one", bidder)
+		for key := range targets {
+			assert.True(t, len(key) <= 20, "%s is longer than the limit", key)
+			allKeys[key] = true
+		}
+	}
+	assert.Len(t, allKeys, keysPerBidder*len(bidders), "The keys of different bidders shouldn't collide")
+}
+
+// findKey returns the targeting key which has the value, or "" if there's none.
+func findKey(targets map[string]string, value string) string {
+	for key, v := range targets {
+		if v == value && key != string(openrtb_ext.HbBidderConstantKey) {
+			return key
+		}
+	}
+	return ""
+}
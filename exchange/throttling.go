@@ -0,0 +1,168 @@
+package exchange
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// bidderThrottler applies the host's throttling rules to the imps of each bidder.
+type bidderThrottler struct {
+	rules []*throttlingRule
+}
+
+type throttlingRule struct {
+	config.ThrottlingRule
+	limiter *qpsLimiter
+}
+
+// newBidderThrottler returns nil if the host has no throttling rules.
+func newBidderThrottler(cfg config.Throttling) *bidderThrottler {
+	if len(cfg.Rules) == 0 {
+		return nil
+	}
+	throttler := &bidderThrottler{rules: make([]*throttlingRule, 0, len(cfg.Rules))}
+	for _, rule := range cfg.Rules {
+		throttlingRule := &throttlingRule{ThrottlingRule: rule}
+		if rule.MaxQPS > 0 {
+			throttlingRule.limiter = &qpsLimiter{max: rule.MaxQPS, now: time.Now}
+		}
+		throttler.rules = append(throttler.rules, throttlingRule)
+	}
+	return throttler
+}
+
+// throttle removes the throttled imps from impsByBidder, and the bidders which have no imps left.
+// It returns the IDs of the imps which each bidder lost.
+//
+// Every rule which matches the request makes one decision for each bidder. If the bidder is throttled,
+// it loses all of the imps which match the rule's media types.
+func (t *bidderThrottler) throttle(req *openrtb.BidRequest, impsByBidder map[string][]openrtb.Imp, aliases map[string]string, pubID string) map[openrtb_ext.BidderName][]string {
+	if t == nil {
+		return nil
+	}
+	var throttled map[openrtb_ext.BidderName][]string
+	for bidder, imps := range impsByBidder {
+		coreBidder := resolveBidder(bidder, aliases)
+		for _, rule := range t.rules {
+			if rule.Bidder != bidder && rule.Bidder != string(coreBidder) || !rule.matchesRequest(req, pubID) {
+				continue
+			}
+			kept := make([]openrtb.Imp, 0, len(imps))
+			var matched []string
+			for _, imp := range imps {
+				if rule.matchesImp(&imp) {
+					matched = append(matched, imp.ID)
+				} else {
+					kept = append(kept, imp)
+				}
+			}
+			if len(matched) == 0 || rule.allow() {
+				continue
+			}
+			if throttled == nil {
+				throttled = make(map[openrtb_ext.BidderName][]string)
+			}
+			throttled[openrtb_ext.BidderName(bidder)] = append(throttled[openrtb_ext.BidderName(bidder)], matched...)
+			imps = kept
+		}
+		if len(imps) == 0 {
+			delete(impsByBidder, bidder)
+		} else {
+			impsByBidder[bidder] = imps
+		}
+	}
+	return throttled
+}
+
+func (rule *throttlingRule) matchesRequest(req *openrtb.BidRequest, pubID string) bool {
+	if len(rule.Accounts) > 0 && !containsString(rule.Accounts, pubID, false) {
+		return false
+	}
+	if len(rule.DeviceTypes) > 0 {
+		if req.Device == nil || !containsInt(rule.DeviceTypes, int(req.Device.DeviceType)) {
+			return false
+		}
+	}
+	if len(rule.Countries) > 0 && !containsString(rule.Countries, requestCountry(req), true) {
+		return false
+	}
+	return true
+}
+
+func (rule *throttlingRule) matchesImp(imp *openrtb.Imp) bool {
+	if len(rule.MediaTypes) == 0 {
+		return true
+	}
+	return imp.Banner != nil && containsString(rule.MediaTypes, string(openrtb_ext.BidTypeBanner), false) ||
+		imp.Video != nil && containsString(rule.MediaTypes, string(openrtb_ext.BidTypeVideo), false) ||
+		imp.Audio != nil && containsString(rule.MediaTypes, string(openrtb_ext.BidTypeAudio), false) ||
+		imp.Native != nil && containsString(rule.MediaTypes, string(openrtb_ext.BidTypeNative), false)
+}
+
+// allow returns true if the bidder should still get the imps which match the rule.
+func (rule *throttlingRule) allow() bool {
+	if rule.Percent != nil && rand.Float64()*100 >= *rule.Percent {
+		return false
+	}
+	return rule.limiter == nil || rule.limiter.take()
+}
+
+// requestCountry returns the country of the device, or of the user if the device has none.
+func requestCountry(req *openrtb.BidRequest) string {
+	if req.Device != nil && req.Device.Geo != nil && req.Device.Geo.Country != "" {
+		return req.Device.Geo.Country
+	}
+	if req.User != nil && req.User.Geo != nil {
+		return req.User.Geo.Country
+	}
+	return ""
+}
+
+func containsString(values []string, value string, ignoreCase bool) bool {
+	for _, v := range values {
+		if v == value || ignoreCase && strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// qpsLimiter lets through up to max requests in each second.
+type qpsLimiter struct {
+	max int
+	now func() time.Time
+
+	lock   sync.Mutex
+	second int64
+	count  int
+}
+
+func (l *qpsLimiter) take() bool {
+	second := l.now().Unix()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if second != l.second {
+		l.second = second
+		l.count = 0
+	}
+	if l.count >= l.max {
+		return false
+	}
+	l.count++
+	return true
+}
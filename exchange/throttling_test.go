@@ -0,0 +1,195 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottle(t *testing.T) {
+	none := 0.0
+	all := 100.0
+	testCases := []struct {
+		description string
+		rule        config.ThrottlingRule
+		expected    map[openrtb_ext.BidderName][]string
+	}{
+		{
+			description: "Other bidders shouldn't be throttled",
+			rule:        config.ThrottlingRule{Bidder: "rubicon", Percent: &none},
+		},
+		{
+			description: "A bidder should lose all of its imps",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", Percent: &none},
+			expected:    map[openrtb_ext.BidderName][]string{"appnexus": {"banner-imp", "video-imp"}},
+		},
+		{
+			description: "Rules for a core bidder should apply to its aliases",
+			rule:        config.ThrottlingRule{Bidder: "pubmatic", Percent: &none},
+			expected:    map[openrtb_ext.BidderName][]string{"pubmatic_alias": {"banner-imp"}},
+		},
+		{
+			description: "Rules should only apply to their media types",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", MediaTypes: []string{"video"}, Percent: &none},
+			expected:    map[openrtb_ext.BidderName][]string{"appnexus": {"video-imp"}},
+		},
+		{
+			description: "Rules should match the account",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", Accounts: []string{"acct-1"}, Percent: &none},
+			expected:    map[openrtb_ext.BidderName][]string{"appnexus": {"banner-imp", "video-imp"}},
+		},
+		{
+			description: "Rules for other accounts shouldn't apply",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", Accounts: []string{"acct-2"}, Percent: &none},
+		},
+		{
+			description: "Rules should match the device type",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", DeviceTypes: []int{3, 7}, Percent: &none},
+			expected:    map[openrtb_ext.BidderName][]string{"appnexus": {"banner-imp", "video-imp"}},
+		},
+		{
+			description: "Rules for other device types shouldn't apply",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", DeviceTypes: []int{2}, Percent: &none},
+		},
+		{
+			description: "Rules should match the country, whatever its case",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", Countries: []string{"fra"}, Percent: &none},
+			expected:    map[openrtb_ext.BidderName][]string{"appnexus": {"banner-imp", "video-imp"}},
+		},
+		{
+			description: "Rules for other countries shouldn't apply",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", Countries: []string{"USA"}, Percent: &none},
+		},
+		{
+			description: "Bidders should keep all of their traffic at 100 percent",
+			rule:        config.ThrottlingRule{Bidder: "appnexus", Percent: &all},
+		},
+	}
+
+	for _, test := range testCases {
+		req := &openrtb.BidRequest{
+			Device: &openrtb.Device{DeviceType: 3, Geo: &openrtb.Geo{Country: "FRA"}},
+		}
+		impsByBidder := map[string][]openrtb.Imp{
+			"appnexus": {
+				{ID: "banner-imp", Banner: &openrtb.Banner{}},
+				{ID: "video-imp", Video: &openrtb.Video{}},
+			},
+			"pubmatic_alias": {
+				{ID: "banner-imp", Banner: &openrtb.Banner{}},
+			},
+		}
+		aliases := map[string]string{"pubmatic_alias": "pubmatic"}
+		throttler := newBidderThrottler(config.Throttling{Rules: []config.ThrottlingRule{test.rule}})
+
+		throttled := throttler.throttle(req, impsByBidder, aliases, "acct-1")
+
+		assert.Equal(t, test.expected, throttled, test.description)
+		for bidder, impIDs := range test.expected {
+			for _, imp := range impsByBidder[string(bidder)] {
+				assert.NotContains(t, impIDs, imp.ID, "%s: throttled imps should be removed", test.description)
+			}
+		}
+	}
+}
+
+func TestThrottleRemovesBidders(t *testing.T) {
+	none := 0.0
+	throttler := newBidderThrottler(config.Throttling{Rules: []config.ThrottlingRule{{Bidder: "appnexus", MediaTypes: []string{"video"}, Percent: &none}}})
+	impsByBidder := map[string][]openrtb.Imp{
+		"appnexus": {{ID: "video-imp", Video: &openrtb.Video{}}},
+		"rubicon":  {{ID: "video-imp", Video: &openrtb.Video{}}},
+	}
+
+	throttler.throttle(&openrtb.BidRequest{}, impsByBidder, nil, "")
+
+	assert.NotContains(t, impsByBidder, "appnexus", "Bidders without imps shouldn't be called")
+	assert.Contains(t, impsByBidder, "rubicon")
+}
+
+func TestThrottleWithoutRules(t *testing.T) {
+	throttler := newBidderThrottler(config.Throttling{})
+	assert.Nil(t, throttler)
+
+	impsByBidder := map[string][]openrtb.Imp{"appnexus": {{ID: "imp"}}}
+	assert.Nil(t, throttler.throttle(&openrtb.BidRequest{}, impsByBidder, nil, ""))
+	assert.Len(t, impsByBidder["appnexus"], 1)
+}
+
+func TestQPSLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := &qpsLimiter{max: 2, now: func() time.Time { return now }}
+
+	assert.True(t, limiter.take())
+	assert.True(t, limiter.take())
+	assert.False(t, limiter.take(), "The third request in a second should be throttled")
+
+	now = now.Add(time.Second)
+	assert.True(t, limiter.take(), "The cap should reset every second")
+}
+
+func TestCleanOpenRTBRequestsThrottling(t *testing.T) {
+	none := 0.0
+	throttler := newBidderThrottler(config.Throttling{Rules: []config.ThrottlingRule{{Bidder: "appnexus", Percent: &none}}})
+	req := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{{
+			ID:     "imp",
+			Banner: &openrtb.Banner{},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1},"rubicon":{}}`),
+		}},
+	}
+
+	requests, _, throttled, errs := cleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, config.Privacy{}, throttler, nil)
+
+	assert.Empty(t, errs)
+	assert.NotContains(t, requests, openrtb_ext.BidderAppnexus)
+	assert.Contains(t, requests, openrtb_ext.BidderRubicon)
+	assert.Equal(t, map[openrtb_ext.BidderName][]string{"appnexus": {"imp"}}, throttled)
+}
+
+func TestHoldAuctionThrottling(t *testing.T) {
+	none := 0.0
+	me := pbsmetrics.NewMetrics(metrics.NewRegistry(), openrtb_ext.BidderList(), config.DisabledMetrics{})
+	e := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]adaptedBidder{
+			openrtb_ext.BidderAppnexus: &mockAdaptedBidder{bidResponse: &pbsOrtbSeatBid{}},
+			openrtb_ext.BidderRubicon:  &mockAdaptedBidder{bidResponse: &pbsOrtbSeatBid{}},
+		},
+		me:                me,
+		cache:             &wellBehavedCache{},
+		gDPR:              gdpr.AlwaysAllow{},
+		currencyConverter: currencies.NewRateConverterDefault(),
+		throttler:         newBidderThrottler(config.Throttling{Rules: []config.ThrottlingRule{{Bidder: "appnexus", Percent: &none}}}),
+	}
+	req := &openrtb.BidRequest{
+		ID:   "request",
+		Test: 1,
+		Imp: []openrtb.Imp{{
+			ID:     "imp",
+			Banner: &openrtb.Banner{},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1},"rubicon":{}}`),
+		}},
+	}
+
+	bidResponse, err := e.HoldAuction(context.Background(), req, &emptyUsersync{}, pbsmetrics.Labels{}, nil, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var responseExt openrtb_ext.ExtBidResponse
+	if assert.NoError(t, json.Unmarshal(bidResponse.Ext, &responseExt)) && assert.NotNil(t, responseExt.Debug) {
+		assert.Equal(t, map[openrtb_ext.BidderName][]string{"appnexus": {"imp"}}, responseExt.Debug.Throttled)
+	}
+	assert.Equal(t, int64(1), me.AdapterMetrics[openrtb_ext.BidderAppnexus].ThrottledMeter.Count())
+	assert.Equal(t, int64(0), me.AdapterMetrics[openrtb_ext.BidderRubicon].ThrottledMeter.Count())
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"strings"
 
 	"github.com/buger/jsonparser"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
@@ -15,6 +17,7 @@ import (
 	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/privacy/ccpa"
 	"github.com/prebid/prebid-server/privacy/lmt"
+	"github.com/prebid/prebid-server/tracing"
 )
 
 // cleanOpenRTBRequests splits the input request into requests which are sanitized for each bidder. Intended behavior is:
@@ -22,6 +25,7 @@ import (
 //   1. BidRequest.Imp[].Ext will only contain the "prebid" field and a "bidder" field which has the params for the intended Bidder.
 //   2. Every BidRequest.Imp[] requested Bids from the Bidder who keys it.
 //   3. BidRequest.User.BuyerUID will be set to that Bidder's ID.
+//   4. Bidders which don't declare OpenRTB 2.6 support in their bidder-info won't get the OpenRTB 2.6 fields which have no 2.5 location.
 func cleanOpenRTBRequests(ctx context.Context,
 	orig *openrtb.BidRequest,
 	usersyncs IdFetcher,
@@ -29,7 +33,9 @@ func cleanOpenRTBRequests(ctx context.Context,
 	labels pbsmetrics.Labels,
 	gDPR gdpr.Permissions,
 	usersyncIfAmbiguous bool,
-	privacyConfig config.Privacy) (requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, errs []error) {
+	privacyConfig config.Privacy,
+	throttler *bidderThrottler,
+	bidderInfos adapters.BidderInfos) (requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, throttled map[openrtb_ext.BidderName][]string, errs []error) {
 
 	impsByBidder, errs := splitImps(orig.Imp)
 	if len(errs) > 0 {
@@ -41,8 +47,18 @@ func cleanOpenRTBRequests(ctx context.Context,
 		return
 	}
 
+	throttled = throttler.throttle(orig, impsByBidder, aliases, labels.PubID)
+
 	requestsByBidder, errs = splitBidRequest(orig, impsByBidder, aliases, usersyncs, blables, labels)
 
+	bidderExt := removeAmpData(orig.Ext)
+	for bidder, bidReq := range requestsByBidder {
+		bidReq.Ext = bidderExt
+		if bidderInfos.OpenRTBVersion(resolveBidder(bidder.String(), aliases)) != openrtb_ext.OpenRTBVersion26 {
+			downconvertTo25(bidReq)
+		}
+	}
+
 	gdpr := extractGDPR(orig, usersyncIfAmbiguous)
 	consent := extractConsent(orig)
 	ampGDPRException := (labels.RType == pbsmetrics.ReqTypeAMP) && gDPR.AMPException()
@@ -65,6 +81,7 @@ func cleanOpenRTBRequests(ctx context.Context,
 	}
 
 	// bidder level privacy policies
+	trace := tracing.AuctionTraceFromContext(ctx)
 	for bidder, bidReq := range requestsByBidder {
 
 		if gdpr == 1 {
@@ -74,17 +91,49 @@ func cleanOpenRTBRequests(ctx context.Context,
 			ok, geo, err := gDPR.PersonalInfoAllowed(ctx, coreBidder, publisherID, consent)
 			privacyEnforcement.GDPR = !ok && err == nil
 			privacyEnforcement.GDPRGeo = !geo && err == nil
+			if err != nil {
+				trace.Basic("privacy", bidder, "GDPR wasn't enforced, since the vendor permissions couldn't be checked: %v", err)
+			}
 		} else {
 			privacyEnforcement.GDPR = false
 			privacyEnforcement.GDPRGeo = false
 		}
 
 		privacyEnforcement.Apply(bidReq, ampGDPRException)
+		tracePrivacy(trace, bidder, privacyEnforcement)
 	}
 
 	return
 }
 
+// tracePrivacy records the privacy policies which were enforced on the request of the bidder.
+func tracePrivacy(trace *tracing.AuctionTrace, bidder openrtb_ext.BidderName, enforcement privacy.Enforcement) {
+	if trace == nil {
+		return
+	}
+	var policies []string
+	if enforcement.GDPR {
+		policies = append(policies, "GDPR")
+	}
+	if enforcement.GDPRGeo {
+		policies = append(policies, "GDPR geo")
+	}
+	if enforcement.CCPA {
+		policies = append(policies, "CCPA")
+	}
+	if enforcement.COPPA {
+		policies = append(policies, "COPPA")
+	}
+	if enforcement.LMT {
+		policies = append(policies, "LMT")
+	}
+	if len(policies) == 0 {
+		trace.Verbose("privacy", bidder, "no privacy policy was enforced")
+		return
+	}
+	trace.Basic("privacy", bidder, "enforced %s", strings.Join(policies, ", "))
+}
+
 func splitBidRequest(req *openrtb.BidRequest, impsByBidder map[string][]openrtb.Imp, aliases map[string]string, usersyncs IdFetcher, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, labels pbsmetrics.Labels) (map[openrtb_ext.BidderName]*openrtb.BidRequest, []error) {
 	requestsByBidder := make(map[openrtb_ext.BidderName]*openrtb.BidRequest, len(impsByBidder))
 	explicitBuyerUIDs, err := extractBuyerUIDs(req.User)
@@ -115,6 +164,52 @@ func splitBidRequest(req *openrtb.BidRequest, impsByBidder map[string][]openrtb.
 	return requestsByBidder, nil
 }
 
+// removeAmpData removes ext.prebid.amp from the request ext which the bidders get. It holds the AMP query params,
+// which are only meant for Prebid Server.
+func removeAmpData(ext json.RawMessage) json.RawMessage {
+	if _, _, _, err := jsonparser.Get(ext, openrtb_ext.PrebidExtKey, "amp"); err != nil {
+		return ext
+	}
+	// jsonparser.Delete changes the ext in place, and the original request must be kept for the analytics modules.
+	return jsonparser.Delete(append(json.RawMessage(nil), ext...), openrtb_ext.PrebidExtKey, "amp")
+}
+
+// downconvertTo25 removes the OpenRTB 2.6 fields which have no OpenRTB 2.5 location from the request.
+// The other OpenRTB 2.6 fields were already moved to their 2.5 ext locations when the request was parsed.
+// The requests of the bidders which support OpenRTB 2.6 have them moved back by adapters.UpconvertOpenRTB26.
+//
+// The request's imps must be copies of the original ones, since their videos are replaced.
+func downconvertTo25(req *openrtb.BidRequest) {
+	for i := range req.Imp {
+		video := req.Imp[i].Video
+		if video == nil || len(video.Ext) == 0 {
+			continue
+		}
+		var videoExt map[string]json.RawMessage
+		if err := json.Unmarshal(video.Ext, &videoExt); err != nil {
+			continue
+		}
+		removed := false
+		for _, field := range openrtb_ext.OpenRTB26VideoExtFields {
+			if _, ok := videoExt[field]; ok {
+				delete(videoExt, field)
+				removed = true
+			}
+		}
+		if !removed {
+			continue
+		}
+
+		videoCopy := *video
+		if len(videoExt) == 0 {
+			videoCopy.Ext = nil
+		} else if rawExt, err := json.Marshal(videoExt); err == nil {
+			videoCopy.Ext = rawExt
+		}
+		req.Imp[i].Video = &videoCopy
+	}
+}
+
 // extractBuyerUIDs parses the values from user.ext.prebid.buyeruids, and then deletes those values from the ext.
 // This prevents a Bidder from using these values to figure out who else is involved in the Auction.
 func extractBuyerUIDs(user *openrtb.User) (map[string]string, error) {
@@ -156,6 +251,8 @@ func extractBuyerUIDs(user *openrtb.User) (map[string]string, error) {
 // The "imp.ext" value of the appnexus Imp will only contain the "prebid" values, and "appnexus" value at the "bidder" key.
 // The "imp.ext" value of the rubicon Imp will only contain the "prebid" values, and "rubicon" value at the "bidder" key.
 //
+// Each of them also gets the first party data in the "context" value, if the Imp has any.
+//
 // The goal here is so that Bidders only get Imps and Imp.Ext values which are intended for them.
 func splitImps(imps []openrtb.Imp) (map[string][]openrtb.Imp, []error) {
 	impExts, err := parseImpExts(imps)
@@ -171,12 +268,13 @@ func splitImps(imps []openrtb.Imp) (map[string][]openrtb.Imp, []error) {
 		impExt := impExts[i]
 
 		rawPrebidExt, ok := impExt[openrtb_ext.PrebidExtKey]
+		rawContextExt := impExt[openrtb_ext.FirstPartyDataContextExtKey]
 
 		if ok {
 			var prebidExt openrtb_ext.ExtImpPrebid
 
 			if err := json.Unmarshal(rawPrebidExt, &prebidExt); err == nil && prebidExt.Bidder != nil {
-				if errs := sanitizedImpCopy(&imp, prebidExt.Bidder, rawPrebidExt, &splitImps); errs != nil {
+				if errs := sanitizedImpCopy(&imp, prebidExt.Bidder, rawPrebidExt, rawContextExt, &splitImps); errs != nil {
 					errList = append(errList, errs...)
 				}
 
@@ -184,7 +282,7 @@ func splitImps(imps []openrtb.Imp) (map[string][]openrtb.Imp, []error) {
 			}
 		}
 
-		if errs := sanitizedImpCopy(&imp, impExt, rawPrebidExt, &splitImps); errs != nil {
+		if errs := sanitizedImpCopy(&imp, impExt, rawPrebidExt, rawContextExt, &splitImps); errs != nil {
 			errList = append(errList, errs...)
 		}
 	}
@@ -192,12 +290,13 @@ func splitImps(imps []openrtb.Imp) (map[string][]openrtb.Imp, []error) {
 	return splitImps, nil
 }
 
-// sanitizedImpCopy returns a copy of imp with its ext filtered so that only "prebid" and bidder params exist.
+// sanitizedImpCopy returns a copy of imp with its ext filtered so that only "prebid", "context" and bidder params exist.
 // It will not mutate the input imp.
 // This function will write the new imps to the output map passed in
 func sanitizedImpCopy(imp *openrtb.Imp,
 	bidderExts map[string]json.RawMessage,
 	rawPrebidExt json.RawMessage,
+	rawContextExt json.RawMessage,
 	out *map[string][]openrtb.Imp) []error {
 
 	var prebidExt map[string]json.RawMessage
@@ -215,7 +314,7 @@ func sanitizedImpCopy(imp *openrtb.Imp,
 	}
 
 	for bidder, ext := range bidderExts {
-		if bidder == openrtb_ext.PrebidExtKey {
+		if bidder == openrtb_ext.PrebidExtKey || bidder == openrtb_ext.FirstPartyDataContextExtKey {
 			continue
 		}
 
@@ -228,6 +327,10 @@ func sanitizedImpCopy(imp *openrtb.Imp,
 			newExt[openrtb_ext.PrebidExtKey] = rawPrebidExt
 		}
 
+		if rawContextExt != nil {
+			newExt[openrtb_ext.FirstPartyDataContextExtKey] = rawContextExt
+		}
+
 		rawExt, err := json.Marshal(newExt)
 		if err != nil {
 			errs = append(errs, err)
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
@@ -80,7 +81,7 @@ func TestCleanOpenRTBRequests(t *testing.T) {
 	}
 
 	for _, test := range testCases {
-		reqByBidders, _, err := cleanOpenRTBRequests(context.Background(), test.req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, privacyConfig)
+		reqByBidders, _, _, err := cleanOpenRTBRequests(context.Background(), test.req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, privacyConfig, nil, nil)
 		if test.hasError {
 			assert.NotNil(t, err, "Error shouldn't be nil")
 		} else {
@@ -120,7 +121,7 @@ func TestCleanOpenRTBRequestsCCPA(t *testing.T) {
 			},
 		}
 
-		results, _, errs := cleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, privacyConfig)
+		results, _, _, errs := cleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, privacyConfig, nil, nil)
 		result := results["appnexus"]
 
 		assert.Nil(t, errs)
@@ -182,7 +183,7 @@ func TestCleanOpenRTBRequestsLMT(t *testing.T) {
 			},
 		}
 
-		results, _, errs := cleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, privacyConfig)
+		results, _, _, errs := cleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, privacyConfig, nil, nil)
 		result := results["appnexus"]
 
 		assert.Nil(t, errs)
@@ -197,6 +198,77 @@ func TestCleanOpenRTBRequestsLMT(t *testing.T) {
 	}
 }
 
+func TestCleanOpenRTBRequestsOpenRTB26(t *testing.T) {
+	req := &openrtb.BidRequest{
+		ID: "req",
+		Imp: []openrtb.Imp{{
+			ID: "imp",
+			Video: &openrtb.Video{
+				MIMEs: []string{"video/mp4"},
+				Ext:   json.RawMessage(`{"podid":"pod-1","slotinpod":1,"other":true}`),
+			},
+			Ext: json.RawMessage(`{"appnexus":{"placementId":1},"rubicon":{"accountId":1},"someAlias":{"placementId":2}}`),
+		}},
+		Ext: json.RawMessage(`{"prebid":{"aliases":{"someAlias":"rubicon"}}}`),
+	}
+	bidderInfos := adapters.BidderInfos{
+		"rubicon": adapters.BidderInfo{OpenRTB: &adapters.OpenRTBInfo{Version: openrtb_ext.OpenRTBVersion26}},
+	}
+
+	results, _, _, errs := cleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, config.Privacy{}, nil, bidderInfos)
+	assert.Empty(t, errs)
+
+	assert.JSONEq(t, `{"other":true}`, string(results["appnexus"].Imp[0].Video.Ext), "OpenRTB 2.5 bidders shouldn't get the OpenRTB 2.6 video fields")
+	assert.JSONEq(t, `{"podid":"pod-1","slotinpod":1,"other":true}`, string(results["rubicon"].Imp[0].Video.Ext), "OpenRTB 2.6 bidders should get the OpenRTB 2.6 video fields")
+	assert.JSONEq(t, `{"podid":"pod-1","slotinpod":1,"other":true}`, string(results["someAlias"].Imp[0].Video.Ext), "Aliases should use the OpenRTB version of their core bidder")
+	assert.JSONEq(t, `{"podid":"pod-1","slotinpod":1,"other":true}`, string(req.Imp[0].Video.Ext), "The original request shouldn't change")
+}
+
+func TestCleanOpenRTBRequestsAmpData(t *testing.T) {
+	req := &openrtb.BidRequest{
+		ID:  "req",
+		Imp: []openrtb.Imp{{ID: "imp", Ext: json.RawMessage(`{"appnexus":{"placementId":1}}`)}},
+		Ext: json.RawMessage(`{"prebid":{"amp":{"data":{"tag_id":"1"}},"targeting":{"includewinners":true}}}`),
+	}
+
+	results, _, _, errs := cleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, config.Privacy{}, nil, adapters.BidderInfos{})
+	assert.Empty(t, errs)
+	assert.JSONEq(t, `{"prebid":{"targeting":{"includewinners":true}}}`, string(results["appnexus"].Ext), "Bidders shouldn't get the AMP query params")
+	assert.JSONEq(t, `{"prebid":{"amp":{"data":{"tag_id":"1"}},"targeting":{"includewinners":true}}}`, string(req.Ext), "The original request shouldn't change")
+}
+
+func TestSplitImpsFirstPartyData(t *testing.T) {
+	imps := []openrtb.Imp{{
+		ID:  "imp",
+		Ext: json.RawMessage(`{"appnexus":{"placementId":1},"rubicon":{"accountId":1},"prebid":{"is_rewarded_inventory":1},"context":{"data":{"section":"sports"}}}`),
+	}}
+
+	results, errs := splitImps(imps)
+	assert.Empty(t, errs)
+	if assert.Len(t, results, 2, "The context shouldn't be treated as a bidder") {
+		assert.JSONEq(t, `{"bidder":{"placementId":1},"prebid":{"is_rewarded_inventory":1},"context":{"data":{"section":"sports"}}}`, string(results["appnexus"][0].Ext))
+		assert.JSONEq(t, `{"bidder":{"accountId":1},"prebid":{"is_rewarded_inventory":1},"context":{"data":{"section":"sports"}}}`, string(results["rubicon"][0].Ext))
+	}
+}
+
+func TestDownconvertTo25(t *testing.T) {
+	req := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{
+			{ID: "banner", Banner: &openrtb.Banner{}},
+			{ID: "only-openrtb26", Video: &openrtb.Video{Ext: json.RawMessage(`{"podid":"pod-1","slotinpod":-1}`)}},
+			{ID: "no-openrtb26", Video: &openrtb.Video{Ext: json.RawMessage(`{"other":true}`)}},
+			{ID: "malformed", Video: &openrtb.Video{Ext: json.RawMessage(`[]`)}},
+		},
+	}
+
+	downconvertTo25(req)
+
+	assert.Nil(t, req.Imp[0].Video)
+	assert.Nil(t, req.Imp[1].Video.Ext)
+	assert.JSONEq(t, `{"other":true}`, string(req.Imp[2].Video.Ext))
+	assert.JSONEq(t, `[]`, string(req.Imp[3].Video.Ext))
+}
+
 // newAdapterAliasBidRequest builds a BidRequest with aliases
 func newAdapterAliasBidRequest(t *testing.T) *openrtb.BidRequest {
 	dnt := int8(1)
@@ -0,0 +1,338 @@
+package exchange
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/golang/glog"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/macros"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// vastWrapper adds the host's trackers and verifications to the VAST of the video bids before they're cached.
+//
+// All functions on this struct are nil-safe. If the wrapper is nil, the bids' VAST is cached as it is.
+type vastWrapper struct {
+	version            string
+	impressionTrackers []*template.Template
+	errorTrackers      []*template.Template
+	verifications      []config.VASTVerification
+}
+
+// newVASTWrapper returns nil if the host doesn't wrap the bids' VAST.
+func newVASTWrapper(cfg config.VAST) *vastWrapper {
+	if !cfg.Wrap {
+		return nil
+	}
+	wrapper := &vastWrapper{
+		version:            cfg.Version,
+		impressionTrackers: parseTrackers(cfg.ImpressionTrackers),
+		errorTrackers:      parseTrackers(cfg.ErrorTrackers),
+		verifications:      cfg.Verifications,
+	}
+	if wrapper.version == "" {
+		wrapper.version = "3.0"
+	}
+	return wrapper
+}
+
+func parseTrackers(trackers []string) []*template.Template {
+	templates := make([]*template.Template, 0, len(trackers))
+	for _, tracker := range trackers {
+		trackerTemplate, err := template.New("vastTracker").Parse(tracker)
+		if err != nil {
+			// The config validation should have caught this already.
+			glog.Errorf("Ignoring the invalid tracker %q: %v", tracker, err)
+			continue
+		}
+		templates = append(templates, trackerTemplate)
+	}
+	return templates
+}
+
+// vast returns the VAST which should be cached for the bid.
+//
+// Bids with an adm get the trackers, the verifications and the IDs of the auction in each of their ads.
+// Bids with only a nurl get a wrapper around it, which has all of those as well.
+func (w *vastWrapper) vast(bid *openrtb.Bid, bidder openrtb_ext.BidderName, account string, auctionID string) string {
+	if w == nil {
+		return makeVAST(bid)
+	}
+	params := macros.TrackerTemplateParams{
+		Bidder:    url.QueryEscape(string(bidder)),
+		AccountID: url.QueryEscape(account),
+		AuctionID: url.QueryEscape(auctionID),
+		BidID:     url.QueryEscape(bid.ID),
+	}
+	ids := `<Extension type="prebid">` +
+		`<Bidder>` + cdata(string(bidder)) + `</Bidder>` +
+		`<AccountId>` + cdata(account) + `</AccountId>` +
+		`<AuctionId>` + cdata(auctionID) + `</AuctionId>` +
+		`<BidId>` + cdata(bid.ID) + `</BidId>` +
+		`</Extension>`
+
+	errorTrackers, impressionTrackers := w.trackers(params)
+	if bid.AdM == "" {
+		if impressionTrackers == "" {
+			impressionTrackers = `<Impression></Impression>`
+		}
+		verifications, extensions := w.verificationNodes(w.version)
+		return `<VAST version="` + w.version + `"><Ad><Wrapper>` +
+			`<AdSystem>prebid.org wrapper</AdSystem>` +
+			`<VASTAdTagURI>` + cdata(bid.NURL) + `</VASTAdTagURI>` +
+			errorTrackers +
+			impressionTrackers +
+			`<Creatives></Creatives>` +
+			verifications +
+			`<Extensions>` + extensions + ids + `</Extensions>` +
+			`</Wrapper></Ad></VAST>`
+	}
+
+	return w.inject(bid.AdM, errorTrackers, impressionTrackers, ids)
+}
+
+// trackers returns the <Error> and the <Impression> elements of the host's trackers.
+func (w *vastWrapper) trackers(params macros.TrackerTemplateParams) (errorNodes string, impressionNodes string) {
+	return trackerNodes("Error", w.errorTrackers, params), trackerNodes("Impression", w.impressionTrackers, params)
+}
+
+func trackerNodes(element string, trackers []*template.Template, params macros.TrackerTemplateParams) string {
+	var nodes strings.Builder
+	for _, tracker := range trackers {
+		if resolved, err := macros.ResolveMacros(*tracker, params); err == nil {
+			nodes.WriteString(`<` + element + `>` + cdata(resolved) + `</` + element + `>`)
+		}
+	}
+	return nodes.String()
+}
+
+// verificationNodes returns the <Verification> elements of the host. VAST 4 has an <AdVerifications> element for them,
+// while the older versions carry them in an <Extension type="AdVerifications">.
+func (w *vastWrapper) verificationNodes(version string) (verifications string, extensions string) {
+	if len(w.verifications) == 0 {
+		return "", ""
+	}
+	var nodes strings.Builder
+	for _, verification := range w.verifications {
+		nodes.WriteString(`<Verification vendor="` + xmlAttribute(verification.Vendor) + `">`)
+		nodes.WriteString(`<JavaScriptResource apiFramework="omid" browserOptional="true">` + cdata(verification.JavaScriptURL) + `</JavaScriptResource>`)
+		if verification.Parameters != "" {
+			nodes.WriteString(`<VerificationParameters>` + cdata(verification.Parameters) + `</VerificationParameters>`)
+		}
+		nodes.WriteString(`</Verification>`)
+	}
+	if strings.HasPrefix(version, "4") {
+		return `<AdVerifications>` + nodes.String() + `</AdVerifications>`, ""
+	}
+	return "", `<Extension type="AdVerifications"><AdVerifications>` + nodes.String() + `</AdVerifications></Extension>`
+}
+
+// inject adds the trackers, the verifications and the IDs to every <InLine> and <Wrapper> ad of the VAST.
+//
+// The VAST is parsed to find where they go, so that text inside CDATA sections, comments and nested elements
+// is never mistaken for the ad's own elements. The bidder's markup is otherwise kept byte for byte.
+// If the VAST can't be parsed, it's returned unchanged.
+func (w *vastWrapper) inject(adm string, errorTrackers string, impressionTrackers string, ids string) string {
+	ads, version, err := findVASTAds(adm)
+	if err != nil {
+		return adm
+	}
+	if version == "" {
+		version = w.version
+	}
+
+	verifications, verificationExtensions := w.verificationNodes(version)
+	insertions := make([]vastInsertion, 0, 4*len(ads))
+	for _, ad := range ads {
+		insertions = append(insertions, ad.trackerInsertions(errorTrackers, impressionTrackers)...)
+		if verifications != "" {
+			if ad.adVerifications.exists() {
+				insertions = append(insertions, ad.adVerifications.fill(verifications[len("<AdVerifications>"):len(verifications)-len("</AdVerifications>")], "AdVerifications"))
+			} else {
+				insertions = append(insertions, vastInsertion{start: ad.closing, end: ad.closing, nodes: verifications})
+			}
+		}
+		if ad.extensions.exists() {
+			insertions = append(insertions, ad.extensions.fill(verificationExtensions+ids, "Extensions"))
+		} else {
+			insertions = append(insertions, vastInsertion{start: ad.closing, end: ad.closing, nodes: `<Extensions>` + verificationExtensions + ids + `</Extensions>`})
+		}
+	}
+
+	sort.SliceStable(insertions, func(i, j int) bool {
+		return insertions[i].start < insertions[j].start
+	})
+	var result strings.Builder
+	previous := 0
+	for _, insertion := range insertions {
+		result.WriteString(adm[previous:insertion.start])
+		result.WriteString(insertion.nodes)
+		previous = insertion.end
+	}
+	result.WriteString(adm[previous:])
+	return result.String()
+}
+
+// vastInsertion replaces adm[start:end] with the nodes. Most insertions have start == end, and replace nothing.
+type vastInsertion struct {
+	start int
+	end   int
+	nodes string
+}
+
+// vastElement is the position of an element in the VAST, from the start of its opening tag to the end of its closing tag.
+// contentEnd is the start of its closing tag, or -1 if the element is self-closing.
+type vastElement struct {
+	start      int
+	end        int
+	contentEnd int
+}
+
+func (e vastElement) exists() bool {
+	return e.end > 0
+}
+
+// fill inserts the nodes at the end of the element's content, expanding it first if it's self-closing.
+func (e vastElement) fill(nodes string, name string) vastInsertion {
+	if e.contentEnd < 0 {
+		return vastInsertion{start: e.start, end: e.end, nodes: `<` + name + `>` + nodes + `</` + name + `>`}
+	}
+	return vastInsertion{start: e.contentEnd, end: e.contentEnd, nodes: nodes}
+}
+
+// vastAd holds the positions of the elements of an <InLine> or <Wrapper> ad which the wrapper adds to.
+// Only the ad's direct children count. Positions are -1 if the ad doesn't have the element.
+type vastAd struct {
+	lastErrorEnd      int
+	firstImpression   int
+	lastImpressionEnd int
+	creatives         int
+	adVerifications   vastElement
+	extensions        vastElement
+	// closing is the start of the ad's closing tag.
+	closing int
+}
+
+// trackerInsertions puts the trackers after the ad's own <Error> and <Impression> elements, so that the VAST schema's
+// order of Error, Impression, Creatives and Extensions holds. If the ad has none, they go before its <Creatives>
+// or <Extensions>.
+func (ad *vastAd) trackerInsertions(errorTrackers string, impressionTrackers string) []vastInsertion {
+	impressionsAt := ad.closing
+	if ad.lastImpressionEnd >= 0 {
+		impressionsAt = ad.lastImpressionEnd
+	} else if ad.creatives >= 0 {
+		impressionsAt = ad.creatives
+	} else if ad.extensions.exists() {
+		impressionsAt = ad.extensions.start
+	}
+	errorsAt := impressionsAt
+	if ad.lastErrorEnd >= 0 {
+		errorsAt = ad.lastErrorEnd
+	} else if ad.firstImpression >= 0 {
+		errorsAt = ad.firstImpression
+	}
+	return []vastInsertion{
+		{start: errorsAt, end: errorsAt, nodes: errorTrackers},
+		{start: impressionsAt, end: impressionsAt, nodes: impressionTrackers},
+	}
+}
+
+// findVASTAds parses the VAST, and returns its <InLine> and <Wrapper> ads along with the version of its root element.
+func findVASTAds(adm string) ([]*vastAd, string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(adm))
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var ads []*vastAd
+	var current *vastAd
+	version := ""
+	// path holds the local names of the open elements. The ad is open while current != nil, at depth len("VAST", "Ad").
+	path := make([]string, 0, 8)
+	// childStart is where the ad's current direct child begins.
+	childStart := 0
+	for {
+		tokenStart := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		tokenEnd := int(decoder.InputOffset())
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			path = append(path, element.Name.Local)
+			if len(path) == 1 {
+				for _, attr := range element.Attr {
+					if attr.Name.Local == "version" {
+						version = attr.Value
+					}
+				}
+			}
+			if current == nil && len(path) == 3 && path[1] == "Ad" && (element.Name.Local == "InLine" || element.Name.Local == "Wrapper") {
+				current = &vastAd{
+					lastErrorEnd:      -1,
+					firstImpression:   -1,
+					lastImpressionEnd: -1,
+					creatives:         -1,
+				}
+			} else if current != nil && len(path) == 4 {
+				childStart = tokenStart
+				switch element.Name.Local {
+				case "Impression":
+					if current.firstImpression < 0 {
+						current.firstImpression = tokenStart
+					}
+				case "Creatives":
+					if current.creatives < 0 {
+						current.creatives = tokenStart
+					}
+				}
+			}
+		case xml.EndElement:
+			if current != nil && len(path) == 3 {
+				current.closing = tokenStart
+				ads = append(ads, current)
+				current = nil
+			} else if current != nil && len(path) == 4 {
+				contentEnd := tokenStart
+				if tokenStart == tokenEnd {
+					// Self-closing elements end right where they started
+					contentEnd = -1
+				}
+				switch element.Name.Local {
+				case "Error":
+					current.lastErrorEnd = tokenEnd
+				case "Impression":
+					current.lastImpressionEnd = tokenEnd
+				case "AdVerifications":
+					current.adVerifications = vastElement{start: childStart, end: tokenEnd, contentEnd: contentEnd}
+				case "Extensions":
+					current.extensions = vastElement{start: childStart, end: tokenEnd, contentEnd: contentEnd}
+				}
+			}
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+	return ads, version, nil
+}
+
+// cdata wraps the text in a CDATA section. A "]]>" in the text is split across two sections.
+func cdata(text string) string {
+	return `<![CDATA[` + strings.Replace(text, "]]>", "]]]]><![CDATA[>", -1) + `]]>`
+}
+
+func xmlAttribute(value string) string {
+	return strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `"`, "&quot;").Replace(value)
+}
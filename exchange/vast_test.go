@@ -0,0 +1,181 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVASTWrapperDisabled(t *testing.T) {
+	wrapper := newVASTWrapper(config.VAST{ImpressionTrackers: []string{"https://t.example.com/imp"}})
+	assert.Nil(t, wrapper)
+
+	bid := &openrtb.Bid{AdM: `<VAST version="3.0"></VAST>`}
+	assert.Equal(t, makeVAST(bid), wrapper.vast(bid, "appnexus", "acct-1", "auction-1"), "The VAST should be cached as it is")
+}
+
+func TestVASTWrapperNurl(t *testing.T) {
+	wrapper := newVASTWrapper(config.VAST{
+		Wrap:               true,
+		Version:            "4.0",
+		ImpressionTrackers: []string{"https://t.example.com/imp?bidder={{.Bidder}}&account={{.AccountID}}&auction={{.AuctionID}}&bid={{.BidID}}"},
+		ErrorTrackers:      []string{"https://t.example.com/err?code=[ERRORCODE]"},
+		Verifications:      []config.VASTVerification{{Vendor: "vendor.com-omid", JavaScriptURL: "https://vendor.com/omid.js", Parameters: "id=1"}},
+	})
+	bid := &openrtb.Bid{ID: "bid 1", NURL: "https://win.example.com/vast"}
+
+	vast := wrapper.vast(bid, "appnexus", "acct-1", "auction-1")
+
+	assert.Equal(t, `<VAST version="4.0"><Ad><Wrapper>`+
+		`<AdSystem>prebid.org wrapper</AdSystem>`+
+		`<VASTAdTagURI><![CDATA[https://win.example.com/vast]]></VASTAdTagURI>`+
+		`<Error><![CDATA[https://t.example.com/err?code=[ERRORCODE]]]></Error>`+
+		`<Impression><![CDATA[https://t.example.com/imp?bidder=appnexus&account=acct-1&auction=auction-1&bid=bid+1]]></Impression>`+
+		`<Creatives></Creatives>`+
+		`<AdVerifications><Verification vendor="vendor.com-omid">`+
+		`<JavaScriptResource apiFramework="omid" browserOptional="true"><![CDATA[https://vendor.com/omid.js]]></JavaScriptResource>`+
+		`<VerificationParameters><![CDATA[id=1]]></VerificationParameters>`+
+		`</Verification></AdVerifications>`+
+		`<Extensions><Extension type="prebid"><Bidder><![CDATA[appnexus]]></Bidder><AccountId><![CDATA[acct-1]]></AccountId>`+
+		`<AuctionId><![CDATA[auction-1]]></AuctionId><BidId><![CDATA[bid 1]]></BidId></Extension></Extensions>`+
+		`</Wrapper></Ad></VAST>`, vast)
+	assert.NoError(t, validateVASTXML(vast, openrtb_ext.BidTypeVideo))
+}
+
+func TestVASTWrapperAdm(t *testing.T) {
+	wrapper := newVASTWrapper(config.VAST{
+		Wrap:               true,
+		ImpressionTrackers: []string{"https://t.example.com/imp?bid={{.BidID}}"},
+		Verifications:      []config.VASTVerification{{Vendor: "vendor", JavaScriptURL: "https://vendor.com/omid.js"}},
+	})
+	bid := &openrtb.Bid{
+		ID: "bid-1",
+		AdM: `<VAST version="3.0">` +
+			`<Ad id="1"><InLine><AdSystem>bidder</AdSystem><Impression><![CDATA[https://bidder.com/imp]]></Impression>` +
+			`<Creatives><Creative></Creative></Creatives><Extensions><Extension type="bidder"></Extension></Extensions></InLine></Ad>` +
+			`<Ad id="2"><Wrapper><AdSystem>bidder</AdSystem><VASTAdTagURI><![CDATA[https://bidder.com/vast]]></VASTAdTagURI></Wrapper></Ad>` +
+			`</VAST>`,
+	}
+
+	vast := wrapper.vast(bid, "appnexus", "acct-1", "auction-1")
+
+	impression := `<Impression><![CDATA[https://t.example.com/imp?bid=bid-1]]></Impression>`
+	verifications := `<Extension type="AdVerifications"><AdVerifications><Verification vendor="vendor">` +
+		`<JavaScriptResource apiFramework="omid" browserOptional="true"><![CDATA[https://vendor.com/omid.js]]></JavaScriptResource>` +
+		`</Verification></AdVerifications></Extension>`
+	ids := `<Extension type="prebid"><Bidder><![CDATA[appnexus]]></Bidder><AccountId><![CDATA[acct-1]]></AccountId>` +
+		`<AuctionId><![CDATA[auction-1]]></AuctionId><BidId><![CDATA[bid-1]]></BidId></Extension>`
+	assert.Equal(t, `<VAST version="3.0">`+
+		`<Ad id="1"><InLine><AdSystem>bidder</AdSystem><Impression><![CDATA[https://bidder.com/imp]]></Impression>`+
+		impression+
+		`<Creatives><Creative></Creative></Creatives><Extensions><Extension type="bidder"></Extension>`+verifications+ids+`</Extensions></InLine></Ad>`+
+		`<Ad id="2"><Wrapper><AdSystem>bidder</AdSystem><VASTAdTagURI><![CDATA[https://bidder.com/vast]]></VASTAdTagURI>`+
+		impression+`<Extensions>`+verifications+ids+`</Extensions></Wrapper></Ad>`+
+		`</VAST>`, vast)
+	assert.NoError(t, validateVASTXML(vast, openrtb_ext.BidTypeVideo))
+}
+
+func TestVASTWrapperAdmVersion4(t *testing.T) {
+	wrapper := newVASTWrapper(config.VAST{
+		Wrap:          true,
+		Verifications: []config.VASTVerification{{Vendor: "vendor", JavaScriptURL: "https://vendor.com/omid.js"}},
+	})
+	bid := &openrtb.Bid{
+		ID:  "bid-1",
+		AdM: `<VAST version="4.1"><Ad><InLine><AdVerifications><Verification vendor="bidder"></Verification></AdVerifications></InLine></Ad></VAST>`,
+	}
+
+	vast := wrapper.vast(bid, "appnexus", "", "auction-1")
+
+	assert.Contains(t, vast, `<AdVerifications><Verification vendor="bidder"></Verification><Verification vendor="vendor">`,
+		"VAST 4 ads should get the verifications in their own <AdVerifications>")
+	assert.NotContains(t, vast, `<Extension type="AdVerifications">`)
+	assert.NoError(t, validateVASTXML(vast, openrtb_ext.BidTypeVideo))
+}
+
+func TestVASTWrapperTrackerOrder(t *testing.T) {
+	wrapper := newVASTWrapper(config.VAST{
+		Wrap:               true,
+		ImpressionTrackers: []string{"https://t.example.com/imp"},
+		ErrorTrackers:      []string{"https://t.example.com/err"},
+	})
+	bid := &openrtb.Bid{
+		ID: "bid-1",
+		AdM: `<VAST version="3.0">` +
+			`<Ad id="1"><InLine><AdSystem>bidder</AdSystem><AdTitle>ad</AdTitle>` +
+			`<Error><![CDATA[https://bidder.com/err]]></Error><Impression><![CDATA[https://bidder.com/imp]]></Impression>` +
+			`<Creatives></Creatives></InLine></Ad>` +
+			`<Ad id="2"><InLine><AdSystem>bidder</AdSystem><AdTitle>ad</AdTitle>` +
+			`<Impression><![CDATA[https://bidder.com/imp]]></Impression><Creatives></Creatives></InLine></Ad>` +
+			`</VAST>`,
+	}
+
+	vast := wrapper.vast(bid, "appnexus", "acct-1", "auction-1")
+
+	hostError := `<Error><![CDATA[https://t.example.com/err]]></Error>`
+	hostImpression := `<Impression><![CDATA[https://t.example.com/imp]]></Impression>`
+	assert.Contains(t, vast, `<Ad id="1"><InLine><AdSystem>bidder</AdSystem><AdTitle>ad</AdTitle>`+
+		`<Error><![CDATA[https://bidder.com/err]]></Error>`+hostError+
+		`<Impression><![CDATA[https://bidder.com/imp]]></Impression>`+hostImpression+
+		`<Creatives></Creatives>`, "The trackers should follow the ad's own Error and Impression elements")
+	assert.Contains(t, vast, `<Ad id="2"><InLine><AdSystem>bidder</AdSystem><AdTitle>ad</AdTitle>`+
+		hostError+
+		`<Impression><![CDATA[https://bidder.com/imp]]></Impression>`+hostImpression+
+		`<Creatives></Creatives>`, "The error trackers should precede the Impression elements of an ad without its own Error")
+	assert.NoError(t, validateVASTXML(vast, openrtb_ext.BidTypeVideo))
+}
+
+func TestVASTWrapperParsesAds(t *testing.T) {
+	wrapper := newVASTWrapper(config.VAST{
+		Wrap:               true,
+		ImpressionTrackers: []string{"https://t.example.com/imp"},
+	})
+	hostImpression := `<Impression><![CDATA[https://t.example.com/imp]]></Impression>`
+	ids := `<Extension type="prebid"><Bidder><![CDATA[appnexus]]></Bidder><AccountId><![CDATA[acct-1]]></AccountId>` +
+		`<AuctionId><![CDATA[auction-1]]></AuctionId><BidId><![CDATA[bid-1]]></BidId></Extension>`
+
+	testCases := []struct {
+		description string
+		adm         string
+		expected    string
+	}{
+		{
+			description: "Element names inside CDATA and comments",
+			adm: `<VAST version="3.0"><Ad><InLine><AdSystem><![CDATA[</Impression><Creatives>]]></AdSystem>` +
+				`<!-- <Impression></Impression> --><Impression><![CDATA[https://bidder.com/imp]]></Impression>` +
+				`<Creatives></Creatives></InLine></Ad></VAST>`,
+			expected: `<VAST version="3.0"><Ad><InLine><AdSystem><![CDATA[</Impression><Creatives>]]></AdSystem>` +
+				`<!-- <Impression></Impression> --><Impression><![CDATA[https://bidder.com/imp]]></Impression>` + hostImpression +
+				`<Creatives></Creatives><Extensions>` + ids + `</Extensions></InLine></Ad></VAST>`,
+		},
+		{
+			description: "Wrapper element nested in an extension",
+			adm: `<VAST version="3.0"><Ad><InLine><AdSystem>bidder</AdSystem><Creatives></Creatives>` +
+				`<Extensions><Extension type="bidder"><Wrapper><Impression></Impression></Wrapper></Extension></Extensions></InLine></Ad></VAST>`,
+			expected: `<VAST version="3.0"><Ad><InLine><AdSystem>bidder</AdSystem>` + hostImpression + `<Creatives></Creatives>` +
+				`<Extensions><Extension type="bidder"><Wrapper><Impression></Impression></Wrapper></Extension>` + ids + `</Extensions></InLine></Ad></VAST>`,
+		},
+		{
+			description: "Self-closing extensions",
+			adm:         `<VAST version="3.0"><Ad><Wrapper><AdSystem>bidder</AdSystem><Extensions/></Wrapper></Ad></VAST>`,
+			expected:    `<VAST version="3.0"><Ad><Wrapper><AdSystem>bidder</AdSystem>` + hostImpression + `<Extensions>` + ids + `</Extensions></Wrapper></Ad></VAST>`,
+		},
+		{
+			description: "Malformed VAST",
+			adm:         `<VAST version="3.0"><Ad><InLine></Ad></VAST>`,
+			expected:    `<VAST version="3.0"><Ad><InLine></Ad></VAST>`,
+		},
+	}
+
+	for _, test := range testCases {
+		vast := wrapper.vast(&openrtb.Bid{ID: "bid-1", AdM: test.adm}, "appnexus", "acct-1", "auction-1")
+		assert.Equal(t, test.expected, vast, test.description)
+	}
+}
+
+func TestCDATA(t *testing.T) {
+	assert.Equal(t, `<![CDATA[a]]]]><![CDATA[>b]]>`, cdata("a]]>b"))
+}
@@ -21,6 +21,14 @@ type UserSyncTemplateParams struct {
 	USPrivacy   string
 }
 
+// TrackerTemplateParams are the macros of the trackers which Prebid Server adds to the markup of bids.
+type TrackerTemplateParams struct {
+	Bidder    string
+	AccountID string
+	AuctionID string
+	BidID     string
+}
+
 // ResolveMacros resolves macros in the given template with the provided params
 func ResolveMacros(aTemplate template.Template, params interface{}) (string, error) {
 	strBuf := bytes.Buffer{}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/prebid/prebid-server/adapters/generic"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/currencies"
 	pbc "github.com/prebid/prebid-server/prebid_cache_client"
@@ -43,10 +44,17 @@ func main() {
 }
 
 const configFileName = "pbs"
+const infoDirectory = "./static/bidder-info"
 
 func loadConfig() (*config.Configuration, error) {
+	// The bidders declared in static/bidder-info must be known before the config sets up their defaults.
+	if err := generic.RegisterBidders(infoDirectory); err != nil {
+		return nil, err
+	}
+
 	v := viper.New()
 	config.SetupViper(v, configFileName)
+	generic.SetDefaults(v)
 	return config.New(v)
 }
 
@@ -62,7 +70,7 @@ func serve(revision string, cfg *config.Configuration) error {
 	pbc.InitPrebidCache(cfg.CacheURL.GetBaseURL())
 
 	corsRouter := router.SupportCORS(r)
-	server.Listen(cfg, router.NoCache{Handler: corsRouter}, router.Admin(revision, currencyConverter), r.MetricsEngine)
+	server.Listen(cfg, router.NoCache{Handler: corsRouter}, router.Admin(revision, currencyConverter, r.StoredRequestValidator), r.MetricsEngine)
 
 	r.Shutdown()
 	return nil
@@ -3,6 +3,7 @@ package openrtb_ext
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 // ExtBid defines the contract for bidresponse.seatbid.bid[i].ext
@@ -111,8 +112,27 @@ const (
 	HbEnvKeyApp string = "mobile-app"
 
 	HbCategoryDurationKey TargetingKey = "hb_pb_cat_dur"
+
+	// HbFormatKey, HbADomainKey, HbSourceKey and HbCrIDKey only exist if the request opts in to them.
+	// They hold the bid's media type, its first advertiser domain, HbSourceS2S and its creative ID.
+	HbFormatKey  TargetingKey = "hb_format"
+	HbADomainKey TargetingKey = "hb_adomain"
+	HbSourceKey  TargetingKey = "hb_source"
+	HbCrIDKey    TargetingKey = "hb_crid"
+
+	// This is not a key, but the value of HbSourceKey, since all of our bids come from the server.
+	HbSourceS2S string = "s2s"
 )
 
+// WithPrefix replaces the "hb" at the start of the key, for requests which set ext.prebid.targeting.prefix.
+// An empty prefix keeps the key as it is.
+func (key TargetingKey) WithPrefix(prefix string) TargetingKey {
+	if prefix == "" {
+		return key
+	}
+	return TargetingKey(prefix + strings.TrimPrefix(string(key), "hb"))
+}
+
 func (key TargetingKey) BidderKey(bidder BidderName, maxLength int) string {
 	s := string(key) + "_" + string(bidder)
 	if maxLength != 0 {
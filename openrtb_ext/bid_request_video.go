@@ -64,11 +64,20 @@ type BidRequestVideo struct {
 	// Attribute:
 	//   video
 	// Type:
-	//   object; required
+	//   object; required if there's no audio
 	// Description:
 	//   Player container object
 	Video *openrtb.Video `json:"video,omitempty"`
 
+	// Attribute:
+	//   audio
+	// Type:
+	//   object; required if there's no video
+	// Description:
+	//   Player container object for audio ad pods. It replaces video, and
+	//   the imps of the pods get it rather than a video object.
+	Audio *openrtb.Audio `json:"audio,omitempty"`
+
 	// Attribute:
 	//   content
 	// Type:
@@ -144,8 +153,32 @@ type BidRequestVideo struct {
 	// Description:
 	//   Indicates that the response should update key to include prefix and tier
 	SupportDeals bool `json:"supportdeals,omitempty"`
+
+	// Attribute:
+	//   targetingprefix
+	// Type:
+	//   string; optional
+	// Description:
+	//   Replaces the "hb" at the start of the targeting keys, like
+	//   ext.prebid.targeting.prefix in /openrtb2/auction requests
+	TargetingPrefix string `json:"targetingprefix,omitempty"`
+
+	// Attribute:
+	//   output
+	// Type:
+	//   string; optional
+	// Description:
+	//   Format of the response: "json" (the default) for the targeting keys of each pod,
+	//   or "vmap" for a VMAP document with an ad break per pod. The "output" query
+	//   parameter overrides it.
+	Output string `json:"output,omitempty"`
 }
 
+const (
+	VideoOutputJSON = "json"
+	VideoOutputVMAP = "vmap"
+)
+
 type PodConfig struct {
 	// Attribute:
 	//   durationrangesec
@@ -168,6 +201,14 @@ type PodConfig struct {
 	//   object; required
 	//  Container object for describing the adPod(s) to be requested.
 	Pods []Pod `json:"pods"`
+
+	// Attribute:
+	//   optimize
+	// Type:
+	//   boolean, optional
+	//  Flag asking Prebid Server to pick the bids of each pod which earn the most,
+	//  rather than returning all of them. Default is false.
+	Optimize bool `json:"optimize,omitempty"`
 }
 
 type Pod struct {
@@ -191,6 +232,21 @@ type Pod struct {
 	//   string; required
 	//  ID of the stored config that corresponds to a single pod request
 	ConfigId string `json:"configid"`
+
+	// Attribute:
+	//   maxads
+	// Type:
+	//   integer; optional
+	//  Most ads which an optimized pod can have. 0 means no limit.
+	MaxAds int `json:"maxads,omitempty"`
+
+	// Attribute:
+	//   timeoffset
+	// Type:
+	//   string; optional
+	//  When the pod's ad break plays, as a VMAP timeOffset: "start", "end", "hh:mm:ss[.mmm]",
+	//  "n%" or "#m". It's only used by VMAP responses, which default to the pod's position in the request.
+	TimeOffset string `json:"timeoffset,omitempty"`
 }
 
 type IncludeBrandCategory struct {
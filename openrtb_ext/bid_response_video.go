@@ -17,4 +17,25 @@ type VideoTargeting struct {
 	HbPb       string `json:"hb_pb"`
 	HbPbCatDur string `json:"hb_pb_cat_dur"`
 	HbCacheID  string `json:"hb_cache_id"`
+	// Position is the slot of the bid in the pod, starting at 1. It's only set if the request asked to optimize its pods.
+	Position int `json:"position,omitempty"`
+	// Prefix replaces the "hb" of the keys, if the request set a targetingprefix.
+	Prefix string `json:"-"`
+}
+
+// MarshalJSON writes the keys with the request's prefix.
+func (vt VideoTargeting) MarshalJSON() ([]byte, error) {
+	if vt.Prefix == "" {
+		type videoTargeting VideoTargeting // Prevents infinite MarshalJSON loops
+		return json.Marshal(videoTargeting(vt))
+	}
+	keys := map[string]interface{}{
+		string(HbpbConstantKey.WithPrefix(vt.Prefix)):       vt.HbPb,
+		string(HbCategoryDurationKey.WithPrefix(vt.Prefix)): vt.HbPbCatDur,
+		string(HbCacheKey.WithPrefix(vt.Prefix)):            vt.HbCacheID,
+	}
+	if vt.Position != 0 {
+		keys["position"] = vt.Position
+	}
+	return json.Marshal(keys)
 }
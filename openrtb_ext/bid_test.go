@@ -16,6 +16,15 @@ func TestTruncatedKey(t *testing.T) {
 	}
 }
 
+func TestKeyWithPrefix(t *testing.T) {
+	if key := HbCacheKey.WithPrefix("pbs"); key != "pbs_cache_id" {
+		t.Errorf("Bad prefixed targeting key. Expected pbs_cache_id, got %s", key)
+	}
+	if key := HbCacheKey.WithPrefix(""); key != HbCacheKey {
+		t.Errorf("An empty prefix should keep the key. Expected hb_cache_id, got %s", key)
+	}
+}
+
 func TestBidParsing(t *testing.T) {
 	assertBidParse(t, "banner", BidTypeBanner)
 	assertBidParse(t, "video", BidTypeVideo)
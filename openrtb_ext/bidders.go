@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/xeipuuv/gojsonschema"
@@ -102,7 +103,8 @@ const (
 	BidderZeroClickFraud   BidderName = "zeroclickfraud"
 )
 
-// BidderMap stores all the valid OpenRTB 2.x Bidders in the project. This map *must not* be mutated.
+// BidderMap stores all the valid OpenRTB 2.x Bidders in the project. This map *must not* be mutated,
+// except by RegisterBidder on startup.
 // The bidder name 'general' is not allowed since it has special meaning in message maps.
 var BidderMap = map[string]BidderName{
 	"33across":          Bidder33Across,
@@ -192,6 +194,75 @@ func BidderList() []BidderName {
 	return bidders
 }
 
+// declaredBidderName matches the names which RegisterBidder and RegisterAlias accept. They are lowercase so that they can be
+// used as config keys and environment variables as-is.
+var declaredBidderName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// declaredSchemas holds the params schemas of the bidders added by RegisterBidder.
+var declaredSchemas = make(map[BidderName]string)
+
+// hostAliases maps the aliases added by RegisterAlias to their core bidders.
+var hostAliases = make(map[BidderName]BidderName)
+
+// RegisterBidder adds a bidder which isn't built into Prebid Server, such as one declared in a static/bidder-info
+// file, to the BidderMap. Its params are validated against paramsSchema, unless static/bidder-params has a file for it.
+//
+// It must be called on startup, before anything reads the BidderMap.
+func RegisterBidder(name string, paramsSchema string) error {
+	if err := validateNewBidderName(name); err != nil {
+		return err
+	}
+	if _, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(paramsSchema)); err != nil {
+		return fmt.Errorf("Invalid params schema for bidder %s: %v", name, err)
+	}
+
+	BidderMap[name] = BidderName(name)
+	declaredSchemas[BidderName(name)] = paramsSchema
+	return nil
+}
+
+// RegisterAlias adds an alias from the host config to the BidderMap. Unlike the aliases in a request,
+// it's a bidder of its own, which takes the same params as its core bidder.
+//
+// It must be called on startup, before anything reads the BidderMap.
+// Registering the same alias again is a no-op, so that it's safe to set up the server more than once.
+func RegisterAlias(alias string, core BidderName) error {
+	if registeredCore, ok := hostAliases[BidderName(alias)]; ok && registeredCore == core {
+		return nil
+	}
+	if _, ok := BidderMap[string(core)]; !ok {
+		return fmt.Errorf("Alias %s refers to the unknown bidder %s.", alias, core)
+	}
+	if _, ok := hostAliases[core]; ok {
+		return fmt.Errorf("Alias %s refers to %s, which is an alias itself.", alias, core)
+	}
+	if err := validateNewBidderName(alias); err != nil {
+		return err
+	}
+
+	BidderMap[alias] = BidderName(alias)
+	hostAliases[BidderName(alias)] = core
+	return nil
+}
+
+// HostAliases returns the aliases added by RegisterAlias, mapped to their core bidders.
+func HostAliases() map[BidderName]BidderName {
+	return hostAliases
+}
+
+func validateNewBidderName(name string) error {
+	if !declaredBidderName.MatchString(name) {
+		return fmt.Errorf("Bidder name %q must be lowercase, and may only contain letters, digits and underscores.", name)
+	}
+	if name == string(BidderNameGeneral) || name == PrebidExtKey || name == FirstPartyDataContextExtKey {
+		return fmt.Errorf("Bidder name %q is reserved.", name)
+	}
+	if _, exists := BidderMap[name]; exists {
+		return fmt.Errorf("Bidder %s is already registered.", name)
+	}
+	return nil
+}
+
 func (name BidderName) MarshalJSON() ([]byte, error) {
 	return []byte(name), nil
 }
@@ -247,6 +318,28 @@ func NewBidderParamsValidator(schemaDirectory string) (BidderParamValidator, err
 		schemaContents[BidderName(bidderName)] = string(fileBytes)
 	}
 
+	for bidderName, schema := range declaredSchemas {
+		if _, hasFile := schemas[bidderName]; hasFile {
+			continue
+		}
+		loadedSchema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the params schema of bidder %s: %v", bidderName, err)
+		}
+		schemas[bidderName] = loadedSchema
+		schemaContents[bidderName] = schema
+	}
+
+	for alias, core := range hostAliases {
+		if _, hasFile := schemas[alias]; hasFile {
+			continue
+		}
+		if coreSchema, ok := schemas[core]; ok {
+			schemas[alias] = coreSchema
+			schemaContents[alias] = schemaContents[core]
+		}
+	}
+
 	return &bidderParamValidator{
 		schemaContents: schemaContents,
 		parsedSchemas:  schemas,
@@ -61,3 +61,100 @@ func TestBidderListDoesNotDefineGeneral(t *testing.T) {
 	bidders := BidderList()
 	assert.NotContains(t, bidders, BidderNameGeneral)
 }
+
+func TestRegisterBidder(t *testing.T) {
+	const schema = `{"type":"object","properties":{"placementId":{"type":"string"}},"required":["placementId"]}`
+	defer unregisterBidder("declared")
+
+	assert.NoError(t, RegisterBidder("declared", schema))
+	assert.Equal(t, BidderName("declared"), BidderMap["declared"])
+	assert.Contains(t, BidderList(), BidderName("declared"))
+
+	paramsValidator, err := NewBidderParamsValidator("../static/bidder-params")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, schema, paramsValidator.Schema("declared"))
+	assert.NoError(t, paramsValidator.Validate("declared", json.RawMessage(`{"placementId":"123"}`)))
+	assert.Error(t, paramsValidator.Validate("declared", json.RawMessage(`{}`)))
+	assert.NotEmpty(t, paramsValidator.Schema(BidderAppnexus), "The schema files should still be loaded")
+}
+
+func TestRegisterBidderErrors(t *testing.T) {
+	const schema = `{"type":"object"}`
+	testCases := []struct {
+		description string
+		name        string
+		schema      string
+		message     string
+	}{
+		{
+			description: "Built-in bidder",
+			name:        "appnexus",
+			schema:      schema,
+			message:     "Bidder appnexus is already registered.",
+		},
+		{
+			description: "Reserved name",
+			name:        "general",
+			schema:      schema,
+			message:     `Bidder name "general" is reserved.`,
+		},
+		{
+			description: "Reserved imp.ext key",
+			name:        "context",
+			schema:      schema,
+			message:     `Bidder name "context" is reserved.`,
+		},
+		{
+			description: "Uppercase name",
+			name:        "myBidder",
+			schema:      schema,
+			message:     `Bidder name "myBidder" must be lowercase, and may only contain letters, digits and underscores.`,
+		},
+		{
+			description: "Invalid schema",
+			name:        "declared",
+			schema:      `{"type":`,
+		},
+	}
+
+	for _, test := range testCases {
+		err := RegisterBidder(test.name, test.schema)
+		if test.message != "" {
+			assert.EqualError(t, err, test.message, test.description)
+		} else {
+			assert.Error(t, err, test.description)
+		}
+	}
+	assert.NotContains(t, BidderMap, "declared", "A bidder with an invalid schema shouldn't be registered")
+	assert.NotContains(t, BidderMap, "general")
+}
+
+func TestRegisterAlias(t *testing.T) {
+	defer unregisterBidder("hostalias")
+
+	assert.NoError(t, RegisterAlias("hostalias", BidderAppnexus))
+	assert.NoError(t, RegisterAlias("hostalias", BidderAppnexus), "Registering the same alias again should be a no-op")
+	assert.Equal(t, BidderName("hostalias"), BidderMap["hostalias"])
+	assert.Equal(t, BidderAppnexus, HostAliases()["hostalias"])
+
+	paramsValidator, err := NewBidderParamsValidator("../static/bidder-params")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, paramsValidator.Schema(BidderAppnexus), paramsValidator.Schema("hostalias"))
+	assert.NoError(t, paramsValidator.Validate("hostalias", json.RawMessage(`{"placementId":123}`)))
+	assert.Error(t, paramsValidator.Validate("hostalias", json.RawMessage(`{}`)))
+
+	assert.EqualError(t, RegisterAlias("otheralias", "hostalias"), "Alias otheralias refers to hostalias, which is an alias itself.")
+	assert.EqualError(t, RegisterAlias("otheralias", "unknown"), "Alias otheralias refers to the unknown bidder unknown.")
+	assert.EqualError(t, RegisterAlias("hostalias", BidderRubicon), "Bidder hostalias is already registered.")
+	assert.EqualError(t, RegisterAlias("rubicon", BidderAppnexus), "Bidder rubicon is already registered.")
+}
+
+func unregisterBidder(name string) {
+	delete(BidderMap, name)
+	delete(declaredSchemas, BidderName(name))
+	delete(hostAliases, BidderName(name))
+}
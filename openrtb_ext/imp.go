@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 )
 
+// FirstPartyDataContextExtKey is the key of bidrequest.imp[i].ext which holds the first party data of the imp,
+// like the key-values of AMP's targeting param in bidrequest.imp[i].ext.context.data. It's sent to every bidder.
+const FirstPartyDataContextExtKey = "context"
+
 // ExtImp defines the contract for bidrequest.imp[i].ext
 type ExtImp struct {
 	Prebid     *ExtImpPrebid     `json:"prebid"`
@@ -33,4 +37,6 @@ type ExtImpPrebid struct {
 // ExtStoredRequest defines the contract for bidrequest.imp[i].ext.prebid.storedrequest
 type ExtStoredRequest struct {
 	ID string `json:"id"`
+	// Version is set by Prebid Server to the version of the Stored Request which was used, if it has versions.
+	Version string `json:"version,omitempty"`
 }
@@ -0,0 +1,293 @@
+package openrtb_ext
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The OpenRTB versions which a Bidder can declare in its static/bidder-info/{bidder}.yaml file.
+const (
+	OpenRTBVersion25 = "2.5"
+	OpenRTBVersion26 = "2.6"
+)
+
+// openrtb26Field is a field which OpenRTB 2.6 added to an object, and the path in that object where
+// Prebid Server keeps it, since it models requests with the OpenRTB 2.5 objects.
+type openrtb26Field struct {
+	name string
+	path []string
+}
+
+var (
+	openrtb26RegsFields = []openrtb26Field{
+		{"gdpr", []string{"ext", "gdpr"}},
+		{"us_privacy", []string{"ext", "us_privacy"}},
+	}
+	openrtb26UserFields = []openrtb26Field{
+		{"consent", []string{"ext", "consent"}},
+		{"eids", []string{"ext", "eids"}},
+	}
+	openrtb26SourceFields = []openrtb26Field{
+		{"schain", []string{"ext", "schain"}},
+	}
+	openrtb26ImpFields = []openrtb26Field{
+		{"rwdd", []string{"ext", PrebidExtKey, "is_rewarded_inventory"}},
+	}
+	openrtb26VideoFields = []openrtb26Field{
+		{"podid", []string{"ext", "podid"}},
+		{"slotinpod", []string{"ext", "slotinpod"}},
+	}
+)
+
+// OpenRTB26VideoExtFields are the fields of imp.video which OpenRTB 2.6 added, and which have no OpenRTB 2.5 location.
+// Prebid Server keeps them in imp.video.ext, and removes them from the requests of Bidders which only support OpenRTB 2.5.
+var OpenRTB26VideoExtFields = []string{"podid", "slotinpod"}
+
+// NormalizeOpenRTB26Request moves the OpenRTB 2.6 fields of a request to the ext locations where Prebid Server
+// reads them, so that the rest of the request can be handled like any OpenRTB 2.5 request:
+//
+//   - regs.gdpr and regs.us_privacy go to regs.ext
+//   - user.consent and user.eids go to user.ext
+//   - source.schain goes to source.ext
+//   - imp[i].rwdd goes to imp[i].ext.prebid.is_rewarded_inventory
+//   - imp[i].video.podid and imp[i].video.slotinpod go to imp[i].video.ext
+//
+// If a value is in both places, the OpenRTB 2.6 one wins. Requests without OpenRTB 2.6 fields are returned as they are.
+// So are requests which aren't well-formed, so that the caller reports their errors like those of any other request.
+func NormalizeOpenRTB26Request(request []byte) ([]byte, error) {
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(request, &req); err != nil {
+		return request, nil
+	}
+
+	changed := false
+	for _, object := range []struct {
+		key    string
+		fields []openrtb26Field
+	}{
+		{"regs", openrtb26RegsFields},
+		{"user", openrtb26UserFields},
+		{"source", openrtb26SourceFields},
+	} {
+		moved, err := moveOpenRTB26Fields(req, object.key, "request."+object.key, object.fields)
+		if err != nil {
+			return nil, err
+		}
+		changed = changed || moved
+	}
+
+	movedImps, err := normalizeOpenRTB26Imps(req)
+	if err != nil {
+		return nil, err
+	}
+	if !changed && !movedImps {
+		return request, nil
+	}
+	return json.Marshal(req)
+}
+
+// UpconvertOpenRTB26Request moves the fields which NormalizeOpenRTB26Request moved back to their OpenRTB 2.6 locations.
+// It's used on the requests which Bidders make, if they support OpenRTB 2.6. Ext objects which are left empty are removed.
+//
+// If a value is in both places, the OpenRTB 2.6 one is kept. Requests which aren't JSON objects, like the ones of Bidders
+// with their own formats, are returned as they are.
+func UpconvertOpenRTB26Request(request []byte) []byte {
+	var req map[string]json.RawMessage
+	if err := json.Unmarshal(request, &req); err != nil || req == nil {
+		return request
+	}
+
+	changed := false
+	for _, object := range []struct {
+		key    string
+		fields []openrtb26Field
+	}{
+		{"regs", openrtb26RegsFields},
+		{"user", openrtb26UserFields},
+		{"source", openrtb26SourceFields},
+	} {
+		changed = upconvertObject(req, object.key, object.fields) || changed
+	}
+
+	var imps []map[string]json.RawMessage
+	if err := json.Unmarshal(req["imp"], &imps); err == nil {
+		changedImps := false
+		for _, imp := range imps {
+			changedImps = upconvertObject(imp, "video", openrtb26VideoFields) || changedImps
+			changedImps = upconvertFields(imp, openrtb26ImpFields) || changedImps
+		}
+		if rawImps, err := json.Marshal(imps); changedImps && err == nil {
+			req["imp"] = rawImps
+			changed = true
+		}
+	}
+
+	if !changed {
+		return request
+	}
+	if upconverted, err := json.Marshal(req); err == nil {
+		return upconverted
+	}
+	return request
+}
+
+// upconvertObject moves the fields of the object at parent[key] to their OpenRTB 2.6 locations.
+func upconvertObject(parent map[string]json.RawMessage, key string, fields []openrtb26Field) bool {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(parent[key], &object); err != nil || object == nil {
+		return false
+	}
+	if !upconvertFields(object, fields) {
+		return false
+	}
+	rawObject, err := json.Marshal(object)
+	if err != nil {
+		return false
+	}
+	parent[key] = rawObject
+	return true
+}
+
+func upconvertFields(object map[string]json.RawMessage, fields []openrtb26Field) bool {
+	moved := false
+	for _, field := range fields {
+		value, ok := takePath(object, field.path)
+		if !ok {
+			continue
+		}
+		if _, exists := object[field.name]; !exists {
+			object[field.name] = value
+		}
+		moved = true
+	}
+	return moved
+}
+
+// takePath removes the value at the path in the object, and its parents if they're left empty.
+func takePath(object map[string]json.RawMessage, keys []string) (json.RawMessage, bool) {
+	raw, ok := object[keys[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(keys) == 1 {
+		delete(object, keys[0])
+		return raw, true
+	}
+
+	var child map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &child); err != nil || child == nil {
+		return nil, false
+	}
+	value, ok := takePath(child, keys[1:])
+	if !ok {
+		return nil, false
+	}
+	if len(child) == 0 {
+		delete(object, keys[0])
+		return value, true
+	}
+	rawChild, err := json.Marshal(child)
+	if err != nil {
+		return nil, false
+	}
+	object[keys[0]] = rawChild
+	return value, true
+}
+
+func normalizeOpenRTB26Imps(req map[string]json.RawMessage) (bool, error) {
+	var imps []map[string]json.RawMessage
+	if err := json.Unmarshal(req["imp"], &imps); err != nil {
+		return false, nil
+	}
+
+	changed := false
+	for i, imp := range imps {
+		path := fmt.Sprintf("request.imp[%d]", i)
+		movedVideo, err := moveOpenRTB26Fields(imp, "video", path+".video", openrtb26VideoFields)
+		if err != nil {
+			return false, err
+		}
+		movedImp, err := moveFields(imp, path, openrtb26ImpFields)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || movedVideo || movedImp
+	}
+	if !changed {
+		return false, nil
+	}
+
+	rawImps, err := json.Marshal(imps)
+	if err != nil {
+		return false, err
+	}
+	req["imp"] = rawImps
+	return true, nil
+}
+
+// moveOpenRTB26Fields moves the fields of the object at parent[key]. Objects which are missing, or aren't
+// JSON objects, are left alone.
+func moveOpenRTB26Fields(parent map[string]json.RawMessage, key string, path string, fields []openrtb26Field) (bool, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(parent[key], &object); err != nil || object == nil {
+		return false, nil
+	}
+	moved, err := moveFields(object, path, fields)
+	if err != nil || !moved {
+		return false, err
+	}
+	rawObject, err := json.Marshal(object)
+	if err != nil {
+		return false, err
+	}
+	parent[key] = rawObject
+	return true, nil
+}
+
+func moveFields(object map[string]json.RawMessage, path string, fields []openrtb26Field) (bool, error) {
+	moved := false
+	for _, field := range fields {
+		value, ok := object[field.name]
+		if !ok {
+			continue
+		}
+		delete(object, field.name)
+		if isJSONNull(value) {
+			continue
+		}
+		if err := setPath(object, path, field.path, value); err != nil {
+			return false, fmt.Errorf("%s.%s can't be moved to %s.%s: %v", path, field.name, path, strings.Join(field.path, "."), err)
+		}
+		moved = true
+	}
+	return moved, nil
+}
+
+func setPath(object map[string]json.RawMessage, path string, keys []string, value json.RawMessage) error {
+	if len(keys) == 1 {
+		object[keys[0]] = value
+		return nil
+	}
+
+	child := make(map[string]json.RawMessage)
+	childPath := path + "." + keys[0]
+	if raw, ok := object[keys[0]]; ok && !isJSONNull(raw) {
+		if err := json.Unmarshal(raw, &child); err != nil {
+			return fmt.Errorf("%s isn't a JSON object", childPath)
+		}
+	}
+	if err := setPath(child, childPath, keys[1:], value); err != nil {
+		return err
+	}
+	rawChild, err := json.Marshal(child)
+	if err != nil {
+		return err
+	}
+	object[keys[0]] = rawChild
+	return nil
+}
+
+func isJSONNull(value json.RawMessage) bool {
+	return strings.TrimSpace(string(value)) == "null"
+}
@@ -0,0 +1,114 @@
+package openrtb_ext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeOpenRTB26Request(t *testing.T) {
+	testCases := []struct {
+		description string
+		request     string
+		expected    string
+	}{
+		{
+			description: "Regs, User and Source",
+			request:     `{"id":"req","regs":{"coppa":1,"gdpr":1,"us_privacy":"1YNN"},"user":{"id":"u","consent":"BOEF","eids":[{"source":"adserver.org","uids":[{"id":"tdid"}]}]},"source":{"tid":"t","schain":{"complete":1,"nodes":[],"ver":"1.0"}}}`,
+			expected:    `{"id":"req","regs":{"coppa":1,"ext":{"gdpr":1,"us_privacy":"1YNN"}},"user":{"id":"u","ext":{"consent":"BOEF","eids":[{"source":"adserver.org","uids":[{"id":"tdid"}]}]}},"source":{"tid":"t","ext":{"schain":{"complete":1,"nodes":[],"ver":"1.0"}}}}`,
+		},
+		{
+			description: "OpenRTB 2.6 values win",
+			request:     `{"regs":{"gdpr":0,"ext":{"gdpr":1,"other":true}},"user":{"consent":"new","ext":{"consent":"old"}}}`,
+			expected:    `{"regs":{"ext":{"gdpr":0,"other":true}},"user":{"ext":{"consent":"new"}}}`,
+		},
+		{
+			description: "Imps",
+			request:     `{"imp":[{"id":"1","rwdd":1,"video":{"mimes":["video/mp4"],"podid":"pod-1","slotinpod":1},"ext":{"prebid":{"storedrequest":{"id":"s"}},"appnexus":{"placementId":1}}},{"id":"2","banner":{}}]}`,
+			expected:    `{"imp":[{"ext":{"appnexus":{"placementId":1},"prebid":{"is_rewarded_inventory":1,"storedrequest":{"id":"s"}}},"id":"1","video":{"ext":{"podid":"pod-1","slotinpod":1},"mimes":["video/mp4"]}},{"id":"2","banner":{}}]}`,
+		},
+		{
+			description: "Null values are dropped",
+			request:     `{"regs":{"gdpr":null,"ext":{"gdpr":1}},"imp":[{"id":"1","rwdd":1}]}`,
+			expected:    `{"imp":[{"ext":{"prebid":{"is_rewarded_inventory":1}},"id":"1"}],"regs":{"gdpr":null,"ext":{"gdpr":1}}}`,
+		},
+		{
+			description: "OpenRTB 2.5 requests are unchanged",
+			request:     `{"id":"req", "regs":{"ext":{"gdpr":1}}}`,
+			expected:    `{"id":"req", "regs":{"ext":{"gdpr":1}}}`,
+		},
+		{
+			description: "Malformed objects are unchanged",
+			request:     `{"regs":"gdpr", "imp":{"rwdd":1}}`,
+			expected:    `{"regs":"gdpr", "imp":{"rwdd":1}}`,
+		},
+	}
+
+	for _, test := range testCases {
+		actual, err := NormalizeOpenRTB26Request([]byte(test.request))
+		if assert.NoError(t, err, test.description) {
+			assert.JSONEq(t, test.expected, string(actual), test.description)
+		}
+	}
+}
+
+func TestNormalizeOpenRTB26RequestMalformed(t *testing.T) {
+	request := []byte(`{"id":"req", "regs":`)
+	actual, err := NormalizeOpenRTB26Request(request)
+	assert.NoError(t, err)
+	assert.Equal(t, request, actual, "Malformed requests should be left for the caller to report")
+}
+
+func TestNormalizeOpenRTB26RequestErrors(t *testing.T) {
+	_, err := NormalizeOpenRTB26Request([]byte(`{"regs":{"gdpr":1,"ext":"gdpr"}}`))
+	assert.EqualError(t, err, "request.regs.gdpr can't be moved to request.regs.ext.gdpr: request.regs.ext isn't a JSON object")
+
+	_, err = NormalizeOpenRTB26Request([]byte(`{"imp":[{"id":"1"},{"id":"2","rwdd":1,"ext":{"prebid":[]}}]}`))
+	assert.EqualError(t, err, "request.imp[1].rwdd can't be moved to request.imp[1].ext.prebid.is_rewarded_inventory: request.imp[1].ext.prebid isn't a JSON object")
+}
+
+func TestUpconvertOpenRTB26Request(t *testing.T) {
+	testCases := []struct {
+		description string
+		request     string
+		expected    string
+	}{
+		{
+			description: "Regs, User and Source",
+			request:     `{"id":"req","regs":{"coppa":1,"ext":{"gdpr":1,"us_privacy":"1YNN"}},"user":{"id":"u","ext":{"consent":"BOEF","eids":[{"source":"adserver.org","uids":[{"id":"tdid"}]}],"digitrust":{"id":"d"}}},"source":{"tid":"t","ext":{"schain":{"complete":1,"nodes":[],"ver":"1.0"}}}}`,
+			expected:    `{"id":"req","regs":{"coppa":1,"gdpr":1,"us_privacy":"1YNN"},"user":{"id":"u","consent":"BOEF","eids":[{"source":"adserver.org","uids":[{"id":"tdid"}]}],"ext":{"digitrust":{"id":"d"}}},"source":{"tid":"t","schain":{"complete":1,"nodes":[],"ver":"1.0"}}}`,
+		},
+		{
+			description: "OpenRTB 2.6 values win",
+			request:     `{"regs":{"gdpr":0,"ext":{"gdpr":1}}}`,
+			expected:    `{"regs":{"gdpr":0}}`,
+		},
+		{
+			description: "Imps",
+			request:     `{"imp":[{"id":"1","video":{"mimes":["video/mp4"],"ext":{"podid":"pod-1","slotinpod":1}},"ext":{"prebid":{"is_rewarded_inventory":1},"bidder":{"placementId":1}}},{"id":"2","banner":{}}]}`,
+			expected:    `{"imp":[{"id":"1","rwdd":1,"video":{"mimes":["video/mp4"],"podid":"pod-1","slotinpod":1},"ext":{"bidder":{"placementId":1}}},{"id":"2","banner":{}}]}`,
+		},
+		{
+			description: "OpenRTB 2.5 requests without these fields are unchanged",
+			request:     `{"id":"req", "regs":{"coppa":1}}`,
+			expected:    `{"id":"req", "regs":{"coppa":1}}`,
+		},
+		{
+			description: "Other formats are unchanged",
+			request:     `[{"placement":1}]`,
+			expected:    `[{"placement":1}]`,
+		},
+	}
+
+	for _, test := range testCases {
+		assert.JSONEq(t, test.expected, string(UpconvertOpenRTB26Request([]byte(test.request))), test.description)
+	}
+}
+
+func TestUpconvertNormalizedRequest(t *testing.T) {
+	request := `{"regs":{"gdpr":1},"user":{"consent":"BOEF","eids":[{"source":"adserver.org"}]},"source":{"schain":{"ver":"1.0"}},"imp":[{"id":"1","rwdd":1,"video":{"podid":"pod-1"}}]}`
+	normalized, err := NormalizeOpenRTB26Request([]byte(request))
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, request, string(UpconvertOpenRTB26Request(normalized)), "Upconverting should undo the normalization")
+	}
+}
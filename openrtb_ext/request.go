@@ -3,6 +3,8 @@ package openrtb_ext
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"regexp"
 )
 
 // ExtRequest defines the contract for bidrequest.ext
@@ -12,12 +14,73 @@ type ExtRequest struct {
 
 // ExtRequestPrebid defines the contract for bidrequest.ext.prebid
 type ExtRequestPrebid struct {
-	Aliases              map[string]string      `json:"aliases,omitempty"`
-	BidAdjustmentFactors map[string]float64     `json:"bidadjustmentfactors,omitempty"`
-	Cache                *ExtRequestPrebidCache `json:"cache,omitempty"`
-	StoredRequest        *ExtStoredRequest      `json:"storedrequest,omitempty"`
-	Targeting            *ExtRequestTargeting   `json:"targeting,omitempty"`
-	SupportDeals         bool                   `json:"supportdeals,omitempty"`
+	Aliases              map[string]string               `json:"aliases,omitempty"`
+	AMP                  *ExtRequestPrebidAMP            `json:"amp,omitempty"`
+	BidAdjustmentFactors map[string]float64              `json:"bidadjustmentfactors,omitempty"`
+	BidAdjustments       *ExtRequestPrebidBidAdjustments `json:"bidadjustments,omitempty"`
+	Cache                *ExtRequestPrebidCache          `json:"cache,omitempty"`
+	StoredRequest        *ExtStoredRequest               `json:"storedrequest,omitempty"`
+	Targeting            *ExtRequestTargeting            `json:"targeting,omitempty"`
+	SupportDeals         bool                            `json:"supportdeals,omitempty"`
+	Trace                TraceLevel                      `json:"trace,omitempty"`
+}
+
+// TraceLevel is the detail of the auction trace asked for in bidrequest.ext.prebid.trace
+type TraceLevel string
+
+const (
+	// TraceNone returns no trace.
+	TraceNone TraceLevel = ""
+	// TraceBasic returns the decisions which Prebid Server made for the whole request and for each bidder.
+	TraceBasic TraceLevel = "basic"
+	// TraceVerbose also returns the decisions which Prebid Server made for each imp and bid.
+	TraceVerbose TraceLevel = "verbose"
+)
+
+// Includes returns true if a trace with this level returns the steps of the other level.
+func (level TraceLevel) Includes(other TraceLevel) bool {
+	switch level {
+	case TraceVerbose:
+		return other == TraceBasic || other == TraceVerbose
+	case TraceBasic:
+		return other == TraceBasic
+	}
+	return false
+}
+
+// ExtRequestPrebidAMP defines the contract for bidrequest.ext.prebid.amp
+type ExtRequestPrebidAMP struct {
+	// Data holds the query params of the AMP request.
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// ExtRequestPrebidBidAdjustments defines the contract for bidrequest.ext.prebid.bidadjustments
+//
+// The adjustments are keyed by media type, then by bidder, then by deal ID.
+// The bidder and deal ID can be "*" to match all of them.
+type ExtRequestPrebidBidAdjustments struct {
+	MediaType map[BidType]map[string]map[string][]ExtBidAdjustment `json:"mediatype,omitempty"`
+}
+
+// BidAdjustmentWildcard matches every bidder, or every deal ID, in bidrequest.ext.prebid.bidadjustments
+const BidAdjustmentWildcard = "*"
+
+// BidAdjustmentType is the way in which an adjustment changes the price of a bid.
+type BidAdjustmentType string
+
+const (
+	// BidAdjustmentMultiplier multiplies the price by the value.
+	BidAdjustmentMultiplier BidAdjustmentType = "multiplier"
+	// BidAdjustmentStatic adds the value to the price. Negative values lower it.
+	BidAdjustmentStatic BidAdjustmentType = "static"
+)
+
+// ExtBidAdjustment defines the contract for an adjustment in bidrequest.ext.prebid.bidadjustments
+type ExtBidAdjustment struct {
+	AdjType BidAdjustmentType `json:"adjtype"`
+	Value   float64           `json:"value"`
+	// Currency of a static Value. It defaults to USD.
+	Currency string `json:"currency,omitempty"`
 }
 
 // ExtRequestPrebidCache defines the contract for bidrequest.ext.prebid.cache
@@ -55,6 +118,25 @@ type ExtRequestTargeting struct {
 	IncludeBidderKeys    bool                     `json:"includebidderkeys"`
 	IncludeBrandCategory *ExtIncludeBrandCategory `json:"includebrandcategory"`
 	DurationRangeSec     []int                    `json:"durationrangesec"`
+	// Prefix replaces the "hb" at the start of every targeting key.
+	Prefix string `json:"prefix,omitempty"`
+	// IncludeFormat, IncludeADomain, IncludeSource and IncludeCrID opt in to the hb_format, hb_adomain,
+	// hb_source and hb_crid keys.
+	IncludeFormat  bool `json:"includeformat,omitempty"`
+	IncludeADomain bool `json:"includeadomain,omitempty"`
+	IncludeSource  bool `json:"includesource,omitempty"`
+	IncludeCrID    bool `json:"includecrid,omitempty"`
+}
+
+var targetingPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateTargetingPrefix makes sure that ad servers will accept the keys which start with the prefix.
+// The empty prefix keeps the default "hb".
+func ValidateTargetingPrefix(prefix string) error {
+	if prefix != "" && !targetingPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("prefix must only contain letters, digits, underscores and dashes. Got \"%s\"", prefix)
+	}
+	return nil
 }
 
 type ExtIncludeBrandCategory struct {
@@ -83,6 +165,9 @@ func (ert *ExtRequestTargeting) UnmarshalJSON(b []byte) error {
 		if !defaults.IncludeWinners && !defaults.IncludeBidderKeys {
 			return errors.New("ext.prebid.targeting: At least one of includewinners or includebidderkeys must be enabled to enable targeting support")
 		}
+		if err := ValidateTargetingPrefix(defaults.Prefix); err != nil {
+			return fmt.Errorf("ext.prebid.targeting.%v", err)
+		}
 		*ert = ExtRequestTargeting(*defaults)
 	}
 
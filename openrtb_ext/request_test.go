@@ -48,6 +48,15 @@ func TestExtRequestTargeting(t *testing.T) {
 	}
 }
 
+func TestExtRequestTargetingPrefix(t *testing.T) {
+	var targeting ExtRequestTargeting
+	if assert.NoError(t, json.Unmarshal([]byte(`{"prefix":"pbs-s2s"}`), &targeting)) {
+		assert.Equal(t, "pbs-s2s", targeting.Prefix)
+	}
+	err := json.Unmarshal([]byte(`{"prefix":"pbs s2s"}`), &targeting)
+	assert.EqualError(t, err, `ext.prebid.targeting.prefix must only contain letters, digits, underscores and dashes. Got "pbs s2s"`)
+}
+
 const ext1 = `{
 	"prebid": {
 		"non_target": "some junk"
@@ -9,6 +9,8 @@ type ExtBidResponse struct {
 	Debug *ExtResponseDebug `json:"debug,omitempty"`
 	// Errors defines the contract for bidresponse.ext.errors
 	Errors map[BidderName][]ExtBidderError `json:"errors,omitempty"`
+	// Warnings defines the contract for bidresponse.ext.warnings
+	Warnings map[BidderName][]ExtBidderError `json:"warnings,omitempty"`
 	// ResponseTimeMillis defines the contract for bidresponse.ext.responsetimemillis
 	ResponseTimeMillis map[BidderName]int `json:"responsetimemillis,omitempty"`
 	// RequestTimeoutMillis returns the timeout used in the auction.
@@ -17,6 +19,8 @@ type ExtBidResponse struct {
 	RequestTimeoutMillis int64 `json:"tmaxrequest,omitempty"`
 	// ResponseUserSync defines the contract for bidresponse.ext.usersync
 	Usersync map[BidderName]*ExtResponseSyncData `json:"usersync,omitempty"`
+	// Trace defines the contract for bidresponse.ext.trace
+	Trace []ExtTraceEvent `json:"trace,omitempty"`
 }
 
 // ExtResponseDebug defines the contract for bidresponse.ext.debug
@@ -25,6 +29,17 @@ type ExtResponseDebug struct {
 	HttpCalls map[BidderName][]*ExtHttpCall `json:"httpcalls,omitempty"`
 	// Request after resolution of stored requests and debug overrides
 	ResolvedRequest *openrtb.BidRequest `json:"resolvedrequest,omitempty"`
+	// Throttled holds the IDs of the imps which the host's throttling rules kept from each bidder
+	Throttled map[BidderName][]string `json:"throttled,omitempty"`
+}
+
+// ExtTraceEvent defines the contract for bidresponse.ext.trace[i]
+type ExtTraceEvent struct {
+	// Stage is the part of the auction which the event happened in, e.g. "stored_request" or "cache".
+	Stage string `json:"stage"`
+	// Bidder is set if the event only concerns one bidder.
+	Bidder  string `json:"bidder,omitempty"`
+	Message string `json:"message"`
 }
 
 // ExtResponseSyncData defines the contract for bidresponse.ext.usersync.{bidder}
@@ -16,6 +16,15 @@ type ExtUser struct {
 	DigiTrust *ExtUserDigiTrust `json:"digitrust,omitempty"`
 
 	Eids []ExtUserEid `json:"eids,omitempty"`
+
+	// ConsentedProvidersSettings holds the Additional Consent string of Google, which lists the ad tech providers
+	// that aren't registered with the IAB but which the user consented to.
+	ConsentedProvidersSettings *ExtUserConsentedProvidersSettings `json:"ConsentedProvidersSettings,omitempty"`
+}
+
+// ExtUserConsentedProvidersSettings defines the contract for bidrequest.user.ext.ConsentedProvidersSettings
+type ExtUserConsentedProvidersSettings struct {
+	ConsentedProviders string `json:"consented_providers,omitempty"`
 }
 
 // ExtUserPrebid defines the contract for bidrequest.user.ext.prebid
@@ -0,0 +1,215 @@
+package pbs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// ToOpenRTBRequest translates the legacy request into an OpenRTB request for some of its bidders,
+// so that their bids can come from the OpenRTB exchange.
+//
+// Every ad unit of these bidders becomes an imp whose ID is the ad unit code, with the legacy
+// params of each bidder in imp.ext.{bidder}.
+func ToOpenRTBRequest(req *PBSRequest, bidders []*PBSBidder) (*openrtb.BidRequest, error) {
+	secure := req.Secure
+	bidRequest := &openrtb.BidRequest{
+		ID:     req.Tid,
+		Regs:   req.Regs,
+		Source: &openrtb.Source{TID: req.Tid},
+		TMax:   req.TimeoutMillis,
+		Cur:    []string{"USD"},
+	}
+	if bidRequest.ID == "" {
+		bidRequest.ID = fmt.Sprintf("%d", rand.Int63())
+	}
+	if req.IsDebug {
+		bidRequest.Test = 1
+	}
+
+	// The legacy adapters may still be reading these, so the exchange gets copies.
+	if req.Device != nil {
+		device := *req.Device
+		bidRequest.Device = &device
+	}
+	if req.User != nil {
+		user := *req.User
+		bidRequest.User = &user
+	}
+	if req.App != nil {
+		app := *req.App
+		if app.Publisher == nil || app.Publisher.ID == "" {
+			app.Publisher = &openrtb.Publisher{ID: req.AccountID}
+		}
+		bidRequest.App = &app
+	} else {
+		bidRequest.Site = &openrtb.Site{
+			Page:      req.Url,
+			Domain:    req.Domain,
+			Publisher: &openrtb.Publisher{ID: req.AccountID},
+		}
+	}
+
+	impExts := make(map[string]map[string]json.RawMessage)
+	aliases := make(map[string]string)
+	for _, bidder := range bidders {
+		if core, ok := config.LegacyBidderAliases[bidder.BidderCode]; ok {
+			aliases[bidder.BidderCode] = string(core)
+		}
+		for _, adUnit := range bidder.AdUnits {
+			impExt, ok := impExts[adUnit.Code]
+			if !ok {
+				impExt = make(map[string]json.RawMessage)
+				impExts[adUnit.Code] = impExt
+				bidRequest.Imp = append(bidRequest.Imp, toOpenRTBImp(adUnit, &secure))
+			}
+			impExt[bidder.BidderCode] = adUnit.Params
+		}
+	}
+	for i := range bidRequest.Imp {
+		impExt, err := json.Marshal(impExts[bidRequest.Imp[i].ID])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid params for ad unit %s: %v", bidRequest.Imp[i].ID, err)
+		}
+		bidRequest.Imp[i].Ext = impExt
+	}
+
+	if len(aliases) > 0 {
+		requestExt, err := json.Marshal(openrtb_ext.ExtRequest{Prebid: openrtb_ext.ExtRequestPrebid{Aliases: aliases}})
+		if err != nil {
+			return nil, err
+		}
+		bidRequest.Ext = requestExt
+	}
+	return bidRequest, nil
+}
+
+func toOpenRTBImp(adUnit PBSAdUnit, secure *int8) openrtb.Imp {
+	imp := openrtb.Imp{
+		ID:     adUnit.Code,
+		Instl:  adUnit.Instl,
+		Secure: secure,
+	}
+	for _, mediaType := range adUnit.MediaTypes {
+		switch mediaType {
+		case MEDIA_TYPE_BANNER:
+			imp.Banner = &openrtb.Banner{
+				Format:   adUnit.Sizes,
+				TopFrame: adUnit.TopFrame,
+			}
+		case MEDIA_TYPE_VIDEO:
+			imp.Video = toOpenRTBVideo(adUnit)
+		}
+	}
+	return imp
+}
+
+func toOpenRTBVideo(adUnit PBSAdUnit) *openrtb.Video {
+	startDelay := openrtb.StartDelay(adUnit.Video.Startdelay)
+	skip := int8(adUnit.Video.Skippable)
+	video := &openrtb.Video{
+		MIMEs:       adUnit.Video.Mimes,
+		MinDuration: adUnit.Video.Minduration,
+		MaxDuration: adUnit.Video.Maxduration,
+		StartDelay:  &startDelay,
+		Skip:        &skip,
+	}
+	if adUnit.Video.PlaybackMethod != 0 {
+		video.PlaybackMethod = []openrtb.PlaybackMethod{openrtb.PlaybackMethod(adUnit.Video.PlaybackMethod)}
+	}
+	for _, protocol := range adUnit.Video.Protocols {
+		video.Protocols = append(video.Protocols, openrtb.Protocol(protocol))
+	}
+	if len(adUnit.Sizes) > 0 {
+		video.W = adUnit.Sizes[0].W
+		video.H = adUnit.Sizes[0].H
+	}
+	return video
+}
+
+// FromOpenRTBResponse translates the exchange's response to a request made by ToOpenRTBRequest back into legacy bids.
+//
+// It also fills in the status of the bidders: their response times, bid counts, errors and debug info.
+func FromOpenRTBResponse(bidResponse *openrtb.BidResponse, bidders []*PBSBidder) (PBSBidSlice, error) {
+	var responseExt openrtb_ext.ExtBidResponse
+	if len(bidResponse.Ext) > 0 {
+		if err := json.Unmarshal(bidResponse.Ext, &responseExt); err != nil {
+			return nil, fmt.Errorf("Invalid response.ext: %v", err)
+		}
+	}
+
+	bidderCodes := make(map[string]*PBSBidder, len(bidders))
+	for _, bidder := range bidders {
+		bidderCodes[bidder.BidderCode] = bidder
+		name := openrtb_ext.BidderName(bidder.BidderCode)
+		bidder.ResponseTime = responseExt.ResponseTimeMillis[name]
+		bidder.Error = toLegacyError(responseExt.Errors[name])
+		if responseExt.Debug != nil {
+			for _, httpCall := range responseExt.Debug.HttpCalls[name] {
+				bidder.Debug = append(bidder.Debug, &BidderDebug{
+					RequestURI:   httpCall.Uri,
+					RequestBody:  httpCall.RequestBody,
+					ResponseBody: httpCall.ResponseBody,
+					StatusCode:   httpCall.Status,
+				})
+			}
+		}
+	}
+
+	var bids PBSBidSlice
+	for _, seatBid := range bidResponse.SeatBid {
+		bidder, ok := bidderCodes[seatBid.Seat]
+		if !ok {
+			continue
+		}
+		for i := range seatBid.Bid {
+			bid := &seatBid.Bid[i]
+			var bidExt openrtb_ext.ExtBid
+			if err := json.Unmarshal(bid.Ext, &bidExt); err != nil || bidExt.Prebid == nil {
+				return nil, fmt.Errorf("Bid %s from %s has no ext.prebid.type", bid.ID, seatBid.Seat)
+			}
+			bids = append(bids, &PBSBid{
+				BidID:             bidder.LookupBidID(bid.ImpID),
+				AdUnitCode:        bid.ImpID,
+				Creative_id:       bid.CrID,
+				CreativeMediaType: string(bidExt.Prebid.Type),
+				BidderCode:        seatBid.Seat,
+				Price:             bid.Price,
+				NURL:              bid.NURL,
+				Adm:               bid.AdM,
+				Width:             bid.W,
+				Height:            bid.H,
+				DealId:            bid.DealID,
+				ResponseTime:      bidder.ResponseTime,
+			})
+			bidder.NumBids++
+		}
+	}
+
+	for _, bidder := range bidders {
+		if bidder.NumBids == 0 && bidder.Error == "" {
+			bidder.NoBid = true
+		}
+	}
+	return bids, nil
+}
+
+// toLegacyError returns the first error of a bidder, in the words of the legacy endpoint.
+// Warnings are left out, since the legacy response can't tell them apart from errors.
+func toLegacyError(errs []openrtb_ext.ExtBidderError) string {
+	for _, err := range errs {
+		if err.Code >= errortypes.InvalidPrivacyConsentWarningCode && err.Code <= errortypes.UnknownWarningCode {
+			continue
+		}
+		if err.Code == errortypes.TimeoutErrorCode {
+			return "Timed out"
+		}
+		return err.Message
+	}
+	return ""
+}
@@ -0,0 +1,173 @@
+package pbs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToOpenRTBRequest(t *testing.T) {
+	req := &PBSRequest{
+		AccountID:     "account",
+		Tid:           "transaction",
+		Secure:        1,
+		TimeoutMillis: 500,
+		IsDebug:       true,
+		Device:        &openrtb.Device{IP: "203.0.113.1", UA: "Mozilla"},
+		User:          &openrtb.User{Ext: json.RawMessage(`{"consent":"BOONs2HOONs2HABABBENAGgAAAAPrABACGA"}`)},
+		Regs:          &openrtb.Regs{Ext: json.RawMessage(`{"gdpr":1}`)},
+		Url:           "https://www.example.com/page",
+		Domain:        "example.com",
+	}
+	banner := PBSAdUnit{
+		Code:       "banner-unit",
+		Sizes:      []openrtb.Format{{W: 300, H: 250}},
+		TopFrame:   1,
+		MediaTypes: []MediaType{MEDIA_TYPE_BANNER},
+	}
+	video := PBSAdUnit{
+		Code:       "video-unit",
+		Sizes:      []openrtb.Format{{W: 640, H: 480}},
+		MediaTypes: []MediaType{MEDIA_TYPE_VIDEO},
+		Video: PBSVideo{
+			Mimes:          []string{"video/mp4"},
+			Maxduration:    30,
+			Skippable:      1,
+			PlaybackMethod: 2,
+			Protocols:      []int8{2, 3},
+		},
+	}
+	appnexus := &PBSBidder{BidderCode: "appnexus", AdUnits: []PBSAdUnit{
+		withParams(banner, "anx-banner", `{"placementId":1}`),
+		withParams(video, "anx-video", `{"placementId":2}`),
+	}}
+	districtm := &PBSBidder{BidderCode: "districtm", AdUnits: []PBSAdUnit{
+		withParams(banner, "dm-banner", `{"placementId":3}`),
+	}}
+
+	bidRequest, err := ToOpenRTBRequest(req, []*PBSBidder{appnexus, districtm})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, "transaction", bidRequest.ID)
+	assert.Equal(t, "transaction", bidRequest.Source.TID)
+	assert.Equal(t, int64(500), bidRequest.TMax)
+	assert.Equal(t, int8(1), bidRequest.Test)
+	assert.Equal(t, []string{"USD"}, bidRequest.Cur)
+	assert.Equal(t, &openrtb.Site{Page: "https://www.example.com/page", Domain: "example.com", Publisher: &openrtb.Publisher{ID: "account"}}, bidRequest.Site)
+	assert.Equal(t, req.Device, bidRequest.Device)
+	assert.False(t, req.Device == bidRequest.Device, "The exchange should get a copy of the device, since legacy adapters may still read it")
+	assert.Equal(t, req.Regs, bidRequest.Regs)
+	assert.JSONEq(t, `{"prebid":{"aliases":{"districtm":"appnexus"}}}`, string(bidRequest.Ext))
+
+	if !assert.Len(t, bidRequest.Imp, 2) {
+		return
+	}
+	bannerImp := bidRequest.Imp[0]
+	assert.Equal(t, "banner-unit", bannerImp.ID)
+	assert.Equal(t, &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}}, TopFrame: 1}, bannerImp.Banner)
+	assert.Nil(t, bannerImp.Video)
+	assert.Equal(t, int8(1), *bannerImp.Secure)
+	assert.JSONEq(t, `{"appnexus":{"placementId":1},"districtm":{"placementId":3}}`, string(bannerImp.Ext))
+
+	videoImp := bidRequest.Imp[1]
+	assert.Equal(t, "video-unit", videoImp.ID)
+	assert.Nil(t, videoImp.Banner)
+	if assert.NotNil(t, videoImp.Video) {
+		assert.Equal(t, []string{"video/mp4"}, videoImp.Video.MIMEs)
+		assert.Equal(t, int64(30), videoImp.Video.MaxDuration)
+		assert.Equal(t, int8(1), *videoImp.Video.Skip)
+		assert.Equal(t, []openrtb.PlaybackMethod{2}, videoImp.Video.PlaybackMethod)
+		assert.Equal(t, []openrtb.Protocol{2, 3}, videoImp.Video.Protocols)
+		assert.Equal(t, uint64(640), videoImp.Video.W)
+		assert.Equal(t, uint64(480), videoImp.Video.H)
+	}
+	assert.JSONEq(t, `{"appnexus":{"placementId":2}}`, string(videoImp.Ext))
+}
+
+func TestToOpenRTBRequestApp(t *testing.T) {
+	req := &PBSRequest{
+		AccountID: "account",
+		App:       &openrtb.App{Bundle: "com.example.app"},
+	}
+	bidder := &PBSBidder{BidderCode: "appnexus", AdUnits: []PBSAdUnit{{Code: "unit", MediaTypes: []MediaType{MEDIA_TYPE_BANNER}}}}
+
+	bidRequest, err := ToOpenRTBRequest(req, []*PBSBidder{bidder})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Nil(t, bidRequest.Site)
+	assert.Equal(t, &openrtb.App{Bundle: "com.example.app", Publisher: &openrtb.Publisher{ID: "account"}}, bidRequest.App)
+	assert.Nil(t, req.App.Publisher, "The legacy request shouldn't be modified")
+	assert.NotEmpty(t, bidRequest.ID, "The request should have an ID without a tid")
+	assert.Empty(t, bidRequest.Ext)
+}
+
+func TestFromOpenRTBResponse(t *testing.T) {
+	appnexus := &PBSBidder{BidderCode: "appnexus", AdUnits: []PBSAdUnit{{Code: "banner-unit", BidID: "anx-banner"}}}
+	rubicon := &PBSBidder{BidderCode: "rubicon", AdUnits: []PBSAdUnit{{Code: "banner-unit", BidID: "rp-banner"}}}
+	pubmatic := &PBSBidder{BidderCode: "pubmatic", AdUnits: []PBSAdUnit{{Code: "banner-unit", BidID: "pm-banner"}}}
+
+	bidResponse := &openrtb.BidResponse{
+		SeatBid: []openrtb.SeatBid{{
+			Seat: "appnexus",
+			Bid: []openrtb.Bid{{
+				ID:     "bid",
+				ImpID:  "banner-unit",
+				Price:  1.5,
+				AdM:    "<div>ad</div>",
+				CrID:   "creative",
+				W:      300,
+				H:      250,
+				DealID: "deal",
+				Ext:    json.RawMessage(`{"prebid":{"type":"banner"}}`),
+			}},
+		}},
+		Ext: json.RawMessage(`{
+			"responsetimemillis":{"appnexus":25,"rubicon":100,"pubmatic":30},
+			"errors":{
+				"rubicon":[{"code":1,"message":"context deadline exceeded"}],
+				"pubmatic":[{"code":10001,"message":"consent warning"}]
+			},
+			"debug":{"httpcalls":{"appnexus":[{"uri":"http://ib.adnxs.com","requestbody":"{}","responsebody":"{}","status":200}]}}
+		}`),
+	}
+
+	bids, err := FromOpenRTBResponse(bidResponse, []*PBSBidder{appnexus, rubicon, pubmatic})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, PBSBidSlice{{
+		BidID:             "anx-banner",
+		AdUnitCode:        "banner-unit",
+		Creative_id:       "creative",
+		CreativeMediaType: "banner",
+		BidderCode:        "appnexus",
+		Price:             1.5,
+		Adm:               "<div>ad</div>",
+		Width:             300,
+		Height:            250,
+		DealId:            "deal",
+		ResponseTime:      25,
+	}}, bids)
+
+	assert.Equal(t, 1, appnexus.NumBids)
+	assert.Equal(t, 25, appnexus.ResponseTime)
+	assert.Equal(t, []*BidderDebug{{RequestURI: "http://ib.adnxs.com", RequestBody: "{}", ResponseBody: "{}", StatusCode: 200}}, appnexus.Debug)
+
+	assert.Equal(t, "Timed out", rubicon.Error)
+	assert.False(t, rubicon.NoBid, "A bidder with an error shouldn't be flagged as a no-bid")
+
+	assert.Empty(t, pubmatic.Error, "Warnings shouldn't become legacy errors")
+	assert.True(t, pubmatic.NoBid)
+}
+
+func withParams(adUnit PBSAdUnit, bidID string, params string) PBSAdUnit {
+	adUnit.BidID = bidID
+	adUnit.Params = json.RawMessage(params)
+	return adUnit
+}
@@ -3,10 +3,12 @@ package config
 import (
 	"time"
 
+	"github.com/golang/glog"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	prometheusmetrics "github.com/prebid/prebid-server/pbsmetrics/prometheus"
+	statsdmetrics "github.com/prebid/prebid-server/pbsmetrics/statsd"
 	metrics "github.com/rcrowley/go-metrics"
 	influxdb "github.com/vrischmann/go-metrics-influxdb"
 )
@@ -15,9 +17,9 @@ import (
 // for this instance.
 func NewMetricsEngine(cfg *config.Configuration, adapterList []openrtb_ext.BidderName) *DetailedMetricsEngine {
 	// Create a list of metrics engines to use.
-	// Capacity of 2, as unlikely to have more than 2 metrics backends, and in the case
+	// Capacity of 3, as unlikely to have more than 3 metrics backends, and in the case
 	// of 1 we won't use the list so it will be garbage collected.
-	engineList := make(MultiMetricsEngine, 0, 2)
+	engineList := make(MultiMetricsEngine, 0, 3)
 	returnEngine := DetailedMetricsEngine{}
 
 	if cfg.Metrics.Influxdb.Host != "" {
@@ -40,6 +42,15 @@ func NewMetricsEngine(cfg *config.Configuration, adapterList []openrtb_ext.Bidde
 		returnEngine.PrometheusMetrics = prometheusmetrics.NewMetrics(cfg.Metrics.Prometheus)
 		engineList = append(engineList, returnEngine.PrometheusMetrics)
 	}
+	if cfg.Metrics.StatsD.Host != "" {
+		// Set up the StatsD metrics, which are pushed to the server rather than scraped.
+		statsdMetrics, err := statsdmetrics.NewMetrics(cfg.Metrics.StatsD)
+		if err != nil {
+			glog.Fatalf("Failed to set up the StatsD metrics: %v", err)
+		}
+		returnEngine.StatsDMetrics = statsdMetrics
+		engineList = append(engineList, returnEngine.StatsDMetrics)
+	}
 
 	// Now return the proper metrics engine
 	if len(engineList) > 1 {
@@ -58,6 +69,7 @@ type DetailedMetricsEngine struct {
 	pbsmetrics.MetricsEngine
 	GoMetrics         *pbsmetrics.Metrics
 	PrometheusMetrics *prometheusmetrics.Metrics
+	StatsDMetrics     *statsdmetrics.Metrics
 }
 
 // MultiMetricsEngine logs metrics to multiple metrics databases The can be useful in transitioning
@@ -111,6 +123,13 @@ func (me *MultiMetricsEngine) RecordAdapterPanic(labels pbsmetrics.AdapterLabels
 	}
 }
 
+// RecordAdapterThrottled across all engines
+func (me *MultiMetricsEngine) RecordAdapterThrottled(labels pbsmetrics.AdapterLabels) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterThrottled(labels)
+	}
+}
+
 // RecordAdapterRequest across all engines
 func (me *MultiMetricsEngine) RecordAdapterRequest(labels pbsmetrics.AdapterLabels) {
 	for _, thisME := range *me {
@@ -195,6 +214,27 @@ func (me *MultiMetricsEngine) RecordTimeoutNotice(success bool) {
 	}
 }
 
+// RecordAdQualityRejection across all engines
+func (me *MultiMetricsEngine) RecordAdQualityRejection(adapter openrtb_ext.BidderName, rule pbsmetrics.AdQualityRule) {
+	for _, thisME := range *me {
+		thisME.RecordAdQualityRejection(adapter, rule)
+	}
+}
+
+// RecordMarkupViolation across all engines
+func (me *MultiMetricsEngine) RecordMarkupViolation(adapter openrtb_ext.BidderName, violation pbsmetrics.MarkupViolation) {
+	for _, thisME := range *me {
+		thisME.RecordMarkupViolation(adapter, violation)
+	}
+}
+
+// RecordDeviceDetection across all engines
+func (me *MultiMetricsEngine) RecordDeviceDetection(deviceType pbsmetrics.DeviceType) {
+	for _, thisME := range *me {
+		thisME.RecordDeviceDetection(deviceType)
+	}
+}
+
 // DummyMetricsEngine is a Noop metrics engine in case no metrics are configured. (may also be useful for tests)
 type DummyMetricsEngine struct{}
 
@@ -226,6 +266,10 @@ func (me *DummyMetricsEngine) RecordRequestTime(labels pbsmetrics.Labels, length
 func (me *DummyMetricsEngine) RecordAdapterPanic(labels pbsmetrics.AdapterLabels) {
 }
 
+// RecordAdapterThrottled as a noop
+func (me *DummyMetricsEngine) RecordAdapterThrottled(labels pbsmetrics.AdapterLabels) {
+}
+
 // RecordAdapterRequest as a noop
 func (me *DummyMetricsEngine) RecordAdapterRequest(labels pbsmetrics.AdapterLabels) {
 }
@@ -273,3 +317,15 @@ func (me *DummyMetricsEngine) RecordRequestQueueTime(success bool, requestType p
 // RecordTimeoutNotice as a noop
 func (me *DummyMetricsEngine) RecordTimeoutNotice(success bool) {
 }
+
+// RecordAdQualityRejection as a noop
+func (me *DummyMetricsEngine) RecordAdQualityRejection(adapter openrtb_ext.BidderName, rule pbsmetrics.AdQualityRule) {
+}
+
+// RecordMarkupViolation as a noop
+func (me *DummyMetricsEngine) RecordMarkupViolation(adapter openrtb_ext.BidderName, violation pbsmetrics.MarkupViolation) {
+}
+
+// RecordDeviceDetection as a noop
+func (me *DummyMetricsEngine) RecordDeviceDetection(deviceType pbsmetrics.DeviceType) {
+}
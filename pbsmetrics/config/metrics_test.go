@@ -7,6 +7,7 @@ import (
 	mainConfig "github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	statsdmetrics "github.com/prebid/prebid-server/pbsmetrics/statsd"
 	"github.com/rcrowley/go-metrics"
 )
 
@@ -32,6 +33,24 @@ func TestGoMetricsEngine(t *testing.T) {
 	}
 }
 
+func TestStatsDMetricsEngine(t *testing.T) {
+	cfg := mainConfig.Configuration{}
+	cfg.Metrics.StatsD = mainConfig.StatsDMetrics{
+		Host:          "127.0.0.1",
+		Port:          8125,
+		Flavor:        "dogstatsd",
+		MaxPacketSize: 1432,
+		FlushInterval: 100,
+	}
+	adapterList := make([]openrtb_ext.BidderName, 0, 2)
+	testEngine := NewMetricsEngine(&cfg, adapterList)
+	defer testEngine.StatsDMetrics.Close()
+	_, ok := testEngine.MetricsEngine.(*statsdmetrics.Metrics)
+	if !ok {
+		t.Error("Expected StatsD Metrics as MetricsEngine, but didn't get it")
+	}
+}
+
 // Test the multiengine
 func TestMultiMetricsEngine(t *testing.T) {
 	cfg := mainConfig.Configuration{}
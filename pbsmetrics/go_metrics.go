@@ -51,6 +51,8 @@ type Metrics struct {
 	TimeoutNotificationSuccess metrics.Meter
 	TimeoutNotificationFailure metrics.Meter
 
+	DeviceDetectionMeter map[DeviceType]metrics.Meter
+
 	AdapterMetrics map[openrtb_ext.BidderName]*AdapterMetrics
 	// Don't export accountMetrics because we need helper functions here to insure its properly populated dynamically
 	accountMetrics        map[string]*accountMetrics
@@ -72,7 +74,10 @@ type AdapterMetrics struct {
 	PriceHistogram    metrics.Histogram
 	BidsReceivedMeter metrics.Meter
 	PanicMeter        metrics.Meter
+	ThrottledMeter    metrics.Meter
 	MarkupMetrics     map[openrtb_ext.BidType]*MarkupDeliveryMetrics
+	AdQualityMeters   map[AdQualityRule]metrics.Meter
+	MarkupViolations  map[MarkupViolation]metrics.Meter
 }
 
 type MarkupDeliveryMetrics struct {
@@ -137,6 +142,8 @@ func NewBlankMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderNa
 		TimeoutNotificationSuccess: blankMeter,
 		TimeoutNotificationFailure: blankMeter,
 
+		DeviceDetectionMeter: make(map[DeviceType]metrics.Meter),
+
 		AdapterMetrics:  make(map[openrtb_ext.BidderName]*AdapterMetrics, len(exchanges)),
 		accountMetrics:  make(map[string]*accountMetrics),
 		MetricsDisabled: disableMetrics,
@@ -147,6 +154,10 @@ func NewBlankMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderNa
 		newMetrics.AdapterMetrics[a] = makeBlankAdapterMetrics()
 	}
 
+	for _, t := range DeviceTypes() {
+		newMetrics.DeviceDetectionMeter[t] = blankMeter
+	}
+
 	for _, t := range RequestTypes() {
 		newMetrics.RequestStatuses[t] = make(map[RequestStatus]metrics.Meter)
 		for _, s := range RequestStatuses() {
@@ -218,6 +229,10 @@ func NewMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderName, d
 
 	newMetrics.TimeoutNotificationSuccess = metrics.GetOrRegisterMeter("timeout_notification.ok", registry)
 	newMetrics.TimeoutNotificationFailure = metrics.GetOrRegisterMeter("timeout_notification.failed", registry)
+
+	for _, t := range DeviceTypes() {
+		newMetrics.DeviceDetectionMeter[t] = metrics.GetOrRegisterMeter("device_detection."+string(t), registry)
+	}
 	return newMetrics
 }
 
@@ -233,11 +248,20 @@ func makeBlankAdapterMetrics() *AdapterMetrics {
 		PriceHistogram:    &metrics.NilHistogram{},
 		BidsReceivedMeter: blankMeter,
 		PanicMeter:        blankMeter,
+		ThrottledMeter:    blankMeter,
 		MarkupMetrics:     makeBlankBidMarkupMetrics(),
+		AdQualityMeters:   make(map[AdQualityRule]metrics.Meter),
+		MarkupViolations:  make(map[MarkupViolation]metrics.Meter),
 	}
 	for _, err := range AdapterErrors() {
 		newAdapter.ErrorMeters[err] = blankMeter
 	}
+	for _, rule := range AdQualityRules() {
+		newAdapter.AdQualityMeters[rule] = blankMeter
+	}
+	for _, violation := range MarkupViolations() {
+		newAdapter.MarkupViolations[violation] = blankMeter
+	}
 	return newAdapter
 }
 
@@ -272,10 +296,17 @@ func registerAdapterMetrics(registry metrics.Registry, adapterOrAccount string,
 	for err := range am.ErrorMeters {
 		am.ErrorMeters[err] = metrics.GetOrRegisterMeter(fmt.Sprintf("%s.%s.requests.%s", adapterOrAccount, exchange, err), registry)
 	}
+	for rule := range am.AdQualityMeters {
+		am.AdQualityMeters[rule] = metrics.GetOrRegisterMeter(fmt.Sprintf("%s.%s.ad_quality_rejections.%s", adapterOrAccount, exchange, rule), registry)
+	}
+	for violation := range am.MarkupViolations {
+		am.MarkupViolations[violation] = metrics.GetOrRegisterMeter(fmt.Sprintf("%s.%s.markup_violations.%s", adapterOrAccount, exchange, violation), registry)
+	}
 	if adapterOrAccount != "adapter" {
 		am.BidsReceivedMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.bids_received", adapterOrAccount, exchange), registry)
 	}
 	am.PanicMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.requests.panic", adapterOrAccount, exchange), registry)
+	am.ThrottledMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.requests.throttled", adapterOrAccount, exchange), registry)
 }
 
 func makeDeliveryMetrics(registry metrics.Registry, prefix string, bidType openrtb_ext.BidType) *MarkupDeliveryMetrics {
@@ -407,6 +438,45 @@ func (me *Metrics) RecordAdapterPanic(labels AdapterLabels) {
 	am.PanicMeter.Mark(1)
 }
 
+// RecordAdapterThrottled implements a part of the MetricsEngine interface
+func (me *Metrics) RecordAdapterThrottled(labels AdapterLabels) {
+	am, ok := me.AdapterMetrics[labels.Adapter]
+	if !ok {
+		glog.Errorf("Trying to run adapter metrics on %s: adapter metrics not found", string(labels.Adapter))
+		return
+	}
+	am.ThrottledMeter.Mark(1)
+	if aam, ok := me.getAccountMetrics(labels.PubID).adapterMetrics[labels.Adapter]; ok {
+		aam.ThrottledMeter.Mark(1)
+	}
+}
+
+// RecordAdQualityRejection implements a part of the MetricsEngine interface
+//
+// The rejections of request aliases are counted under the alias, so their meters are registered when they're first needed.
+func (me *Metrics) RecordAdQualityRejection(adapter openrtb_ext.BidderName, rule AdQualityRule) {
+	am, ok := me.AdapterMetrics[adapter]
+	if !ok {
+		metrics.GetOrRegisterMeter(fmt.Sprintf("adapter.%s.ad_quality_rejections.%s", adapter, rule), me.MetricsRegistry).Mark(1)
+		return
+	}
+	if meter, ok := am.AdQualityMeters[rule]; ok {
+		meter.Mark(1)
+	}
+}
+
+// RecordMarkupViolation implements a part of the MetricsEngine interface
+func (me *Metrics) RecordMarkupViolation(adapter openrtb_ext.BidderName, violation MarkupViolation) {
+	am, ok := me.AdapterMetrics[adapter]
+	if !ok {
+		glog.Errorf("Trying to run adapter metrics on %s: adapter metrics not found", string(adapter))
+		return
+	}
+	if meter, ok := am.MarkupViolations[violation]; ok {
+		meter.Mark(1)
+	}
+}
+
 // RecordAdapterRequest implements a part of the MetricsEngine interface
 func (me *Metrics) RecordAdapterRequest(labels AdapterLabels) {
 	am, ok := me.AdapterMetrics[labels.Adapter]
@@ -562,6 +632,13 @@ func (me *Metrics) RecordTimeoutNotice(success bool) {
 	return
 }
 
+// RecordDeviceDetection implements a part of the MetricsEngine interface
+func (me *Metrics) RecordDeviceDetection(deviceType DeviceType) {
+	if meter, ok := me.DeviceDetectionMeter[deviceType]; ok {
+		meter.Mark(1)
+	}
+}
+
 func doMark(bidder openrtb_ext.BidderName, meters map[openrtb_ext.BidderName]metrics.Meter) {
 	met, ok := meters[bidder]
 	if ok {
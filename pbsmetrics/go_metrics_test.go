@@ -214,3 +214,51 @@ func VerifyMetrics(t *testing.T, name string, expected int64, actual int64) {
 		t.Errorf("Error in metric %s: expected %d, got %d.", name, expected, actual)
 	}
 }
+
+func TestRecordAdapterThrottled(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus}, config.DisabledMetrics{})
+
+	m.RecordAdapterThrottled(AdapterLabels{Adapter: openrtb_ext.BidderAppnexus, PubID: "acct-1"})
+
+	ensureContains(t, registry, "adapter.appnexus.requests.throttled", m.AdapterMetrics[openrtb_ext.BidderAppnexus].ThrottledMeter)
+	ensureContains(t, registry, "account.acct-1.appnexus.requests.throttled", m.getAccountMetrics("acct-1").adapterMetrics[openrtb_ext.BidderAppnexus].ThrottledMeter)
+	assert.Equal(t, int64(1), m.AdapterMetrics[openrtb_ext.BidderAppnexus].ThrottledMeter.Count())
+}
+
+func TestRecordAdQualityRejection(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus}, config.DisabledMetrics{})
+
+	m.RecordAdQualityRejection(openrtb_ext.BidderAppnexus, AdQualityBlockedCategories)
+
+	ensureContains(t, registry, "adapter.appnexus.ad_quality_rejections.bcat", m.AdapterMetrics[openrtb_ext.BidderAppnexus].AdQualityMeters[AdQualityBlockedCategories])
+	assert.Equal(t, int64(1), m.AdapterMetrics[openrtb_ext.BidderAppnexus].AdQualityMeters[AdQualityBlockedCategories].Count())
+	assert.Equal(t, int64(0), m.AdapterMetrics[openrtb_ext.BidderAppnexus].AdQualityMeters[AdQualityBlockedAdvertisers].Count())
+
+	m.RecordAdQualityRejection("requestAlias", AdQualityBlockedCategories)
+	assert.Equal(t, int64(1), metrics.GetOrRegisterMeter("adapter.requestAlias.ad_quality_rejections.bcat", registry).Count(), "Request aliases should be counted under their own name")
+	assert.Equal(t, int64(1), m.AdapterMetrics[openrtb_ext.BidderAppnexus].AdQualityMeters[AdQualityBlockedCategories].Count())
+}
+
+func TestRecordMarkupViolation(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus}, config.DisabledMetrics{})
+
+	m.RecordMarkupViolation(openrtb_ext.BidderAppnexus, MarkupInsecure)
+
+	ensureContains(t, registry, "adapter.appnexus.markup_violations.insecure", m.AdapterMetrics[openrtb_ext.BidderAppnexus].MarkupViolations[MarkupInsecure])
+	assert.Equal(t, int64(1), m.AdapterMetrics[openrtb_ext.BidderAppnexus].MarkupViolations[MarkupInsecure].Count())
+	assert.Equal(t, int64(0), m.AdapterMetrics[openrtb_ext.BidderAppnexus].MarkupViolations[MarkupOversized].Count())
+}
+
+func TestRecordDeviceDetection(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus}, config.DisabledMetrics{})
+
+	m.RecordDeviceDetection(DeviceTypePhone)
+
+	ensureContains(t, registry, "device_detection.phone", m.DeviceDetectionMeter[DeviceTypePhone])
+	assert.Equal(t, int64(1), m.DeviceDetectionMeter[DeviceTypePhone].Count())
+	assert.Equal(t, int64(0), m.DeviceDetectionMeter[DeviceTypeUnknown].Count())
+}
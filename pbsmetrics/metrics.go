@@ -70,6 +70,15 @@ type AdapterError string
 // CacheResult : Cache hit/miss
 type CacheResult string
 
+// AdQualityRule : The request field whose blocking rule a bid broke
+type AdQualityRule string
+
+// MarkupViolation : The problem found in a bid's markup
+type MarkupViolation string
+
+// DeviceType : The type of device detected from a request's User-Agent
+type DeviceType string
+
 // PublisherUnknown : Default value for Labels.PubID
 const PublisherUnknown = "unknown"
 
@@ -221,6 +230,66 @@ func CacheResults() []CacheResult {
 	}
 }
 
+// The ad quality rules which bids are checked against
+const (
+	AdQualityBlockedAdvertisers AdQualityRule = "badv"
+	AdQualityBlockedCategories  AdQualityRule = "bcat"
+	AdQualityBlockedAttributes  AdQualityRule = "battr"
+	AdQualityBlockedApps        AdQualityRule = "bapp"
+)
+
+// AdQualityRules returns all the ad quality rules
+func AdQualityRules() []AdQualityRule {
+	return []AdQualityRule{
+		AdQualityBlockedAdvertisers,
+		AdQualityBlockedCategories,
+		AdQualityBlockedAttributes,
+		AdQualityBlockedApps,
+	}
+}
+
+// The problems which the markup of the bids is checked for
+const (
+	MarkupInsecure         MarkupViolation = "insecure"
+	MarkupOversized        MarkupViolation = "oversized"
+	MarkupDisallowedScript MarkupViolation = "disallowed_script"
+)
+
+// MarkupViolations returns all the problems which the markup is checked for
+func MarkupViolations() []MarkupViolation {
+	return []MarkupViolation{
+		MarkupInsecure,
+		MarkupOversized,
+		MarkupDisallowedScript,
+	}
+}
+
+// The device types which device detection may find
+const (
+	DeviceTypeMobileTablet    DeviceType = "mobile_tablet"
+	DeviceTypePC              DeviceType = "pc"
+	DeviceTypeCTV             DeviceType = "ctv"
+	DeviceTypePhone           DeviceType = "phone"
+	DeviceTypeTablet          DeviceType = "tablet"
+	DeviceTypeConnectedDevice DeviceType = "connected_device"
+	DeviceTypeSetTopBox       DeviceType = "set_top_box"
+	DeviceTypeUnknown         DeviceType = "unknown"
+)
+
+// DeviceTypes returns all the device types which device detection may find
+func DeviceTypes() []DeviceType {
+	return []DeviceType{
+		DeviceTypeMobileTablet,
+		DeviceTypePC,
+		DeviceTypeCTV,
+		DeviceTypePhone,
+		DeviceTypeTablet,
+		DeviceTypeConnectedDevice,
+		DeviceTypeSetTopBox,
+		DeviceTypeUnknown,
+	}
+}
+
 // UserLabels : Labels for /setuid endpoint
 type UserLabels struct {
 	Action RequestAction
@@ -263,6 +332,8 @@ type MetricsEngine interface {
 	RecordRequestTime(labels Labels, length time.Duration) // ignores adapter. only statusOk and statusErr fom status
 	RecordAdapterRequest(labels AdapterLabels)
 	RecordAdapterPanic(labels AdapterLabels)
+	// This records a request in which a throttling rule kept some or all of the imps from the adapter.
+	RecordAdapterThrottled(labels AdapterLabels)
 	// This records whether or not a bid of a particular type uses `adm` or `nurl`.
 	// Since the legacy endpoints don't have a bid type, it can only count bids from OpenRTB and AMP.
 	RecordAdapterBidReceived(labels AdapterLabels, bidType openrtb_ext.BidType, hasAdm bool)
@@ -276,4 +347,10 @@ type MetricsEngine interface {
 	RecordPrebidCacheRequestTime(success bool, length time.Duration)
 	RecordRequestQueueTime(success bool, requestType RequestType, length time.Duration)
 	RecordTimeoutNotice(sucess bool)
+	// This records a bid which was removed from the response because it broke one of the request's ad quality rules.
+	RecordAdQualityRejection(adapter openrtb_ext.BidderName, rule AdQualityRule)
+	// This records a problem found in a bid's markup, whether or not the bid was removed because of it.
+	RecordMarkupViolation(adapter openrtb_ext.BidderName, violation MarkupViolation)
+	// This records the device type detected from the User-Agent of a request, or DeviceTypeUnknown if none was.
+	RecordDeviceDetection(deviceType DeviceType)
 }
@@ -47,6 +47,11 @@ func (me *MetricsEngineMock) RecordAdapterPanic(labels AdapterLabels) {
 	me.Called(labels)
 }
 
+// RecordAdapterThrottled mock
+func (me *MetricsEngineMock) RecordAdapterThrottled(labels AdapterLabels) {
+	me.Called(labels)
+}
+
 // RecordAdapterRequest mock
 func (me *MetricsEngineMock) RecordAdapterRequest(labels AdapterLabels) {
 	me.Called(labels)
@@ -106,3 +111,18 @@ func (me *MetricsEngineMock) RecordRequestQueueTime(success bool, requestType Re
 func (me *MetricsEngineMock) RecordTimeoutNotice(success bool) {
 	me.Called(success)
 }
+
+// RecordAdQualityRejection mock
+func (me *MetricsEngineMock) RecordAdQualityRejection(adapter openrtb_ext.BidderName, rule AdQualityRule) {
+	me.Called(adapter, rule)
+}
+
+// RecordMarkupViolation mock
+func (me *MetricsEngineMock) RecordMarkupViolation(adapter openrtb_ext.BidderName, violation MarkupViolation) {
+	me.Called(adapter, violation)
+}
+
+// RecordDeviceDetection mock
+func (me *MetricsEngineMock) RecordDeviceDetection(deviceType DeviceType) {
+	me.Called(deviceType)
+}
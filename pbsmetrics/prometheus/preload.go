@@ -15,6 +15,7 @@ func preloadLabelValues(m *Metrics) {
 		cacheResultValues     = cacheResultsAsString()
 		cookieValues          = cookieTypesAsString()
 		connectionErrorValues = []string{connectionAcceptError, connectionCloseError}
+		deviceTypeValues      = deviceTypesAsString()
 		requestStatusValues   = requestStatusesAsString()
 		requestTypeValues     = requestTypesAsString()
 	)
@@ -23,6 +24,10 @@ func preloadLabelValues(m *Metrics) {
 		connectionErrorLabel: connectionErrorValues,
 	})
 
+	preloadLabelValuesForCounter(m.deviceDetections, map[string][]string{
+		deviceTypeLabel: deviceTypeValues,
+	})
+
 	preloadLabelValuesForCounter(m.impressions, map[string][]string{
 		isBannerLabel: boolValues,
 		isVideoLabel:  boolValues,
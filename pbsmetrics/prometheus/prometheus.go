@@ -19,6 +19,7 @@ type Metrics struct {
 	connectionsError             *prometheus.CounterVec
 	connectionsOpened            prometheus.Counter
 	cookieSync                   prometheus.Counter
+	deviceDetections             *prometheus.CounterVec
 	impressions                  *prometheus.CounterVec
 	impressionsLegacy            prometheus.Counter
 	prebidCacheWriteTimer        *prometheus.HistogramVec
@@ -31,14 +32,17 @@ type Metrics struct {
 	timeout_notifications        *prometheus.CounterVec
 
 	// Adapter Metrics
-	adapterBids          *prometheus.CounterVec
-	adapterCookieSync    *prometheus.CounterVec
-	adapterErrors        *prometheus.CounterVec
-	adapterPanics        *prometheus.CounterVec
-	adapterPrices        *prometheus.HistogramVec
-	adapterRequests      *prometheus.CounterVec
-	adapterRequestsTimer *prometheus.HistogramVec
-	adapterUserSync      *prometheus.CounterVec
+	adapterAdQualityRejections *prometheus.CounterVec
+	adapterBids                *prometheus.CounterVec
+	adapterCookieSync          *prometheus.CounterVec
+	adapterErrors              *prometheus.CounterVec
+	adapterMarkupViolations    *prometheus.CounterVec
+	adapterPanics              *prometheus.CounterVec
+	adapterPrices              *prometheus.HistogramVec
+	adapterRequests            *prometheus.CounterVec
+	adapterRequestsTimer       *prometheus.HistogramVec
+	adapterThrottledRequests   *prometheus.CounterVec
+	adapterUserSync            *prometheus.CounterVec
 
 	// Account Metrics
 	accountRequests *prometheus.CounterVec
@@ -48,17 +52,20 @@ const (
 	accountLabel         = "account"
 	actionLabel          = "action"
 	adapterErrorLabel    = "adapter_error"
+	adQualityRuleLabel   = "rule"
 	adapterLabel         = "adapter"
 	bidTypeLabel         = "bid_type"
 	cacheResultLabel     = "cache_result"
 	connectionErrorLabel = "connection_error"
 	cookieLabel          = "cookie"
+	deviceTypeLabel      = "device_type"
 	hasBidsLabel         = "has_bids"
 	isAudioLabel         = "audio"
 	isBannerLabel        = "banner"
 	isNativeLabel        = "native"
 	isVideoLabel         = "video"
 	markupDeliveryLabel  = "delivery"
+	markupViolationLabel = "violation"
 	privacyBlockedLabel  = "privacy_blocked"
 	requestStatusLabel   = "request_status"
 	requestTypeLabel     = "request_type"
@@ -158,6 +165,16 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		"Count of timeout notifications triggered, and if they were successfully sent.",
 		[]string{successLabel})
 
+	metrics.deviceDetections = newCounter(cfg, metrics.Registry,
+		"device_detections",
+		"Count of requests with a User-Agent labeled by the device type detected from it, or unknown if none was.",
+		[]string{deviceTypeLabel})
+
+	metrics.adapterAdQualityRejections = newCounter(cfg, metrics.Registry,
+		"adapter_ad_quality_rejections",
+		"Count of bids removed for breaking the request's ad quality rules, labeled by adapter and rule (badv, bcat, battr or bapp).",
+		[]string{adapterLabel, adQualityRuleLabel})
+
 	metrics.adapterBids = newCounter(cfg, metrics.Registry,
 		"adapter_bids",
 		"Count of bids labeled by adapter and markup delivery type (adm or nurl).",
@@ -173,6 +190,11 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		"Count of errors labeled by adapter and error type.",
 		[]string{adapterLabel, adapterErrorLabel})
 
+	metrics.adapterMarkupViolations = newCounter(cfg, metrics.Registry,
+		"adapter_markup_violations",
+		"Count of bids whose markup was oversized, insecure or ran a disallowed script, labeled by adapter and violation.",
+		[]string{adapterLabel, markupViolationLabel})
+
 	metrics.adapterPanics = newCounter(cfg, metrics.Registry,
 		"adapter_panics",
 		"Count of panics labeled by adapter.",
@@ -195,6 +217,11 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		[]string{adapterLabel},
 		requestTimeBuckets)
 
+	metrics.adapterThrottledRequests = newCounter(cfg, metrics.Registry,
+		"adapter_throttled_requests",
+		"Count of requests in which a throttling rule kept some or all of the imps from the adapter, labeled by adapter.",
+		[]string{adapterLabel})
+
 	metrics.adapterUserSync = newCounter(cfg, metrics.Registry,
 		"adapter_user_sync",
 		"Count of user ID sync requests received labeled by adapter and action.",
@@ -211,6 +238,8 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		[]string{requestTypeLabel, requestStatusLabel},
 		queuedRequestTimeBuckets)
 
+	// adapterAdQualityRejections and adapterMarkupViolations aren't preloaded, since most adapters will never have a bid rejected.
+	// adapterThrottledRequests isn't either, since most adapters won't have throttling rules.
 	preloadLabelValues(&metrics)
 
 	return &metrics
@@ -334,6 +363,12 @@ func (m *Metrics) RecordAdapterPanic(labels pbsmetrics.AdapterLabels) {
 	}).Inc()
 }
 
+func (m *Metrics) RecordAdapterThrottled(labels pbsmetrics.AdapterLabels) {
+	m.adapterThrottledRequests.With(prometheus.Labels{
+		adapterLabel: string(labels.Adapter),
+	}).Inc()
+}
+
 func (m *Metrics) RecordAdapterBidReceived(labels pbsmetrics.AdapterLabels, bidType openrtb_ext.BidType, hasAdm bool) {
 	markupDelivery := markupDeliveryNurl
 	if hasAdm {
@@ -421,3 +456,23 @@ func (m *Metrics) RecordTimeoutNotice(success bool) {
 		}).Inc()
 	}
 }
+
+func (m *Metrics) RecordDeviceDetection(deviceType pbsmetrics.DeviceType) {
+	m.deviceDetections.With(prometheus.Labels{
+		deviceTypeLabel: string(deviceType),
+	}).Inc()
+}
+
+func (m *Metrics) RecordAdQualityRejection(adapter openrtb_ext.BidderName, rule pbsmetrics.AdQualityRule) {
+	m.adapterAdQualityRejections.With(prometheus.Labels{
+		adapterLabel:       string(adapter),
+		adQualityRuleLabel: string(rule),
+	}).Inc()
+}
+
+func (m *Metrics) RecordMarkupViolation(adapter openrtb_ext.BidderName, violation pbsmetrics.MarkupViolation) {
+	m.adapterMarkupViolations.With(prometheus.Labels{
+		adapterLabel:         string(adapter),
+		markupViolationLabel: string(violation),
+	}).Inc()
+}
@@ -750,6 +750,63 @@ func TestAdapterCookieSyncMetric(t *testing.T) {
 		})
 }
 
+func TestAdapterThrottledMetric(t *testing.T) {
+	m := createMetricsForTesting()
+	adapterName := "anyName"
+
+	m.RecordAdapterThrottled(pbsmetrics.AdapterLabels{Adapter: openrtb_ext.BidderName(adapterName)})
+
+	expectedCount := float64(1)
+	assertCounterVecValue(t, "", "adapterThrottledRequests", m.adapterThrottledRequests,
+		expectedCount,
+		prometheus.Labels{
+			adapterLabel: adapterName,
+		})
+}
+
+func TestAdQualityRejectionMetric(t *testing.T) {
+	m := createMetricsForTesting()
+	adapterName := "anyName"
+
+	m.RecordAdQualityRejection(openrtb_ext.BidderName(adapterName), pbsmetrics.AdQualityBlockedAdvertisers)
+
+	expectedCount := float64(1)
+	assertCounterVecValue(t, "", "adapterAdQualityRejections", m.adapterAdQualityRejections,
+		expectedCount,
+		prometheus.Labels{
+			adapterLabel:       adapterName,
+			adQualityRuleLabel: "badv",
+		})
+}
+
+func TestMarkupViolationMetric(t *testing.T) {
+	m := createMetricsForTesting()
+	adapterName := "anyName"
+
+	m.RecordMarkupViolation(openrtb_ext.BidderName(adapterName), pbsmetrics.MarkupOversized)
+
+	expectedCount := float64(1)
+	assertCounterVecValue(t, "", "adapterMarkupViolations", m.adapterMarkupViolations,
+		expectedCount,
+		prometheus.Labels{
+			adapterLabel:         adapterName,
+			markupViolationLabel: "oversized",
+		})
+}
+
+func TestDeviceDetectionMetric(t *testing.T) {
+	m := createMetricsForTesting()
+
+	m.RecordDeviceDetection(pbsmetrics.DeviceTypeCTV)
+
+	expectedCount := float64(1)
+	assertCounterVecValue(t, "", "deviceDetections", m.deviceDetections,
+		expectedCount,
+		prometheus.Labels{
+			deviceTypeLabel: "ctv",
+		})
+}
+
 func TestUserIDSetMetric(t *testing.T) {
 	m := createMetricsForTesting()
 	adapterName := "anyName"
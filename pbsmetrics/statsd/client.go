@@ -0,0 +1,102 @@
+package statsdmetrics
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// client batches metric lines into packets of up to maxPacketSize bytes.
+//
+// Packets are sent whenever the next line wouldn't fit, and every flushInterval.
+// Send errors are ignored. UDP gives no delivery guarantees anyway, and metrics must never slow down an auction.
+type client struct {
+	writer        io.Writer
+	maxPacketSize int
+
+	mutex  sync.Mutex
+	buffer []byte
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newClient(writer io.Writer, maxPacketSize int, flushInterval time.Duration) *client {
+	c := &client{
+		writer:        writer,
+		maxPacketSize: maxPacketSize,
+		buffer:        make([]byte, 0, maxPacketSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go c.flushEvery(flushInterval)
+	return c
+}
+
+func (c *client) send(line string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.buffer) > 0 && len(c.buffer)+1+len(line) > c.maxPacketSize {
+		c.flushLocked()
+	}
+	if len(c.buffer) > 0 {
+		c.buffer = append(c.buffer, '\n')
+	}
+	c.buffer = append(c.buffer, line...)
+	// A line which is too big for a packet on its own is still sent, on its own.
+	if len(c.buffer) >= c.maxPacketSize {
+		c.flushLocked()
+	}
+}
+
+func (c *client) flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.flushLocked()
+}
+
+func (c *client) flushLocked() {
+	if len(c.buffer) == 0 {
+		return
+	}
+	c.writer.Write(c.buffer)
+	c.buffer = c.buffer[:0]
+}
+
+func (c *client) flushEvery(interval time.Duration) {
+	defer close(c.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flush()
+		case <-c.stop:
+			c.flush()
+			return
+		}
+	}
+}
+
+func (c *client) close() {
+	close(c.stop)
+	<-c.done
+}
+
+var sanitizer = strings.NewReplacer(
+	":", "_",
+	"|", "_",
+	"@", "_",
+	"#", "_",
+	",", "_",
+	".", "_",
+	" ", "_",
+	"\n", "_",
+)
+
+// sanitize replaces the characters which have a special meaning in the StatsD protocol, or in plain StatsD names,
+// so that label values (like account IDs) can't corrupt the metric lines.
+func sanitize(value string) string {
+	return sanitizer.Replace(value)
+}
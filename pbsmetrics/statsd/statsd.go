@@ -0,0 +1,320 @@
+package statsdmetrics
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// Metrics sends the metrics of the MetricsEngine to a StatsD server, or agent, over UDP.
+//
+// The metric names and labels match the Prometheus metrics. DogStatsD sends the labels as tags:
+//
+//	prebidserver.requests:1|c|#request_type:amp,request_status:ok
+//
+// Plain StatsD has no tags, so each label is added to the metric name as a key and value:
+//
+//	prebidserver.requests.request_type.amp.request_status.ok:1|c
+type Metrics struct {
+	client      *client
+	prefix      string
+	dogStatsD   bool
+	sampleRates map[string]float64
+	random      func() float64
+}
+
+const (
+	metricTypeCounter   = "c"
+	metricTypeTimer     = "ms"
+	metricTypeHistogram = "h"
+)
+
+const (
+	accountLabel         = "account"
+	actionLabel          = "action"
+	adapterErrorLabel    = "adapter_error"
+	adQualityRuleLabel   = "rule"
+	adapterLabel         = "adapter"
+	cacheResultLabel     = "cache_result"
+	connectionErrorLabel = "connection_error"
+	cookieLabel          = "cookie"
+	deviceTypeLabel      = "device_type"
+	hasBidsLabel         = "has_bids"
+	isAudioLabel         = "audio"
+	isBannerLabel        = "banner"
+	isNativeLabel        = "native"
+	isVideoLabel         = "video"
+	markupDeliveryLabel  = "delivery"
+	markupViolationLabel = "violation"
+	privacyBlockedLabel  = "privacy_blocked"
+	requestStatusLabel   = "request_status"
+	requestTypeLabel     = "request_type"
+	successLabel         = "success"
+)
+
+const (
+	connectionAcceptError = "accept"
+	connectionCloseError  = "close"
+)
+
+const (
+	markupDeliveryAdm  = "adm"
+	markupDeliveryNurl = "nurl"
+)
+
+const (
+	requestSuccessLabel = "requestAcceptedLabel"
+	requestRejectLabel  = "requestRejectedLabel"
+)
+
+const (
+	requestSuccessful = "ok"
+	requestFailed     = "failed"
+)
+
+// NewMetrics returns Metrics which are sent to the server described by the config.
+func NewMetrics(cfg config.StatsDMetrics) (*Metrics, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil, err
+	}
+	return newMetrics(cfg, conn), nil
+}
+
+func newMetrics(cfg config.StatsDMetrics, conn io.Writer) *Metrics {
+	return &Metrics{
+		client:      newClient(conn, cfg.MaxPacketSize, time.Duration(cfg.FlushInterval)*time.Millisecond),
+		prefix:      cfg.Prefix,
+		dogStatsD:   cfg.Flavor == "dogstatsd",
+		sampleRates: cfg.SampleRates,
+		random:      rand.Float64,
+	}
+}
+
+// Close sends any metrics which are still buffered, and stops sending new ones.
+func (m *Metrics) Close() {
+	m.client.close()
+}
+
+type tag struct {
+	key   string
+	value string
+}
+
+func (m *Metrics) emit(name string, value string, metricType string, tags ...tag) {
+	rate, hasRate := m.sampleRates[name]
+	if hasRate && m.random() >= rate {
+		return
+	}
+
+	var line strings.Builder
+	line.WriteString(m.prefix)
+	line.WriteString(name)
+	if !m.dogStatsD {
+		for _, t := range tags {
+			line.WriteByte('.')
+			line.WriteString(t.key)
+			line.WriteByte('.')
+			line.WriteString(sanitize(t.value))
+		}
+	}
+	line.WriteByte(':')
+	line.WriteString(value)
+	line.WriteByte('|')
+	line.WriteString(metricType)
+	if hasRate && rate < 1 {
+		line.WriteString("|@")
+		line.WriteString(strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	if m.dogStatsD && len(tags) > 0 {
+		line.WriteString("|#")
+		for i, t := range tags {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			line.WriteString(t.key)
+			line.WriteByte(':')
+			line.WriteString(sanitize(t.value))
+		}
+	}
+	m.client.send(line.String())
+}
+
+func (m *Metrics) count(name string, inc int, tags ...tag) {
+	m.emit(name, strconv.Itoa(inc), metricTypeCounter, tags...)
+}
+
+func (m *Metrics) timing(name string, length time.Duration, tags ...tag) {
+	m.emit(name, strconv.FormatFloat(float64(length)/float64(time.Millisecond), 'f', -1, 64), metricTypeTimer, tags...)
+}
+
+// histogram records a distribution which isn't a duration.
+// Plain StatsD has no histogram type, but its timers compute the same aggregates.
+func (m *Metrics) histogram(name string, value float64, tags ...tag) {
+	metricType := metricTypeTimer
+	if m.dogStatsD {
+		metricType = metricTypeHistogram
+	}
+	m.emit(name, strconv.FormatFloat(value, 'f', -1, 64), metricType, tags...)
+}
+
+func (m *Metrics) RecordConnectionAccept(success bool) {
+	if success {
+		m.count("connections_opened", 1)
+	} else {
+		m.count("connections_error", 1, tag{connectionErrorLabel, connectionAcceptError})
+	}
+}
+
+func (m *Metrics) RecordConnectionClose(success bool) {
+	if success {
+		m.count("connections_closed", 1)
+	} else {
+		m.count("connections_error", 1, tag{connectionErrorLabel, connectionCloseError})
+	}
+}
+
+func (m *Metrics) RecordRequest(labels pbsmetrics.Labels) {
+	m.count("requests", 1,
+		tag{requestTypeLabel, string(labels.RType)},
+		tag{requestStatusLabel, string(labels.RequestStatus)})
+
+	if labels.CookieFlag == pbsmetrics.CookieFlagNo {
+		m.count("requests_without_cookie", 1, tag{requestTypeLabel, string(labels.RType)})
+	}
+
+	if labels.PubID != pbsmetrics.PublisherUnknown {
+		m.count("account_requests", 1, tag{accountLabel, labels.PubID})
+	}
+}
+
+func (m *Metrics) RecordImps(labels pbsmetrics.ImpLabels) {
+	m.count("impressions_requests", 1,
+		tag{isBannerLabel, strconv.FormatBool(labels.BannerImps)},
+		tag{isVideoLabel, strconv.FormatBool(labels.VideoImps)},
+		tag{isAudioLabel, strconv.FormatBool(labels.AudioImps)},
+		tag{isNativeLabel, strconv.FormatBool(labels.NativeImps)})
+}
+
+func (m *Metrics) RecordLegacyImps(labels pbsmetrics.Labels, numImps int) {
+	m.count("impressions_requests_legacy", numImps)
+}
+
+func (m *Metrics) RecordRequestTime(labels pbsmetrics.Labels, length time.Duration) {
+	if labels.RequestStatus == pbsmetrics.RequestStatusOK {
+		m.timing("request_time", length, tag{requestTypeLabel, string(labels.RType)})
+	}
+}
+
+func (m *Metrics) RecordAdapterRequest(labels pbsmetrics.AdapterLabels) {
+	m.count("adapter_requests", 1,
+		tag{adapterLabel, string(labels.Adapter)},
+		tag{cookieLabel, string(labels.CookieFlag)},
+		tag{hasBidsLabel, strconv.FormatBool(labels.AdapterBids == pbsmetrics.AdapterBidPresent)})
+
+	for err := range labels.AdapterErrors {
+		m.count("adapter_errors", 1,
+			tag{adapterLabel, string(labels.Adapter)},
+			tag{adapterErrorLabel, string(err)})
+	}
+}
+
+func (m *Metrics) RecordAdapterPanic(labels pbsmetrics.AdapterLabels) {
+	m.count("adapter_panics", 1, tag{adapterLabel, string(labels.Adapter)})
+}
+
+func (m *Metrics) RecordAdapterThrottled(labels pbsmetrics.AdapterLabels) {
+	m.count("adapter_throttled_requests", 1, tag{adapterLabel, string(labels.Adapter)})
+}
+
+func (m *Metrics) RecordAdapterBidReceived(labels pbsmetrics.AdapterLabels, bidType openrtb_ext.BidType, hasAdm bool) {
+	markupDelivery := markupDeliveryNurl
+	if hasAdm {
+		markupDelivery = markupDeliveryAdm
+	}
+	m.count("adapter_bids", 1,
+		tag{adapterLabel, string(labels.Adapter)},
+		tag{markupDeliveryLabel, markupDelivery})
+}
+
+func (m *Metrics) RecordAdapterPrice(labels pbsmetrics.AdapterLabels, cpm float64) {
+	m.histogram("adapter_prices", cpm, tag{adapterLabel, string(labels.Adapter)})
+}
+
+func (m *Metrics) RecordAdapterTime(labels pbsmetrics.AdapterLabels, length time.Duration) {
+	if len(labels.AdapterErrors) == 0 {
+		m.timing("adapter_request_time", length, tag{adapterLabel, string(labels.Adapter)})
+	}
+}
+
+func (m *Metrics) RecordCookieSync() {
+	m.count("cookie_sync_requests", 1)
+}
+
+func (m *Metrics) RecordAdapterCookieSync(adapter openrtb_ext.BidderName, privacyBlocked bool) {
+	m.count("adapter_cookie_sync", 1,
+		tag{adapterLabel, string(adapter)},
+		tag{privacyBlockedLabel, strconv.FormatBool(privacyBlocked)})
+}
+
+func (m *Metrics) RecordUserIDSet(labels pbsmetrics.UserLabels) {
+	adapter := string(labels.Bidder)
+	if adapter != "" {
+		m.count("adapter_user_sync", 1,
+			tag{adapterLabel, adapter},
+			tag{actionLabel, string(labels.Action)})
+	}
+}
+
+func (m *Metrics) RecordStoredReqCacheResult(cacheResult pbsmetrics.CacheResult, inc int) {
+	m.count("stored_request_cache_performance", inc, tag{cacheResultLabel, string(cacheResult)})
+}
+
+func (m *Metrics) RecordStoredImpCacheResult(cacheResult pbsmetrics.CacheResult, inc int) {
+	m.count("stored_impressions_cache_performance", inc, tag{cacheResultLabel, string(cacheResult)})
+}
+
+func (m *Metrics) RecordPrebidCacheRequestTime(success bool, length time.Duration) {
+	m.timing("prebidcache_write_time", length, tag{successLabel, strconv.FormatBool(success)})
+}
+
+func (m *Metrics) RecordRequestQueueTime(success bool, requestType pbsmetrics.RequestType, length time.Duration) {
+	successLabelFormatted := requestRejectLabel
+	if success {
+		successLabelFormatted = requestSuccessLabel
+	}
+	m.timing("request_queue_time", length,
+		tag{requestTypeLabel, string(requestType)},
+		tag{requestStatusLabel, successLabelFormatted})
+}
+
+func (m *Metrics) RecordTimeoutNotice(success bool) {
+	if success {
+		m.count("timeout_notification", 1, tag{successLabel, requestSuccessful})
+	} else {
+		m.count("timeout_notification", 1, tag{successLabel, requestFailed})
+	}
+}
+
+func (m *Metrics) RecordDeviceDetection(deviceType pbsmetrics.DeviceType) {
+	m.count("device_detections", 1, tag{deviceTypeLabel, string(deviceType)})
+}
+
+func (m *Metrics) RecordAdQualityRejection(adapter openrtb_ext.BidderName, rule pbsmetrics.AdQualityRule) {
+	m.count("adapter_ad_quality_rejections", 1,
+		tag{adapterLabel, string(adapter)},
+		tag{adQualityRuleLabel, string(rule)})
+}
+
+func (m *Metrics) RecordMarkupViolation(adapter openrtb_ext.BidderName, violation pbsmetrics.MarkupViolation) {
+	m.count("adapter_markup_violations", 1,
+		tag{adapterLabel, string(adapter)},
+		tag{markupViolationLabel, string(violation)})
+}
@@ -0,0 +1,187 @@
+package statsdmetrics
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsDNames(t *testing.T) {
+	m, packets := newTestMetrics("statsd", nil)
+
+	m.RecordRequest(pbsmetrics.Labels{
+		RType:         pbsmetrics.ReqTypeAMP,
+		RequestStatus: pbsmetrics.RequestStatusOK,
+		CookieFlag:    pbsmetrics.CookieFlagNo,
+		PubID:         "pub.1|x",
+	})
+	m.RecordRequestTime(pbsmetrics.Labels{RType: pbsmetrics.ReqTypeAMP, RequestStatus: pbsmetrics.RequestStatusOK}, 1500*time.Microsecond)
+	m.RecordAdapterPrice(pbsmetrics.AdapterLabels{Adapter: openrtb_ext.BidderAppnexus}, 2.5)
+	m.RecordConnectionAccept(true)
+	m.Close()
+
+	assert.Equal(t, []string{
+		"pbs.requests.request_type.amp.request_status.ok:1|c",
+		"pbs.requests_without_cookie.request_type.amp:1|c",
+		"pbs.account_requests.account.pub_1_x:1|c",
+		"pbs.request_time.request_type.amp:1.5|ms",
+		"pbs.adapter_prices.adapter.appnexus:2.5|ms",
+		"pbs.connections_opened:1|c",
+	}, packets.lines())
+}
+
+func TestDogStatsDTags(t *testing.T) {
+	m, packets := newTestMetrics("dogstatsd", nil)
+
+	m.RecordAdapterRequest(pbsmetrics.AdapterLabels{
+		Adapter:       openrtb_ext.BidderAppnexus,
+		CookieFlag:    pbsmetrics.CookieFlagYes,
+		AdapterBids:   pbsmetrics.AdapterBidPresent,
+		AdapterErrors: map[pbsmetrics.AdapterError]struct{}{pbsmetrics.AdapterErrorTimeout: {}},
+	})
+	m.RecordAdapterPrice(pbsmetrics.AdapterLabels{Adapter: openrtb_ext.BidderAppnexus}, 2.5)
+	m.RecordStoredReqCacheResult(pbsmetrics.CacheHit, 3)
+	m.RecordAdapterTime(pbsmetrics.AdapterLabels{Adapter: openrtb_ext.BidderAppnexus}, 20*time.Millisecond)
+	m.Close()
+
+	assert.Equal(t, []string{
+		"pbs.adapter_requests:1|c|#adapter:appnexus,cookie:exists,has_bids:true",
+		"pbs.adapter_errors:1|c|#adapter:appnexus,adapter_error:timeout",
+		"pbs.adapter_prices:2.5|h|#adapter:appnexus",
+		"pbs.stored_request_cache_performance:3|c|#cache_result:hit",
+		"pbs.adapter_request_time:20|ms|#adapter:appnexus",
+	}, packets.lines())
+}
+
+func TestSampleRates(t *testing.T) {
+	m, packets := newTestMetrics("dogstatsd", map[string]float64{"adapter_prices": 0.25, "requests": 1})
+
+	randoms := []float64{0.1, 0.9, 0.5}
+	m.random = func() float64 {
+		next := randoms[0]
+		randoms = randoms[1:]
+		return next
+	}
+
+	labels := pbsmetrics.AdapterLabels{Adapter: openrtb_ext.BidderAppnexus}
+	m.RecordAdapterPrice(labels, 1)
+	m.RecordAdapterPrice(labels, 2)
+	m.RecordRequest(pbsmetrics.Labels{RType: pbsmetrics.ReqTypeVideo, RequestStatus: pbsmetrics.RequestStatusOK, PubID: pbsmetrics.PublisherUnknown})
+	m.RecordCookieSync()
+	m.Close()
+
+	assert.Equal(t, []string{
+		"pbs.adapter_prices:1|h|@0.25|#adapter:appnexus",
+		"pbs.requests:1|c|#request_type:video,request_status:ok",
+		"pbs.cookie_sync_requests:1|c",
+	}, packets.lines(), "Only sampled values should be sent, with their sample rate")
+}
+
+func TestPacketBatching(t *testing.T) {
+	packets := &packetRecorder{}
+	c := newClient(packets, 20, time.Hour)
+
+	c.send("aaaaaaaa:1|c")
+	c.send("bbbbbbbb:1|c")
+	c.send("this-line-is-too-long-for-a-packet:1|c")
+	c.send("cccc:1|c")
+	c.close()
+
+	assert.Equal(t, []string{
+		"aaaaaaaa:1|c",
+		"bbbbbbbb:1|c",
+		"this-line-is-too-long-for-a-packet:1|c",
+		"cccc:1|c",
+	}, packets.packets)
+
+	packets = &packetRecorder{}
+	c = newClient(packets, 100, time.Hour)
+	c.send("a:1|c")
+	c.send("b:1|c")
+	c.close()
+	assert.Equal(t, []string{"a:1|c\nb:1|c"}, packets.packets, "Lines should share a packet while they fit")
+}
+
+func TestFlushInterval(t *testing.T) {
+	packets := &packetRecorder{}
+	c := newClient(packets, 1000, 10*time.Millisecond)
+	defer c.close()
+
+	c.send("a:1|c")
+	assert.Eventually(t, func() bool {
+		packets.mutex.Lock()
+		defer packets.mutex.Unlock()
+		return len(packets.packets) == 1
+	}, time.Second, 5*time.Millisecond, "Buffered lines should be sent after the flush interval")
+}
+
+func TestUDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen for UDP packets: %v", err)
+	}
+	defer listener.Close()
+
+	m, err := NewMetrics(config.StatsDMetrics{
+		Host:          "127.0.0.1",
+		Port:          listener.LocalAddr().(*net.UDPAddr).Port,
+		Prefix:        "pbs.",
+		Flavor:        "statsd",
+		MaxPacketSize: 1432,
+		FlushInterval: 1000,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	m.RecordCookieSync()
+	m.Close()
+
+	buf := make([]byte, 1432)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "pbs.cookie_sync_requests:1|c", string(buf[:n]))
+	}
+}
+
+func newTestMetrics(flavor string, sampleRates map[string]float64) (*Metrics, *packetRecorder) {
+	packets := &packetRecorder{}
+	m := newMetrics(config.StatsDMetrics{
+		Prefix:        "pbs.",
+		Flavor:        flavor,
+		MaxPacketSize: 1432,
+		FlushInterval: 60000,
+		SampleRates:   sampleRates,
+	}, packets)
+	return m, packets
+}
+
+// packetRecorder stands in for a UDP connection.
+type packetRecorder struct {
+	mutex   sync.Mutex
+	packets []string
+}
+
+func (r *packetRecorder) Write(packet []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.packets = append(r.packets, string(packet))
+	return len(packet), nil
+}
+
+func (r *packetRecorder) lines() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var lines []string
+	for _, packet := range r.packets {
+		lines = append(lines, strings.Split(packet, "\n")...)
+	}
+	return lines
+}
@@ -6,9 +6,10 @@ import (
 
 	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/endpoints"
+	"github.com/prebid/prebid-server/endpoints/openrtb2"
 )
 
-func Admin(revision string, rateConverter *currencies.RateConverter) *http.ServeMux {
+func Admin(revision string, rateConverter *currencies.RateConverter, storedRequestValidator *openrtb2.StoredRequestValidator) *http.ServeMux {
 	// Add endpoints to the admin server
 	// Making sure to add pprof routes
 	mux := http.NewServeMux()
@@ -21,5 +22,8 @@ func Admin(revision string, rateConverter *currencies.RateConverter) *http.Serve
 	// Register prebid-server defined admin handlers
 	mux.HandleFunc("/currency/rates", endpoints.NewCurrencyRatesEndpoint(rateConverter))
 	mux.HandleFunc("/version", endpoints.NewVersionEndpoint(revision))
+	if storedRequestValidator != nil {
+		mux.HandleFunc("/storedrequests/validate", storedRequestValidator.Handle)
+	}
 	return mux
 }
@@ -31,6 +31,7 @@ import (
 	"github.com/prebid/prebid-server/cache/postgrescache"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/devicedetection"
 	"github.com/prebid/prebid-server/endpoints"
 	infoEndpoints "github.com/prebid/prebid-server/endpoints/info"
 	"github.com/prebid/prebid-server/endpoints/openrtb2"
@@ -43,6 +44,7 @@ import (
 	"github.com/prebid/prebid-server/router/aspects"
 	"github.com/prebid/prebid-server/ssl"
 	storedRequestsConf "github.com/prebid/prebid-server/stored_requests/config"
+	"github.com/prebid/prebid-server/tracing"
 	"github.com/prebid/prebid-server/usersync/usersyncers"
 
 	"github.com/golang/glog"
@@ -81,6 +83,15 @@ func NewJsonDirectoryServer(schemaDirectory string, validator openrtb_ext.Bidder
 		data[bidder] = json.RawMessage(validator.Schema(bidderName))
 	}
 
+	// Add the generated schemas of the bidders declared in static/bidder-info
+	for bidder, bidderName := range openrtb_ext.BidderMap {
+		if _, ok := data[bidder]; !ok {
+			if schema := validator.Schema(bidderName); schema != "" {
+				data[bidder] = json.RawMessage(schema)
+			}
+		}
+	}
+
 	// Add in any default aliases
 	for aliasName, bidderName := range aliases {
 		bidderData, ok := data[bidderName]
@@ -164,9 +175,10 @@ func newExchangeMap(cfg *config.Configuration) map[string]adapters.Adapter {
 
 type Router struct {
 	*httprouter.Router
-	MetricsEngine   *metricsConf.DetailedMetricsEngine
-	ParamsValidator openrtb_ext.BidderParamValidator
-	Shutdown        func()
+	MetricsEngine          *metricsConf.DetailedMetricsEngine
+	ParamsValidator        openrtb_ext.BidderParamValidator
+	StoredRequestValidator *openrtb2.StoredRequestValidator
+	Shutdown               func()
 }
 
 func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r *Router, err error) {
@@ -177,6 +189,15 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 		Router: httprouter.New(),
 	}
 
+	// Host aliases are bidders of their own, so they must be registered before anything reads the BidderMap.
+	for name, adapter := range cfg.Adapters {
+		if adapter.AliasOf != "" {
+			if err := openrtb_ext.RegisterAlias(name, openrtb_ext.BidderName(adapter.AliasOf)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// For bid processing, we need both the hardcoded certificates and the certificates found in container's
 	// local file system
 	certPool := ssl.GetRootCAPool()
@@ -213,8 +234,32 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 	r.MetricsEngine = metricsConf.NewMetricsEngine(cfg, legacyBidderList)
 	db, shutdown, fetcher, ampFetcher, categoriesFetcher, videoFetcher := storedRequestsConf.NewStoredRequests(cfg, r.MetricsEngine, generalHttpClient, r.Router)
 
+	var tracer *tracing.Tracer
+	if cfg.Tracing.Enabled {
+		exporter, err := tracing.NewExporter(&cfg.Tracing, generalHttpClient)
+		if err != nil {
+			glog.Fatalf("Failed to create the tracing exporter. %v", err)
+		}
+		tracer = tracing.NewTracer(&cfg.Tracing, exporter)
+	}
+
+	// The endpoints share a single detector, so that its rules are only loaded and reloaded once.
+	deviceDetector, err := devicedetection.NewDetector(cfg.DeviceDetection)
+	if err != nil {
+		glog.Fatalf("Failed to create the device detector. %v", err)
+	}
+
 	// todo(zachbadgett): better shutdown
-	r.Shutdown = shutdown
+	r.Shutdown = func() {
+		shutdown()
+		tracer.Shutdown()
+		if fileDetector, ok := deviceDetector.(*devicedetection.FileDetector); ok {
+			fileDetector.StopPeriodicReloading()
+		}
+		if r.MetricsEngine.StatsDMetrics != nil {
+			r.MetricsEngine.StatsDMetrics.Close()
+		}
+	}
 	if err := loadDataCache(cfg, db); err != nil {
 		return nil, fmt.Errorf("Prebid Server could not load data cache: %v", err)
 	}
@@ -237,25 +282,33 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 	defaultAliases, defReqJSON := readDefaultRequest(cfg.DefReqConfig)
 
 	syncers := usersyncers.NewSyncerMap(cfg)
-	gdprPerms := gdpr.NewPermissions(context.Background(), cfg.GDPR, adapters.GDPRAwareSyncerIDs(syncers), generalHttpClient)
+	gdprPerms := gdpr.NewPermissions(context.Background(), cfg.GDPR, usersyncers.GDPRVendorIDs(cfg), generalHttpClient)
 
 	exchanges = newExchangeMap(cfg)
 	cacheClient := pbc.NewClient(cacheHttpClient, &cfg.CacheURL, &cfg.ExtCacheURL, r.MetricsEngine)
 	theExchange := exchange.NewExchange(generalHttpClient, cacheClient, cfg, r.MetricsEngine, bidderInfos, gdprPerms, rateConvertor)
 
-	openrtbEndpoint, err := openrtb2.NewEndpoint(theExchange, paramsValidator, fetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, activeBiddersMap)
+	openrtbEndpoint, err := openrtb2.NewEndpoint(theExchange, paramsValidator, fetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, activeBiddersMap, deviceDetector)
 
 	if err != nil {
 		glog.Fatalf("Failed to create the openrtb endpoint handler. %v", err)
 	}
 
-	ampEndpoint, err := openrtb2.NewAmpEndpoint(theExchange, paramsValidator, ampFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, activeBiddersMap)
+	r.StoredRequestValidator, err = openrtb2.NewStoredRequestValidator(paramsValidator, fetcher, cfg, disabledBidders, defReqJSON, activeBiddersMap)
+	if err != nil {
+		glog.Fatalf("Failed to create the stored request validator. %v", err)
+	}
+	if cfg.StoredRequests.ValidateOnStartup {
+		r.StoredRequestValidator.LogCatalogErrors()
+	}
+
+	ampEndpoint, err := openrtb2.NewAmpEndpoint(theExchange, paramsValidator, ampFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, activeBiddersMap, deviceDetector)
 
 	if err != nil {
 		glog.Fatalf("Failed to create the amp endpoint handler. %v", err)
 	}
 
-	videoEndpoint, err := openrtb2.NewVideoEndpoint(theExchange, paramsValidator, fetcher, videoFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, activeBiddersMap, cacheClient)
+	videoEndpoint, err := openrtb2.NewVideoEndpoint(theExchange, paramsValidator, fetcher, videoFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, activeBiddersMap, cacheClient, deviceDetector)
 	if err != nil {
 		glog.Fatalf("Failed to create the video endpoint handler. %v", err)
 	}
@@ -265,10 +318,10 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 		videoEndpoint = aspects.QueuedRequestTimeout(videoEndpoint, cfg.RequestTimeoutHeaders, r.MetricsEngine, pbsmetrics.ReqTypeVideo)
 	}
 
-	r.POST("/auction", endpoints.Auction(cfg, syncers, gdprPerms, r.MetricsEngine, dataCache, exchanges))
-	r.POST("/openrtb2/auction", openrtbEndpoint)
-	r.POST("/openrtb2/video", videoEndpoint)
-	r.GET("/openrtb2/amp", ampEndpoint)
+	r.POST("/auction", endpoints.Auction(cfg, syncers, gdprPerms, r.MetricsEngine, dataCache, exchanges, theExchange))
+	r.POST("/openrtb2/auction", tracer.Handler("openrtb2.auction", openrtbEndpoint))
+	r.POST("/openrtb2/video", tracer.Handler("openrtb2.video", videoEndpoint))
+	r.GET("/openrtb2/amp", tracer.Handler("openrtb2.amp", ampEndpoint))
 	r.GET("/info/bidders", infoEndpoints.NewBiddersEndpoint(defaultAliases))
 	r.GET("/info/bidders/:bidderName", infoEndpoints.NewBidderDetailsEndpoint(bidderInfos, defaultAliases))
 	r.GET("/bidders/params", NewJsonDirectoryServer(schemaDirectory, paramsValidator, defaultAliases))
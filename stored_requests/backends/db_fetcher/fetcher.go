@@ -11,17 +11,28 @@ import (
 	"github.com/prebid/prebid-server/stored_requests"
 )
 
-func NewFetcher(db *sql.DB, queryMaker func(int, int) string) stored_requests.AllFetcher {
+// NewFetcher makes a Fetcher which reads Stored Requests from the database using the queryMaker.
+//
+// If listQuery is non-empty, the Fetcher also implements stored_requests.Lister. listQuery should
+// select every Stored Request and Stored Imp, in the same (id, data, type) format as the queryMaker's queries.
+func NewFetcher(db *sql.DB, queryMaker func(int, int) string, listQuery string) stored_requests.AllFetcher {
 	if db == nil {
 		glog.Fatalf("The Postgres Stored Request Fetcher requires a database connection. Please report this as a bug.")
 	}
 	if queryMaker == nil {
 		glog.Fatalf("The Postgres Stored Request Fetcher requires a queryMaker function. Please report this as a bug.")
 	}
-	return &dbFetcher{
+	fetcher := &dbFetcher{
 		db:         db,
 		queryMaker: queryMaker,
 	}
+	if listQuery != "" {
+		return &listingDBFetcher{
+			dbFetcher: fetcher,
+			listQuery: listQuery,
+		}
+	}
+	return fetcher
 }
 
 // dbFetcher fetches Stored Requests from a database. This should be instantiated through the NewFetcher() function.
@@ -93,6 +104,52 @@ func (fetcher *dbFetcher) FetchRequests(ctx context.Context, requestIDs []string
 	return storedRequestData, storedImpData, errs
 }
 
+// listingDBFetcher is a dbFetcher which can also list every ID in the database.
+type listingDBFetcher struct {
+	*dbFetcher
+	listQuery string
+}
+
+func (fetcher *listingDBFetcher) ListIDs(ctx context.Context) (requestIDs []string, impIDs []string) {
+	rows, err := fetcher.db.QueryContext(ctx, fetcher.listQuery)
+	if err != nil {
+		glog.Errorf("Error listing the Stored Request IDs in the DB: %v", err)
+		return nil, nil
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			glog.Errorf("error closing DB connection: %v", err)
+		}
+	}()
+
+	for rows.Next() {
+		var id string
+		var data []byte
+		var dataType string
+
+		if err := rows.Scan(&id, &data, &dataType); err != nil {
+			glog.Errorf("Error listing the Stored Request IDs in the DB: %v", err)
+			return nil, nil
+		}
+
+		switch dataType {
+		case "request":
+			requestIDs = append(requestIDs, id)
+		case "imp":
+			impIDs = append(impIDs, id)
+		default:
+			glog.Errorf("Postgres result set with id=%s has invalid type: %s. This will be ignored.", id, dataType)
+		}
+	}
+
+	if rows.Err() != nil {
+		glog.Errorf("Error listing the Stored Request IDs in the DB: %v", rows.Err())
+		return nil, nil
+	}
+
+	return requestIDs, impIDs
+}
+
 func (fetcher *dbFetcher) FetchCategories(ctx context.Context, primaryAdServer, publisherId, iabCategory string) (string, error) {
 	return "", nil
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prebid/prebid-server/stored_requests"
 )
 
 func TestEmptyQuery(t *testing.T) {
@@ -173,6 +174,62 @@ func TestRowErrors(t *testing.T) {
 	assertMapLength(t, 0, data)
 }
 
+// TestListIDs makes sure the list query's rows are split into Stored Request and Stored Imp IDs.
+func TestListIDs(t *testing.T) {
+	listQuery := "SELECT id, data, 'request' AS dataType FROM req_table UNION ALL SELECT id, data, 'imp' as dataType FROM imp_table"
+	mockReturn := sqlmock.NewRows([]string{"id", "data", "dataType"}).
+		AddRow("request-id", `{"req":true}`, "request").
+		AddRow("imp-id", `{"imp":true}`, "imp").
+		AddRow("bad-id", `{}`, "unknown")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(fmt.Sprintf("^%s$", regexp.QuoteMeta(listQuery))).WillReturnRows(mockReturn)
+
+	fetcher := NewFetcher(db, successfulQueryMaker(""), listQuery)
+	lister, ok := fetcher.(stored_requests.Lister)
+	if !ok {
+		t.Fatalf("The fetcher should list its IDs when it has a list query")
+	}
+	requestIDs, impIDs := lister.ListIDs(context.Background())
+
+	assertMockExpectations(t, mock)
+	assertIDs(t, []string{"request-id"}, requestIDs)
+	assertIDs(t, []string{"imp-id"}, impIDs)
+}
+
+// TestListIDsDatabaseError makes sure nothing is listed if the list query fails.
+func TestListIDsDatabaseError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(".*").WillReturnError(errors.New("Invalid query."))
+
+	fetcher := NewFetcher(db, successfulQueryMaker(""), "SELECT id, data, dataType FROM my_table")
+	requestIDs, impIDs := fetcher.(stored_requests.Lister).ListIDs(context.Background())
+
+	assertIDs(t, nil, requestIDs)
+	assertIDs(t, nil, impIDs)
+}
+
+// TestNoListQuery makes sure the fetcher doesn't claim to list its IDs without a list query.
+func TestNoListQuery(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock: %v", err)
+	}
+	defer db.Close()
+
+	if _, ok := NewFetcher(db, successfulQueryMaker(""), "").(stored_requests.Lister); ok {
+		t.Errorf("The fetcher shouldn't list its IDs without a list query")
+	}
+}
+
 func newFetcher(t *testing.T, rows *sqlmock.Rows, query string, args ...driver.Value) (sqlmock.Sqlmock, *dbFetcher) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -215,6 +272,20 @@ func assertHasData(t *testing.T, data map[string]json.RawMessage, key string, va
 	}
 }
 
+func assertIDs(t *testing.T, expected []string, actual []string) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Errorf("Wrong IDs. Expected %v, Got %v.", expected, actual)
+		return
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("Wrong IDs. Expected %v, Got %v.", expected, actual)
+			return
+		}
+	}
+}
+
 func assertErrorCount(t *testing.T, num int, errs []error) {
 	t.Helper()
 	if len(errs) != num {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"sort"
 	"strings"
 
 	"github.com/prebid/prebid-server/stored_requests"
@@ -33,6 +34,19 @@ func (fetcher *eagerFetcher) FetchRequests(ctx context.Context, requestIDs []str
 	return storedRequests, storedImpressions, errs
 }
 
+// ListIDs implements the stored_requests.Lister interface. Every file in the directory is in memory, so this is cheap.
+func (fetcher *eagerFetcher) ListIDs(ctx context.Context) (requestIDs []string, impIDs []string) {
+	for id := range fetcher.FileSystem.Directories["stored_requests"].Files {
+		requestIDs = append(requestIDs, id)
+	}
+	for id := range fetcher.FileSystem.Directories["stored_imps"].Files {
+		impIDs = append(impIDs, id)
+	}
+	sort.Strings(requestIDs)
+	sort.Strings(impIDs)
+	return
+}
+
 func (fetcher *eagerFetcher) FetchCategories(ctx context.Context, primaryAdServer, publisherId, iabCategory string) (string, error) {
 	fileName := primaryAdServer
 
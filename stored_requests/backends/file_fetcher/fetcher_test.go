@@ -24,6 +24,17 @@ func TestFileFetcher(t *testing.T) {
 	validateImp(t, storedImps)
 }
 
+func TestFileFetcherListIDs(t *testing.T) {
+	fetcher, err := NewFileFetcher("./test")
+	if err != nil {
+		t.Errorf("Failed to create a Fetcher: %v", err)
+	}
+
+	requestIDs, impIDs := fetcher.(stored_requests.Lister).ListIDs(context.Background())
+	assert.Equal(t, []string{"1", "2"}, requestIDs, "All the Stored Request IDs should be listed")
+	assert.Equal(t, []string{"some-imp"}, impIDs, "All the Stored Imp IDs should be listed")
+}
+
 func TestInvalidDirectory(t *testing.T) {
 	_, err := NewFileFetcher("./nonexistant-directory")
 	if err == nil {
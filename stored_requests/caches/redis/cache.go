@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/stored_requests"
+)
+
+// NewCache returns a Cache which stores data on a server which speaks the Redis protocol,
+// so that it can be shared by every Prebid Server instance.
+//
+// It is meant to be composed behind an in-memory cache with stored_requests.ComposedCache.
+// Any errors talking to the server are logged, and treated like cache misses.
+func NewCache(client *Client, cfg *config.RedisCache) stored_requests.Cache {
+	glog.Infof("Using a shared Stored Request cache at %s. TTL: %d seconds.", cfg.Address, cfg.TTL)
+	return &cache{
+		client:    client,
+		keyPrefix: cfg.KeyPrefix,
+		ttl:       cfg.TTL,
+	}
+}
+
+type cache struct {
+	client    *Client
+	keyPrefix string
+	ttl       int
+}
+
+func (c *cache) Get(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage) {
+	requestData = make(map[string]json.RawMessage, len(requestIDs))
+	impData = make(map[string]json.RawMessage, len(impIDs))
+
+	var commands [][]string
+	if len(requestIDs) > 0 {
+		commands = append(commands, append([]string{"MGET"}, c.keys("request", requestIDs)...))
+	}
+	if len(impIDs) > 0 {
+		commands = append(commands, append([]string{"MGET"}, c.keys("imp", impIDs)...))
+	}
+	if len(commands) == 0 {
+		return
+	}
+
+	replies, err := c.client.Pipeline(ctx, commands)
+	if err != nil {
+		glog.Errorf("Failed to get Stored Requests from the shared cache: %v", err)
+		return
+	}
+
+	if len(requestIDs) > 0 {
+		addValues(requestData, requestIDs, replies[0])
+		replies = replies[1:]
+	}
+	if len(impIDs) > 0 {
+		addValues(impData, impIDs, replies[0])
+	}
+	return
+}
+
+func (c *cache) Save(ctx context.Context, requestData map[string]json.RawMessage, impData map[string]json.RawMessage) {
+	commands := make([][]string, 0, len(requestData)+len(impData))
+	commands = c.appendSets(commands, "request", requestData)
+	commands = c.appendSets(commands, "imp", impData)
+	c.run(ctx, commands, "save Stored Requests to")
+}
+
+func (c *cache) Invalidate(ctx context.Context, requestIDs []string, impIDs []string) {
+	var commands [][]string
+	if len(requestIDs) > 0 {
+		commands = append(commands, append([]string{"DEL"}, c.keys("request", requestIDs)...))
+	}
+	if len(impIDs) > 0 {
+		commands = append(commands, append([]string{"DEL"}, c.keys("imp", impIDs)...))
+	}
+	c.run(ctx, commands, "invalidate Stored Requests in")
+}
+
+func (c *cache) run(ctx context.Context, commands [][]string, action string) {
+	if len(commands) == 0 {
+		return
+	}
+
+	replies, err := c.client.Pipeline(ctx, commands)
+	if err != nil {
+		glog.Errorf("Failed to %s the shared cache: %v", action, err)
+		return
+	}
+	for _, reply := range replies {
+		if replyErr, ok := reply.(Error); ok {
+			glog.Errorf("Failed to %s the shared cache: %v", action, replyErr)
+		}
+	}
+}
+
+func (c *cache) appendSets(commands [][]string, dataType string, data map[string]json.RawMessage) [][]string {
+	for id, value := range data {
+		command := []string{"SET", c.key(dataType, id), string(value)}
+		if c.ttl > 0 {
+			command = append(command, "EX", strconv.Itoa(c.ttl))
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+func (c *cache) keys(dataType string, ids []string) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = c.key(dataType, id)
+	}
+	return keys
+}
+
+func (c *cache) key(dataType string, id string) string {
+	return c.keyPrefix + dataType + ":" + id
+}
+
+// addValues adds the non-null values of an MGET reply to the data.
+func addValues(data map[string]json.RawMessage, ids []string, reply interface{}) {
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != len(ids) {
+		glog.Errorf("Unexpected reply from the shared Stored Request cache: %v", reply)
+		return
+	}
+	for i, value := range values {
+		if bytes, ok := value.([]byte); ok {
+			data[ids[i]] = json.RawMessage(bytes)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/stored_requests"
+	"github.com/prebid/prebid-server/stored_requests/caches/cachestest"
+	"github.com/prebid/prebid-server/stored_requests/caches/memory"
+	"github.com/prebid/prebid-server/stored_requests/caches/redis/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisRobustness(t *testing.T) {
+	server := newTestServer(t, "")
+	defer server.Close()
+
+	prefix := 0
+	cachestest.AssertCacheRobustness(t, func() stored_requests.Cache {
+		// Use a separate key prefix for each test, so that they don't share data.
+		prefix++
+		cfg := testConfig(server, "")
+		cfg.KeyPrefix = "test" + strconv.Itoa(prefix) + ":"
+		return NewCache(NewClient(cfg), cfg)
+	})
+}
+
+func TestRedisKeys(t *testing.T) {
+	server := newTestServer(t, "secret")
+	defer server.Close()
+
+	cfg := testConfig(server, "secret")
+	cfg.TTL = 60
+	cache := NewCache(NewClient(cfg), cfg)
+
+	cache.Save(context.Background(), map[string]json.RawMessage{"req": json.RawMessage(`{"req":true}`)}, map[string]json.RawMessage{"imp": json.RawMessage(`{"imp":true}`)})
+	assert.Equal(t, map[string]string{
+		"pbs:request:req": `{"req":true}`,
+		"pbs:imp:imp":     `{"imp":true}`,
+	}, server.Data(), "Stored data should be saved under prefixed keys")
+
+	cache.Invalidate(context.Background(), []string{"req"}, nil)
+	assert.Equal(t, map[string]string{
+		"pbs:imp:imp": `{"imp":true}`,
+	}, server.Data(), "Invalidated data should be deleted")
+}
+
+func TestRedisWrongPassword(t *testing.T) {
+	server := newTestServer(t, "secret")
+	defer server.Close()
+
+	cfg := testConfig(server, "wrong")
+	cache := NewCache(NewClient(cfg), cfg)
+
+	cache.Save(context.Background(), map[string]json.RawMessage{"req": json.RawMessage(`{"req":true}`)}, nil)
+	requestData, impData := cache.Get(context.Background(), []string{"req"}, []string{"imp"})
+	assert.Empty(t, requestData, "Failed connections should be treated as cache misses")
+	assert.Empty(t, impData, "Failed connections should be treated as cache misses")
+	assert.Empty(t, server.Data(), "Nothing should be saved without authentication")
+}
+
+func TestRedisUnavailable(t *testing.T) {
+	server := newTestServer(t, "")
+	cfg := testConfig(server, "")
+	server.Close()
+
+	cache := NewCache(NewClient(cfg), cfg)
+	cache.Save(context.Background(), map[string]json.RawMessage{"req": json.RawMessage(`{"req":true}`)}, nil)
+	cache.Invalidate(context.Background(), []string{"req"}, nil)
+	requestData, _ := cache.Get(context.Background(), []string{"req"}, nil)
+	assert.Empty(t, requestData, "An unavailable server should be treated as a cache miss")
+}
+
+func TestComposedWithMemory(t *testing.T) {
+	server := newTestServer(t, "")
+	defer server.Close()
+
+	cfg := testConfig(server, "")
+	shared := NewCache(NewClient(cfg), cfg)
+	shared.Save(context.Background(), map[string]json.RawMessage{"req": json.RawMessage(`{"req":true}`)}, nil)
+
+	// A new instance starts with an empty memory cache, but can still find the data in the shared one.
+	composed := stored_requests.ComposedCache{
+		memory.NewCache(&config.InMemoryCache{TTL: -1}),
+		NewCache(NewClient(cfg), cfg),
+	}
+	requestData, _ := composed.Get(context.Background(), []string{"req"}, nil)
+	assert.JSONEq(t, `{"req":true}`, string(requestData["req"]), "Data in the shared cache should be found by every instance")
+}
+
+func newTestServer(t *testing.T, password string) *redistest.Server {
+	server, err := redistest.NewServer(password)
+	if err != nil {
+		t.Fatalf("Failed to start the test server: %v", err)
+	}
+	return server
+}
+
+func testConfig(server *redistest.Server, password string) *config.RedisCache {
+	return &config.RedisCache{
+		Address:   server.Address(),
+		Password:  password,
+		KeyPrefix: "pbs:",
+		Timeout:   1000,
+		PoolSize:  2,
+	}
+}
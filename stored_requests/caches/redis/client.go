@@ -0,0 +1,293 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+// Client is a minimal client for servers which speak the Redis protocol (RESP).
+// It only supports what the Stored Request cache and events need: plain commands, pipelines and pub/sub.
+//
+// Client is safe for concurrent access by multiple goroutines.
+type Client struct {
+	address  string
+	password string
+	db       int
+	timeout  time.Duration
+	pool     chan *conn
+}
+
+// Error is an error reply sent by the server. It doesn't mean that the connection is broken.
+type Error string
+
+func (err Error) Error() string {
+	return string(err)
+}
+
+// NewClient returns a Client for the server described by the config. Connections are opened lazily.
+func NewClient(cfg *config.RedisCache) *Client {
+	return &Client{
+		address:  cfg.Address,
+		password: cfg.Password,
+		db:       cfg.DB,
+		timeout:  cfg.TimeoutDuration(),
+		pool:     make(chan *conn, cfg.PoolSize),
+	}
+}
+
+// Do sends a single command to the server, and returns its reply.
+//
+// Replies are returned as a string (simple strings), []byte (bulk strings), int64 (integers), nil (null replies),
+// []interface{} (arrays) or an Error.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	replies, err := c.Pipeline(ctx, [][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	if replyErr, ok := replies[0].(Error); ok {
+		return nil, replyErr
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends several commands to the server in a single round trip, and returns their replies in order.
+// Error replies to individual commands are returned in the replies, rather than as the error.
+func (c *Client) Pipeline(ctx context.Context, commands [][]string) ([]interface{}, error) {
+	cn, err := c.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := cn.pipeline(ctx, c.timeout, commands)
+	c.putConn(cn, err)
+	return replies, err
+}
+
+// Subscribe listens for messages published on the channel, and passes them to the handler.
+// It blocks until the context is cancelled or the connection fails. The returned error is never nil.
+func (c *Client) Subscribe(ctx context.Context, channel string, handler func(message []byte)) error {
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer cn.netConn.Close()
+
+	// Unblock the read below when the context ends.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cn.netConn.Close()
+		case <-done:
+		}
+	}()
+
+	cn.netConn.SetDeadline(time.Now().Add(c.timeout))
+	if err := writeCommand(cn.netConn, []string{"SUBSCRIBE", channel}); err != nil {
+		return err
+	}
+	if _, err := readReply(cn.reader); err != nil {
+		return err
+	}
+	cn.netConn.SetDeadline(time.Time{})
+
+	for {
+		reply, err := readReply(cn.reader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		// Messages look like: ["message", channel, payload]
+		if parts, ok := reply.([]interface{}); ok && len(parts) == 3 {
+			if kind, ok := parts[0].([]byte); ok && string(kind) == "message" {
+				if payload, ok := parts[2].([]byte); ok {
+					handler(payload)
+				}
+			}
+		}
+	}
+}
+
+func (c *Client) getConn(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+		return c.dial(ctx)
+	}
+}
+
+// putConn returns the connection to the pool, unless it's broken or the pool is full.
+func (c *Client) putConn(cn *conn, err error) {
+	if err != nil {
+		cn.netConn.Close()
+		return
+	}
+	select {
+	case c.pool <- cn:
+	default:
+		cn.netConn.Close()
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{
+		netConn: netConn,
+		reader:  bufio.NewReader(netConn),
+	}
+
+	var setup [][]string
+	if c.password != "" {
+		setup = append(setup, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		replies, err := cn.pipeline(ctx, c.timeout, setup)
+		if err == nil {
+			for _, reply := range replies {
+				if replyErr, ok := reply.(Error); ok {
+					err = replyErr
+					break
+				}
+			}
+		}
+		if err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("Failed to set up the connection to %s: %v", c.address, err)
+		}
+	}
+	return cn, nil
+}
+
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+}
+
+func (cn *conn) pipeline(ctx context.Context, timeout time.Duration, commands [][]string) ([]interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := cn.netConn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	writer := bufio.NewWriter(cn.netConn)
+	for _, command := range commands {
+		if err := writeCommand(writer, command); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	for i := range commands {
+		reply, err := readReply(cn.reader)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// writeCommand writes the command as a RESP array of bulk strings.
+func writeCommand(w io.Writer, args []string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("Empty reply from the server")
+	}
+
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		length, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:length], nil
+	case '*':
+		length, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, nil
+		}
+		elements := make([]interface{}, length)
+		for i := range elements {
+			if elements[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+	default:
+		return nil, fmt.Errorf("Unexpected reply from the server: %q", line)
+	}
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("Malformed reply from the server: %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadReply(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		expected    interface{}
+	}{
+		{
+			description: "Simple string",
+			input:       "+OK\r\n",
+			expected:    "OK",
+		},
+		{
+			description: "Error",
+			input:       "-ERR bad\r\n",
+			expected:    Error("ERR bad"),
+		},
+		{
+			description: "Integer",
+			input:       ":42\r\n",
+			expected:    int64(42),
+		},
+		{
+			description: "Bulk string",
+			input:       "$5\r\nhe\r\no\r\n",
+			expected:    []byte("he\r\no"),
+		},
+		{
+			description: "Null bulk string",
+			input:       "$-1\r\n",
+			expected:    nil,
+		},
+		{
+			description: "Array",
+			input:       "*2\r\n$1\r\na\r\n$-1\r\n",
+			expected:    []interface{}{[]byte("a"), nil},
+		},
+	}
+
+	for _, test := range testCases {
+		reply, err := readReply(bufio.NewReader(strings.NewReader(test.input)))
+		assert.NoError(t, err, test.description)
+		assert.Equal(t, test.expected, reply, test.description)
+	}
+}
+
+func TestReadReplyMalformed(t *testing.T) {
+	for _, input := range []string{"", "\r\n", "?what\r\n", "+OK\n", "$5\r\nab\r\n", ":abc\r\n"} {
+		_, err := readReply(bufio.NewReader(strings.NewReader(input)))
+		assert.Error(t, err, "Expected an error for %q", input)
+	}
+}
+
+func TestWriteCommand(t *testing.T) {
+	var output strings.Builder
+	assert.NoError(t, writeCommand(&output, []string{"SET", "key", "some value"}))
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$10\r\nsome value\r\n", output.String())
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	server := newTestServer(t, "secret")
+	defer server.Close()
+
+	client := NewClient(testConfig(server, "secret"))
+	for i := 0; i < 3; i++ {
+		reply, err := client.Do(context.Background(), "PING")
+		assert.NoError(t, err)
+		assert.Equal(t, "PONG", reply)
+	}
+	assert.Equal(t, []string{"AUTH", "PING", "PING", "PING"}, server.Commands(), "Only one connection should be opened")
+
+	_, err := client.Do(context.Background(), "UNKNOWN")
+	assert.IsType(t, Error(""), err, "Error replies should be returned as errors")
+}
@@ -0,0 +1,250 @@
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is a local stand-in for a server which speaks the Redis protocol.
+// It keeps its data in memory, and supports just enough commands to test the Stored Request cache and events:
+// AUTH, SELECT, PING, GET, MGET, SET, DEL, PUBLISH and SUBSCRIBE.
+type Server struct {
+	listener net.Listener
+	password string
+
+	mutex       sync.Mutex
+	data        map[string]string
+	subscribers map[string][]*subscriber
+	commands    []string
+}
+
+type subscriber struct {
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// NewServer starts a Server on a random local port. If password isn't empty, clients must AUTH with it.
+func NewServer(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener:    listener,
+		password:    password,
+		data:        make(map[string]string),
+		subscribers: make(map[string][]*subscriber),
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Address returns the host:port which the Server is listening on.
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the Server.
+func (s *Server) Close() {
+	s.listener.Close()
+}
+
+// Data returns a copy of the keys and values stored in the Server.
+func (s *Server) Data() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data := make(map[string]string, len(s.data))
+	for key, value := range s.data {
+		data[key] = value
+	}
+	return data
+}
+
+// Commands returns the names of all the commands which the Server has received, in order.
+func (s *Server) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Subscribers returns the number of connections subscribed to the channel.
+func (s *Server) Subscribers(channel string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.subscribers[channel])
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	var sub *subscriber
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if sub != nil {
+				s.unsubscribe(sub)
+			}
+			return
+		}
+
+		s.mutex.Lock()
+		s.commands = append(s.commands, strings.ToUpper(args[0]))
+		s.mutex.Unlock()
+
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if len(args) == 2 && args[1] == s.password {
+				authenticated = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-ERR invalid password\r\n"
+			}
+		case "PING":
+			reply = "+PONG\r\n"
+		default:
+			if !authenticated {
+				reply = "-NOAUTH Authentication required.\r\n"
+			} else {
+				reply, sub = s.execute(conn, args, sub)
+			}
+		}
+
+		if sub != nil {
+			sub.mutex.Lock()
+			_, err = io.WriteString(conn, reply)
+			sub.mutex.Unlock()
+		} else {
+			_, err = io.WriteString(conn, reply)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) execute(conn net.Conn, args []string, sub *subscriber) (string, *subscriber) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "SELECT":
+		return "+OK\r\n", sub
+	case "GET":
+		if value, ok := s.data[args[1]]; ok {
+			return bulkString(value), sub
+		}
+		return "$-1\r\n", sub
+	case "MGET":
+		reply := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+		for _, key := range args[1:] {
+			if value, ok := s.data[key]; ok {
+				reply += bulkString(value)
+			} else {
+				reply += "$-1\r\n"
+			}
+		}
+		return reply, sub
+	case "SET":
+		// Expirations are accepted, but ignored.
+		s.data[args[1]] = args[2]
+		return "+OK\r\n", sub
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.data[key]; ok {
+				delete(s.data, key)
+				deleted++
+			}
+		}
+		return ":" + strconv.Itoa(deleted) + "\r\n", sub
+	case "PUBLISH":
+		channel, payload := args[1], args[2]
+		message := "*3\r\n" + bulkString("message") + bulkString(channel) + bulkString(payload)
+		for _, subscriber := range s.subscribers[channel] {
+			subscriber.mutex.Lock()
+			io.WriteString(subscriber.conn, message)
+			subscriber.mutex.Unlock()
+		}
+		return ":" + strconv.Itoa(len(s.subscribers[channel])) + "\r\n", sub
+	case "SUBSCRIBE":
+		if sub == nil {
+			sub = &subscriber{conn: conn}
+		}
+		s.subscribers[args[1]] = append(s.subscribers[args[1]], sub)
+		return "*3\r\n" + bulkString("subscribe") + bulkString(args[1]) + ":1\r\n", sub
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0]), sub
+	}
+}
+
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for channel, subscribers := range s.subscribers {
+		remaining := subscribers[:0]
+		for _, other := range subscribers {
+			if other != sub {
+				remaining = append(remaining, other)
+			}
+		}
+		s.subscribers[channel] = remaining
+	}
+}
+
+func bulkString(value string) string {
+	return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command: %q", line)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:length])
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
@@ -18,10 +18,12 @@ import (
 	"github.com/prebid/prebid-server/stored_requests/backends/http_fetcher"
 	"github.com/prebid/prebid-server/stored_requests/caches/memory"
 	"github.com/prebid/prebid-server/stored_requests/caches/nil_cache"
+	redisCache "github.com/prebid/prebid-server/stored_requests/caches/redis"
 	"github.com/prebid/prebid-server/stored_requests/events"
 	apiEvents "github.com/prebid/prebid-server/stored_requests/events/api"
 	httpEvents "github.com/prebid/prebid-server/stored_requests/events/http"
 	postgresEvents "github.com/prebid/prebid-server/stored_requests/events/postgres"
+	redisEvents "github.com/prebid/prebid-server/stored_requests/events/redis"
 )
 
 // This gets set to the connection string used when a database connection is made. We only support a single
@@ -63,21 +65,46 @@ func CreateStoredRequests(cfg *config.StoredRequestsSlim, metricsEngine pbsmetri
 		}
 	}
 
-	eventProducers := newEventProducers(cfg, client, dbc.db, router)
+	var redisClient *redisCache.Client
+	var pubSub *redisEvents.PubSub
+	if cfg.RedisCache.Address != "" {
+		redisClient = redisCache.NewClient(&cfg.RedisCache)
+		// The events from other instances are only applied to the in-memory cache, so nothing would read them without one.
+		if cfg.RedisCache.PubSubChannel != "" && cfg.InMemoryCache.Type != "" {
+			pubSub = redisEvents.NewPubSub(redisClient, cfg.RedisCache.PubSubChannel)
+		}
+	}
+
+	eventProducers := newEventProducers(cfg, client, dbc.db, router, pubSub)
 	fetcher = newFetcher(cfg, client, dbc.db)
 
-	var shutdown1 func()
+	var shutdown1, shutdown2 func()
 
 	if cfg.InMemoryCache.Type != "" {
-		cache := newCache(cfg)
+		localCache := newCache(cfg)
+		cache := localCache
+		if redisClient != nil {
+			cache = stored_requests.ComposedCache{localCache, redisCache.NewCache(redisClient, &cfg.RedisCache)}
+		}
 		fetcher = stored_requests.WithCache(fetcher, cache, metricsEngine)
 		shutdown1 = addListeners(cache, eventProducers)
+
+		// Events from other instances only need to reach the local cache, since they've already updated the shared one.
+		if pubSub != nil {
+			shutdown2 = addListeners(localCache, []events.EventProducer{pubSub})
+		}
 	}
 
 	shutdown = func() {
 		if shutdown1 != nil {
 			shutdown1()
 		}
+		if shutdown2 != nil {
+			shutdown2()
+		}
+		if pubSub != nil {
+			pubSub.Stop()
+		}
 		if dbc.db != nil {
 			db := dbc.db
 			dbc.db = nil
@@ -158,6 +185,11 @@ func resolvedStoredRequestsConfig(cfg *config.Configuration) (auc, amp config.St
 	auc.HTTPEvents.RefreshRate = sr.HTTPEvents.RefreshRate
 	auc.HTTPEvents.Timeout = sr.HTTPEvents.Timeout
 	auc.HTTPEvents.Endpoint = sr.HTTPEvents.Endpoint
+	auc.RedisCache = sr.RedisCache
+	auc.RedisCache.KeyPrefix = sr.RedisCache.KeyPrefix + "openrtb2:"
+	if sr.RedisCache.PubSubChannel != "" {
+		auc.RedisCache.PubSubChannel = sr.RedisCache.PubSubChannel + ":openrtb2"
+	}
 
 	// Amp endpoint uses all the slim data but some fields get replacyed by Amp* version of similar fields
 	amp.Files.Enabled = sr.Files
@@ -176,6 +208,11 @@ func resolvedStoredRequestsConfig(cfg *config.Configuration) (auc, amp config.St
 	amp.HTTPEvents.RefreshRate = sr.HTTPEvents.RefreshRate
 	amp.HTTPEvents.Timeout = sr.HTTPEvents.Timeout
 	amp.HTTPEvents.Endpoint = sr.HTTPEvents.AmpEndpoint
+	amp.RedisCache = sr.RedisCache
+	amp.RedisCache.KeyPrefix = sr.RedisCache.KeyPrefix + "amp:"
+	if sr.RedisCache.PubSubChannel != "" {
+		amp.RedisCache.PubSubChannel = sr.RedisCache.PubSubChannel + ":amp"
+	}
 
 	return
 }
@@ -205,7 +242,7 @@ func newFetcher(cfg *config.StoredRequestsSlim, client *http.Client, db *sql.DB)
 	}
 	if cfg.Postgres.FetcherQueries.QueryTemplate != "" {
 		glog.Infof("Loading Stored Requests via Postgres.\nQuery: %s", cfg.Postgres.FetcherQueries.QueryTemplate)
-		idList = append(idList, db_fetcher.NewFetcher(db, cfg.Postgres.FetcherQueries.MakeQuery))
+		idList = append(idList, db_fetcher.NewFetcher(db, cfg.Postgres.FetcherQueries.MakeQuery, cfg.Postgres.CacheInitialization.Query))
 	}
 	if cfg.HTTP.Endpoint != "" {
 		glog.Infof("Loading Stored Requests via HTTP. endpoint=%s", cfg.HTTP.Endpoint)
@@ -225,9 +262,15 @@ func newCache(cfg *config.StoredRequestsSlim) stored_requests.Cache {
 	return memory.NewCache(&cfg.InMemoryCache)
 }
 
-func newEventProducers(cfg *config.StoredRequestsSlim, client *http.Client, db *sql.DB, router *httprouter.Router) (eventProducers []events.EventProducer) {
+func newEventProducers(cfg *config.StoredRequestsSlim, client *http.Client, db *sql.DB, router *httprouter.Router, pubSub *redisEvents.PubSub) (eventProducers []events.EventProducer) {
 	if cfg.CacheEvents.Enabled {
-		eventProducers = append(eventProducers, newEventsAPI(router, cfg.CacheEvents.Endpoint))
+		apiEvents := newEventsAPI(router, cfg.CacheEvents.Endpoint)
+		// The API is only called on a single instance, so its events must be propagated to the others.
+		// Every instance polls the other backends for itself.
+		if pubSub != nil {
+			apiEvents = pubSub.Propagate(apiEvents)
+		}
+		eventProducers = append(eventProducers, apiEvents)
 	}
 	if cfg.HTTPEvents.RefreshRate != 0 && cfg.HTTPEvents.Endpoint != "" {
 		eventProducers = append(eventProducers, newHttpEvents(client, cfg.HTTPEvents.TimeoutDuration(), cfg.HTTPEvents.RefreshRateDuration(), cfg.HTTPEvents.Endpoint))
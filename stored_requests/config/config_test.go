@@ -126,6 +126,8 @@ func TestResolveConfig(t *testing.T) {
 	cfg.StoredRequests.Postgres.PollUpdates.Query = "auc-poll-query"
 	cfg.StoredRequests.HTTP.Endpoint = "auc-http-fetcher-endpoint"
 	cfg.StoredRequests.HTTPEvents.Endpoint = "auc-http-events-endpoint"
+	cfg.StoredRequests.RedisCache.KeyPrefix = "pbs:"
+	cfg.StoredRequests.RedisCache.PubSubChannel = "events"
 
 	auc, amp := resolvedStoredRequestsConfig(cfg)
 
@@ -136,6 +138,8 @@ func TestResolveConfig(t *testing.T) {
 	assertStringsEqual(t, auc.HTTP.Endpoint, cfg.StoredRequests.HTTP.Endpoint)
 	assertStringsEqual(t, auc.HTTPEvents.Endpoint, cfg.StoredRequests.HTTPEvents.Endpoint)
 	assertStringsEqual(t, auc.CacheEvents.Endpoint, "/storedrequests/openrtb2")
+	assertStringsEqual(t, auc.RedisCache.KeyPrefix, "pbs:openrtb2:")
+	assertStringsEqual(t, auc.RedisCache.PubSubChannel, "events:openrtb2")
 
 	// Amp slim should have the amp values in it
 	assertStringsEqual(t, amp.Postgres.FetcherQueries.QueryTemplate, cfg.StoredRequests.Postgres.FetcherQueries.AmpQueryTemplate)
@@ -144,6 +148,8 @@ func TestResolveConfig(t *testing.T) {
 	assertStringsEqual(t, amp.HTTP.Endpoint, cfg.StoredRequests.HTTP.AmpEndpoint)
 	assertStringsEqual(t, amp.HTTPEvents.Endpoint, cfg.StoredRequests.HTTPEvents.AmpEndpoint)
 	assertStringsEqual(t, amp.CacheEvents.Endpoint, "/storedrequests/amp")
+	assertStringsEqual(t, amp.RedisCache.KeyPrefix, "pbs:amp:")
+	assertStringsEqual(t, amp.RedisCache.PubSubChannel, "events:amp")
 }
 
 func TestNewHTTPEvents(t *testing.T) {
@@ -159,7 +165,7 @@ func TestNewHTTPEvents(t *testing.T) {
 			Timeout:     1000,
 		},
 	}
-	evProducers := newEventProducers(cfg, server1.Client(), nil, nil)
+	evProducers := newEventProducers(cfg, server1.Client(), nil, nil, nil)
 	assertSliceLength(t, evProducers, 1)
 	assertHttpWithURL(t, evProducers[0], server1.URL)
 }
@@ -223,10 +229,10 @@ func TestNewPostgresEventProducers(t *testing.T) {
 	mock.ExpectQuery("^" + regexp.QuoteMeta(cfg.Postgres.CacheInitialization.Query) + "$").WillReturnError(errors.New("Query failed"))
 	mock.ExpectQuery("^" + regexp.QuoteMeta(ampCfg.Postgres.CacheInitialization.Query) + "$").WillReturnError(errors.New("Query failed"))
 
-	evProducers := newEventProducers(cfg, client, db, nil)
+	evProducers := newEventProducers(cfg, client, db, nil, nil)
 	assertProducerLength(t, evProducers, 2)
 
-	ampEvProducers := newEventProducers(ampCfg, client, db, nil)
+	ampEvProducers := newEventProducers(ampCfg, client, db, nil, nil)
 	assertProducerLength(t, ampEvProducers, 2)
 
 	assertExpectationsMet(t, mock)
@@ -0,0 +1,170 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/golang/glog"
+	redisCache "github.com/prebid/prebid-server/stored_requests/caches/redis"
+	"github.com/prebid/prebid-server/stored_requests/events"
+)
+
+// resubscribeDelay is how long to wait before subscribing again after the connection to the server fails.
+const resubscribeDelay = 5 * time.Second
+
+// PubSub propagates cache events between Prebid Server instances through a channel on a server
+// which speaks the Redis protocol.
+//
+// The PubSub is itself an EventProducer, which produces the events published by the other instances.
+// Those events should only be applied to the local caches, since shared caches were already updated
+// by the instance which published them.
+//
+// Use Propagate to publish the events of a local EventProducer to every other instance.
+type PubSub struct {
+	client        *redisCache.Client
+	channel       string
+	origin        string
+	saves         chan events.Save
+	invalidations chan events.Invalidation
+	done          <-chan struct{}
+	stop          func()
+}
+
+// message is the format of the messages published on the channel.
+type message struct {
+	// Origin identifies the instance which published the message, so that it can ignore its own messages.
+	Origin       string               `json:"origin"`
+	Save         *events.Save         `json:"save,omitempty"`
+	Invalidation *events.Invalidation `json:"invalidation,omitempty"`
+}
+
+// NewPubSub subscribes to the channel, and returns a PubSub which produces the events published there by other instances.
+func NewPubSub(client *redisCache.Client, channel string) *PubSub {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &PubSub{
+		client:        client,
+		channel:       channel,
+		origin:        uuid.Must(uuid.NewV4()).String(),
+		saves:         make(chan events.Save, 1),
+		invalidations: make(chan events.Invalidation, 1),
+		done:          ctx.Done(),
+		stop:          cancel,
+	}
+	glog.Infof("Propagating Stored Request cache events through channel %s", channel)
+
+	go p.subscribe(ctx)
+	return p
+}
+
+// Stop unsubscribes from the channel, and stops propagating events.
+func (p *PubSub) Stop() {
+	p.stop()
+}
+
+func (p *PubSub) Saves() <-chan events.Save {
+	return p.saves
+}
+
+func (p *PubSub) Invalidations() <-chan events.Invalidation {
+	return p.invalidations
+}
+
+// Propagate returns an EventProducer which produces the same events as the given one,
+// after publishing them to every other instance. The events stop when the PubSub is stopped.
+func (p *PubSub) Propagate(producer events.EventProducer) events.EventProducer {
+	propagated := &propagatedEvents{
+		saves:         make(chan events.Save),
+		invalidations: make(chan events.Invalidation),
+	}
+
+	go func() {
+		for {
+			select {
+			case save := <-producer.Saves():
+				p.publish(message{Origin: p.origin, Save: &save})
+				select {
+				case propagated.saves <- save:
+				case <-p.done:
+					return
+				}
+			case invalidation := <-producer.Invalidations():
+				p.publish(message{Origin: p.origin, Invalidation: &invalidation})
+				select {
+				case propagated.invalidations <- invalidation:
+				case <-p.done:
+					return
+				}
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return propagated
+}
+
+func (p *PubSub) publish(msg message) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		glog.Errorf("Failed to marshal a Stored Request cache event: %v", err)
+		return
+	}
+	if _, err := p.client.Do(context.Background(), "PUBLISH", p.channel, string(payload)); err != nil {
+		glog.Errorf("Failed to publish a Stored Request cache event to channel %s: %v", p.channel, err)
+	}
+}
+
+func (p *PubSub) subscribe(ctx context.Context) {
+	for {
+		err := p.client.Subscribe(ctx, p.channel, p.handleMessage)
+		if ctx.Err() != nil {
+			return
+		}
+		glog.Errorf("Lost the subscription to channel %s. Retrying in %v: %v", p.channel, resubscribeDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+func (p *PubSub) handleMessage(payload []byte) {
+	var msg message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		glog.Errorf("Received an invalid Stored Request cache event on channel %s: %v", p.channel, err)
+		return
+	}
+	if msg.Origin == p.origin {
+		return
+	}
+	// Once the PubSub is stopped, nothing reads the events anymore.
+	if msg.Save != nil {
+		select {
+		case p.saves <- *msg.Save:
+		case <-p.done:
+		}
+	}
+	if msg.Invalidation != nil {
+		select {
+		case p.invalidations <- *msg.Invalidation:
+		case <-p.done:
+		}
+	}
+}
+
+type propagatedEvents struct {
+	saves         chan events.Save
+	invalidations chan events.Invalidation
+}
+
+func (e *propagatedEvents) Saves() <-chan events.Save {
+	return e.saves
+}
+
+func (e *propagatedEvents) Invalidations() <-chan events.Invalidation {
+	return e.invalidations
+}
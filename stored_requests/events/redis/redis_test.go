@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	redisCache "github.com/prebid/prebid-server/stored_requests/caches/redis"
+	"github.com/prebid/prebid-server/stored_requests/caches/redis/redistest"
+	"github.com/prebid/prebid-server/stored_requests/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagation(t *testing.T) {
+	server, err := redistest.NewServer("")
+	if err != nil {
+		t.Fatalf("Failed to start the test server: %v", err)
+	}
+	defer server.Close()
+
+	cfg := &config.RedisCache{Address: server.Address(), Timeout: 1000, PoolSize: 1}
+	first := NewPubSub(redisCache.NewClient(cfg), "events")
+	defer first.Stop()
+	second := NewPubSub(redisCache.NewClient(cfg), "events")
+	defer second.Stop()
+	waitForSubscribers(t, server, "events", 2)
+
+	local := &dummyProducer{
+		saves:         make(chan events.Save),
+		invalidations: make(chan events.Invalidation),
+	}
+	propagated := first.Propagate(local)
+
+	save := events.Save{Requests: map[string]json.RawMessage{"req": json.RawMessage(`{"req":true}`)}}
+	go func() { local.saves <- save }()
+	assert.Equal(t, save, <-propagated.Saves(), "Local events should still be produced locally")
+	assert.Equal(t, save, <-second.Saves(), "Local events should be produced by the other instances")
+
+	invalidation := events.Invalidation{Imps: []string{"imp"}}
+	go func() { local.invalidations <- invalidation }()
+	assert.Equal(t, invalidation, <-propagated.Invalidations(), "Local events should still be produced locally")
+	assert.Equal(t, invalidation, <-second.Invalidations(), "Local events should be produced by the other instances")
+
+	select {
+	case <-first.Saves():
+		t.Error("An instance should ignore the events it published itself")
+	case <-first.Invalidations():
+		t.Error("An instance should ignore the events it published itself")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestInvalidMessage(t *testing.T) {
+	pubSub := &PubSub{
+		origin:        "me",
+		saves:         make(chan events.Save, 1),
+		invalidations: make(chan events.Invalidation, 1),
+	}
+
+	pubSub.handleMessage([]byte(`not json`))
+	pubSub.handleMessage([]byte(`{"origin":"me","save":{"requests":{"req":{}}}}`))
+	assert.Len(t, pubSub.saves, 0, "Invalid and self-published messages should be ignored")
+
+	pubSub.handleMessage([]byte(`{"origin":"other","save":{"requests":{"req":{}}}}`))
+	assert.Len(t, pubSub.saves, 1, "Messages from other instances should produce events")
+}
+
+func TestStoppedPubSub(t *testing.T) {
+	done := make(chan struct{})
+	close(done)
+	pubSub := &PubSub{
+		origin:        "me",
+		saves:         make(chan events.Save),
+		invalidations: make(chan events.Invalidation),
+		done:          done,
+	}
+
+	handled := make(chan struct{})
+	go func() {
+		pubSub.handleMessage([]byte(`{"origin":"other","save":{"requests":{"req":{}}},"invalidation":{"imps":["imp"]}}`))
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Error("A stopped PubSub shouldn't wait for someone to read its events")
+	}
+}
+
+func waitForSubscribers(t *testing.T, server *redistest.Server, channel string, count int) {
+	deadline := time.Now().Add(time.Second)
+	for server.Subscribers(channel) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d subscribers", count)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type dummyProducer struct {
+	saves         chan events.Save
+	invalidations chan events.Invalidation
+}
+
+func (p *dummyProducer) Saves() <-chan events.Save {
+	return p.saves
+}
+
+func (p *dummyProducer) Invalidations() <-chan events.Invalidation {
+	return p.invalidations
+}
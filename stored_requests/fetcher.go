@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/golang/glog"
 	"github.com/prebid/prebid-server/pbsmetrics"
 )
 
@@ -25,6 +26,13 @@ type Fetcher interface {
 	FetchRequests(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage, errs []error)
 }
 
+// Lister is implemented by Fetchers which know every Stored Request and Stored Imp ID in their backend.
+// It is used to validate the whole catalog of Stored Requests, so it doesn't need to be fast.
+type Lister interface {
+	// ListIDs returns the IDs of all the Stored Requests and Stored Imps which this Fetcher can find.
+	ListIDs(ctx context.Context) (requestIDs []string, impIDs []string)
+}
+
 type CategoryFetcher interface {
 	// FetchCategories fetches the ad-server/publisher specific category for the given IAB category
 	FetchCategories(ctx context.Context, primaryAdServer, publisherId, iabCategory string) (string, error)
@@ -81,7 +89,8 @@ type Cache interface {
 type ComposedCache []Cache
 
 // Get will attempt to Get from the caches in the order in which they are in the slice,
-// stopping as soon as a value is found (or when all caches have been exhausted)
+// stopping as soon as a value is found (or when all caches have been exhausted).
+// Values found in a later cache are saved to the caches before it, so that they warm up.
 func (c ComposedCache) Get(ctx context.Context, requestIDs []string, impIDs []string) (requestData map[string]json.RawMessage, impData map[string]json.RawMessage) {
 	requestData = make(map[string]json.RawMessage, len(requestIDs))
 	impData = make(map[string]json.RawMessage, len(impIDs))
@@ -89,12 +98,18 @@ func (c ComposedCache) Get(ctx context.Context, requestIDs []string, impIDs []st
 	remainingReqIDs := requestIDs
 	remainingImpIDs := impIDs
 
-	for _, cache := range c {
+	for i, cache := range c {
 		cachedReqData, cachedImpData := cache.Get(ctx, remainingReqIDs, remainingImpIDs)
 
 		requestData, remainingReqIDs = updateFromCache(requestData, remainingReqIDs, cachedReqData)
 		impData, remainingImpIDs = updateFromCache(impData, remainingImpIDs, cachedImpData)
 
+		if len(cachedReqData) > 0 || len(cachedImpData) > 0 {
+			for _, missedCache := range c[:i] {
+				missedCache.Save(ctx, cachedReqData, cachedImpData)
+			}
+		}
+
 		// return if all ids filled
 		if len(remainingReqIDs) == 0 && len(remainingImpIDs) == 0 {
 			return
@@ -181,6 +196,15 @@ func (f *fetcherWithCache) FetchRequests(ctx context.Context, requestIDs []strin
 	return
 }
 
+// ListIDs implements the Lister interface, if the underlying Fetcher does.
+func (f *fetcherWithCache) ListIDs(ctx context.Context) (requestIDs []string, impIDs []string) {
+	if lister, ok := f.fetcher.(Lister); ok {
+		return lister.ListIDs(ctx)
+	}
+	glog.Warningf("The Stored Request fetcher %T can't list its IDs, so they won't be validated.", f.fetcher)
+	return nil, nil
+}
+
 func (f *fetcherWithCache) FetchCategories(ctx context.Context, primaryAdServer, publisherId, iabCategory string) (string, error) {
 	return "", nil
 }
@@ -183,6 +183,15 @@ func TestComposedCache(t *testing.T) {
 		map[string]json.RawMessage{
 			"3": json.RawMessage(`{"id": "3"}`),
 		})
+	c1.On("Save", ctx,
+		map[string]json.RawMessage{"2": json.RawMessage(`{"id": "2"}`)},
+		map[string]json.RawMessage{"2": json.RawMessage(`{"id": "2"}`)})
+	c1.On("Save", ctx,
+		map[string]json.RawMessage{"3": json.RawMessage(`{"id": "3"}`)},
+		map[string]json.RawMessage{"3": json.RawMessage(`{"id": "3"}`)})
+	c2.On("Save", ctx,
+		map[string]json.RawMessage{"3": json.RawMessage(`{"id": "3"}`)},
+		map[string]json.RawMessage{"3": json.RawMessage(`{"id": "3"}`)})
 	metricsEngine.On("RecordStoredReqCacheResult", pbsmetrics.CacheHit, 3)
 	metricsEngine.On("RecordStoredReqCacheResult", pbsmetrics.CacheMiss, 0)
 	metricsEngine.On("RecordStoredImpCacheResult", pbsmetrics.CacheHit, 3)
@@ -193,6 +202,7 @@ func TestComposedCache(t *testing.T) {
 	c1.AssertExpectations(t)
 	c2.AssertExpectations(t)
 	c3.AssertExpectations(t)
+	c4.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
 	fetcher.AssertExpectations(t)
 	metricsEngine.AssertExpectations(t)
 	assert.Len(t, reqData, len(reqIDs), "FetchRequests should be able to return all request data from a composed cache")
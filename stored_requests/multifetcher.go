@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+
+	"github.com/golang/glog"
 )
 
 // MultiFetcher is a Fetcher composed of multiple sub-Fetchers that are all polled for results.
@@ -36,6 +38,33 @@ func (mf MultiFetcher) FetchRequests(ctx context.Context, requestIDs []string, i
 	return
 }
 
+// ListIDs implements the Lister interface for MultiFetcher. It returns the IDs known to every sub-Fetcher which is a Lister,
+// and logs the ones which aren't.
+func (mf MultiFetcher) ListIDs(ctx context.Context) (requestIDs []string, impIDs []string) {
+	seenRequests := make(map[string]struct{})
+	seenImps := make(map[string]struct{})
+	for _, f := range mf {
+		if lister, ok := f.(Lister); ok {
+			theseRequestIDs, theseImpIDs := lister.ListIDs(ctx)
+			requestIDs = appendUnique(requestIDs, theseRequestIDs, seenRequests)
+			impIDs = appendUnique(impIDs, theseImpIDs, seenImps)
+		} else {
+			glog.Warningf("The Stored Request fetcher %T can't list its IDs, so they won't be validated.", f)
+		}
+	}
+	return
+}
+
+func appendUnique(ids []string, newIDs []string, seen map[string]struct{}) []string {
+	for _, id := range newIDs {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 func (mf MultiFetcher) FetchCategories(ctx context.Context, primaryAdServer, publisherId, iabCategory string) (string, error) {
 	for _, f := range mf {
 		if cf, ok := f.(CategoryFetcher); ok {
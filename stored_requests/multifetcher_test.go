@@ -125,3 +125,30 @@ func TestOtherError(t *testing.T) {
 	assert.JSONEq(t, `{"req_id": "def"}`, string(reqData["def"]), "MultiFetcher should return the right request data")
 	assert.JSONEq(t, `{"imp_id": "imp-1"}`, string(impData["imp-1"]), "MultiFetcher should return the right imp data")
 }
+
+func TestMultiFetcherListIDs(t *testing.T) {
+	lister := &mockListingFetcher{
+		requestIDs: []string{"abc", "def"},
+		impIDs:     []string{"imp-1"},
+	}
+	otherLister := &mockListingFetcher{
+		requestIDs: []string{"def", "ghi"},
+		impIDs:     []string{"imp-2"},
+	}
+	fetcher := MultiFetcher{lister, &mockFetcher{}, otherLister}
+
+	requestIDs, impIDs := fetcher.ListIDs(context.Background())
+
+	assert.Equal(t, []string{"abc", "def", "ghi"}, requestIDs, "MultiFetcher should list every unique request ID")
+	assert.Equal(t, []string{"imp-1", "imp-2"}, impIDs, "MultiFetcher should list every unique imp ID")
+}
+
+type mockListingFetcher struct {
+	mockFetcher
+	requestIDs []string
+	impIDs     []string
+}
+
+func (f *mockListingFetcher) ListIDs(ctx context.Context) ([]string, []string) {
+	return f.requestIDs, f.impIDs
+}
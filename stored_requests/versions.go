@@ -0,0 +1,125 @@
+package stored_requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/buger/jsonparser"
+)
+
+// VersionedData is the format used by Stored Requests and Stored Imps which carry several versions
+// of their data, so that a change can be rolled out to a fraction of the traffic first.
+//
+// Stored data which doesn't have a top-level "versions" array is unversioned, and is used as-is.
+// To roll a change forward or back, save a new VersionedData with different percentages (or a plain,
+// unversioned blob) through any of the usual events.Save or events.Invalidation channels.
+type VersionedData struct {
+	Versions []Version `json:"versions"`
+}
+
+// Version is a single version of some Stored Request or Stored Imp data.
+type Version struct {
+	// ID identifies the version. It is echoed back at ext.prebid.storedrequest.version.
+	ID string `json:"id"`
+	// Percent is the share of the traffic which should use this version.
+	// The percentages of all the versions of an entry must add up to 100.
+	Percent int `json:"percent"`
+	// Data is the Stored Request or Stored Imp data for this version.
+	Data json.RawMessage `json:"data"`
+}
+
+// IsVersioned returns true if the stored data uses the VersionedData format.
+func IsVersioned(data json.RawMessage) bool {
+	_, dataType, _, err := jsonparser.Get(data, "versions")
+	return err == nil && dataType == jsonparser.Array
+}
+
+// ResolveVersion picks the version of the stored data which should be used for a given request.
+//
+// The id is the Stored Request or Stored Imp ID which the data was fetched for. The choice is
+// deterministic on the id and seed (usually the request.id, or the user's ID or IP address), so that
+// retries of the same request see the same version. If the seed is empty, the choice only depends on the id.
+//
+// If the data is unversioned, it will be returned unchanged along with an empty version ID.
+func ResolveVersion(id string, data json.RawMessage, seed string) (json.RawMessage, string, error) {
+	if !IsVersioned(data) {
+		return data, "", nil
+	}
+
+	versions, err := AllVersions(id, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bucket := versionBucket(id, seed)
+	total := 0
+	for _, version := range versions {
+		total += version.Percent
+		if bucket < total {
+			return version.Data, version.ID, nil
+		}
+	}
+
+	// Unreachable, since validate() guarantees that the percentages add up to 100.
+	last := versions[len(versions)-1]
+	return last.Data, last.ID, nil
+}
+
+// AllVersions returns every version of the stored data, after making sure that they're well-formed.
+//
+// If the data is unversioned, it will be returned as a single Version with an empty ID which gets all the traffic.
+func AllVersions(id string, data json.RawMessage) ([]Version, error) {
+	if !IsVersioned(data) {
+		return []Version{{Percent: 100, Data: data}}, nil
+	}
+
+	var versioned VersionedData
+	if err := json.Unmarshal(data, &versioned); err != nil {
+		return nil, fmt.Errorf("Stored data with ID=\"%s\" has invalid versions: %v", id, err)
+	}
+	if err := versioned.validate(); err != nil {
+		return nil, fmt.Errorf("Stored data with ID=\"%s\" has invalid versions: %v", id, err)
+	}
+	return versioned.Versions, nil
+}
+
+func (v *VersionedData) validate() error {
+	if len(v.Versions) == 0 {
+		return fmt.Errorf("at least one version is required")
+	}
+
+	seen := make(map[string]struct{}, len(v.Versions))
+	total := 0
+	for i, version := range v.Versions {
+		if version.ID == "" {
+			return fmt.Errorf("versions[%d] missing required field: \"id\"", i)
+		}
+		if _, ok := seen[version.ID]; ok {
+			return fmt.Errorf("versions[%d].id \"%s\" is used more than once", i, version.ID)
+		}
+		seen[version.ID] = struct{}{}
+
+		if version.Percent < 0 || version.Percent > 100 {
+			return fmt.Errorf("versions[%d].percent must be between 0 and 100. Got %d", i, version.Percent)
+		}
+		if len(version.Data) == 0 {
+			return fmt.Errorf("versions[%d] missing required field: \"data\"", i)
+		}
+		total += version.Percent
+	}
+
+	if total != 100 {
+		return fmt.Errorf("version percentages must add up to 100. Got %d", total)
+	}
+	return nil
+}
+
+// versionBucket maps the id and seed onto a number in [0, 100).
+func versionBucket(id string, seed string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	hash.Write([]byte{0})
+	hash.Write([]byte(seed))
+	return int(hash.Sum32() % 100)
+}
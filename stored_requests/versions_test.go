@@ -0,0 +1,96 @@
+package stored_requests
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveVersionUnversioned(t *testing.T) {
+	data := json.RawMessage(`{"tmax":500}`)
+	resolved, version, err := ResolveVersion("req-id", data, "request-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "", version, "Unversioned data should not have a version")
+	assert.JSONEq(t, `{"tmax":500}`, string(resolved), "Unversioned data should be returned as-is")
+}
+
+func TestResolveVersionSingleVersion(t *testing.T) {
+	data := json.RawMessage(`{"versions":[{"id":"v1","percent":0,"data":{"tmax":1}},{"id":"v2","percent":100,"data":{"tmax":2}}]}`)
+	resolved, version, err := ResolveVersion("req-id", data, "request-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", version)
+	assert.JSONEq(t, `{"tmax":2}`, string(resolved))
+}
+
+func TestResolveVersionDeterministic(t *testing.T) {
+	data := json.RawMessage(`{"versions":[{"id":"v1","percent":50,"data":{"tmax":1}},{"id":"v2","percent":50,"data":{"tmax":2}}]}`)
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		seed := "request-" + strconv.Itoa(i)
+		_, first, err := ResolveVersion("req-id", data, seed)
+		assert.NoError(t, err)
+		_, second, _ := ResolveVersion("req-id", data, seed)
+		assert.Equal(t, first, second, "The same request ID should always get the same version")
+		counts[first]++
+	}
+
+	assert.InDelta(t, 500, counts["v1"], 100, "Traffic should be split roughly evenly")
+	assert.InDelta(t, 500, counts["v2"], 100, "Traffic should be split roughly evenly")
+}
+
+func TestResolveVersionWithoutSeed(t *testing.T) {
+	data := json.RawMessage(`{"versions":[{"id":"v1","percent":50,"data":{"tmax":1}},{"id":"v2","percent":50,"data":{"tmax":2}}]}`)
+
+	_, first, err := ResolveVersion("req-id", data, "")
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, version, _ := ResolveVersion("req-id", data, "")
+		assert.Equal(t, first, version, "Requests without a seed should always get the same version")
+	}
+}
+
+func TestResolveVersionInvalid(t *testing.T) {
+	testCases := []struct {
+		description string
+		data        string
+	}{
+		{
+			description: "No versions",
+			data:        `{"versions":[]}`,
+		},
+		{
+			description: "Percentages don't add up to 100",
+			data:        `{"versions":[{"id":"v1","percent":50,"data":{}},{"id":"v2","percent":40,"data":{}}]}`,
+		},
+		{
+			description: "Negative percentage",
+			data:        `{"versions":[{"id":"v1","percent":110,"data":{}},{"id":"v2","percent":-10,"data":{}}]}`,
+		},
+		{
+			description: "Missing ID",
+			data:        `{"versions":[{"percent":100,"data":{}}]}`,
+		},
+		{
+			description: "Duplicate ID",
+			data:        `{"versions":[{"id":"v1","percent":50,"data":{}},{"id":"v1","percent":50,"data":{}}]}`,
+		},
+		{
+			description: "Missing data",
+			data:        `{"versions":[{"id":"v1","percent":100}]}`,
+		},
+		{
+			description: "Malformed version",
+			data:        `{"versions":[{"id":1,"percent":100,"data":{}}]}`,
+		},
+	}
+
+	for _, test := range testCases {
+		_, _, err := ResolveVersion("req-id", json.RawMessage(test.data), "request-1")
+		assert.Error(t, err, test.description)
+	}
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// AuctionTrace records the steps of an auction in the order in which they happen, so that they can be returned
+// in response.ext.trace to callers which ask for them with request.ext.prebid.trace.
+//
+// The level of the trace can't be known until the Stored Requests have been merged into the request, so steps
+// are recorded before SetLevel is called. SetLevel then drops the steps which are too detailed for the level,
+// and the later ones aren't recorded at all.
+//
+// Steps can be recorded from several goroutines. A nil AuctionTrace records nothing.
+type AuctionTrace struct {
+	mu       sync.Mutex
+	level    openrtb_ext.TraceLevel
+	levelSet bool
+	events   []auctionTraceEvent
+}
+
+type auctionTraceEvent struct {
+	level openrtb_ext.TraceLevel
+	event openrtb_ext.ExtTraceEvent
+}
+
+type auctionTraceKey struct{}
+
+// StartAuctionTrace returns a context which records the steps of the auction to the returned AuctionTrace.
+func StartAuctionTrace(ctx context.Context) (context.Context, *AuctionTrace) {
+	trace := &AuctionTrace{}
+	return context.WithValue(ctx, auctionTraceKey{}, trace), trace
+}
+
+// AuctionTraceFromContext returns the AuctionTrace of the context, or nil if the auction isn't being traced.
+func AuctionTraceFromContext(ctx context.Context) *AuctionTrace {
+	trace, _ := ctx.Value(auctionTraceKey{}).(*AuctionTrace)
+	return trace
+}
+
+// Basic records a step which is returned in basic and verbose traces.
+// The bidder should be empty if the step concerns the whole request.
+func (t *AuctionTrace) Basic(stage string, bidder openrtb_ext.BidderName, format string, args ...interface{}) {
+	t.add(openrtb_ext.TraceBasic, stage, bidder, format, args)
+}
+
+// Verbose records a step which is only returned in verbose traces.
+// The bidder should be empty if the step concerns the whole request.
+func (t *AuctionTrace) Verbose(stage string, bidder openrtb_ext.BidderName, format string, args ...interface{}) {
+	t.add(openrtb_ext.TraceVerbose, stage, bidder, format, args)
+}
+
+func (t *AuctionTrace) add(level openrtb_ext.TraceLevel, stage string, bidder openrtb_ext.BidderName, format string, args []interface{}) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.levelSet && !t.level.Includes(level) {
+		return
+	}
+	t.events = append(t.events, auctionTraceEvent{
+		level: level,
+		event: openrtb_ext.ExtTraceEvent{
+			Stage:   stage,
+			Bidder:  string(bidder),
+			Message: fmt.Sprintf(format, args...),
+		},
+	})
+}
+
+// SetLevel sets the level of the trace, and drops the steps which were recorded above it.
+func (t *AuctionTrace) SetLevel(level openrtb_ext.TraceLevel) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.level = level
+	t.levelSet = true
+	kept := t.events[:0]
+	for _, event := range t.events {
+		if level.Includes(event.level) {
+			kept = append(kept, event)
+		}
+	}
+	t.events = kept
+}
+
+// Events returns the steps recorded so far, or nil if SetLevel hasn't been called with a level which returns a trace.
+func (t *AuctionTrace) Events() []openrtb_ext.ExtTraceEvent {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.levelSet || t.level == openrtb_ext.TraceNone || len(t.events) == 0 {
+		return nil
+	}
+	events := make([]openrtb_ext.ExtTraceEvent, 0, len(t.events))
+	for _, event := range t.events {
+		events = append(events, event.event)
+	}
+	return events
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuctionTraceLevels(t *testing.T) {
+	testCases := []struct {
+		description    string
+		level          openrtb_ext.TraceLevel
+		expectedEvents []openrtb_ext.ExtTraceEvent
+	}{
+		{
+			description: "Verbose traces should return every step",
+			level:       openrtb_ext.TraceVerbose,
+			expectedEvents: []openrtb_ext.ExtTraceEvent{
+				{Stage: "stored_request", Message: "merged stored request req-1"},
+				{Stage: "stored_request", Message: "merged stored imp imp-1 into imp[0]"},
+				{Stage: "privacy", Bidder: "appnexus", Message: "enforced GDPR"},
+				{Stage: "currency_conversion", Bidder: "appnexus", Message: "bid bid-1: converted 1.00 EUR to 1.20 USD"},
+			},
+		},
+		{
+			description: "Basic traces should only return the basic steps",
+			level:       openrtb_ext.TraceBasic,
+			expectedEvents: []openrtb_ext.ExtTraceEvent{
+				{Stage: "stored_request", Message: "merged stored request req-1"},
+				{Stage: "privacy", Bidder: "appnexus", Message: "enforced GDPR"},
+			},
+		},
+		{
+			description: "Traces without a level should return nothing",
+			level:       openrtb_ext.TraceNone,
+		},
+	}
+
+	for _, test := range testCases {
+		ctx, trace := StartAuctionTrace(context.Background())
+		trace.Basic("stored_request", "", "merged stored request %s", "req-1")
+		trace.Verbose("stored_request", "", "merged stored imp %s into imp[%d]", "imp-1", 0)
+		assert.Nil(t, trace.Events(), "%s: steps shouldn't be returned before the level is set", test.description)
+
+		trace.SetLevel(test.level)
+		AuctionTraceFromContext(Detach(ctx)).Basic("privacy", "appnexus", "enforced GDPR")
+		trace.Verbose("currency_conversion", "appnexus", "bid %s: converted %.2f %s to %.2f %s", "bid-1", 1.0, "EUR", 1.2, "USD")
+
+		assert.Equal(t, test.expectedEvents, trace.Events(), test.description)
+	}
+}
+
+func TestNilAuctionTrace(t *testing.T) {
+	trace := AuctionTraceFromContext(context.Background())
+	assert.Nil(t, trace)
+
+	trace.Basic("cache", "", "cached %d bids", 2)
+	trace.SetLevel(openrtb_ext.TraceVerbose)
+	assert.Nil(t, trace.Events())
+}
@@ -0,0 +1,209 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// Exporter sends finished spans somewhere they can be inspected.
+type Exporter interface {
+	Export(spans []SpanData) error
+}
+
+// NewExporter returns the Exporter described by the config.
+func NewExporter(cfg *config.Tracing, client *http.Client) (Exporter, error) {
+	switch cfg.Exporter {
+	case "stdout":
+		return NewWriterExporter(os.Stdout), nil
+	case "file":
+		file, err := os.OpenFile(cfg.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewWriterExporter(file), nil
+	case "otlp":
+		return NewOTLPExporter(client, cfg.OTLPEndpoint, cfg.ServiceName, time.Duration(cfg.Timeout)*time.Millisecond), nil
+	default:
+		return nil, fmt.Errorf("Unknown tracing exporter: %s", cfg.Exporter)
+	}
+}
+
+// NewWriterExporter returns an Exporter which writes each span to w as a line of JSON.
+func NewWriterExporter(w io.Writer) Exporter {
+	return &writerExporter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+type writerExporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+func (e *writerExporter) Export(spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, span := range spans {
+		if err := e.encoder.Encode(span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewOTLPExporter returns an Exporter which sends spans to an OpenTelemetry collector,
+// using the JSON encoding of OTLP over HTTP.
+func NewOTLPExporter(client *http.Client, endpoint string, serviceName string, timeout time.Duration) Exporter {
+	return &otlpExporter{
+		client:      client,
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		timeout:     timeout,
+	}
+}
+
+type otlpExporter struct {
+	client      *http.Client
+	endpoint    string
+	serviceName string
+	timeout     time.Duration
+}
+
+func (e *otlpExporter) Export(spans []SpanData) error {
+	body, err := json.Marshal(e.makeRequest(spans))
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest("POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	httpResp, err := ctxhttp.Do(ctx, e.client, httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	ioutil.ReadAll(httpResp.Body)
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return fmt.Errorf("Collector at %s responded with status %d", e.endpoint, httpResp.StatusCode)
+	}
+	return nil
+}
+
+func (e *otlpExporter) makeRequest(spans []SpanData) otlpRequest {
+	otlpSpans := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		otlpSpans[i] = otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        makeAttributes(span.Attributes),
+		}
+		if span.Error != "" {
+			otlpSpans[i].Status = &otlpStatus{Code: otlpStatusCodeError, Message: span.Error}
+		}
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: makeAttributes(map[string]string{"service.name": e.serviceName}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/prebid/prebid-server/tracing"},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+func makeAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	otlpAttributes := make([]otlpAttribute, len(keys))
+	for i, key := range keys {
+		otlpAttributes[i] = otlpAttribute{Key: key, Value: otlpValue{StringValue: attributes[key]}}
+	}
+	return otlpAttributes
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusCodeError  = 2
+)
+
+// The types below are the subset of the OTLP JSON encoding which Prebid Server uses.
+// See https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
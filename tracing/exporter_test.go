@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSpan = SpanData{
+	TraceID:      "0af7651916cd43dd8448eb211c80319c",
+	SpanID:       "00f067aa0ba902b7",
+	ParentSpanID: "b7ad6b7169203331",
+	Name:         "http_request",
+	Start:        time.Unix(1600000000, 0),
+	End:          time.Unix(1600000000, 5000000),
+	Attributes:   map[string]string{"http.method": "POST", "bidder": "appnexus"},
+	Error:        "timeout",
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := NewWriterExporter(&buf)
+	assert.NoError(t, exporter.Export([]SpanData{testSpan, testSpan}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 2, "Each span should be written on its own line") {
+		var decoded SpanData
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &decoded))
+		assert.Equal(t, testSpan.TraceID, decoded.TraceID)
+		assert.Equal(t, testSpan.Attributes, decoded.Attributes)
+		assert.True(t, testSpan.End.Equal(decoded.End))
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var received []byte
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.Client(), server.URL, "prebid-server", time.Second)
+	assert.NoError(t, exporter.Export([]SpanData{testSpan}))
+
+	assert.Equal(t, "application/json", contentType)
+	assert.JSONEq(t, `{
+		"resourceSpans": [{
+			"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "prebid-server"}}]},
+			"scopeSpans": [{
+				"scope": {"name": "github.com/prebid/prebid-server/tracing"},
+				"spans": [{
+					"traceId": "0af7651916cd43dd8448eb211c80319c",
+					"spanId": "00f067aa0ba902b7",
+					"parentSpanId": "b7ad6b7169203331",
+					"name": "http_request",
+					"kind": 1,
+					"startTimeUnixNano": "1600000000000000000",
+					"endTimeUnixNano": "1600000000005000000",
+					"attributes": [
+						{"key": "bidder", "value": {"stringValue": "appnexus"}},
+						{"key": "http.method", "value": {"stringValue": "POST"}}
+					],
+					"status": {"code": 2, "message": "timeout"}
+				}]
+			}]
+		}]
+	}`, string(received))
+}
+
+func TestOTLPExporterBadStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPExporter(server.Client(), server.URL, "prebid-server", time.Second)
+	assert.Error(t, exporter.Export([]SpanData{testSpan}))
+}
+
+func TestNewExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatalf("Failed to create a temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "spans.json")
+	exporter, err := NewExporter(&config.Tracing{Exporter: "file", FilePath: path}, http.DefaultClient)
+	if assert.NoError(t, err) {
+		assert.NoError(t, exporter.Export([]SpanData{testSpan}))
+		written, _ := ioutil.ReadFile(path)
+		assert.Contains(t, string(written), testSpan.TraceID)
+	}
+
+	_, err = NewExporter(&config.Tracing{Exporter: "jaeger"}, http.DefaultClient)
+	assert.Error(t, err)
+}
@@ -0,0 +1,339 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prebid/prebid-server/config"
+)
+
+// TraceparentHeader is the W3C Trace Context header which carries the trace and parent span IDs between services.
+// See https://www.w3.org/TR/trace-context/
+const TraceparentHeader = "traceparent"
+
+// Tracer starts the root span of each traced request, and exports every span once it has ended.
+//
+// Spans are exported in batches, in the background, so that tracing never delays an auction.
+// If the exporter falls behind, new spans are dropped.
+type Tracer struct {
+	sampleRate    float64
+	exporter      Exporter
+	batchSize     int
+	flushInterval time.Duration
+
+	spans chan SpanData
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewTracer returns a Tracer which exports its spans through the exporter.
+func NewTracer(cfg *config.Tracing, exporter Exporter) *Tracer {
+	t := &Tracer{
+		sampleRate:    cfg.SampleRate,
+		exporter:      exporter,
+		batchSize:     cfg.BatchSize,
+		flushInterval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		spans:         make(chan SpanData, 2*cfg.BatchSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Shutdown exports the spans which have already ended, and stops the Tracer.
+func (t *Tracer) Shutdown() {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		close(t.stop)
+		<-t.done
+	})
+}
+
+// Handler wraps an endpoint so that each request to it is traced, starting with a root span with the given name.
+// If the caller sent a traceparent header, the root span continues that trace.
+//
+// A nil Tracer returns the handle unchanged.
+func (t *Tracer) Handler(name string, handle httprouter.Handle) httprouter.Handle {
+	if t == nil {
+		return handle
+	}
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		ctx, span := t.StartRootSpan(r.Context(), name, r.Header.Get(TraceparentHeader))
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		handle(w, r.WithContext(ctx), params)
+	}
+}
+
+// StartRootSpan starts the first span which this service records for a trace.
+// If traceparent is a valid W3C traceparent header, the span joins that trace, and follows its sampling decision.
+// Otherwise it starts a new trace, which is sampled according to the configured sample rate.
+func (t *Tracer) StartRootSpan(ctx context.Context, name string, traceparent string) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			SpanID: newID(8),
+			Name:   name,
+			Start:  time.Now(),
+		},
+	}
+	if traceID, parentID, sampled, ok := parseTraceparent(traceparent); ok {
+		span.data.TraceID = traceID
+		span.data.ParentSpanID = parentID
+		span.sampled = sampled
+	} else {
+		span.data.TraceID = newID(16)
+		span.sampled = t.sampleRate > 0 && mathrand.Float64() < t.sampleRate
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *Tracer) export(data SpanData) {
+	select {
+	case t.spans <- data:
+	default:
+		// The exporter can't keep up. Dropping spans is better than slowing down the auctions.
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			glog.Errorf("Failed to export %d spans: %v", len(batch), err)
+		}
+		batch = make([]SpanData, 0, t.batchSize)
+	}
+
+	for {
+		select {
+		case data := <-t.spans:
+			batch = append(batch, data)
+			if len(batch) >= t.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			for {
+				select {
+				case data := <-t.spans:
+					batch = append(batch, data)
+					if len(batch) >= t.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// SpanData is everything recorded about a span.
+type SpanData struct {
+	TraceID      string            `json:"traceId"`
+	SpanID       string            `json:"spanId"`
+	ParentSpanID string            `json:"parentSpanId,omitempty"`
+	Name         string            `json:"name"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Span records a single operation within a trace.
+//
+// All the methods on Span are safe to call on a nil Span, which is what StartSpan returns when the request
+// isn't being traced. This means that code which records spans doesn't need to check whether tracing is enabled.
+type Span struct {
+	tracer  *Tracer
+	sampled bool
+
+	mutex sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SetAttribute adds a key-value pair which describes the operation.
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]string)
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the operation as failed. Nil errors are ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End finishes the span. Calls after the first one have no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mutex.Unlock()
+
+	if s.sampled {
+		s.tracer.export(data)
+	}
+}
+
+// Data returns a copy of what the span has recorded so far.
+func (s *Span) Data() SpanData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data := s.data
+	if s.data.Attributes != nil {
+		data.Attributes = make(map[string]string, len(s.data.Attributes))
+		for key, value := range s.data.Attributes {
+			data.Attributes[key] = value
+		}
+	}
+	return data
+}
+
+// traceparent formats the span as a W3C traceparent header value.
+func (s *Span) traceparent() string {
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + s.data.TraceID + "-" + s.data.SpanID + "-" + flags
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the current span, or nil if the context isn't being traced.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// StartSpan starts a child of the current span. The returned context should be used for any work done within the span.
+//
+// If the context isn't being traced, it is returned unchanged along with a nil Span.
+func StartSpan(ctx context.Context, name string) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		tracer:  parent.tracer,
+		sampled: parent.sampled,
+		data: SpanData{
+			TraceID:      parent.data.TraceID,
+			SpanID:       newID(8),
+			ParentSpanID: parent.data.SpanID,
+			Name:         name,
+			Start:        time.Now(),
+		},
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Detach returns a background context which continues the trace of ctx, and records to its AuctionTrace.
+//
+// Auctions must keep running even if the client disconnects, so they can't use the request's context directly.
+func Detach(ctx context.Context) context.Context {
+	detached := context.Background()
+	if span := SpanFromContext(ctx); span != nil {
+		detached = context.WithValue(detached, spanKey{}, span)
+	}
+	if trace := AuctionTraceFromContext(ctx); trace != nil {
+		detached = context.WithValue(detached, auctionTraceKey{}, trace)
+	}
+	return detached
+}
+
+// Traceparent returns the W3C traceparent header value which continues the trace of ctx in another service,
+// or an empty string if the context isn't being traced.
+func Traceparent(ctx context.Context) string {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.traceparent()
+	}
+	return ""
+}
+
+// parseTraceparent parses a version 00 traceparent header value.
+func parseTraceparent(value string) (traceID string, parentID string, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return "", "", false, false
+	}
+	if !isHexID(parts[1], 16) || !isHexID(parts[2], 8) || len(parts[3]) != 2 {
+		return "", "", false, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return "", "", false, false
+	}
+	return parts[1], parts[2], flags[0]&0x01 == 0x01, true
+}
+
+// isHexID returns true if value is the lowercase hex encoding of a non-zero ID with the given number of bytes.
+func isHexID(value string, size int) bool {
+	if len(value) != 2*size || strings.ToLower(value) != value {
+		return false
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return false
+	}
+	for _, b := range decoded {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func newID(size int) string {
+	id := make([]byte, size)
+	if _, err := rand.Read(id); err != nil {
+		// crypto/rand should never fail, but the IDs don't need to be secure anyway.
+		mathrand.Read(id)
+	}
+	return fmt.Sprintf("%x", id)
+}
@@ -0,0 +1,257 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prebid/prebid-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpanTree(t *testing.T) {
+	exporter := &mockExporter{}
+	tracer := NewTracer(newTestConfig(1), exporter)
+
+	ctx, root := tracer.StartRootSpan(context.Background(), "root", "")
+	childCtx, child := StartSpan(ctx, "child")
+	_, grandchild := StartSpan(childCtx, "grandchild")
+	grandchild.SetAttribute("key", "value")
+	grandchild.SetError(errors.New("failed"))
+	grandchild.End()
+	child.End()
+	root.End()
+	tracer.Shutdown()
+
+	spans := exporter.spans()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+	assert.Equal(t, "grandchild", spans[0].Name)
+	assert.Equal(t, "child", spans[1].Name)
+	assert.Equal(t, "root", spans[2].Name)
+
+	assert.Len(t, spans[2].TraceID, 32)
+	assert.Len(t, spans[2].SpanID, 16)
+	assert.Empty(t, spans[2].ParentSpanID)
+	for _, span := range spans {
+		assert.Equal(t, spans[2].TraceID, span.TraceID, "All spans should belong to the same trace")
+		assert.False(t, span.End.Before(span.Start))
+	}
+	assert.Equal(t, spans[2].SpanID, spans[1].ParentSpanID)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, map[string]string{"key": "value"}, spans[0].Attributes)
+	assert.Equal(t, "failed", spans[0].Error)
+}
+
+func TestSpanEndTwice(t *testing.T) {
+	exporter := &mockExporter{}
+	tracer := NewTracer(newTestConfig(1), exporter)
+
+	_, span := tracer.StartRootSpan(context.Background(), "root", "")
+	span.End()
+	span.End()
+	tracer.Shutdown()
+
+	assert.Len(t, exporter.spans(), 1)
+}
+
+func TestUntracedContext(t *testing.T) {
+	ctx, span := StartSpan(context.Background(), "orphan")
+	assert.Nil(t, span)
+	assert.Equal(t, context.Background(), ctx)
+	assert.Empty(t, Traceparent(ctx))
+
+	// None of these should panic
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("failed"))
+	span.End()
+
+	var tracer *Tracer
+	tracer.Shutdown()
+}
+
+func TestSampling(t *testing.T) {
+	exporter := &mockExporter{}
+	tracer := NewTracer(newTestConfig(0), exporter)
+
+	ctx, root := tracer.StartRootSpan(context.Background(), "root", "")
+	_, child := StartSpan(ctx, "child")
+	child.End()
+	root.End()
+	tracer.Shutdown()
+
+	assert.Empty(t, exporter.spans(), "Unsampled traces shouldn't be exported")
+	assert.Regexp(t, "^00-[0-9a-f]{32}-[0-9a-f]{16}-00$", Traceparent(ctx), "Unsampled traces should still be propagated")
+}
+
+func TestTraceparent(t *testing.T) {
+	testCases := []struct {
+		description   string
+		incoming      string
+		expectTraceID string
+		expectParent  string
+		expectSampled bool
+	}{
+		{
+			description:   "Sampled parent",
+			incoming:      "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			expectTraceID: "0af7651916cd43dd8448eb211c80319c",
+			expectParent:  "b7ad6b7169203331",
+			expectSampled: true,
+		},
+		{
+			description:   "Unsampled parent",
+			incoming:      "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00",
+			expectTraceID: "0af7651916cd43dd8448eb211c80319c",
+			expectParent:  "b7ad6b7169203331",
+			expectSampled: false,
+		},
+		{
+			description:   "Unknown version",
+			incoming:      "01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			expectSampled: true,
+		},
+		{
+			description:   "All-zero trace ID",
+			incoming:      "00-00000000000000000000000000000000-b7ad6b7169203331-01",
+			expectSampled: true,
+		},
+		{
+			description:   "Uppercase IDs",
+			incoming:      "00-0AF7651916CD43DD8448EB211C80319C-B7AD6B7169203331-01",
+			expectSampled: true,
+		},
+		{
+			description:   "Garbage",
+			incoming:      "not-a-traceparent",
+			expectSampled: true,
+		},
+	}
+
+	tracer := NewTracer(newTestConfig(1), &mockExporter{})
+	defer tracer.Shutdown()
+
+	for _, test := range testCases {
+		ctx, span := tracer.StartRootSpan(context.Background(), "root", test.incoming)
+		data := span.Data()
+		if test.expectTraceID != "" {
+			assert.Equal(t, test.expectTraceID, data.TraceID, test.description)
+			assert.Equal(t, test.expectParent, data.ParentSpanID, test.description)
+		} else {
+			assert.NotEqual(t, "0af7651916cd43dd8448eb211c80319c", data.TraceID, test.description)
+			assert.Empty(t, data.ParentSpanID, test.description)
+		}
+
+		flags := "-00"
+		if test.expectSampled {
+			flags = "-01"
+		}
+		assert.Equal(t, "00-"+data.TraceID+"-"+data.SpanID+flags, Traceparent(ctx), test.description)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	tracer := NewTracer(newTestConfig(1), &mockExporter{})
+	defer tracer.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, span := tracer.StartRootSpan(ctx, "root", "")
+	ctx, trace := StartAuctionTrace(ctx)
+	cancel()
+
+	detached := Detach(ctx)
+	assert.NoError(t, detached.Err(), "Detached contexts shouldn't be cancelled with their parent")
+	assert.Equal(t, span, SpanFromContext(detached))
+	assert.Equal(t, trace, AuctionTraceFromContext(detached))
+	assert.Equal(t, context.Background(), Detach(context.Background()))
+}
+
+func TestHandler(t *testing.T) {
+	exporter := &mockExporter{}
+	tracer := NewTracer(newTestConfig(1), exporter)
+
+	var handledSpan *Span
+	handle := tracer.Handler("endpoint", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		handledSpan = SpanFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest("POST", "/openrtb2/auction", nil)
+	req.Header.Set(TraceparentHeader, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	handle(httptest.NewRecorder(), req, nil)
+	tracer.Shutdown()
+
+	if assert.NotNil(t, handledSpan, "The handler should run within the root span") {
+		spans := exporter.spans()
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, "endpoint", spans[0].Name)
+			assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", spans[0].TraceID)
+			assert.Equal(t, "b7ad6b7169203331", spans[0].ParentSpanID)
+			assert.Equal(t, "POST", spans[0].Attributes["http.method"])
+			assert.Equal(t, "/openrtb2/auction", spans[0].Attributes["http.target"])
+		}
+	}
+}
+
+func TestNilTracerHandler(t *testing.T) {
+	var tracer *Tracer
+	called := false
+	handle := tracer.Handler("endpoint", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		called = true
+		assert.Nil(t, SpanFromContext(r.Context()))
+	})
+	handle(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), nil)
+	assert.True(t, called)
+}
+
+func TestBatching(t *testing.T) {
+	exporter := &mockExporter{}
+	cfg := newTestConfig(1)
+	cfg.BatchSize = 2
+	tracer := NewTracer(cfg, exporter)
+
+	for i := 0; i < 3; i++ {
+		_, span := tracer.StartRootSpan(context.Background(), "root", "")
+		span.End()
+	}
+	tracer.Shutdown()
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	assert.Len(t, exporter.batches, 2, "Three spans should be exported as a full batch, and then the rest on Shutdown")
+}
+
+func newTestConfig(sampleRate float64) *config.Tracing {
+	return &config.Tracing{
+		Enabled:       true,
+		SampleRate:    sampleRate,
+		BatchSize:     100,
+		FlushInterval: 60000,
+	}
+}
+
+type mockExporter struct {
+	mutex   sync.Mutex
+	batches [][]SpanData
+}
+
+func (e *mockExporter) Export(spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.batches = append(e.batches, spans)
+	return nil
+}
+
+func (e *mockExporter) spans() []SpanData {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var spans []SpanData
+	for _, batch := range e.batches {
+		spans = append(spans, batch...)
+	}
+	return spans
+}
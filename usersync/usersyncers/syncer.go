@@ -33,6 +33,7 @@ import (
 	"github.com/prebid/prebid-server/adapters/eplanning"
 	"github.com/prebid/prebid-server/adapters/gamma"
 	"github.com/prebid/prebid-server/adapters/gamoshi"
+	"github.com/prebid/prebid-server/adapters/generic"
 	"github.com/prebid/prebid-server/adapters/grid"
 	"github.com/prebid/prebid-server/adapters/gumgum"
 	"github.com/prebid/prebid-server/adapters/improvedigital"
@@ -79,7 +80,28 @@ import (
 // The same keys should exist in this map as in the exchanges map.
 // Static syncer map will be removed when adapter isolation is complete.
 func NewSyncerMap(cfg *config.Configuration) map[openrtb_ext.BidderName]usersync.Usersyncer {
-	syncers := make(map[openrtb_ext.BidderName]usersync.Usersyncer, len(cfg.Adapters))
+	return newSyncerMaps(cfg).syncers
+}
+
+// GDPRVendorIDs returns the GDPR vendor ID of every bidder and host alias which has one.
+//
+// Unlike the syncers, the vendor IDs don't depend on the usersync_url. A bidder or an alias which can't be
+// synced still needs its vendor checked against the consent string before it gets any personal info.
+func GDPRVendorIDs(cfg *config.Configuration) map[openrtb_ext.BidderName]uint16 {
+	return newSyncerMaps(cfg).vendorIDs
+}
+
+// syncerMaps holds the syncers and the GDPR vendor IDs which newSyncerMaps builds together.
+type syncerMaps struct {
+	syncers   map[openrtb_ext.BidderName]usersync.Usersyncer
+	vendorIDs map[openrtb_ext.BidderName]uint16
+}
+
+func newSyncerMaps(cfg *config.Configuration) *syncerMaps {
+	syncers := &syncerMaps{
+		syncers:   make(map[openrtb_ext.BidderName]usersync.Usersyncer, len(cfg.Adapters)),
+		vendorIDs: make(map[openrtb_ext.BidderName]uint16, len(cfg.Adapters)),
+	}
 
 	insertIntoMap(cfg, syncers, openrtb_ext.Bidder33Across, ttx.New33AcrossSyncer)
 	insertIntoMap(cfg, syncers, openrtb_ext.BidderAdform, adform.NewAdformSyncer)
@@ -147,15 +169,81 @@ func NewSyncerMap(cfg *config.Configuration) map[openrtb_ext.BidderName]usersync
 	insertIntoMap(cfg, syncers, openrtb_ext.BidderYieldone, yieldone.NewYieldoneSyncer)
 	insertIntoMap(cfg, syncers, openrtb_ext.BidderZeroClickFraud, zeroclickfraud.NewZeroClickFraudSyncer)
 
+	for name, declaration := range generic.Declarations() {
+		if declaration.UserSync != nil {
+			name, userSync := name, *declaration.UserSync
+			insertIntoMap(cfg, syncers, name, func(temp *template.Template) usersync.Usersyncer {
+				return generic.NewGenericSyncer(name, userSync, temp)
+			})
+		}
+	}
+
+	// Aliases of bidders without a syncer still have the vendor ID from their config
+	for name, adapter := range cfg.Adapters {
+		if adapter.AliasOf != "" && adapter.GVLVendorID != 0 {
+			syncers.vendorIDs[openrtb_ext.BidderName(name)] = adapter.GVLVendorID
+		}
+	}
+
 	return syncers
 }
 
-func insertIntoMap(cfg *config.Configuration, syncers map[openrtb_ext.BidderName]usersync.Usersyncer, bidder openrtb_ext.BidderName, syncerFactory func(*template.Template) usersync.Usersyncer) {
+func insertIntoMap(cfg *config.Configuration, syncers *syncerMaps, bidder openrtb_ext.BidderName, syncerFactory func(*template.Template) usersync.Usersyncer) {
+	// The vendor ID doesn't depend on the URL, so a syncer without one still tells us what it is
+	coreVendorID := syncerFactory(template.Must(template.New(string(bidder) + "_vendor_id").Parse(""))).GDPRVendorID()
+	if coreVendorID != 0 {
+		syncers.vendorIDs[bidder] = coreVendorID
+	}
+	insertAliasesIntoMap(cfg, syncers, bidder, coreVendorID, syncerFactory)
+
 	lowercased := strings.ToLower(string(bidder))
 	urlString := cfg.Adapters[lowercased].UserSyncURL
 	if urlString == "" {
 		glog.Warningf("adapters." + string(bidder) + ".usersync_url was not defined, and their usersync API isn't flexible enough for Prebid Server to choose a good default. No usersyncs will be performed with " + string(bidder))
 		return
 	}
-	syncers[bidder] = syncerFactory(template.Must(template.New(lowercased + "_usersync_url").Parse(urlString)))
+	syncers.syncers[bidder] = syncerFactory(template.Must(template.New(lowercased + "_usersync_url").Parse(urlString)))
+}
+
+// insertAliasesIntoMap adds a syncer for each host alias of the bidder. It syncs like the core bidder,
+// but with the alias' own usersync_url, and stores the ID under the alias' name.
+// The alias gets its own vendor ID, or the core bidder's, whether or not it has a usersync_url.
+func insertAliasesIntoMap(cfg *config.Configuration, syncers *syncerMaps, bidder openrtb_ext.BidderName, coreVendorID uint16, syncerFactory func(*template.Template) usersync.Usersyncer) {
+	for name, adapter := range cfg.Adapters {
+		if adapter.AliasOf != string(bidder) {
+			continue
+		}
+		vendorID := adapter.GVLVendorID
+		if vendorID == 0 {
+			vendorID = coreVendorID
+		}
+		if vendorID != 0 {
+			syncers.vendorIDs[openrtb_ext.BidderName(name)] = vendorID
+		}
+		if adapter.UserSyncURL == "" {
+			glog.Warningf("adapters." + name + ".usersync_url was not defined. No usersyncs will be performed with the alias " + name)
+			continue
+		}
+		coreSyncer := syncerFactory(template.Must(template.New(name + "_usersync_url").Parse(adapter.UserSyncURL)))
+		syncers.syncers[openrtb_ext.BidderName(name)] = &aliasSyncer{
+			Usersyncer:   coreSyncer,
+			familyName:   name,
+			gdprVendorID: vendorID,
+		}
+	}
+}
+
+// aliasSyncer is the syncer of a host alias.
+type aliasSyncer struct {
+	usersync.Usersyncer
+	familyName   string
+	gdprVendorID uint16
+}
+
+func (s *aliasSyncer) FamilyName() string {
+	return s.familyName
+}
+
+func (s *aliasSyncer) GDPRVendorID() uint16 {
+	return s.gdprVendorID
 }
@@ -6,6 +6,8 @@ import (
 
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/privacy"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewSyncerMap(t *testing.T) {
@@ -131,9 +133,55 @@ func TestVendorIDUniqueness(t *testing.T) {
 	}
 }
 
+func TestHostAliasSyncers(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: map[string]config.Adapter{
+			"appnexus":        {UserSyncURL: "https://ib.adnxs.com/getuid"},
+			"appnexus_video":  {AliasOf: "appnexus", UserSyncURL: "https://sync.appnexus-video.com"},
+			"appnexus_eu":     {AliasOf: "appnexus", UserSyncURL: "https://sync.appnexus-eu.com", GVLVendorID: 99},
+			"appnexus_nosync": {AliasOf: "appnexus"},
+		},
+	}
+	syncers := NewSyncerMap(cfg)
+
+	video, ok := syncers["appnexus_video"]
+	if !assert.True(t, ok, "A host alias should have a syncer of its own") {
+		return
+	}
+	assert.Equal(t, "appnexus_video", video.FamilyName())
+	assert.Equal(t, syncers[openrtb_ext.BidderAppnexus].GDPRVendorID(), video.GDPRVendorID(), "An alias should default to the vendor ID of its core bidder")
+	info, err := video.GetUsersyncInfo(privacy.Policies{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://sync.appnexus-video.com", info.URL)
+		assert.Equal(t, "redirect", info.Type)
+	}
+
+	assert.Equal(t, uint16(99), syncers["appnexus_eu"].GDPRVendorID())
+	assert.NotContains(t, syncers, openrtb_ext.BidderName("appnexus_nosync"))
+}
+
 func assertStringsMatch(t *testing.T, expected string, actual string) {
 	t.Helper()
 	if expected != actual {
 		t.Errorf("Expected %s, got %s", expected, actual)
 	}
 }
+
+func TestGDPRVendorIDs(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: map[string]config.Adapter{
+			"appnexus_nosync": {AliasOf: "appnexus"},
+			"appnexus_eu":     {AliasOf: "appnexus", GVLVendorID: 99},
+			"kidoz_eu":        {AliasOf: "kidoz", GVLVendorID: 77},
+			"kidoz_nosync":    {AliasOf: "kidoz"},
+		},
+	}
+	vendorIDs := GDPRVendorIDs(cfg)
+	appnexusVendorID := vendorIDs[openrtb_ext.BidderAppnexus]
+
+	assert.NotZero(t, appnexusVendorID, "A bidder without a usersync_url should still have its vendor ID")
+	assert.Equal(t, appnexusVendorID, vendorIDs["appnexus_nosync"], "An alias without a usersync_url should default to the vendor ID of its core bidder")
+	assert.Equal(t, uint16(99), vendorIDs["appnexus_eu"])
+	assert.Equal(t, uint16(77), vendorIDs["kidoz_eu"], "An alias of a bidder without a syncer should have its configured vendor ID")
+	assert.NotContains(t, vendorIDs, openrtb_ext.BidderName("kidoz_nosync"))
+}